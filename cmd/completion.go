@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/BHChen24/repo2context/pkg/formatter"
+	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
+
+	"github.com/spf13/cobra"
+)
+
+// registerCompletions adds shell completion hints for flags with a fixed set
+// of values or a path argument. Called once the flags are defined.
+func registerCompletions() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = false
+
+	completions := map[string][]string{
+		"format":          formatter.SupportedFormats,
+		"encoding":        tokencounter.SupportedEncodings,
+		"model":           formatter.ModelNames(),
+		"format-override": formatter.SupportedModelFormats,
+		"output-encoding": formatter.SupportedOutputEncodings,
+		"output-mode":     {formatter.OutputModeOverwrite, formatter.OutputModeAppend, formatter.OutputModeVersion},
+	}
+	for name, values := range completions {
+		//nolint:errcheck
+		rootCmd.RegisterFlagCompletionFunc(name, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+	}
+
+	//nolint:errcheck
+	rootCmd.MarkFlagFilename("output")
+	//nolint:errcheck
+	rootCmd.MarkFlagDirname("output-dir")
+	//nolint:errcheck
+	rootCmd.MarkFlagFilename("save-scan-result", "gz")
+	//nolint:errcheck
+	rootCmd.MarkFlagFilename("load-scan-result", "gz")
+	//nolint:errcheck
+	rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml", "json", "toml")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/BHChen24/repo2context/pkg/flagConfig"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configCheckCmd validates the configuration found by initConfig without scanning
+var configCheckCmd = &cobra.Command{
+	Use:   "config-check",
+	Short: "Validate the configuration file",
+	Long: `Loads the configuration file (--config, .r2c-config.toml in the current
+directory or $HOME/.repo2context.yaml), warns about keys that match no option
+and reports every invalid value. Exits with status 1 on any error.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkConfig(cmd.OutOrStdout(), viper.ConfigFileUsed(), flagCfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCheckCmd)
+}
+
+// checkConfig reports the unknown keys of configFile and the validation
+// errors of cfg, the options loaded from it, to out
+func checkConfig(out io.Writer, configFile string, cfg flagConfig.FlagConfig) error {
+	if configFile == "" {
+		fmt.Fprintln(out, "No config file found, checking flag and environment values only")
+	} else {
+		unknown, err := unknownConfigKeys(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		for _, key := range unknown {
+			fmt.Fprintf(out, "Warning: unknown key '%s' in %s\n", key, configFile)
+		}
+	}
+
+	errs := cfg.ValidateAll()
+	for _, err := range errs {
+		var fieldErr *flagConfig.FieldError
+		if errors.As(err, &fieldErr) {
+			fmt.Fprintf(out, "Error: %v for key '%s'\n", fieldErr.Err, fieldErr.Key)
+		} else {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d configuration error(s)", len(errs))
+	}
+
+	fmt.Fprintln(out, "Configuration is valid")
+	return nil
+}
+
+// unknownConfigKeys returns the keys of a config file that match no
+// FlagConfig field, in name order
+// Keys of nested tables are dotted, e.g. "output.dir"
+func unknownConfigKeys(configFile string) ([]string, error) {
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(configFile)
+	if err := fileConfig.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	known := configKeys()
+	var unknown []string
+	for _, key := range fileConfig.AllKeys() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
@@ -22,19 +22,45 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/BHChen24/repo2context/pkg/core"
+	"github.com/BHChen24/repo2context/pkg/envloader"
 	"github.com/BHChen24/repo2context/pkg/flagConfig"
+	"github.com/BHChen24/repo2context/pkg/formatter"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 var flagCfg flagConfig.FlagConfig
 
+// overrideAnnotation marks flags listed under "Override Flags" in the help text
+const overrideAnnotation = "r2c_override"
+
+// overrideFlags re-include paths that .gitignore commonly excludes
+var overrideFlags = []string{"vendor", "include-node-modules", "include-generated"}
+
+// skipErrors backs --skip-errors, which only documents the default behavior
+var skipErrors bool
+
+// absolutePaths and relativePaths are shorthands for --path-style
+var absolutePaths, relativePaths bool
+
+// debugGitignore backs --debug-gitignore, which explains ignore rules for a path instead of scanning
+var debugGitignore string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "r2c [flags] path1 path2 ...",
@@ -48,9 +74,51 @@ Features:
 - Respects .gitignore files by default
 - Supports file filtering and exclusion`,
 	Version: "v0.2.2",
-	Args:    cobra.MinimumNArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		// --debug-gitignore takes its path as the flag value
+		if debugGitignore != "" {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := core.Run(args, flagCfg); err != nil {
+		if debugGitignore != "" {
+			explanation, err := scanner.ExplainIgnore(debugGitignore, scanner.ScanOptions{
+				NoGitignore:        flagCfg.NoGitignore || flagCfg.IncludeGitignored,
+				NoR2cignore:        flagCfg.NoR2cignore || flagCfg.IncludeGitignored,
+				IncludeVendor:      flagCfg.IncludeVendor || flagCfg.IncludeGitignored,
+				IncludeNodeModules: flagCfg.IncludeNodeModules || flagCfg.IncludeGitignored,
+				IncludeGenerated:   flagCfg.IncludeGenerated || flagCfg.IncludeGitignored,
+			})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Print(explanation)
+			return
+		}
+
+		if absolutePaths {
+			flagCfg.PathStyle = scanner.PathStyleAbsolute
+		}
+		if relativePaths {
+			flagCfg.PathStyle = scanner.PathStyleRelative
+		}
+
+		// Ctrl+C cancels the scan cleanly
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		err := core.RunWithStdin(ctx, args, flagCfg, cmd.InOrStdin())
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "cancelled")
+			os.Exit(130)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -71,26 +139,332 @@ func init() {
 
 	// Persistent config file flag
 	rootCmd.PersistentFlags().StringVar(&flagCfg.ConfigFile, "config", "", "config file (default is $HOME/.repo2context.yaml)")
+	rootCmd.PersistentFlags().StringVar(&flagCfg.EnvFile, "env-file", "", "load R2C_* settings from a .env-style file (lowest priority after defaults)")
 
 	// Other CLI flags
-	rootCmd.Flags().BoolVar(&flagCfg.NoGitignore, "no-gitignore", false, "disable automatic .gitignore filtering")
+	rootCmd.Flags().BoolVar(&flagCfg.NoGitignore, "no-gitignore", false, "disable automatic .gitignore filtering (see --include-gitignored to also disable .r2cignore)")
+	rootCmd.Flags().BoolVar(&flagCfg.IncludeGitignored, "include-gitignored", false, "include everything: disable .gitignore, .git/info/exclude, .r2cignore and lock/generated file skipping")
+	rootCmd.Flags().BoolVar(&flagCfg.NoR2cignore, "no-r2cignore", false, "disable automatic .r2cignore filtering")
+	rootCmd.Flags().StringVar(&debugGitignore, "debug-gitignore", "", "explain which .gitignore/.r2cignore pattern excludes a path, without scanning")
 	rootCmd.Flags().StringVarP(&flagCfg.OutputFile, "output", "o", "", "save output to file instead of stdout")
+	rootCmd.Flags().BoolVar(&flagCfg.SplitOutput, "split-output", false, "write each file's context to its own file in --output-dir, with an _index.md manifest")
+	rootCmd.Flags().StringVar(&flagCfg.OutputDir, "output-dir", "", "directory for --split-output files")
+	rootCmd.Flags().StringVar(&flagCfg.OutputEncoding, "output-encoding", formatter.OutputEncodingUTF8, "encoding of output files ("+strings.Join(formatter.SupportedOutputEncodings, ", ")+")")
+	rootCmd.MarkFlagsMutuallyExclusive("output", "split-output")
+	rootCmd.Flags().BoolVar(&flagCfg.Clipboard, "clipboard", false, "copy output to the system clipboard (also writes --output if set)")
 	rootCmd.Flags().BoolVarP(&flagCfg.DisplayLineNum, "line-numbers", "l", false, "display line numbers in file contents")
+	rootCmd.Flags().StringVar(&flagCfg.LineNumberStyle, "line-number-style", "tab", "line number format with --line-numbers (tab, space, bracket, padded)")
+	rootCmd.Flags().StringVar(&flagCfg.LineEnding, "line-ending", "", "normalize line endings in file content (lf, crlf)")
+	rootCmd.Flags().StringVar(&flagCfg.PathStyle, "path-style", scanner.PathStyleRelative, "how file paths are shown (relative to the scan root, absolute, cwd)")
+	rootCmd.Flags().BoolVar(&absolutePaths, "absolute-paths", false, "show absolute file paths (same as --path-style absolute)")
+	rootCmd.Flags().BoolVar(&relativePaths, "relative-paths", false, "show file paths relative to the scan root (same as --path-style relative)")
+	rootCmd.MarkFlagsMutuallyExclusive("path-style", "absolute-paths", "relative-paths")
+	rootCmd.Flags().BoolVar(&flagCfg.SanitizePaths, "sanitize-paths", false, "hide the filesystem location: show the scan root by name and file paths relative to it")
+	rootCmd.Flags().StringVar(&flagCfg.TreeStyle, "tree-style", formatter.TreeStyleIndent, "directory tree style in the Structure section (indent, ascii, unicode)")
 	rootCmd.Flags().BoolVarP(&flagCfg.Verbose, "verbose", "", false, "display verbose output")
-	rootCmd.Flags().BoolVarP(&flagCfg.CountTokens, "count-tokens", "t", false, "count tokens in file contents")
+	rootCmd.Flags().BoolVar(&flagCfg.VerboseJSON, "verbose-json", false, "display verbose output as JSON lines (implies --verbose)")
+	rootCmd.Flags().BoolVarP(&flagCfg.CountTokens, "count-tokens", "t", false, "count tokens for each file using tiktoken")
+	rootCmd.Flags().IntVar(&flagCfg.TokenCountWorkers, "token-count-workers", 0, "goroutines counting tokens in parallel (0 means one per CPU)")
+	rootCmd.Flags().BoolVar(&flagCfg.UseEstimatedTokens, "estimate-tokens", false, "approximate token counts at 4 bytes per token instead of encoding (implies --count-tokens)")
+	rootCmd.Flags().StringSliceVar(&flagCfg.IncludeLanguages, "include-language", nil, "only include files of these languages (e.g. go,python; alias --only-language)")
+	rootCmd.Flags().StringSliceVar(&flagCfg.ExcludeLanguages, "exclude-language", nil, "exclude files of these languages (e.g. text)")
+	rootCmd.Flags().StringArrayVar(&flagCfg.ExcludePatterns, "exclude", nil, "exclude files matching a glob pattern; a leading ! re-includes (repeatable, applied in order)")
+	rootCmd.Flags().StringArrayVar(&flagCfg.ExcludePaths, "exclude-path", nil, "exclude a path relative to the scan root and everything below it, matched exactly (repeatable)")
+	rootCmd.Flags().StringVar(&flagCfg.RepoName, "repo-name", "", "repository name in the output title (default: the scanned directory's name)")
+	rootCmd.Flags().StringVar(&flagCfg.RepoDescription, "repo-description", "", "short tagline shown as a blockquote below the title")
+	rootCmd.Flags().StringVar(&flagCfg.OutputMode, "output-mode", "overwrite", "how --output treats an existing file (overwrite, append, version)")
+	rootCmd.Flags().StringVar(&flagCfg.OutputVersionFormat, "output-version-format", formatter.DefaultVersionFormat, "Go time layout of the timestamp added to the file name with --output-mode version")
+	rootCmd.Flags().BoolVar(&flagCfg.IncludeGitConfig, "include-git-config", false, "add the configured git user.name and user.email to the git info")
+	rootCmd.Flags().BoolVar(&flagCfg.CollapsibleFiles, "collapsible-files", false, "wrap each file in a collapsible <details> section (GitHub markdown)")
+	rootCmd.Flags().StringArrayVar(&flagCfg.NoCollapse, "no-collapse", nil, "keep files matching a glob pattern expanded with --collapsible-files (repeatable)")
+	rootCmd.Flags().StringArrayVar(&flagCfg.IncludePatterns, "include", nil, "keep files matching a glob pattern even if --exclude-test-files or another exclusion flag would drop them (repeatable)")
+	rootCmd.Flags().BoolVar(&flagCfg.ExcludeTestFiles, "exclude-test-files", false, "exclude test files (*_test.go, *.test.ts, *.spec.js, test_*.py, files in test/, tests/, spec/, __tests__/)")
+	rootCmd.Flags().StringSliceVar(&flagCfg.AddLanguages, "add-language", nil, "map a file extension to a language label (e.g. tpl=html)")
+	rootCmd.Flags().StringVar(&flagCfg.Encoding, "encoding", tokencounter.DefaultEncoding, "tiktoken encoding used with --count-tokens ("+strings.Join(tokencounter.SupportedEncodings, ", ")+")")
+	rootCmd.Flags().BoolVar(&flagCfg.SkipLockFiles, "skip-lock-files", false, "exclude dependency lock files (package-lock.json, go.sum, *.lock, ...)")
+	rootCmd.Flags().BoolVar(&flagCfg.SkipGenerated, "skip-generated", false, "exclude generated files (*.pb.go, *_gen.go, mock_*.go, or a \"// Code generated\" header)")
+	rootCmd.Flags().BoolVar(&flagCfg.IncludeLockFiles, "include-lock-files", false, "include lock files even if skip_lock_files is set in the config file")
+	rootCmd.Flags().StringVar(&flagCfg.CommitHash, "commit-hash", "", "scan files as they were at this git commit instead of the working tree")
+	rootCmd.Flags().BoolVar(&flagCfg.PruneEmptyDirs, "prune-empty-dirs", true, "leave directories with no included files out of the tree (--prune-empty-dirs=false keeps them)")
+	rootCmd.Flags().BoolVar(&flagCfg.NoContent, "no-content", false, "output structure and metadata only, without file contents")
+	rootCmd.Flags().BoolVar(&flagCfg.NoSummary, "no-summary", false, "omit the Summary section")
+	rootCmd.Flags().BoolVar(&flagCfg.NoGitInfo, "no-git-info", false, "omit the Git Info section")
+	rootCmd.Flags().BoolVar(&flagCfg.NoStructure, "no-structure", false, "omit the Structure section")
+	rootCmd.Flags().BoolVar(&flagCfg.OnlyErrors, "only-errors", false, "output only the scan errors and the summary (e.g. to audit unreadable files)")
+	rootCmd.Flags().BoolVar(&flagCfg.SummaryOnly, "summary-only", false, "output only the git info and summary, without reading file contents unless counting tokens")
+	rootCmd.Flags().BoolVar(&flagCfg.Merge, "merge", false, "combine all paths into a single document instead of one per path")
+	rootCmd.Flags().BoolVar(&flagCfg.ShowGitLog, "git-log", false, "include recent commits for each file")
+	rootCmd.Flags().IntVar(&flagCfg.GitLogMaxCommits, "git-log-commits", 5, "number of recent commits shown per file with --git-log")
+	rootCmd.Flags().BoolVar(&flagCfg.ShowContributors, "contributors", false, "include the git authors of each file")
+	rootCmd.Flags().BoolVar(&flagCfg.ShowBlame, "blame", false, "prefix each line of file contents with its git author and commit (runs git blame once per file, slow on large repositories)")
+	rootCmd.Flags().BoolVar(&flagCfg.BlameShort, "blame-short", false, "like --blame, with author initials and short commit hashes")
+	rootCmd.Flags().BoolVar(&flagCfg.ShowGitStatus, "show-git-status", false, "mark changed files with their git status ([M], [A], [?]) in the tree and file headers")
+	rootCmd.Flags().IntVar(&flagCfg.MaxContributors, "max-contributors", 5, "maximum number of authors shown per file with --contributors")
+	rootCmd.Flags().BoolVar(&flagCfg.GroupByExtension, "group-by-extension", false, "group file sections by language instead of walk order")
+	rootCmd.Flags().StringVar(&flagCfg.OutputFormat, "format", formatter.MarkdownFormat, "output format ("+strings.Join(formatter.SupportedFormats, ", ")+")")
+	rootCmd.Flags().StringVar(&flagCfg.Model, "model", "", "target model (e.g. claude-sonnet-4, gpt-4o, gemini-2.5-pro); selects the prompt format its family prefers")
+	rootCmd.Flags().StringVar(&flagCfg.FormatOverride, "format-override", "", "force a prompt format regardless of --model ("+strings.Join(formatter.SupportedModelFormats, ", ")+")")
+	rootCmd.Flags().StringVar(&flagCfg.Prefix, "prefix", "", "text written before the output (supports \\n escapes)")
+	rootCmd.Flags().StringVar(&flagCfg.Suffix, "suffix", "", "text written after the output (supports \\n escapes)")
+	rootCmd.Flags().BoolVar(&flagCfg.FailOnErrors, "fail-on-errors", false, "exit with a non-zero code if any scan errors occur")
+	rootCmd.Flags().IntVar(&flagCfg.MaxPaths, "max-paths", 0, "maximum number of paths accepted in one run (0 means the default of 10, -1 means unlimited)")
+	rootCmd.Flags().IntVar(&flagCfg.MaxErrors, "max-errors", 0, "abort scanning after more than N errors (0 means unlimited)")
+	rootCmd.Flags().BoolVar(&skipErrors, "skip-errors", true, "skip unreadable files and keep scanning (default)")
+	rootCmd.Flags().BoolVar(&flagCfg.AbortOnError, "abort-on-error", false, "stop scanning at the first unreadable file")
+	rootCmd.MarkFlagsMutuallyExclusive("skip-errors", "abort-on-error")
+	rootCmd.Flags().IntVar(&flagCfg.Retry, "retry", 0, "read a file up to N more times after a transient error such as EIO on a network filesystem (0 disables retries)")
+	rootCmd.Flags().DurationVar(&flagCfg.RetryDelay, "retry-delay", 100*time.Millisecond, "wait between read attempts with --retry")
+	rootCmd.Flags().DurationVar(&flagCfg.Timeout, "timeout", 0, "abort if processing takes longer than this duration (e.g. 30s, 5m; 0 means no timeout)")
+	rootCmd.Flags().BoolVar(&flagCfg.StdinContent, "stdin-content", false, "include piped stdin as a virtual file ahead of the scanned files")
+	rootCmd.Flags().StringVar(&flagCfg.StdinLabel, "stdin-label", "(stdin)", "display name of the stdin content with --stdin-content")
+	rootCmd.Flags().StringVar(&flagCfg.GitHubToken, "github-token", "", "token for cloning private GitHub repositories (default $GITHUB_TOKEN)")
+	rootCmd.Flags().BoolVar(&flagCfg.NoCloneSubmodules, "no-clone-submodules", false, "skip submodules when cloning a GitHub repository")
+	rootCmd.Flags().StringVar(&flagCfg.CloneCacheDir, "clone-cache-dir", "", "keep GitHub clones in this directory and reuse them")
+	rootCmd.Flags().BoolVar(&flagCfg.GitHubActions, "github-actions", false, "set step outputs (r2c_token_count, r2c_file_count, r2c_output_file, r2c_output) and a step summary when run in GitHub Actions")
+	rootCmd.Flags().StringVar(&flagCfg.SaveScanResult, "save-scan-result", "", "save the directory scan to this gzipped JSON file (e.g. cache.json.gz)")
+	rootCmd.Flags().StringVar(&flagCfg.LoadScanResult, "load-scan-result", "", "use the directory scan saved in this file instead of scanning, if it is fresh")
+	rootCmd.Flags().DurationVar(&flagCfg.ScanResultTTL, "scan-result-ttl", 0, "treat a --load-scan-result file older than this as missing (e.g. 1h; 0 means it never expires)")
+	rootCmd.Flags().IntVar(&flagCfg.MaxTokensPerFile, "max-tokens-per-file", 0, "truncate files above N tokens at a line boundary (implies --count-tokens; 0 means no limit)")
+	rootCmd.Flags().Var((*byteSizeValue)(&flagCfg.MinFileSizeBytes), "min-file-size", "skip files smaller than this size, e.g. 10B for stubs and empty __init__.py files (0 means no minimum)")
+	rootCmd.Flags().BoolVar(&flagCfg.FollowSymlinks, "follow-symlinks", false, "descend into symlinked directories, stopping at circular symlinks with a warning")
+	rootCmd.Flags().BoolVar(&flagCfg.RespectEditorConfig, "respect-editorconfig", false, "read files in the charset .editorconfig declares (utf-16le, utf-16be, latin1) and convert them to UTF-8")
+	rootCmd.Flags().BoolVar(&flagCfg.StripComments, "strip-comments", false, "remove comments from Go, Python and JavaScript/TypeScript files")
+	rootCmd.Flags().BoolVar(&flagCfg.PreserveDocComments, "preserve-doc-comments", false, "keep doc comments and docstrings with --strip-comments")
+	rootCmd.Flags().BoolVar(&flagCfg.TokenDensity, "token-density", false, "show each file's token count and tokens per line in its header (implies --count-tokens)")
+	rootCmd.Flags().BoolVar(&flagCfg.TreeShowDensity, "tree-show-density", false, "show each file's tokens per line in the directory tree (implies --count-tokens)")
+	rootCmd.Flags().IntVar(&flagCfg.ContextWindow, "context-window", 0, "context window in tokens to report usage against, overriding the --model default; without --model it is the split budget like --token-limit (implies --count-tokens)")
+	rootCmd.Flags().IntVar(&flagCfg.TokenLimit, "token-limit", 0, "split output into parts of at most N tokens (implies --count-tokens; 0 means no limit)")
+	rootCmd.Flags().StringVar(&flagCfg.Checksum, "checksum", "", "include a hash of each file in its header (md5, sha256)")
+	rootCmd.Flags().StringVar(&flagCfg.TemplatePath, "template", "", "format output with a custom Go text/template file")
+	rootCmd.Flags().StringVar(&flagCfg.FileHeaderTemplate, "file-header-template", "", "Go text/template for each file header (fields: .Path .Size .ModTime .TokenCount .Language .Lines)")
+
+	// Override flags re-include paths that .gitignore commonly excludes
+	rootCmd.Flags().BoolVar(&flagCfg.IncludeVendor, "vendor", false, "include vendor/ directories even if gitignored")
+	rootCmd.Flags().BoolVar(&flagCfg.IncludeNodeModules, "include-node-modules", false, "include node_modules/ directories even if gitignored")
+	rootCmd.Flags().BoolVar(&flagCfg.IncludeGenerated, "include-generated", false, "include generated files (*.pb.go, *_gen.go, ...) even if gitignored")
+	rootCmd.MarkFlagsMutuallyExclusive("include-generated", "skip-generated")
+	rootCmd.MarkFlagsMutuallyExclusive("only-errors", "split-output")
+	rootCmd.MarkFlagsMutuallyExclusive("only-errors", "token-limit")
+	rootCmd.MarkFlagsMutuallyExclusive("summary-only", "split-output")
+	rootCmd.MarkFlagsMutuallyExclusive("summary-only", "token-limit")
+	for _, name := range overrideFlags {
+		//nolint:errcheck
+		rootCmd.Flags().SetAnnotation(name, overrideAnnotation, []string{"true"})
+	}
+
+	// --only-language reads better next to --exclude-language
+	rootCmd.SetGlobalNormalizationFunc(func(fs *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "only-language" {
+			name = "include-language"
+		}
+		return pflag.NormalizedName(name)
+	})
+
+	// List override flags in their own help section
+	cobra.AddTemplateFunc("generalFlagUsages", func(fs *pflag.FlagSet) string { return flagUsages(fs, false) })
+	cobra.AddTemplateFunc("overrideFlagUsages", func(fs *pflag.FlagSet) string { return flagUsages(fs, true) })
+	rootCmd.SetUsageTemplate(strings.Replace(rootCmd.UsageTemplate(),
+		"{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}",
+		"{{generalFlagUsages .LocalFlags | trimTrailingWhitespaces}}\n\nOverride Flags:\n{{overrideFlagUsages .LocalFlags | trimTrailingWhitespaces}}", 1))
 
 	// Bind flags to Viper
 	// nolint: errcheck
 	//nolint:errcheck
 	viper.BindPFlag("no_gitignore", rootCmd.Flags().Lookup("no-gitignore"))
 	//nolint:errcheck
+	viper.BindPFlag("no_r2cignore", rootCmd.Flags().Lookup("no-r2cignore"))
+	//nolint:errcheck
 	viper.BindPFlag("output", rootCmd.Flags().Lookup("output"))
 	//nolint:errcheck
+	viper.BindPFlag("split_output", rootCmd.Flags().Lookup("split-output"))
+	//nolint:errcheck
+	viper.BindPFlag("output_dir", rootCmd.Flags().Lookup("output-dir"))
+	//nolint:errcheck
+	viper.BindPFlag("clipboard", rootCmd.Flags().Lookup("clipboard"))
+	//nolint:errcheck
 	viper.BindPFlag("display_line_num", rootCmd.Flags().Lookup("line-numbers"))
 	//nolint:errcheck
+	viper.BindPFlag("line_number_style", rootCmd.Flags().Lookup("line-number-style"))
+	//nolint:errcheck
+	viper.BindPFlag("line_ending", rootCmd.Flags().Lookup("line-ending"))
+	//nolint:errcheck
+	viper.BindPFlag("path_style", rootCmd.Flags().Lookup("path-style"))
+	//nolint:errcheck
+	viper.BindPFlag("sanitize_paths", rootCmd.Flags().Lookup("sanitize-paths"))
+	//nolint:errcheck
+	viper.BindPFlag("tree_style", rootCmd.Flags().Lookup("tree-style"))
+	//nolint:errcheck
 	viper.BindPFlag("verbose", rootCmd.Flags().Lookup("verbose"))
 	//nolint:errcheck
+	viper.BindPFlag("verbose_json", rootCmd.Flags().Lookup("verbose-json"))
+	//nolint:errcheck
 	viper.BindPFlag("count_tokens", rootCmd.Flags().Lookup("count-tokens"))
+	//nolint:errcheck
+	viper.BindPFlag("token_count_workers", rootCmd.Flags().Lookup("token-count-workers"))
+	//nolint:errcheck
+	viper.BindPFlag("estimate_tokens", rootCmd.Flags().Lookup("estimate-tokens"))
+	//nolint:errcheck
+	viper.BindPFlag("include_language", rootCmd.Flags().Lookup("include-language"))
+	//nolint:errcheck
+	viper.BindPFlag("exclude_language", rootCmd.Flags().Lookup("exclude-language"))
+	//nolint:errcheck
+	viper.BindPFlag("exclude", rootCmd.Flags().Lookup("exclude"))
+	//nolint:errcheck
+	viper.BindPFlag("include", rootCmd.Flags().Lookup("include"))
+	//nolint:errcheck
+	viper.BindPFlag("exclude_test_files", rootCmd.Flags().Lookup("exclude-test-files"))
+	//nolint:errcheck
+	viper.BindPFlag("add_language", rootCmd.Flags().Lookup("add-language"))
+	//nolint:errcheck
+	viper.BindPFlag("template", rootCmd.Flags().Lookup("template"))
+	//nolint:errcheck
+	viper.BindPFlag("file_header_template", rootCmd.Flags().Lookup("file-header-template"))
+	//nolint:errcheck
+	viper.BindPFlag("encoding", rootCmd.Flags().Lookup("encoding"))
+	//nolint:errcheck
+	viper.BindPFlag("skip_lock_files", rootCmd.Flags().Lookup("skip-lock-files"))
+	//nolint:errcheck
+	viper.BindPFlag("include_lock_files", rootCmd.Flags().Lookup("include-lock-files"))
+	//nolint:errcheck
+	viper.BindPFlag("commit_hash", rootCmd.Flags().Lookup("commit-hash"))
+	//nolint:errcheck
+	viper.BindPFlag("no_content", rootCmd.Flags().Lookup("no-content"))
+	//nolint:errcheck
+	viper.BindPFlag("git_log", rootCmd.Flags().Lookup("git-log"))
+	//nolint:errcheck
+	viper.BindPFlag("git_log_commits", rootCmd.Flags().Lookup("git-log-commits"))
+	//nolint:errcheck
+	viper.BindPFlag("group_by_extension", rootCmd.Flags().Lookup("group-by-extension"))
+	//nolint:errcheck
+	viper.BindPFlag("contributors", rootCmd.Flags().Lookup("contributors"))
+	//nolint:errcheck
+	viper.BindPFlag("blame", rootCmd.Flags().Lookup("blame"))
+	//nolint:errcheck
+	viper.BindPFlag("blame_short", rootCmd.Flags().Lookup("blame-short"))
+	//nolint:errcheck
+	viper.BindPFlag("max_contributors", rootCmd.Flags().Lookup("max-contributors"))
+	//nolint:errcheck
+	viper.BindPFlag("format", rootCmd.Flags().Lookup("format"))
+	//nolint:errcheck
+	viper.BindPFlag("model", rootCmd.Flags().Lookup("model"))
+	//nolint:errcheck
+	viper.BindPFlag("format_override", rootCmd.Flags().Lookup("format-override"))
+	//nolint:errcheck
+	viper.BindPFlag("prefix", rootCmd.Flags().Lookup("prefix"))
+	//nolint:errcheck
+	viper.BindPFlag("suffix", rootCmd.Flags().Lookup("suffix"))
+	//nolint:errcheck
+	viper.BindPFlag("fail_on_errors", rootCmd.Flags().Lookup("fail-on-errors"))
+	//nolint:errcheck
+	viper.BindPFlag("max_errors", rootCmd.Flags().Lookup("max-errors"))
+	//nolint:errcheck
+	viper.BindPFlag("abort_on_error", rootCmd.Flags().Lookup("abort-on-error"))
+	//nolint:errcheck
+	viper.BindPFlag("timeout", rootCmd.Flags().Lookup("timeout"))
+	//nolint:errcheck
+	viper.BindPFlag("checksum", rootCmd.Flags().Lookup("checksum"))
+	//nolint:errcheck
+	viper.BindPFlag("token_limit", rootCmd.Flags().Lookup("token-limit"))
+	//nolint:errcheck
+	viper.BindPFlag("github_token", rootCmd.Flags().Lookup("github-token"))
+	//nolint:errcheck
+	viper.BindPFlag("no_clone_submodules", rootCmd.Flags().Lookup("no-clone-submodules"))
+	//nolint:errcheck
+	viper.BindPFlag("clone_cache_dir", rootCmd.Flags().Lookup("clone-cache-dir"))
+	//nolint:errcheck
+	viper.BindPFlag("stdin_content", rootCmd.Flags().Lookup("stdin-content"))
+	//nolint:errcheck
+	viper.BindPFlag("stdin_label", rootCmd.Flags().Lookup("stdin-label"))
+	//nolint:errcheck
+	viper.BindPFlag("include_vendor", rootCmd.Flags().Lookup("vendor"))
+	//nolint:errcheck
+	viper.BindPFlag("include_node_modules", rootCmd.Flags().Lookup("include-node-modules"))
+	//nolint:errcheck
+	viper.BindPFlag("include_generated", rootCmd.Flags().Lookup("include-generated"))
+	//nolint:errcheck
+	viper.BindPFlag("skip_generated", rootCmd.Flags().Lookup("skip-generated"))
+	//nolint:errcheck
+	viper.BindPFlag("no_summary", rootCmd.Flags().Lookup("no-summary"))
+	//nolint:errcheck
+	viper.BindPFlag("no_git_info", rootCmd.Flags().Lookup("no-git-info"))
+	//nolint:errcheck
+	viper.BindPFlag("no_structure", rootCmd.Flags().Lookup("no-structure"))
+	//nolint:errcheck
+	viper.BindPFlag("show_git_status", rootCmd.Flags().Lookup("show-git-status"))
+	//nolint:errcheck
+	viper.BindPFlag("only_errors", rootCmd.Flags().Lookup("only-errors"))
+	//nolint:errcheck
+	viper.BindPFlag("merge", rootCmd.Flags().Lookup("merge"))
+	//nolint:errcheck
+	viper.BindPFlag("output_encoding", rootCmd.Flags().Lookup("output-encoding"))
+	//nolint:errcheck
+	viper.BindPFlag("prune_empty_dirs", rootCmd.Flags().Lookup("prune-empty-dirs"))
+	//nolint:errcheck
+	viper.BindPFlag("max_paths", rootCmd.Flags().Lookup("max-paths"))
+	//nolint:errcheck
+	viper.BindPFlag("exclude_path", rootCmd.Flags().Lookup("exclude-path"))
+	//nolint:errcheck
+	viper.BindPFlag("max_tokens_per_file", rootCmd.Flags().Lookup("max-tokens-per-file"))
+	//nolint:errcheck
+	viper.BindPFlag("strip_comments", rootCmd.Flags().Lookup("strip-comments"))
+	//nolint:errcheck
+	viper.BindPFlag("preserve_doc_comments", rootCmd.Flags().Lookup("preserve-doc-comments"))
+	//nolint:errcheck
+	viper.BindPFlag("include_gitignored", rootCmd.Flags().Lookup("include-gitignored"))
+	//nolint:errcheck
+	viper.BindPFlag("collapsible_files", rootCmd.Flags().Lookup("collapsible-files"))
+	//nolint:errcheck
+	viper.BindPFlag("no_collapse", rootCmd.Flags().Lookup("no-collapse"))
+	//nolint:errcheck
+	viper.BindPFlag("include_git_config", rootCmd.Flags().Lookup("include-git-config"))
+	//nolint:errcheck
+	viper.BindPFlag("output_mode", rootCmd.Flags().Lookup("output-mode"))
+	//nolint:errcheck
+	viper.BindPFlag("output_version_format", rootCmd.Flags().Lookup("output-version-format"))
+	//nolint:errcheck
+	viper.BindPFlag("respect_editorconfig", rootCmd.Flags().Lookup("respect-editorconfig"))
+	//nolint:errcheck
+	viper.BindPFlag("context_window", rootCmd.Flags().Lookup("context-window"))
+	//nolint:errcheck
+	viper.BindPFlag("repo_name", rootCmd.Flags().Lookup("repo-name"))
+	//nolint:errcheck
+	viper.BindPFlag("repo_description", rootCmd.Flags().Lookup("repo-description"))
+	//nolint:errcheck
+	viper.BindPFlag("summary_only", rootCmd.Flags().Lookup("summary-only"))
+	//nolint:errcheck
+	viper.BindPFlag("token_density", rootCmd.Flags().Lookup("token-density"))
+	//nolint:errcheck
+	viper.BindPFlag("tree_show_density", rootCmd.Flags().Lookup("tree-show-density"))
+	//nolint:errcheck
+	viper.BindPFlag("save_scan_result", rootCmd.Flags().Lookup("save-scan-result"))
+	//nolint:errcheck
+	viper.BindPFlag("load_scan_result", rootCmd.Flags().Lookup("load-scan-result"))
+	//nolint:errcheck
+	viper.BindPFlag("scan_result_ttl", rootCmd.Flags().Lookup("scan-result-ttl"))
+	//nolint:errcheck
+	viper.BindPFlag("github_actions", rootCmd.Flags().Lookup("github-actions"))
+	//nolint:errcheck
+	viper.BindPFlag("follow_symlinks", rootCmd.Flags().Lookup("follow-symlinks"))
+	//nolint:errcheck
+	viper.BindPFlag("retry", rootCmd.Flags().Lookup("retry"))
+	//nolint:errcheck
+	viper.BindPFlag("retry_delay", rootCmd.Flags().Lookup("retry-delay"))
+	//nolint:errcheck
+	viper.BindPFlag("min_file_size", rootCmd.Flags().Lookup("min-file-size"))
+
+	registerCompletions()
+}
+
+// flagUsages renders the usage of either the override flags or all other flags
+func flagUsages(fs *pflag.FlagSet, overrides bool) string {
+	filtered := pflag.NewFlagSet("", pflag.ContinueOnError)
+	fs.VisitAll(func(f *pflag.Flag) {
+		_, isOverride := f.Annotations[overrideAnnotation]
+		if isOverride == overrides {
+			filtered.AddFlag(f)
+		}
+	})
+	return filtered.FlagUsages()
 }
 
 func initConfig() {
@@ -118,6 +492,20 @@ func initConfig() {
 
 	viper.AutomaticEnv() // read env vars
 
+	// Also read R2C_-prefixed env vars (e.g. R2C_OUTPUT) for every config key
+	for key := range configKeys() {
+		//nolint:errcheck
+		viper.BindEnv(key, envloader.EnvKey(key))
+	}
+
+	// Env file values only fill in what flags, env vars and the config file leave unset
+	if flagCfg.EnvFile != "" {
+		if err := loadEnvFile(flagCfg.EnvFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading env file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Read config with enhanced error handling
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok && flagCfg.ConfigFile == "" {
@@ -137,3 +525,40 @@ func initConfig() {
 		os.Exit(1)
 	}
 }
+
+// loadEnvFile registers .env file values as viper defaults, so CLI flags,
+// environment variables and the config file all take priority over them
+func loadEnvFile(path string) error {
+	values, err := envloader.LoadEnvFile(path)
+	if err != nil {
+		return err
+	}
+
+	known := configKeys()
+	for envKey, value := range values {
+		key, ok := envloader.ConfigKey(envKey)
+		if !ok || !known[key] {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring unknown key %s in %s\n", envKey, path)
+			continue
+		}
+
+		// Variables set in the shell override the file
+		if _, set := os.LookupEnv(envKey); set {
+			continue
+		}
+		viper.SetDefault(key, value)
+	}
+	return nil
+}
+
+// configKeys returns the config keys of all FlagConfig fields
+func configKeys() map[string]bool {
+	keys := make(map[string]bool)
+	fields := reflect.TypeOf(flagConfig.FlagConfig{})
+	for i := 0; i < fields.NumField(); i++ {
+		if key := fields.Field(i).Tag.Get("mapstructure"); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/BHChen24/repo2context/pkg/buildinfo"
+	"github.com/BHChen24/repo2context/pkg/flagConfig"
+	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Helper Functions
+
+// captureStdout captures stdout output during function execution
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	oldStdout := os.Stdout
+	defer func() { os.Stdout = oldStdout }()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	// Read concurrently so large outputs don't block on the pipe buffer
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		//nolint:errcheck
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	fn()
+
+	//nolint:errcheck
+	w.Close()
+	return <-done
+}
+
+// executeRoot runs the root command with the given arguments and returns stdout
+func executeRoot(t *testing.T, args ...string) string {
+	t.Helper()
+
+	return captureStdout(t, func() {
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+}
+
+// Integration tests for the root command
+
+func TestRootCommand_CountTokensFlag(t *testing.T) {
+	// Token counting needs the encoding data, which may be unavailable offline
+	if _, err := tokencounter.NewTokenCounter(""); err != nil {
+		t.Skipf("Token encoding unavailable: %v", err)
+	}
+
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	output := executeRoot(t, "--count-tokens", "--no-gitignore", tempDir)
+
+	// Then
+	if !regexp.MustCompile(`main\.go \(\d+ tokens\)`).MatchString(output) {
+		t.Errorf("Expected directory tree to contain token annotation, got:\n%s", output)
+	}
+	if !regexp.MustCompile(`- Total tokens: \d+`).MatchString(output) {
+		t.Errorf("Expected summary to contain total tokens, got:\n%s", output)
+	}
+}
+
+func TestRootCommand_OnlyLanguageAlias(t *testing.T) {
+	t.Cleanup(func() {
+		flag := rootCmd.Flags().Lookup("include-language")
+		//nolint:errcheck
+		flag.Value.(pflag.SliceValue).Replace(nil)
+		flag.Changed = false
+	})
+
+	// Given
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{"main.go": "package main\n", "notes.txt": "notes\n"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	output := executeRoot(t, "--only-language", "go", "--no-gitignore", tempDir)
+
+	// Then
+	if !strings.Contains(output, "### File: main.go") {
+		t.Errorf("Expected main.go to be included, got:\n%s", output)
+	}
+	if strings.Contains(output, "notes.txt") {
+		t.Errorf("Expected notes.txt to be filtered out, got:\n%s", output)
+	}
+}
+
+func TestVersionCommand_JSON(t *testing.T) {
+	t.Cleanup(func() { versionJSON = false })
+
+	// When
+	var buf bytes.Buffer
+	rootCmd.SetOut(&buf)
+	t.Cleanup(func() { rootCmd.SetOut(nil) })
+	rootCmd.SetArgs([]string{"version", "--json"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Then
+	var info buildinfo.BuildInfo
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", buf.String(), err)
+	}
+	if info.Version == "" || info.GoVersion != runtime.Version() {
+		t.Errorf("Expected version and Go version, got %+v", info)
+	}
+}
+
+func TestRootCommand_FlagCompletion(t *testing.T) {
+	tests := []struct {
+		flag     string
+		expected string
+	}{
+		{"--encoding", "cl100k_base"},
+		{"--format", "json-lines"},
+		{"--model", "claude-sonnet-4-5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			// When
+			var buf bytes.Buffer
+			rootCmd.SetOut(&buf)
+			t.Cleanup(func() { rootCmd.SetOut(nil) })
+			rootCmd.SetArgs([]string{cobra.ShellCompRequestCmd, tt.flag, ""})
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Then
+			if !strings.Contains(buf.String(), tt.expected+"\n") {
+				t.Errorf("Expected %s to complete to %s, got:\n%s", tt.flag, tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"10", 10, false},
+		{"10B", 10, false},
+		{"2kb", 2048, false},
+		{"1.5MB", 1536 * 1024, false},
+		{"1 GB", 1 << 30, false},
+		{"", 0, true},
+		{"ten", 0, true},
+		{"-5B", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseByteSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	// Given: a config file with an unknown key
+	configFile := filepath.Join(t.TempDir(), ".r2c-config.toml")
+	if err := os.WriteFile(configFile, []byte("encoding = \"gpt4-bad-encoding\"\nverbos = true\n"), 0644); err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+
+	// When: the loaded options have two invalid values
+	var out bytes.Buffer
+	err := checkConfig(&out, configFile, flagConfig.FlagConfig{Encoding: "gpt4-bad-encoding", Retry: -1})
+
+	// Then
+	if err == nil {
+		t.Fatal("Expected an error for invalid values")
+	}
+	for _, want := range []string{
+		"Warning: unknown key 'verbos' in " + configFile,
+		"Error: --retry must not be negative (got -1)",
+		`Error: unsupported encoding "gpt4-bad-encoding"`,
+		"for key 'encoding'",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in report, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "'encoding' in") {
+		t.Errorf("Expected encoding to be a known key, got:\n%s", out.String())
+	}
+
+	// A valid configuration passes
+	out.Reset()
+	if err := checkConfig(&out, "", flagConfig.FlagConfig{}); err != nil {
+		t.Errorf("Unexpected error: %v\n%s", err, out.String())
+	}
+}
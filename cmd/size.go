@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// byteSizeUnits are the suffixes accepted by parseByteSize, longest first
+var byteSizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseByteSize parses a size such as "10", "10B", "4KB" or "1.5MB"
+// Units are case-insensitive powers of 1024, matching the sizes in the summary
+func parseByteSize(text string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if trimmed, ok := strings.CutSuffix(number, unit.suffix); ok {
+			number, multiplier = strings.TrimSpace(trimmed), unit.bytes
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q (e.g. 10B, 4KB, 1MB)", text)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// byteSizeValue is a pflag.Value holding a size given with a unit suffix
+// String returns the plain byte count, which viper decodes into an int64
+type byteSizeValue int64
+
+func (v *byteSizeValue) String() string {
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+func (v *byteSizeValue) Set(text string) error {
+	size, err := parseByteSize(text)
+	if err != nil {
+		return err
+	}
+	*v = byteSizeValue(size)
+	return nil
+}
+
+func (v *byteSizeValue) Type() string {
+	return "size"
+}
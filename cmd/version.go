@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/BHChen24/repo2context/pkg/buildinfo"
+
+	"github.com/spf13/cobra"
+)
+
+// versionJSON backs version --json
+var versionJSON bool
+
+// versionCmd prints the build information of the binary
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version and build information",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := buildInfo()
+		if !versionJSON {
+			fmt.Fprint(cmd.OutOrStdout(), "r2c "+info.String())
+			return nil
+		}
+
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(data))
+		return nil
+	},
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print the build information as JSON")
+	rootCmd.AddCommand(versionCmd)
+
+	// --version prints the same details as the version subcommand
+	info := buildInfo()
+	rootCmd.Version = info.Version
+	rootCmd.SetVersionTemplate("r2c " + info.String())
+}
+
+// buildInfo returns the build information of the binary, with rootCmd.Version
+// as the version unless another one was set with -ldflags
+func buildInfo() buildinfo.BuildInfo {
+	info := buildinfo.Get()
+	if buildinfo.Version == "dev" {
+		info.Version = rootCmd.Version
+	}
+	return info
+}
@@ -0,0 +1,73 @@
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags, see the Makefile:
+//
+//	go build -ldflags "-X github.com/BHChen24/repo2context/pkg/buildinfo.Version=v1.2.3"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// tiktokenModule is the module whose version is reported as TiktokenVersion
+const tiktokenModule = "github.com/localit-io/tiktoken-go"
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	BuildDate       string `json:"build_date"`
+	GoVersion       string `json:"go_version"`
+	OS              string `json:"os"`
+	Arch            string `json:"arch"`
+	TiktokenVersion string `json:"tiktoken_version"`
+}
+
+// Get returns the build information of the running binary. Values not set
+// with -ldflags fall back to what the Go toolchain embedded: the module
+// version for go install, and the VCS revision and time for builds in a checkout.
+func Get() BuildInfo {
+	info := BuildInfo{
+		Version:         Version,
+		Commit:          Commit,
+		BuildDate:       BuildDate,
+		GoVersion:       runtime.Version(),
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+		TiktokenVersion: "unknown",
+	}
+
+	embedded, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && embedded.Main.Version != "" && embedded.Main.Version != "(devel)" {
+		info.Version = embedded.Main.Version
+	}
+	for _, setting := range embedded.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "unknown":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.BuildDate == "unknown":
+			info.BuildDate = setting.Value
+		}
+	}
+	for _, dep := range embedded.Deps {
+		if dep.Path == tiktokenModule {
+			info.TiktokenVersion = dep.Version
+		}
+	}
+	return info
+}
+
+// String renders the build information one field per line, starting with the version
+func (info BuildInfo) String() string {
+	return fmt.Sprintf("%s\ncommit: %s\nbuilt: %s\ngo: %s\nplatform: %s/%s\ntiktoken-go: %s\n",
+		info.Version, info.Commit, info.BuildDate, info.GoVersion, info.OS, info.Arch, info.TiktokenVersion)
+}
@@ -0,0 +1,45 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Tests for Get
+
+func TestGet_UsesLinkerValues(t *testing.T) {
+	defer func(version, commit, date string) { Version, Commit, BuildDate = version, commit, date }(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "v1.2.3", "abc123", "2025-01-02T03:04:05Z"
+
+	info := Get()
+
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.BuildDate != "2025-01-02T03:04:05Z" {
+		t.Errorf("Expected the -ldflags values, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() || info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		t.Errorf("Expected the runtime's Go version and platform, got %+v", info)
+	}
+}
+
+func TestBuildInfo_String(t *testing.T) {
+	info := BuildInfo{Version: "v1.2.3", Commit: "abc123", BuildDate: "today", GoVersion: "go1.25.1", OS: "linux", Arch: "amd64", TiktokenVersion: "v0.2.0"}
+
+	expected := "v1.2.3\ncommit: abc123\nbuilt: today\ngo: go1.25.1\nplatform: linux/amd64\ntiktoken-go: v0.2.0\n"
+	if got := info.String(); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestBuildInfo_JSON(t *testing.T) {
+	data, err := json.Marshal(BuildInfo{Version: "v1.2.3", BuildDate: "today"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, key := range []string{`"version":"v1.2.3"`, `"build_date":"today"`, `"tiktoken_version":""`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("Expected %s in %s", key, data)
+		}
+	}
+}
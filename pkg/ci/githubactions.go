@@ -0,0 +1,112 @@
+package ci
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+)
+
+// ErrNoGitHubOutput is returned outside GitHub Actions, where GITHUB_OUTPUT isn't set
+var ErrNoGitHubOutput = errors.New("GITHUB_OUTPUT is not set")
+
+// Report is what a run tells a GitHub Actions step
+type Report struct {
+	Root       string
+	FileCount  int
+	LineCount  int
+	Size       int64
+	TokenCount int // 0 when tokens weren't counted
+	ErrorCount int
+	OutputFile string // empty for stdout
+	Content    string // the rendered context, empty when it wasn't written as one document
+}
+
+// WriteGitHubActions sets the step outputs r2c_token_count, r2c_file_count,
+// r2c_output_file and, for a single document, r2c_output in $GITHUB_OUTPUT,
+// and appends an HTML table of the scan statistics to $GITHUB_STEP_SUMMARY
+// when it is set
+func WriteGitHubActions(report Report) error {
+	outputPath := os.Getenv("GITHUB_OUTPUT")
+	if outputPath == "" {
+		return ErrNoGitHubOutput
+	}
+
+	var outputs strings.Builder
+	fmt.Fprintf(&outputs, "r2c_token_count=%d\n", report.TokenCount)
+	fmt.Fprintf(&outputs, "r2c_file_count=%d\n", report.FileCount)
+	fmt.Fprintf(&outputs, "r2c_output_file=%s\n", report.OutputFile)
+	if report.Content != "" {
+		if err := writeMultiline(&outputs, "r2c_output", report.Content); err != nil {
+			return err
+		}
+	}
+	if err := appendFile(outputPath, outputs.String()); err != nil {
+		return fmt.Errorf("failed to write GitHub Actions outputs: %w", err)
+	}
+
+	if summaryPath := os.Getenv("GITHUB_STEP_SUMMARY"); summaryPath != "" {
+		if err := appendFile(summaryPath, StepSummary(report)); err != nil {
+			return fmt.Errorf("failed to write GitHub Actions step summary: %w", err)
+		}
+	}
+	return nil
+}
+
+// StepSummary renders the scan statistics as a compact HTML table
+func StepSummary(report Report) string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "<h3>repo2context: %s</h3>\n<table>\n", html.EscapeString(report.Root))
+	row := func(name, value string) {
+		fmt.Fprintf(&summary, "<tr><th align=\"left\">%s</th><td>%s</td></tr>\n", name, html.EscapeString(value))
+	}
+	row("Files", fmt.Sprint(report.FileCount))
+	row("Lines", fmt.Sprint(report.LineCount))
+	row("Size", fmt.Sprintf("%d bytes", report.Size))
+	if report.TokenCount > 0 {
+		row("Tokens", fmt.Sprint(report.TokenCount))
+	}
+	if report.ErrorCount > 0 {
+		row("Errors", fmt.Sprint(report.ErrorCount))
+	}
+	if report.OutputFile != "" {
+		row("Output", report.OutputFile)
+	}
+	summary.WriteString("</table>\n")
+	return summary.String()
+}
+
+// writeMultiline writes name<<DELIMITER, the value and the delimiter, with a
+// random delimiter that doesn't occur in the value
+func writeMultiline(outputs *strings.Builder, name, value string) error {
+	var delimiter string
+	for delimiter == "" || strings.Contains(value, delimiter) {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("failed to generate output delimiter: %w", err)
+		}
+		delimiter = "ghadelimiter_" + hex.EncodeToString(buf)
+	}
+
+	fmt.Fprintf(outputs, "%s<<%s\n%s", name, delimiter, value)
+	if !strings.HasSuffix(value, "\n") {
+		outputs.WriteByte('\n')
+	}
+	fmt.Fprintf(outputs, "%s\n", delimiter)
+	return nil
+}
+
+// appendFile appends text to the file at path, creating it if needed
+func appendFile(path, text string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(text); err != nil {
+		return errors.Join(err, file.Close())
+	}
+	return file.Close()
+}
@@ -0,0 +1,106 @@
+package ci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// setGitHubEnv points GITHUB_OUTPUT and GITHUB_STEP_SUMMARY at temporary files
+func setGitHubEnv(t *testing.T) (outputPath, summaryPath string) {
+	t.Helper()
+	dir := t.TempDir()
+	outputPath = filepath.Join(dir, "output")
+	summaryPath = filepath.Join(dir, "summary")
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+	return outputPath, summaryPath
+}
+
+// Tests for WriteGitHubActions
+
+func TestWriteGitHubActions_Outputs(t *testing.T) {
+	outputPath, summaryPath := setGitHubEnv(t)
+
+	err := WriteGitHubActions(Report{
+		Root:       "/repo",
+		FileCount:  42,
+		TokenCount: 12450,
+		OutputFile: "context.md",
+		Content:    "# Repository Context\n\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Expected GITHUB_OUTPUT to be written: %v", err)
+	}
+	pattern := regexp.MustCompile(`^r2c_token_count=12450\nr2c_file_count=42\nr2c_output_file=context.md\n` +
+		`r2c_output<<(ghadelimiter_[0-9a-f]+)\n# Repository Context\n\nline two\n(ghadelimiter_[0-9a-f]+)\n$`)
+	match := pattern.FindStringSubmatch(string(data))
+	if match == nil || match[1] != match[2] {
+		t.Errorf("Unexpected outputs:\n%s", data)
+	}
+
+	summary, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("Expected GITHUB_STEP_SUMMARY to be written: %v", err)
+	}
+	if !strings.Contains(string(summary), "<th align=\"left\">Tokens</th><td>12450</td>") {
+		t.Errorf("Expected the token count in the summary, got:\n%s", summary)
+	}
+}
+
+func TestWriteGitHubActions_AppendsWithoutContent(t *testing.T) {
+	outputPath, _ := setGitHubEnv(t)
+	if err := os.WriteFile(outputPath, []byte("earlier=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteGitHubActions(Report{FileCount: 1}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "earlier=1\nr2c_token_count=0\nr2c_file_count=1\nr2c_output_file=\n"
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+}
+
+func TestWriteGitHubActions_NotInGitHubActions(t *testing.T) {
+	t.Setenv("GITHUB_OUTPUT", "")
+
+	err := WriteGitHubActions(Report{})
+	if !errors.Is(err, ErrNoGitHubOutput) {
+		t.Errorf("Expected ErrNoGitHubOutput, got %v", err)
+	}
+}
+
+// Tests for StepSummary
+
+func TestStepSummary_EscapesAndOmitsEmptyRows(t *testing.T) {
+	summary := StepSummary(Report{Root: "/repo/<dir>", FileCount: 3, LineCount: 10, Size: 200})
+
+	if !strings.Contains(summary, "<h3>repo2context: /repo/&lt;dir&gt;</h3>") {
+		t.Errorf("Expected an escaped heading, got:\n%s", summary)
+	}
+	for _, row := range []string{"Files</th><td>3", "Lines</th><td>10", "Size</th><td>200 bytes"} {
+		if !strings.Contains(summary, row) {
+			t.Errorf("Expected %q in the summary, got:\n%s", row, summary)
+		}
+	}
+	for _, row := range []string{"Tokens", "Errors", "Output"} {
+		if strings.Contains(summary, row) {
+			t.Errorf("Expected no %s row, got:\n%s", row, summary)
+		}
+	}
+}
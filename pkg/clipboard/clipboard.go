@@ -0,0 +1,57 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrUnavailable is returned when no clipboard command is installed
+var ErrUnavailable = errors.New("no clipboard command found")
+
+// commands lists the clipboard commands of each platform in order of preference
+// Platforms not listed use the Linux commands
+var commands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip"}},
+	"linux":   {{"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+}
+
+// goos is the platform used to pick clipboard commands, replaced in tests
+var goos = runtime.GOOS
+
+// Write copies content to the system clipboard using the first available
+// platform command (pbcopy, xclip, xsel or clip)
+func Write(content string) error {
+	name, args, err := findCommand()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// findCommand returns the path and arguments of the first installed clipboard command
+func findCommand() (string, []string, error) {
+	candidates, ok := commands[goos]
+	if !ok {
+		candidates = commands["linux"]
+	}
+
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate[0]); err == nil {
+			return path, candidate[1:], nil
+		}
+	}
+	return "", nil, ErrUnavailable
+}
@@ -0,0 +1,97 @@
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// installFakeCommand puts a shell script named name on an otherwise empty PATH
+// The script appends its arguments and stdin to the returned file
+func installFakeCommand(t *testing.T, name string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake clipboard commands are shell scripts")
+	}
+
+	binDir := t.TempDir()
+	capture := filepath.Join(t.TempDir(), "clipboard.txt")
+	// Only shell builtins, since PATH holds nothing else
+	script := "#!/bin/sh\necho \"args: $*\" > '" + capture + "'\nwhile IFS= read -r line; do echo \"$line\" >> '" + capture + "'; done\n"
+	if err := os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to create fake command: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+	return capture
+}
+
+// setPlatform pretends to run on platform for the duration of the test
+func setPlatform(t *testing.T, platform string) {
+	t.Helper()
+	original := goos
+	goos = platform
+	t.Cleanup(func() { goos = original })
+}
+
+// Tests for Write
+
+func TestWrite_UsesPlatformCommand(t *testing.T) {
+	tests := []struct {
+		platform string
+		command  string
+		args     string
+	}{
+		{"darwin", "pbcopy", ""},
+		{"linux", "xclip", "-selection clipboard"},
+		{"linux", "xsel", "--clipboard --input"},
+		{"freebsd", "xclip", "-selection clipboard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform+"/"+tt.command, func(t *testing.T) {
+			setPlatform(t, tt.platform)
+			capture := installFakeCommand(t, tt.command)
+
+			if err := Write("hello\nclipboard\n"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(capture)
+			if err != nil {
+				t.Fatalf("Fake command was not run: %v", err)
+			}
+			expected := "args: " + tt.args + "\nhello\nclipboard\n"
+			if string(got) != expected {
+				t.Errorf("Expected %q, got %q", expected, got)
+			}
+		})
+	}
+}
+
+func TestWrite_NoCommand(t *testing.T) {
+	setPlatform(t, "linux")
+	t.Setenv("PATH", t.TempDir())
+
+	if err := Write("content"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestWrite_CommandFails(t *testing.T) {
+	setPlatform(t, "darwin")
+	if runtime.GOOS == "windows" {
+		t.Skip("fake clipboard commands are shell scripts")
+	}
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "pbcopy"), []byte("#!/bin/sh\necho 'no display' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("Failed to create fake command: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	err := Write("content")
+	if err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Expected command failure, got %v", err)
+	}
+}
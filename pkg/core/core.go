@@ -1,191 +1,855 @@
 package core
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/BHChen24/repo2context/pkg/ci"
+	"github.com/BHChen24/repo2context/pkg/clipboard"
 	"github.com/BHChen24/repo2context/pkg/flagConfig"
 	"github.com/BHChen24/repo2context/pkg/formatter"
+	"github.com/BHChen24/repo2context/pkg/gitinfo"
+	"github.com/BHChen24/repo2context/pkg/languages"
+	"github.com/BHChen24/repo2context/pkg/sanitizer"
 	"github.com/BHChen24/repo2context/pkg/scanner"
 	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
 )
 
-// verboseLog prints message to stderr if verbose mode is enabled
+// ErrScanErrors is returned when --fail-on-errors is set and a scan reported errors
+var ErrScanErrors = errors.New("scan completed with errors")
+
+// DefaultMaxPaths is the path limit of a run when FlagConfig.MaxPaths is 0
+const DefaultMaxPaths = 10
+
+// legacyMaxPaths is the fixed path limit of versions before --max-paths
+const legacyMaxPaths = 5
+
+// stdinInput is the piped content of --stdin-content, read once by Run and
+// added to the first output written
+type stdinInput struct {
+	file *scanner.FileInfo
+}
+
+// take returns the stdin file on the first call and nil afterwards
+func (in *stdinInput) take() *scanner.FileInfo {
+	if in == nil {
+		return nil
+	}
+	file := in.file
+	in.file = nil
+	return file
+}
+
+// verboseLog prints message to stderr if verbose mode is enabled,
+// as a JSON line with --verbose-json
 // arg: other arguments, type free
-func verboseLog(verbose bool, info string, args ...interface{}) {
-	if verbose {
-		fmt.Fprintf(os.Stderr, "-> "+info+"\n", args...)
+func verboseLog(flagCfg flagConfig.FlagConfig, info string, args ...interface{}) {
+	if !flagCfg.Verbose {
+		return
 	}
+	if flagCfg.VerboseJSON {
+		logJSON(fmt.Sprintf(info, args...))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "-> "+info+"\n", args...)
+}
+
+// logJSON writes a single structured verbose log line to stderr
+func logJSON(msg string) {
+	line, err := json.Marshal(struct {
+		Level string `json:"level"`
+		Ts    string `json:"ts"`
+		Msg   string `json:"msg"`
+	}{"verbose", time.Now().UTC().Format(time.RFC3339Nano), msg})
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(os.Stderr, string(line))
 }
 
 // countTokensInScanResult counts tokens for all files in the scan result
-func countTokensInScanResult(scanResult *scanner.ScanResult, verbose bool) error {
-	verboseLog(verbose, "Starting token counting...")
+// An empty encoding falls back to the default (o200k_base)
+func countTokensInScanResult(scanResult *scanner.ScanResult, encoding string) error {
+	_, err := countTokensWithWorkers(scanResult, flagConfig.FlagConfig{Encoding: encoding})
+	return err
+}
 
-	// Create token counter with default encoding (o200k_base)
-	tc, err := tokencounter.NewTokenCounter("")
+// countTokens counts tokens as configured: estimated with --estimate-tokens,
+// otherwise encoded on --token-count-workers workers
+// It returns the count it used, so truncation can measure kept content the same way
+func countTokens(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) (func(string) int, error) {
+	if flagCfg.UseEstimatedTokens {
+		estimateTokensInScanResult(scanResult, flagCfg)
+		return tokencounter.EstimateTokens, nil
+	}
+	pool, err := countTokensWithWorkers(scanResult, flagCfg)
 	if err != nil {
-		return fmt.Errorf("failed to create token counter: %w", err)
+		return nil, err
 	}
+	return func(text string) int {
+		tc := pool.Get()
+		defer pool.Put(tc)
+		tokens, _ := tc.CountTokens(text)
+		return tokens
+	}, nil
+}
 
+// estimateTokensInScanResult sets approximate token counts with
+// tokencounter.EstimateTokens, which needs no encoding to be loaded
+func estimateTokensInScanResult(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) {
+	verboseLog(flagCfg, "Estimating tokens at 4 bytes per token...")
 	totalTokens := 0
-	fileCount := 0
-
-	// Count tokens for each file
 	for i := range scanResult.Files {
 		file := &scanResult.Files[i]
-
-		// Skip directories and files with errors
-		if file.IsDir || file.Error != nil {
+		if file.IsDir || file.Error != nil || file.Content == "" {
 			continue
 		}
+		file.TokenCount = tokencounter.EstimateTokens(file.Content)
+		totalTokens += file.TokenCount
+	}
+
+	scanResult.TotalTokens = totalTokens
+	scanResult.TokenEncoding = ""
+	scanResult.TokensEstimated = true
+	scanResult.TokensByDirectory = tokensByDirectory(scanResult.Files)
+	verboseLog(flagCfg, "Token estimation completed - ~%d total tokens", totalTokens)
+}
+
+// tokenJob is a file whose content is waiting to be encoded
+type tokenJob struct {
+	index   int
+	content string
+	path    string
+}
+
+// tokenCount is the result of encoding one file
+type tokenCount struct {
+	index int
+	count int
+	err   error
+}
+
+// countTokensWithWorkers counts tokens in the --encoding like
+// countTokensInScanResult, encoding files on a pool of --token-count-workers
+// goroutines (0 means runtime.NumCPU()) that each count with their own encoder
+// from a TokenCounterPool, and returns the pool
+func countTokensWithWorkers(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) (*tokencounter.TokenCounterPool, error) {
+	encoding := flagCfg.Encoding
+	if encoding == "" {
+		encoding = tokencounter.DefaultEncoding
+	}
 
-		// Skip empty files
-		if file.Content == "" {
+	// Skip directories, files with errors and empty files
+	var jobs []tokenJob
+	for i, file := range scanResult.Files {
+		if file.IsDir || file.Error != nil || file.Content == "" {
 			continue
 		}
+		jobs = append(jobs, tokenJob{index: i, content: file.Content, path: file.Path})
+	}
 
-		// Count tokens
-		count, err := tc.CountTokensWithPath(file.Content, file.Path)
-		if err != nil {
-			verboseLog(verbose, "Warning: failed to count tokens for %s: %v", file.RelativePath, err)
+	workers := flagCfg.TokenCountWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = max(min(workers, len(jobs)), 1)
+	verboseLog(flagCfg, "Starting token counting with %s encoding and %d worker(s)...", encoding, workers)
+
+	pool, err := tokencounter.NewTokenCounterPool(encoding, workers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token counter: %w", err)
+	}
+	scanResult.TokenEncoding = encoding
+
+	// Encoding is CPU-bound; each worker counts with its own encoder from the pool
+	jobCh := make(chan tokenJob)
+	resultCh := make(chan tokenCount, len(jobs))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tc := pool.Get()
+			defer pool.Put(tc)
+			for job := range jobCh {
+				count, err := tc.CountTokensWithPath(job.content, job.path)
+				resultCh <- tokenCount{index: job.index, count: count, err: err}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	wg.Wait()
+	close(resultCh)
+
+	counts := make(map[int]tokenCount, len(jobs))
+	for result := range resultCh {
+		counts[result.index] = result
+	}
+
+	// Aggregate in file order so verbose output is deterministic
+	totalTokens := 0
+	fileCount := 0
+	for _, job := range jobs {
+		file := &scanResult.Files[job.index]
+		result := counts[job.index]
+		if result.err != nil {
+			verboseLog(flagCfg, "Warning: failed to count tokens for %s: %v", file.RelativePath, result.err)
 			continue
 		}
 
 		// Store per-file token count
-		file.TokenCount = count
-		totalTokens += count
+		file.TokenCount = result.count
+		totalTokens += result.count
 		fileCount++
-		verboseLog(verbose, "  %s: %d tokens", file.RelativePath, count)
+		verboseLog(flagCfg, "  %s: %d tokens", file.RelativePath, result.count)
 	}
 
 	scanResult.TotalTokens = totalTokens
-	verboseLog(verbose, "Token counting completed - %d files, %d total tokens", fileCount, totalTokens)
+	scanResult.TokensByDirectory = tokensByDirectory(scanResult.Files)
+	verboseLog(flagCfg, "Token counting completed - %d files, %d total tokens", fileCount, totalTokens)
 
-	return nil
+	return pool, nil
+}
+
+// tokensByDirectory sums file token counts into every ancestor directory:
+// pkg/core/core.go counts toward "pkg/core/", "pkg/" and the root "."
+func tokensByDirectory(files []scanner.FileInfo) map[string]int {
+	totals := make(map[string]int)
+	for _, file := range files {
+		if file.IsDir || file.TokenCount == 0 {
+			continue
+		}
+		totals["."] += file.TokenCount
+		for dir := filepath.Dir(file.RelativePath); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			totals[filepath.ToSlash(dir)+"/"] += file.TokenCount
+		}
+	}
+	return totals
 }
 
 // Run processes paths and generates repository context output
-func Run(paths []string, flagCfg flagConfig.FlagConfig) error {
-	verboseLog(flagCfg.Verbose, "Starting repo2context with %d path(s)", len(paths))
+// Cancelling ctx stops the scan and returns the context error
+// --stdin-content reads os.Stdin; see RunWithStdin for another source
+func Run(ctx context.Context, paths []string, flagCfg flagConfig.FlagConfig) error {
+	return RunWithStdin(ctx, paths, flagCfg, os.Stdin)
+}
+
+// RunWithStdin is Run taking the --stdin-content input from stdin
+func RunWithStdin(ctx context.Context, paths []string, flagCfg flagConfig.FlagConfig, stdin io.Reader) error {
+	// Reject invalid options before doing any work
+	if err := flagCfg.Validate(); err != nil {
+		return err
+	}
 
-	// Check if too many files are provided
-	if len(paths) > 5 {
-		return fmt.Errorf("too many files specified (%d). Maximum allowed: %d", len(paths), 5)
+	// Bound the whole run when a timeout is set
+	if flagCfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, flagCfg.Timeout)
+		defer cancel()
 	}
 
-	verboseLog(flagCfg.Verbose, "Processing paths: %v", paths)
+	// Structured verbose logging implies verbose mode
+	if flagCfg.VerboseJSON {
+		flagCfg.Verbose = true
+	}
+
+	// Read piped stdin once; it is included in the first output only
+	input := &stdinInput{}
+	if flagCfg.StdinContent {
+		file, err := readStdinFile(stdin, flagCfg.StdinLabel)
+		if err != nil {
+			return err
+		}
+		input.file = file
+	}
+
+	// Splitting by token budget, the per-file index and truncation need per-file token counts
+	if flagCfg.TokenLimit > 0 || flagCfg.SplitOutput || flagCfg.MaxTokensPerFile > 0 {
+		flagCfg.CountTokens = true
+	}
+	// Measuring usage of a given context window needs the total
+	if flagCfg.ContextWindow > 0 {
+		flagCfg.CountTokens = true
+	}
+	// Without --model or --token-limit, the context window is the budget when splitting to a file
+	if flagCfg.ContextWindow > 0 && flagCfg.Model == "" && flagCfg.TokenLimit == 0 && flagCfg.OutputFile != "" &&
+		!flagCfg.SplitOutput && !flagCfg.SummaryOnly && !flagCfg.OnlyErrors {
+		flagCfg.TokenLimit = flagCfg.ContextWindow
+	}
+	// Token density divides each file's token count by its lines
+	if flagCfg.TokenDensity || flagCfg.TreeShowDensity {
+		flagCfg.CountTokens = true
+	}
+	if flagCfg.UseEstimatedTokens {
+		flagCfg.CountTokens = true
+	}
+
+	// RST output without an extension is named for Sphinx
+	if rendersRST(flagCfg) && flagCfg.OutputFile != "" && filepath.Ext(flagCfg.OutputFile) == "" {
+		flagCfg.OutputFile += ".rst"
+	}
+
+	// --include-gitignored turns off every ignore file layer, not just .gitignore,
+	// and the lock file and generated file skipping
+	if flagCfg.IncludeGitignored {
+		flagCfg.NoGitignore = true
+		flagCfg.NoR2cignore = true
+		flagCfg.IncludeLockFiles = true
+		flagCfg.SkipGenerated = false
+		flagCfg.IncludeVendor = true
+		flagCfg.IncludeNodeModules = true
+		flagCfg.IncludeGenerated = true
+	}
+
+	verboseLog(flagCfg, "Starting repo2context with %d path(s)", len(paths))
+
+	// Expand glob patterns the shell left untouched (cmd.exe and PowerShell don't glob)
+	paths = expandGlobs(paths)
+
+	// Check if too many paths are provided (0 means the default, negative unlimited)
+	maxPaths := flagCfg.MaxPaths
+	if maxPaths == 0 {
+		maxPaths = DefaultMaxPaths
+	}
+	if maxPaths > 0 && len(paths) > maxPaths {
+		return fmt.Errorf("too many paths specified (%d). Maximum allowed: %d (raise it with --max-paths)", len(paths), maxPaths)
+	}
+	// A scan cache file holds the scan of one directory
+	if flagCfg.SaveScanResult != "" || flagCfg.LoadScanResult != "" {
+		if len(paths) > 1 {
+			return fmt.Errorf("--save-scan-result and --load-scan-result take a single path (got %d)", len(paths))
+		}
+		if len(paths) == 1 && isPlainFile(paths[0]) {
+			return fmt.Errorf("--save-scan-result and --load-scan-result take a directory, not the file %s", paths[0])
+		}
+	}
+	// Only users relying on the default limit may expect the old one
+	if flagCfg.MaxPaths == 0 && len(paths) > legacyMaxPaths {
+		fmt.Fprintf(os.Stderr, "Note: %d paths given. Versions before --max-paths rejected more than %d\n", len(paths), legacyMaxPaths)
+	}
+
+	// Register user-defined language mappings before scanning
+	for _, mapping := range flagCfg.AddLanguages {
+		ext, language, err := languages.ParseMapping(mapping)
+		if err != nil {
+			return err
+		}
+		languages.Register(ext, language)
+	}
+
+	verboseLog(flagCfg, "Processing paths: %v", paths)
+
+	// Track whether any scan reported errors for --fail-on-errors
+	scanErrors := false
+	var mergePaths []string
+	// Clones of merged paths are scanned after the loop, so they are removed last
+	var mergeCleanups []func()
+	defer func() {
+		for _, cleanup := range mergeCleanups {
+			cleanup()
+		}
+	}()
+	// Names of the per-path --output-dir subdirectories used so far
+	splitDirs := make(map[string]bool)
 
 	// Process each path provided
 	for i, path := range paths {
-		verboseLog(flagCfg.Verbose, "Processing path %d/%d: %s", i+1, len(paths), path)
-		absPath, err := filepath.Abs(path)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error getting absolute path for '%s': %v\n", path, err)
+		if err := ctx.Err(); err != nil {
+			return contextError(err, flagCfg)
+		}
+
+		verboseLog(flagCfg, "Processing path %d/%d: %s", i+1, len(paths), path)
+		absPath, cleanup, ok := resolvePath(path, flagCfg)
+		if !ok {
 			continue
 		}
 
-		// Check if the path exists
-		if _, err := os.Stat(absPath); err != nil {
-			if os.IsNotExist(err) {
-				fmt.Fprintf(os.Stderr, "path does not exist: %s\n", absPath)
-				continue
-			}
-			fmt.Fprintf(os.Stderr, "error checking path '%s': %v\n", absPath, err)
+		// Merged paths are scanned together after the loop
+		if flagCfg.Merge && len(paths) > 1 {
+			mergePaths = append(mergePaths, absPath)
+			mergeCleanups = append(mergeCleanups, cleanup)
 			continue
 		}
 
+		// Each path gets its own --output-dir subdirectory so outputs and indexes don't overwrite each other
+		pathCfg := flagCfg
+		if flagCfg.SplitOutput && len(paths) > 1 {
+			pathCfg.OutputDir = filepath.Join(flagCfg.OutputDir, uniqueName(sanitizeFileName(filepath.Base(absPath)), splitDirs))
+		}
+
 		// Process the path based on whether it's a file or directory
-		verboseLog(flagCfg.Verbose, "Processing absolute path: %s", absPath)
-		err = processPath(absPath, flagCfg)
+		verboseLog(flagCfg, "Processing absolute path: %s", absPath)
+		err := processPath(ctx, absPath, input, pathCfg)
+		// A temporary clone is removed as soon as its output is written
+		cleanup()
+		if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return contextError(ctxErr, flagCfg)
+		}
+		if errors.Is(err, ErrScanErrors) {
+			scanErrors = true
+			continue
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error processing path '%s': %v\n", absPath, err)
 			continue
 		}
-		verboseLog(flagCfg.Verbose, "Successfully processed: %s", absPath)
+		verboseLog(flagCfg, "Successfully processed: %s", absPath)
+	}
+
+	if len(mergePaths) > 0 {
+		err := processMerged(ctx, mergePaths, input, flagCfg)
+		if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return contextError(ctxErr, flagCfg)
+		}
+		if errors.Is(err, ErrScanErrors) {
+			scanErrors = true
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "error merging paths: %v\n", err)
+		}
+	}
+	verboseLog(flagCfg, "Completed processing all paths")
+
+	if scanErrors {
+		return ErrScanErrors
 	}
-	verboseLog(flagCfg.Verbose, "Completed processing all paths")
 	return nil
 }
 
+// resolvePath turns a path argument into an existing absolute path, cloning
+// GitHub URLs first. The returned cleanup removes a temporary clone. Problems
+// are reported to stderr and return false, with any clone already removed.
+func resolvePath(path string, flagCfg flagConfig.FlagConfig) (string, func(), bool) {
+	cleanup := func() {}
+
+	// Clone GitHub repositories given as URLs
+	if owner, repo, ok := gitinfo.ParseGitHubURL(path); ok {
+		dir, removeClone, err := cloneGitHubPath(owner, repo, flagCfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return "", cleanup, false
+		}
+		cleanup = removeClone
+		path = dir
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error getting absolute path for '%s': %v\n", path, err)
+		cleanup()
+		return "", cleanup, false
+	}
+
+	// Check if the path exists
+	if _, err := os.Stat(absPath); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "path does not exist: %s\n", absPath)
+		} else {
+			fmt.Fprintf(os.Stderr, "error checking path '%s': %v\n", absPath, err)
+		}
+		cleanup()
+		return "", cleanup, false
+	}
+	return absPath, cleanup, true
+}
+
+// timeoutError reports a run stopped by --timeout
+// It unwraps to context.DeadlineExceeded
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("scan timed out after %s", e.timeout)
+}
+
+func (e *timeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// contextError describes why the run was stopped
+func contextError(err error, flagCfg flagConfig.FlagConfig) error {
+	if errors.Is(err, context.DeadlineExceeded) && flagCfg.Timeout > 0 {
+		return &timeoutError{timeout: flagCfg.Timeout}
+	}
+	return err
+}
+
+// cloneGitHubPath clones owner/repo for scanning, authenticating with
+// --github-token or the GITHUB_TOKEN environment variable
+func cloneGitHubPath(owner, repo string, flagCfg flagConfig.FlagConfig) (string, func(), error) {
+	token := flagCfg.GitHubToken
+	if token == "" {
+		token = os.Getenv("GITHUB_TOKEN")
+	}
+
+	if flagCfg.OutputFile == "" && flagCfg.CloneCacheDir == "" {
+		fmt.Fprintf(os.Stderr, "Warning: writing %s/%s context to stdout; the temporary clone is removed afterwards\n", owner, repo)
+	}
+
+	verboseLog(flagCfg, "Cloning github.com/%s/%s", owner, repo)
+	return gitinfo.CloneGitHubRepo(owner, repo, gitinfo.CloneOptions{
+		Token:        token,
+		NoSubmodules: flagCfg.NoCloneSubmodules,
+		CacheDir:     flagCfg.CloneCacheDir,
+	})
+}
+
+// expandGlobs replaces path arguments containing glob metacharacters with their matches
+// Patterns that match nothing are dropped with a warning on stderr
+func expandGlobs(paths []string) []string {
+	expanded := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "*?[") {
+			expanded = append(expanded, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: invalid glob pattern '%s': %v\n", path, err)
+			continue
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: no files match '%s'\n", path)
+			continue
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded
+}
+
 // processPath handles a single file or directory
-func processPath(absPath string, flagCfg flagConfig.FlagConfig) error {
+func processPath(ctx context.Context, absPath string, input *stdinInput, flagCfg flagConfig.FlagConfig) error {
 	stat, err := os.Stat(absPath)
 	if err != nil {
 		return fmt.Errorf("failed to stat path: %w", err)
 	}
 
 	if stat.IsDir() {
-		verboseLog(flagCfg.Verbose, "Detected directory: %s", absPath)
-		return processDirectory(absPath, flagCfg)
+		verboseLog(flagCfg, "Detected directory: %s", absPath)
+		return processDirectory(ctx, absPath, input, flagCfg)
+	} else if scanner.IsArchive(absPath) {
+		// Archives are scanned as virtual directories
+		verboseLog(flagCfg, "Detected archive: %s", absPath)
+		return processDirectory(ctx, absPath, input, flagCfg)
 	} else {
-		verboseLog(flagCfg.Verbose, "Detected file: %s", absPath)
-		return processFile(absPath, flagCfg)
+		verboseLog(flagCfg, "Detected file: %s", absPath)
+		return processFile(absPath, input, flagCfg)
+	}
+}
+
+// isPlainFile reports whether path is an existing file that is scanned on its
+// own rather than as a directory, i.e. not a directory or an archive
+func isPlainFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && !scanner.IsArchive(path)
+}
+
+// processMerged scans several paths and writes them as one document (--merge)
+// Git info is taken from the first path
+func processMerged(ctx context.Context, absPaths []string, input *stdinInput, flagCfg flagConfig.FlagConfig) error {
+	results := make([]*scanner.ScanResult, 0, len(absPaths))
+	for _, absPath := range absPaths {
+		verboseLog(flagCfg, "Scanning for merge: %s", absPath)
+		var scanResult *scanner.ScanResult
+		var err error
+		if stat, statErr := os.Stat(absPath); statErr == nil && !stat.IsDir() && !scanner.IsArchive(absPath) {
+			scanResult, err = scanFile(absPath, flagCfg)
+		} else {
+			scanResult, err = scanDirectory(ctx, absPath, flagCfg)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error processing path '%s': %v\n", absPath, err)
+			continue
+		}
+		results = append(results, scanResult)
 	}
+	if len(results) == 0 {
+		return fmt.Errorf("no path could be scanned")
+	}
+
+	merged := scanner.MergeScanResults(results, "")
+	if flagCfg.CountTokens {
+		merged.TokensByDirectory = tokensByDirectory(merged.Files)
+	}
+	verboseLog(flagCfg, "Merged %d scans rooted at %s", len(results), merged.RootPath)
+
+	return writeDirectoryOutput(merged, results[0].RootPath, input, flagCfg)
 }
 
 // processDirectory scans and formats directory output
-func processDirectory(dirPath string, flagCfg flagConfig.FlagConfig) error {
-	verboseLog(flagCfg.Verbose, "Starting directory scan: %s", dirPath)
-	verboseLog(flagCfg.Verbose, "Scan options - NoGitignore: %t, DisplayLineNum: %t", flagCfg.NoGitignore, flagCfg.DisplayLineNum)
+func processDirectory(ctx context.Context, dirPath string, input *stdinInput, flagCfg flagConfig.FlagConfig) error {
+	scanResult, err := scanDirectoryCached(ctx, dirPath, flagCfg)
+	if err != nil {
+		return err
+	}
+	return writeDirectoryOutput(scanResult, dirPath, input, flagCfg)
+}
+
+// scanDirectoryCached takes the scan of dirPath from --load-scan-result when
+// that cache is fresh and of the same directory, and otherwise scans it,
+// saving the result to --save-scan-result
+func scanDirectoryCached(ctx context.Context, dirPath string, flagCfg flagConfig.FlagConfig) (*scanner.ScanResult, error) {
+	optionsHash := scanOptionsHash(flagCfg)
+	if flagCfg.LoadScanResult != "" {
+		scanResult, err := scanner.LoadScanResult(flagCfg.LoadScanResult, flagCfg.ScanResultTTL)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) || errors.Is(err, scanner.ErrScanResultExpired):
+			verboseLog(flagCfg, "Not using scan cache: %v", err)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		case scanResult.RootPath != dirPath:
+			fmt.Fprintf(os.Stderr, "Warning: %s holds a scan of %s, not %s; scanning again\n", flagCfg.LoadScanResult, scanResult.RootPath, dirPath)
+		case scanResult.OptionsHash != optionsHash:
+			verboseLog(flagCfg, "Not using scan cache: %s was saved with different scan options", flagCfg.LoadScanResult)
+		default:
+			verboseLog(flagCfg, "Loaded scan result from %s", flagCfg.LoadScanResult)
+			return scanResult, nil
+		}
+	}
+
+	scanResult, err := scanDirectory(ctx, dirPath, flagCfg)
+	if err != nil {
+		return nil, err
+	}
+	if flagCfg.SaveScanResult != "" {
+		scanResult.OptionsHash = optionsHash
+		if err := scanner.SaveScanResult(scanResult, flagCfg.SaveScanResult); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			verboseLog(flagCfg, "Saved scan result to %s", flagCfg.SaveScanResult)
+		}
+	}
+	return scanResult, nil
+}
+
+// scanOptionsHash identifies the options that shape a directory scan, so a
+// cached scan is only reused with the options it was made with. Output-only
+// options such as --output or --format are left out.
+func scanOptionsHash(flagCfg flagConfig.FlagConfig) string {
+	scanOptions := []any{
+		flagCfg.NoGitignore, flagCfg.NoR2cignore, flagCfg.IncludeGitignored,
+		flagCfg.IncludeVendor, flagCfg.IncludeNodeModules, flagCfg.IncludeGenerated,
+		flagCfg.IncludeLanguages, flagCfg.ExcludeLanguages, flagCfg.AddLanguages,
+		flagCfg.IncludePatterns, flagCfg.ExcludePatterns, flagCfg.ExcludePaths,
+		flagCfg.SkipGenerated, flagCfg.SkipLockFiles, flagCfg.IncludeLockFiles, flagCfg.SkipLockFilesExtra,
+		flagCfg.ExcludeTestFiles, flagCfg.TestFilePatterns, flagCfg.MinFileSizeBytes,
+		flagCfg.FollowSymlinks, flagCfg.PruneEmptyDirs, flagCfg.CommitHash,
+		flagCfg.NoContent, flagCfg.SummaryOnly, flagCfg.MaxErrors, flagCfg.AbortOnError,
+		flagCfg.DisplayLineNum, flagCfg.LineNumberStyle, flagCfg.LineEnding, flagCfg.Checksum,
+		flagCfg.StripComments, flagCfg.PreserveDocComments, flagCfg.RespectEditorConfig,
+		flagCfg.CountTokens, flagCfg.UseEstimatedTokens, flagCfg.Encoding, flagCfg.MaxTokensPerFile,
+		flagCfg.TokenDensity, flagCfg.TreeShowDensity, flagCfg.PathStyle,
+		flagCfg.ShowGitStatus, flagCfg.ShowContributors, flagCfg.MaxContributors,
+		flagCfg.ShowGitLog, flagCfg.GitLogMaxCommits, flagCfg.ShowBlame, flagCfg.BlameShort,
+	}
+	encoded, err := json.Marshal(scanOptions)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// scanDirectory scans a directory or archive and annotates the result with
+// git status, token counts and contributors as requested
+func scanDirectory(ctx context.Context, dirPath string, flagCfg flagConfig.FlagConfig) (*scanner.ScanResult, error) {
+	verboseLog(flagCfg, "Starting directory scan: %s", dirPath)
+	verboseLog(flagCfg, "Scan options - NoGitignore: %t, DisplayLineNum: %t", flagCfg.NoGitignore, flagCfg.DisplayLineNum)
+
+	// Report walk progress in verbose mode
+	var progress func(scanned, total int, currentPath string)
+	if flagCfg.Verbose {
+		progress = func(scanned, total int, currentPath string) {
+			if total < 0 {
+				verboseLog(flagCfg, "Scanned %d files: %s", scanned, currentPath)
+			}
+		}
+	}
 
 	// Scan the directory with options
-	scanResult, err := scanner.ScanDirectoryWithOptions(dirPath, scanner.ScanOptions{
-		NoGitignore:    flagCfg.NoGitignore,
-		DisplayLineNum: flagCfg.DisplayLineNum,
-	})
+	scanOptions := scanner.ScanOptions{
+		Context:             ctx,
+		NoGitignore:         flagCfg.NoGitignore,
+		NoR2cignore:         flagCfg.NoR2cignore,
+		DisplayLineNum:      flagCfg.DisplayLineNum,
+		LineNumberStyle:     flagCfg.LineNumberStyle,
+		LineEnding:          flagCfg.LineEnding,
+		IncludeLanguages:    flagCfg.IncludeLanguages,
+		ExcludeLanguages:    flagCfg.ExcludeLanguages,
+		NoContent:           flagCfg.NoContent,
+		MaxErrors:           flagCfg.MaxErrors,
+		ProgressCallback:    progress,
+		AbortOnError:        flagCfg.AbortOnError,
+		Checksum:            flagCfg.Checksum,
+		IncludeVendor:       flagCfg.IncludeVendor,
+		IncludeNodeModules:  flagCfg.IncludeNodeModules,
+		IncludeGenerated:    flagCfg.IncludeGenerated,
+		Filters:             scanFilters(flagCfg),
+		PruneEmptyDirs:      flagCfg.PruneEmptyDirs,
+		ExcludePaths:        flagCfg.ExcludePaths,
+		StripComments:       flagCfg.StripComments,
+		PreserveDocComments: flagCfg.PreserveDocComments,
+		RespectEditorConfig: flagCfg.RespectEditorConfig,
+		FollowSymlinks:      flagCfg.FollowSymlinks,
+		RetryCount:          flagCfg.Retry,
+		RetryDelay:          flagCfg.RetryDelay,
+		MinFileSizeBytes:    flagCfg.MinFileSizeBytes,
+		// Token counting needs the content; otherwise only lines are counted
+		SummaryOnly: flagCfg.SummaryOnly && !flagCfg.CountTokens,
+	}
+	var scanResult *scanner.ScanResult
+	var err error
+	if scanner.IsArchive(dirPath) {
+		scanResult, err = scanner.ScanArchive(dirPath, scanOptions)
+	} else if flagCfg.CommitHash != "" {
+		verboseLog(flagCfg, "Scanning files at commit %s", flagCfg.CommitHash)
+		scanResult, err = scanner.ScanCommit(dirPath, flagCfg.CommitHash, scanOptions)
+	} else {
+		scanResult, err = scanner.ScanDirectoryWithOptions(dirPath, scanOptions)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to scan directory: %w", err)
+		return nil, fmt.Errorf("failed to scan directory: %w", err)
 	}
 
-	verboseLog(flagCfg.Verbose, "Directory scan completed - Found %d files, %d total lines", scanResult.TotalFiles, scanResult.TotalLines)
+	verboseLog(flagCfg, "Directory scan completed - Found %d files, %d total lines", scanResult.TotalFiles, scanResult.TotalLines)
+	for _, skipped := range scanResult.SkippedSmallFiles {
+		verboseLog(flagCfg, "Warning: skipped %s, smaller than --min-file-size (%d bytes)", skipped, flagCfg.MinFileSizeBytes)
+	}
 
 	// Print any errors to stderr
 	for _, errMsg := range scanResult.Errors {
 		fmt.Fprintf(os.Stderr, "Warning: %s\n", errMsg)
 	}
+	if flagCfg.OnlyErrors {
+		fmt.Fprintf(os.Stderr, "Found %d errors\n", len(scanResult.Errors))
+	}
+
+	// Mark changed files in the working tree
+	if flagCfg.ShowGitStatus && flagCfg.CommitHash == "" {
+		populateGitStatus(scanResult, flagCfg)
+		scanResult.DirectoryTree = scanner.RegenerateDirectoryTree(scanResult)
+	}
 
 	// Count tokens if flag is enabled
 	if flagCfg.CountTokens {
-		if err := countTokensInScanResult(scanResult, flagCfg.Verbose); err != nil {
+		if count, err := countTokens(scanResult, flagCfg); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: token counting failed: %v\n", err)
+		} else if flagCfg.MaxTokensPerFile > 0 {
+			truncateScanResult(scanResult, count, flagCfg)
+		}
+		if flagCfg.TokenDensity || flagCfg.TreeShowDensity {
+			scanner.ComputeTokenDensity(scanResult)
+			scanResult.TreeShowDensity = flagCfg.TreeShowDensity
 		}
 		// Regenerate directory tree with token counts
 		scanResult.DirectoryTree = scanner.RegenerateDirectoryTree(scanResult)
 	}
 
-	verboseLog(flagCfg.Verbose, "Creating context data for formatting")
+	// Show tree paths in the requested style
+	if flagCfg.PathStyle != "" && flagCfg.PathStyle != scanner.PathStyleRelative {
+		scanResult.DirectoryTree = scanner.RegenerateDirectoryTreeWithStyle(scanResult, flagCfg.PathStyle)
+	}
+
+	if flagCfg.ShowContributors {
+		populateContributors(scanResult, flagCfg)
+	}
+	if flagCfg.ShowGitLog {
+		populateGitLog(scanResult, flagCfg)
+	}
+	if flagCfg.ShowBlame || flagCfg.BlameShort {
+		populateBlame(scanResult, flagCfg)
+	}
+	return scanResult, nil
+}
+
+// writeDirectoryOutput formats and writes the context of a directory scan,
+// taking git info from gitPath
+func writeDirectoryOutput(scanResult *scanner.ScanResult, gitPath string, input *stdinInput, flagCfg flagConfig.FlagConfig) error {
+	// Reorder file sections so files of the same language are adjacent
+	if flagCfg.GroupByExtension {
+		verboseLog(flagCfg, "Grouping files by language")
+		scanResult.Files = flattenGroups(groupFilesByExtension(scanResult.Files), scanResult.Files)
+	}
+
+	// Put piped stdin content ahead of the scanned files
+	prependStdinFile(scanResult, input.take(), flagCfg)
+	sanitizePaths(scanResult, flagCfg)
+
+	verboseLog(flagCfg, "Creating context data for formatting")
 	// Create context data
-	contextData, err := formatter.NewContextData(scanResult, dirPath)
+	contextData, err := newContextData(scanResult, gitPath, flagCfg)
 	if err != nil {
-		return fmt.Errorf("failed to create context data: %w", err)
+		return err
+	}
+	contextData.Options = formatOptions(flagCfg)
+	omitSections(contextData, flagCfg)
+	if flagCfg.CommitHash != "" {
+		applyCommitGitInfo(contextData, flagCfg.CommitHash)
+	}
+	if flagCfg.IncludeGitConfig {
+		appendGitUser(contextData, flagCfg)
 	}
 
-	// Handle output - either to file or stdout
-	if flagCfg.OutputFile != "" {
-		verboseLog(flagCfg.Verbose, "Saving output to file: %s", flagCfg.OutputFile)
-		// Save to file
-		err = formatter.SaveToFile(contextData, flagCfg.OutputFile)
-		if err != nil {
-			return fmt.Errorf("failed to save to file: %w", err)
-		}
-		fmt.Fprintf(os.Stderr, "Output saved to: %s\n", flagCfg.OutputFile)
-		verboseLog(flagCfg.Verbose, "File saved successfully")
-	} else {
-		verboseLog(flagCfg.Verbose, "Formatting output for stdout")
-		// Format and output to stdout
-		output, err := formatter.Format(contextData)
-		if err != nil {
-			return fmt.Errorf("failed to format output: %w", err)
-		}
-		verboseLog(flagCfg.Verbose, "Output formatted, writing to stdout")
-		fmt.Print(output)
+	if err := writeOutput(contextData, flagCfg); err != nil {
+		return err
 	}
 
+	// Fail after writing output so the errors can still be inspected
+	if flagCfg.FailOnErrors && len(scanResult.Errors) > 0 {
+		return fmt.Errorf("%w: %d error(s) in %s", ErrScanErrors, len(scanResult.Errors), scanResult.RootPath)
+	}
 	return nil
 }
 
+// readStdinFile reads all of stdin into a synthetic file named label ("(stdin)" by default)
+// Returns nil when stdin is a terminal, since nothing was piped in
+func readStdinFile(stdin io.Reader, label string) (*scanner.FileInfo, error) {
+	if file, ok := stdin.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return nil, nil
+		}
+	}
+
+	content, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+
+	if label == "" {
+		label = "(stdin)"
+	}
+	return &scanner.FileInfo{
+		Path:         label,
+		RelativePath: label,
+		Size:         int64(len(content)),
+		Content:      string(content),
+		Language:     "text",
+		Lines:        countLines(string(content)),
+	}, nil
+}
+
+// countLines counts the lines of content, including a last line without a newline
 func countLines(content string) int {
 	lines := 0
 	if content != "" {
@@ -202,96 +866,677 @@ func countLines(content string) int {
 	return lines
 }
 
-// processFile handles individual file output
-func processFile(filePath string, flagCfg flagConfig.FlagConfig) error {
-	// For individual files, treat the parent directory as the root
-	parentDir := filepath.Dir(filePath)
+// prependStdinFile adds the stdin file, if any, to the front of the scan result
+// It is not listed in the directory tree, but counts toward the summary totals
+func prependStdinFile(scanResult *scanner.ScanResult, stdinFile *scanner.FileInfo, flagCfg flagConfig.FlagConfig) {
+	if stdinFile == nil {
+		return
+	}
+	file := *stdinFile
 
-	// Read the file content
-	content, err := scanner.Peek(filePath, flagCfg.DisplayLineNum)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+	// Count the stdin tokens on their own so the directory tree is left untouched
+	if flagCfg.CountTokens {
+		stdinResult := &scanner.ScanResult{Files: []scanner.FileInfo{file}}
+		if _, err := countTokens(stdinResult, flagCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: token counting failed for stdin: %v\n", err)
+		}
+		file = stdinResult.Files[0]
+		scanResult.TotalTokens += file.TokenCount
 	}
 
-	// Get file info
-	stat, err := os.Stat(filePath)
+	scanResult.Files = append([]scanner.FileInfo{file}, scanResult.Files...)
+	scanResult.TotalFiles++
+	scanResult.TotalLines += file.Lines
+	scanResult.TotalSize += file.Size
+}
+
+// sanitizePaths hides the filesystem location of the scan with --sanitize-paths
+// Git info is read from the unsanitized path, so it is unaffected
+func sanitizePaths(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) {
+	if !flagCfg.SanitizePaths {
+		return
+	}
+	homeDir, _ := os.UserHomeDir()
+	sanitizer.SanitizeScanResult(scanResult, homeDir)
+	verboseLog(flagCfg, "Sanitized paths under %s", scanResult.RootPath)
+}
+
+// newContextData creates the context data for a scan result, reporting git
+// failures other than a missing repository in verbose mode
+func newContextData(scanResult *scanner.ScanResult, gitPath string, flagCfg flagConfig.FlagConfig) (*formatter.ContextData, error) {
+	contextData, err := formatter.NewContextData(scanResult, gitPath)
 	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
+		return nil, fmt.Errorf("failed to create context data: %w", err)
 	}
+	if contextData.GitInfoErr != nil {
+		verboseLog(flagCfg, "Failed to read git info: %v", contextData.GitInfoErr)
+	}
+	return contextData, nil
+}
 
-	// Create a simple scan result for this single file
+// applyCommitGitInfo replaces the git info of HEAD with that of the scanned commit
+func applyCommitGitInfo(contextData *formatter.ContextData, commit string) {
+	if info, err := gitinfo.GetGitInfoAtCommit(contextData.GitPath, commit); err == nil {
+		contextData.GitInfo = info
+	}
+}
 
-	// Since this variable is calculated from the parent directory
-	// and the file path, it basically is obtaining the file name
-	// Keep it here for possible future enhancement
-	relPath, _ := filepath.Rel(parentDir, filePath)
+// appendGitUser adds the git user configured for the repository to the git info
+// Nothing is added outside a repository or when no user is configured
+func appendGitUser(contextData *formatter.ContextData, flagCfg flagConfig.FlagConfig) {
+	if contextData.GitInfo == "" || contextData.GitInfoErr != nil {
+		return
+	}
+	root := contextData.GitPath
+	name, err := gitinfo.GetGitConfig(root, "user.name")
+	if err != nil {
+		verboseLog(flagCfg, "Failed to read git user: %v", err)
+		return
+	}
+	email, err := gitinfo.GetGitConfig(root, "user.email")
+	if err != nil {
+		verboseLog(flagCfg, "Failed to read git user: %v", err)
+		return
+	}
 
-	// Count lines in content
-	lines := countLines(content)
+	user := strings.TrimSpace(name)
+	if email != "" {
+		user = strings.TrimSpace(fmt.Sprintf("%s <%s>", user, email))
+	}
+	if user != "" {
+		contextData.GitInfo += "\nUser  : " + user
+	}
+}
 
-	// For single files, show the full path structure from current directory
-	cwd, _ := os.Getwd()
-	displayPath, _ := filepath.Rel(cwd, filePath)
-	if displayPath == "" || filepath.IsAbs(displayPath) {
-		// If we can't get a relative path, use the filename
-		displayPath = relPath
-	}
-
-	// Construct the scan result
-	scanResult := &scanner.ScanResult{
-		RootPath: parentDir,
-		Files: []scanner.FileInfo{
-			{
-				Path:         filePath,
-				RelativePath: relPath,
-				IsDir:        false,
-				Size:         stat.Size(),
-				Content:      content,
-				ModTime:      stat.ModTime(),
-				Error:        nil,
-			},
-		},
-		DirectoryTree: displayPath,
-		TotalFiles:    1,
-		TotalLines:    lines,
-		Errors:        []string{},
+// scanFilters builds the scanner filters enabled by flags
+func scanFilters(flagCfg flagConfig.FlagConfig) []scanner.FileFilter {
+	var filters []scanner.FileFilter
+	if len(flagCfg.ExcludePatterns) > 0 {
+		filters = append(filters, scanner.GlobExcludeFilter{Patterns: flagCfg.ExcludePatterns})
+	}
+	if flagCfg.SkipLockFiles && !flagCfg.IncludeLockFiles {
+		filters = append(filters, scanner.LockFileFilter{Extra: flagCfg.SkipLockFilesExtra})
+	}
+	if flagCfg.SkipGenerated {
+		filters = append(filters, scanner.GeneratedFileFilter{})
+	}
+	if flagCfg.ExcludeTestFiles {
+		filters = append(filters, scanner.TestFileFilter{Patterns: flagCfg.TestFilePatterns})
 	}
+	// Files named with --include are kept even if a filter above excludes them
+	if len(flagCfg.IncludePatterns) > 0 && len(filters) > 0 {
+		filters = []scanner.FileFilter{scanner.IncludeOverrideFilter{Include: flagCfg.IncludePatterns, Filters: filters}}
+	}
+	return filters
+}
 
-	// Count tokens if flag is enabled
-	if flagCfg.CountTokens {
-		if err := countTokensInScanResult(scanResult, flagCfg.Verbose); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: token counting failed: %v\n", err)
+// populateContributors fills in the git authors of each file, keeping at most
+// --max-contributors, from a single read of the git history
+// Files outside a git repository are left without contributors
+func populateContributors(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) {
+	verboseLog(flagCfg, "Collecting contributors from git history")
+	allContributors, err := gitinfo.GetAllContributors(scanResult.RootPath)
+	if err != nil {
+		verboseLog(flagCfg, "Warning: failed to get contributors: %v", err)
+		return
+	}
+
+	for i := range scanResult.Files {
+		file := &scanResult.Files[i]
+		if file.IsDir || file.Error != nil || file.RelativePath == "" {
+			continue
 		}
-		// Regenerate directory tree with token counts
-		scanResult.DirectoryTree = scanner.RegenerateDirectoryTree(scanResult)
+
+		contributors := allContributors[filepath.ToSlash(file.RelativePath)]
+		if flagCfg.MaxContributors > 0 && len(contributors) > flagCfg.MaxContributors {
+			contributors = contributors[:flagCfg.MaxContributors]
+		}
+		file.Contributors = contributors
 	}
+}
 
-	// Create context data
-	contextData, err := formatter.NewContextData(scanResult, parentDir)
+// populateGitLog fills in the recent commits of each file, keeping at most
+// --git-log-commits, from a single read of the git history
+func populateGitLog(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) {
+	verboseLog(flagCfg, "Collecting recent commits from git history")
+	logs, err := gitinfo.GetGitLogs(scanResult.RootPath, flagCfg.GitLogMaxCommits)
 	if err != nil {
-		return fmt.Errorf("failed to create context data: %w", err)
+		verboseLog(flagCfg, "Warning: failed to get git log: %v", err)
+		return
 	}
 
-	// Handle output - either to file or stdout
-	if flagCfg.OutputFile != "" {
-		verboseLog(flagCfg.Verbose, "Saving output to file: %s", flagCfg.OutputFile)
-		// Save to file
-		err = formatter.SaveToFile(contextData, flagCfg.OutputFile)
+	for i := range scanResult.Files {
+		file := &scanResult.Files[i]
+		if file.IsDir || file.RelativePath == "" {
+			continue
+		}
+		file.RecentCommits = logs[filepath.ToSlash(file.RelativePath)]
+	}
+}
+
+// populateBlame fills in the blame of each file whose content is the working
+// tree file unchanged; blame of transformed content (comments stripped, cut to
+// a token limit, read at another commit) would not match it line for line
+// git blame has no multi-file form, so it runs one git process per file
+func populateBlame(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) {
+	if flagCfg.StripComments || flagCfg.CommitHash != "" {
+		verboseLog(flagCfg, "Warning: blame is not shown for content read at a commit or with comments stripped")
+		return
+	}
+
+	verboseLog(flagCfg, "Collecting blame from git history")
+	for i := range scanResult.Files {
+		file := &scanResult.Files[i]
+		if file.IsDir || file.Error != nil || file.TruncatedFrom > 0 {
+			continue
+		}
+
+		blame, err := gitinfo.GetGitBlame(scanResult.RootPath, file.Path)
 		if err != nil {
+			verboseLog(flagCfg, "Warning: failed to get blame for %s: %v", file.RelativePath, err)
+			continue
+		}
+		file.Blame = blame
+	}
+}
+
+// populateGitStatus sets the git status of each changed or untracked file
+func populateGitStatus(scanResult *scanner.ScanResult, flagCfg flagConfig.FlagConfig) {
+	verboseLog(flagCfg, "Collecting git status")
+	gitRoot, err := gitinfo.GetGitRoot(scanResult.RootPath)
+	if err != nil {
+		verboseLog(flagCfg, "Warning: %s is not in a git repository", scanResult.RootPath)
+		return
+	}
+	status, err := gitinfo.GetGitStatus(gitRoot)
+	if err != nil {
+		verboseLog(flagCfg, "Warning: failed to get git status: %v", err)
+		return
+	}
+
+	// Status paths are relative to the repository root, file paths to the scan root
+	root := scanResult.RootPath
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	prefix, err := filepath.Rel(gitRoot, root)
+	if err != nil {
+		verboseLog(flagCfg, "Warning: failed to get path relative to repository: %v", err)
+		return
+	}
+	for i := range scanResult.Files {
+		file := &scanResult.Files[i]
+		if file.IsDir || file.RelativePath == "" {
+			continue
+		}
+		file.GitStatus = status[filepath.ToSlash(filepath.Join(prefix, file.RelativePath))]
+	}
+}
+
+// groupFilesByExtension partitions files by language, ordered by language name
+// Directories are not included in any group
+func groupFilesByExtension(files []scanner.FileInfo) [][]scanner.FileInfo {
+	groupMap := make(map[string][]scanner.FileInfo)
+	for _, file := range files {
+		if file.IsDir {
+			continue
+		}
+		language := file.Language
+		if language == "" {
+			language = languages.Detect(file.Path)
+		}
+		groupMap[language] = append(groupMap[language], file)
+	}
+
+	languages := make([]string, 0, len(groupMap))
+	for language := range groupMap {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	groups := make([][]scanner.FileInfo, 0, len(languages))
+	for _, language := range languages {
+		groups = append(groups, groupMap[language])
+	}
+	return groups
+}
+
+// flattenGroups joins grouped files back into one slice, keeping the
+// directories from the original file list at the end
+func flattenGroups(groups [][]scanner.FileInfo, original []scanner.FileInfo) []scanner.FileInfo {
+	files := make([]scanner.FileInfo, 0, len(original))
+	for _, group := range groups {
+		files = append(files, group...)
+	}
+	for _, file := range original {
+		if file.IsDir {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// contextWindow returns the context window to measure the output against:
+// --context-window if set, otherwise the window of --model (0 when unknown)
+func contextWindow(flagCfg flagConfig.FlagConfig) int {
+	if flagCfg.ContextWindow > 0 {
+		return flagCfg.ContextWindow
+	}
+	return formatter.ModelContextWindow(flagCfg.Model)
+}
+
+// contextUsageWarning is the share of the context window above which a warning is printed
+const contextUsageWarning = 90
+
+// warnContextUsage warns on stderr when the counted tokens take more than
+// contextUsageWarning percent of the context window
+func warnContextUsage(tokens, window int) {
+	if tokens == 0 || window == 0 {
+		return
+	}
+	if usage := formatter.ContextUsage(tokens, window); usage > contextUsageWarning {
+		fmt.Fprintf(os.Stderr, "Warning: output uses %.1f%% of the %d token context window\n", usage, window)
+	}
+}
+
+// formatOptions builds formatter options from the CLI flags
+func formatOptions(flagCfg flagConfig.FlagConfig) formatter.FormatOptions {
+	return formatter.FormatOptions{
+		NoContent:          flagCfg.NoContent,
+		ShowGitLog:         flagCfg.ShowGitLog,
+		GroupByLanguage:    flagCfg.GroupByExtension,
+		Checksum:           flagCfg.Checksum,
+		ShowContributors:   flagCfg.ShowContributors,
+		ShowBlame:          flagCfg.ShowBlame || flagCfg.BlameShort,
+		BlameShort:         flagCfg.BlameShort,
+		PathStyle:          flagCfg.PathStyle,
+		TreeStyle:          flagCfg.TreeStyle,
+		FileHeaderTemplate: flagCfg.FileHeaderTemplate,
+		OnlyErrors:         flagCfg.OnlyErrors,
+		SummaryOnly:        flagCfg.SummaryOnly,
+		TokenDensity:       flagCfg.TokenDensity,
+		CollapsibleFiles:   flagCfg.CollapsibleFiles,
+		NoCollapse:         flagCfg.NoCollapse,
+		ContextWindow:      contextWindow(flagCfg),
+		RepoName:           flagCfg.RepoName,
+		RepoDescription:    flagCfg.RepoDescription,
+	}
+}
+
+// omitSections drops the metadata sections disabled by --no-summary,
+// --no-git-info and --no-structure
+func omitSections(contextData *formatter.ContextData, flagCfg flagConfig.FlagConfig) {
+	contextData.NoSummary = flagCfg.NoSummary
+	contextData.NoGitInfo = flagCfg.NoGitInfo
+	contextData.NoStructure = flagCfg.NoStructure
+}
+
+// renderOutput formats context data with the custom template if one is set,
+// otherwise with the formatter selected by --format
+func renderOutput(contextData *formatter.ContextData, flagCfg flagConfig.FlagConfig) (string, error) {
+	// The error report and the summary are always markdown
+	if flagCfg.OnlyErrors || flagCfg.SummaryOnly {
+		return formatter.Format(contextData)
+	}
+
+	if flagCfg.TemplatePath != "" {
+		verboseLog(flagCfg, "Formatting output with template: %s", flagCfg.TemplatePath)
+		return formatter.FormatWithTemplate(contextData, flagCfg.TemplatePath)
+	}
+
+	// A forced prompt format wins over the one chosen by --model
+	if flagCfg.FormatOverride != "" {
+		return formatter.FormatModelFormat(contextData, flagCfg.FormatOverride)
+	}
+	if flagCfg.Model != "" {
+		return formatter.FormatContextForModel(contextData, flagCfg.Model)
+	}
+
+	switch flagCfg.OutputFormat {
+	case formatter.JSONLinesFormat:
+		return formatter.FormatJSONLines(contextData)
+	case formatter.RSTFormat:
+		return formatter.FormatRST(contextData)
+	default:
+		return formatter.Format(contextData)
+	}
+}
+
+// rendersRST reports whether renderOutput uses the RST formatter of --format rst
+func rendersRST(flagCfg flagConfig.FlagConfig) bool {
+	if flagCfg.OnlyErrors || flagCfg.SummaryOnly || flagCfg.TemplatePath != "" || flagCfg.FormatOverride != "" || flagCfg.Model != "" {
+		return false
+	}
+	return flagCfg.OutputFormat == formatter.RSTFormat
+}
+
+// streamsMarkdown reports whether the output is markdown rendered by
+// formatter.WriteTo, which can be written out piece by piece instead of as one
+// string. Templates, prompt formats and other output formats need the whole string.
+func streamsMarkdown(flagCfg flagConfig.FlagConfig) bool {
+	if flagCfg.OnlyErrors || flagCfg.SummaryOnly {
+		return true
+	}
+	markdown := flagCfg.OutputFormat == "" || flagCfg.OutputFormat == formatter.MarkdownFormat
+	return markdown && flagCfg.TemplatePath == "" && flagCfg.FormatOverride == "" && flagCfg.Model == ""
+}
+
+// streamsUTF8 reports whether output files are written as plain UTF-8, the
+// only encoding formatter.StreamToFile writes
+func streamsUTF8(flagCfg flagConfig.FlagConfig) bool {
+	return flagCfg.OutputEncoding == "" || flagCfg.OutputEncoding == formatter.OutputEncodingUTF8
+}
+
+// streamsOutput reports whether the output goes to --output or stdout as it
+// is rendered: streamable markdown with no copy to the clipboard or GitHub
+// Actions outputs, and for a file, UTF-8 replacing it
+func streamsOutput(flagCfg flagConfig.FlagConfig) bool {
+	if flagCfg.Clipboard || flagCfg.GitHubActions || !streamsMarkdown(flagCfg) {
+		return false
+	}
+	if flagCfg.OutputFile == "" {
+		return true
+	}
+	if flagCfg.OutputMode != "" && flagCfg.OutputMode != formatter.OutputModeOverwrite {
+		return false
+	}
+	return streamsUTF8(flagCfg)
+}
+
+// writeMarkdown writes the markdown context to w between the --prefix and --suffix delimiters
+func writeMarkdown(w io.Writer, contextData *formatter.ContextData, flagCfg flagConfig.FlagConfig) error {
+	return formatter.WriteWrapped(w, flagCfg.Prefix, flagCfg.Suffix, func(w io.Writer) error {
+		return formatter.WriteTo(contextData, w)
+	})
+}
+
+// writeDocument writes the context to path in the output encoding,
+// streaming markdown rather than rendering it as one string first
+func writeDocument(contextData *formatter.ContextData, path string, flagCfg flagConfig.FlagConfig) error {
+	if streamsMarkdown(flagCfg) && streamsUTF8(flagCfg) {
+		return formatter.StreamToFile(path, func(w io.Writer) error {
+			return writeMarkdown(w, contextData, flagCfg)
+		})
+	}
+
+	output, err := renderOutput(contextData, flagCfg)
+	if err != nil {
+		return fmt.Errorf("failed to format output: %w", err)
+	}
+	return formatter.WriteFileWithEncoding(formatter.Wrap(output, flagCfg.Prefix, flagCfg.Suffix), path, flagCfg.OutputEncoding)
+}
+
+// writeOutput handles output - either to file or stdout
+func writeOutput(contextData *formatter.ContextData, flagCfg flagConfig.FlagConfig) error {
+	warnContextUsage(contextData.ScanResult.TotalTokens, contextData.Options.ContextWindow)
+
+	// Write one document per file when requested
+	if flagCfg.SplitOutput {
+		if err := writePerFileOutput(contextData, flagCfg); err != nil {
+			return err
+		}
+		reportGitHubActions(contextData, "", flagCfg.OutputDir, flagCfg)
+		return nil
+	}
+
+	// Split into several documents when the context exceeds the token budget
+	if flagCfg.TokenLimit > 0 {
+		chunks := SplitByTokenBudget(contextData.ScanResult.Files, flagCfg.TokenLimit)
+		if len(chunks) > 1 {
+			if err := writeSplitOutput(contextData, chunks, flagCfg); err != nil {
+				return err
+			}
+			reportGitHubActions(contextData, "", flagCfg.OutputFile, flagCfg)
+			return nil
+		}
+	}
+
+	// Stream markdown straight to the output file or stdout when nothing needs it as one string
+	if streamsOutput(flagCfg) {
+		if flagCfg.OutputFile == "" {
+			verboseLog(flagCfg, "Streaming output to stdout")
+			stdout := bufio.NewWriter(os.Stdout)
+			if err := writeMarkdown(stdout, contextData, flagCfg); err != nil {
+				return fmt.Errorf("failed to write output: %w", err)
+			}
+			return stdout.Flush()
+		}
+
+		verboseLog(flagCfg, "Streaming output to file: %s", flagCfg.OutputFile)
+		if err := writeDocument(contextData, flagCfg.OutputFile, flagCfg); err != nil {
 			return fmt.Errorf("failed to save to file: %w", err)
 		}
 		fmt.Fprintf(os.Stderr, "Output saved to: %s\n", flagCfg.OutputFile)
-		verboseLog(flagCfg.Verbose, "File saved successfully")
-	} else {
-		verboseLog(flagCfg.Verbose, "Formatting output for stdout")
-		// Format and output to stdout
-		output, err := formatter.Format(contextData)
+		verboseLog(flagCfg, "File saved successfully")
+		return nil
+	}
+
+	verboseLog(flagCfg, "Formatting output")
+	output, err := renderOutput(contextData, flagCfg)
+	if err != nil {
+		return fmt.Errorf("failed to format output: %w", err)
+	}
+	output = formatter.Wrap(output, flagCfg.Prefix, flagCfg.Suffix)
+
+	// Copy to the clipboard, falling back to stdout when no clipboard command works
+	copied := false
+	outputFile := ""
+	if flagCfg.Clipboard {
+		if err := clipboard.Write(output); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not copy to clipboard: %v\n", err)
+		} else {
+			copied = true
+			if tokens := contextData.ScanResult.TotalTokens; tokens > 0 {
+				fmt.Fprintf(os.Stderr, "Copied %d tokens to clipboard\n", tokens)
+			} else {
+				fmt.Fprintf(os.Stderr, "Copied %d bytes to clipboard\n", len(output))
+			}
+		}
+	}
+
+	if flagCfg.OutputFile != "" {
+		verboseLog(flagCfg, "Saving output to file: %s", flagCfg.OutputFile)
+		// Save to file, replacing, appending to or versioning it per --output-mode
+		savedPath, err := formatter.WriteFileWithMode(output, flagCfg.OutputFile, flagCfg.OutputEncoding, flagCfg.OutputMode, flagCfg.OutputVersionFormat)
 		if err != nil {
-			return fmt.Errorf("failed to format output: %w", err)
+			return fmt.Errorf("failed to save to file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Output saved to: %s\n", savedPath)
+		verboseLog(flagCfg, "File saved successfully")
+		outputFile = savedPath
+		if flagCfg.OutputMode == formatter.OutputModeVersion {
+			warnManyVersions(flagCfg.OutputFile, flagCfg.OutputVersionFormat)
 		}
-		verboseLog(flagCfg.Verbose, "Output formatted, writing to stdout")
+	} else if !copied {
+		verboseLog(flagCfg, "Output formatted, writing to stdout")
 		fmt.Print(output)
 	}
 
+	reportGitHubActions(contextData, output, outputFile, flagCfg)
 	return nil
 }
+
+// reportGitHubActions sets the GitHub Actions step outputs and summary of a
+// written context with --github-actions. content is empty when the context
+// was written as several documents.
+func reportGitHubActions(contextData *formatter.ContextData, content, outputFile string, flagCfg flagConfig.FlagConfig) {
+	if !flagCfg.GitHubActions {
+		return
+	}
+	scanResult := contextData.ScanResult
+	err := ci.WriteGitHubActions(ci.Report{
+		Root:       scanResult.RootPath,
+		FileCount:  scanResult.TotalFiles,
+		LineCount:  scanResult.TotalLines,
+		Size:       scanResult.TotalSize,
+		TokenCount: scanResult.TotalTokens,
+		ErrorCount: len(scanResult.Errors),
+		OutputFile: outputFile,
+		Content:    content,
+	})
+	if errors.Is(err, ci.ErrNoGitHubOutput) {
+		fmt.Fprintf(os.Stderr, "Warning: --github-actions ignored: %v\n", err)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		verboseLog(flagCfg, "Set GitHub Actions outputs")
+	}
+}
+
+// maxVersionsBeforeWarning is how many versioned outputs accumulate before a warning
+const maxVersionsBeforeWarning = 10
+
+// warnManyVersions lists the versioned outputs of path once there are more
+// than maxVersionsBeforeWarning, so old context snapshots don't pile up unnoticed
+func warnManyVersions(path, layout string) {
+	versions, err := formatter.ListVersions(path, layout)
+	if err != nil || len(versions) <= maxVersionsBeforeWarning {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %d versioned outputs of %s exist:\n", len(versions), path)
+	for _, version := range versions {
+		fmt.Fprintf(os.Stderr, "  %s\n", version)
+	}
+}
+
+// processFile handles individual file output
+func processFile(filePath string, input *stdinInput, flagCfg flagConfig.FlagConfig) error {
+	// For individual files, treat the parent directory as the root
+	parentDir := filepath.Dir(filePath)
+
+	scanResult, err := scanFile(filePath, flagCfg)
+	if err != nil {
+		return err
+	}
+
+	// Put piped stdin content ahead of the file
+	prependStdinFile(scanResult, input.take(), flagCfg)
+	sanitizePaths(scanResult, flagCfg)
+
+	// Create context data
+	contextData, err := newContextData(scanResult, parentDir, flagCfg)
+	if err != nil {
+		return err
+	}
+	contextData.Options = formatOptions(flagCfg)
+	omitSections(contextData, flagCfg)
+	if flagCfg.CommitHash != "" {
+		applyCommitGitInfo(contextData, flagCfg.CommitHash)
+	}
+	if flagCfg.IncludeGitConfig {
+		appendGitUser(contextData, flagCfg)
+	}
+	contextData.IsSingleFile = len(scanResult.Files) == 1
+
+	return writeOutput(contextData, flagCfg)
+}
+
+// scanFile scans a single file and annotates the result with git status,
+// token counts and contributors as requested
+func scanFile(filePath string, flagCfg flagConfig.FlagConfig) (*scanner.ScanResult, error) {
+	var scanResult *scanner.ScanResult
+	var err error
+	if flagCfg.CommitHash != "" {
+		scanResult, err = scanFileAtCommit(filePath, flagCfg)
+	} else {
+		scanResult, err = scanSingleFile(filePath, flagCfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if flagCfg.OnlyErrors {
+		fmt.Fprintf(os.Stderr, "Found %d errors\n", len(scanResult.Errors))
+	}
+
+	// Mark changed files in the working tree
+	if flagCfg.ShowGitStatus && flagCfg.CommitHash == "" {
+		populateGitStatus(scanResult, flagCfg)
+		scanResult.DirectoryTree = scanner.RegenerateDirectoryTree(scanResult)
+	}
+
+	// Count tokens if flag is enabled
+	if flagCfg.CountTokens {
+		if count, err := countTokens(scanResult, flagCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: token counting failed: %v\n", err)
+		} else if flagCfg.MaxTokensPerFile > 0 {
+			truncateScanResult(scanResult, count, flagCfg)
+		}
+		if flagCfg.TokenDensity || flagCfg.TreeShowDensity {
+			scanner.ComputeTokenDensity(scanResult)
+			scanResult.TreeShowDensity = flagCfg.TreeShowDensity
+		}
+		// Regenerate directory tree with token counts
+		scanResult.DirectoryTree = scanner.RegenerateDirectoryTree(scanResult)
+	}
+
+	// Show tree paths in the requested style
+	if flagCfg.PathStyle != "" && flagCfg.PathStyle != scanner.PathStyleRelative {
+		scanResult.DirectoryTree = scanner.RegenerateDirectoryTreeWithStyle(scanResult, flagCfg.PathStyle)
+	}
+
+	if flagCfg.ShowContributors {
+		populateContributors(scanResult, flagCfg)
+	}
+	if flagCfg.ShowGitLog {
+		populateGitLog(scanResult, flagCfg)
+	}
+	if flagCfg.ShowBlame || flagCfg.BlameShort {
+		populateBlame(scanResult, flagCfg)
+	}
+	return scanResult, nil
+}
+
+// scanSingleFile builds a scan result holding one file from the working tree
+func scanSingleFile(filePath string, flagCfg flagConfig.FlagConfig) (*scanner.ScanResult, error) {
+	scanResult, err := scanner.ScanFile(filePath, scanner.ScanOptions{
+		DisplayLineNum:      flagCfg.DisplayLineNum,
+		LineNumberStyle:     flagCfg.LineNumberStyle,
+		LineEnding:          flagCfg.LineEnding,
+		NoContent:           flagCfg.NoContent,
+		Checksum:            flagCfg.Checksum,
+		StripComments:       flagCfg.StripComments,
+		PreserveDocComments: flagCfg.PreserveDocComments,
+		RespectEditorConfig: flagCfg.RespectEditorConfig,
+		SummaryOnly:         flagCfg.SummaryOnly && !flagCfg.CountTokens,
+		RetryCount:          flagCfg.Retry,
+		RetryDelay:          flagCfg.RetryDelay,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// For single files, show the full path structure from current directory
+	cwd, _ := os.Getwd()
+	displayPath, _ := filepath.Rel(cwd, filePath)
+	if displayPath == "" || filepath.IsAbs(displayPath) {
+		// If we can't get a relative path, use the filename
+		displayPath = scanResult.Files[0].RelativePath
+	}
+	scanResult.DirectoryTree = displayPath
+
+	return scanResult, nil
+}
+
+// scanFileAtCommit builds a scan result holding one file as it was at --commit-hash
+func scanFileAtCommit(filePath string, flagCfg flagConfig.FlagConfig) (*scanner.ScanResult, error) {
+	scanResult, err := scanner.ScanCommit(filepath.Dir(filePath), flagCfg.CommitHash, scanner.ScanOptions{
+		NoGitignore:         true,
+		NoR2cignore:         true,
+		DisplayLineNum:      flagCfg.DisplayLineNum,
+		LineNumberStyle:     flagCfg.LineNumberStyle,
+		LineEnding:          flagCfg.LineEnding,
+		NoContent:           flagCfg.NoContent,
+		AllowList:           []string{filepath.Base(filePath)},
+		Checksum:            flagCfg.Checksum,
+		StripComments:       flagCfg.StripComments,
+		PreserveDocComments: flagCfg.PreserveDocComments,
+		RespectEditorConfig: flagCfg.RespectEditorConfig,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file at commit: %w", err)
+	}
+	if len(scanResult.Files) != 1 || scanResult.Files[0].Error != nil {
+		return nil, fmt.Errorf("%s does not exist at commit %s", filePath, flagCfg.CommitHash)
+	}
+	return scanResult, nil
+}
@@ -2,13 +2,24 @@ package core
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/BHChen24/repo2context/pkg/flagConfig"
+	"github.com/BHChen24/repo2context/pkg/formatter"
+	"github.com/BHChen24/repo2context/pkg/gitinfo/mock"
 	"github.com/BHChen24/repo2context/pkg/scanner"
+	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
 )
 
 // Helper Functions
@@ -57,7 +68,7 @@ func createMockScanResult(files []scanner.FileInfo) *scanner.ScanResult {
 
 func TestVerboseLog_EnabledWritesToStderr(t *testing.T) {
 	output := captureStderr(func() {
-		verboseLog(true, "test message")
+		verboseLog(flagConfig.FlagConfig{Verbose: true}, "test message")
 	})
 
 	expected := "-> test message\n"
@@ -68,7 +79,7 @@ func TestVerboseLog_EnabledWritesToStderr(t *testing.T) {
 
 func TestVerboseLog_DisabledProducesNoOutput(t *testing.T) {
 	output := captureStderr(func() {
-		verboseLog(false, "should not appear")
+		verboseLog(flagConfig.FlagConfig{}, "should not appear")
 	})
 
 	if output != "" {
@@ -78,7 +89,7 @@ func TestVerboseLog_DisabledProducesNoOutput(t *testing.T) {
 
 func TestVerboseLog_FormatsMessageWithArgs(t *testing.T) {
 	output := captureStderr(func() {
-		verboseLog(true, "File %s has %d lines", "test.go", 42)
+		verboseLog(flagConfig.FlagConfig{Verbose: true}, "File %s has %d lines", "test.go", 42)
 	})
 
 	expected := "-> File test.go has 42 lines\n"
@@ -89,7 +100,7 @@ func TestVerboseLog_FormatsMessageWithArgs(t *testing.T) {
 
 func TestVerboseLog_EmptyMessage(t *testing.T) {
 	output := captureStderr(func() {
-		verboseLog(true, "")
+		verboseLog(flagConfig.FlagConfig{Verbose: true}, "")
 	})
 
 	expected := "-> \n"
@@ -146,7 +157,7 @@ func TestVerboseLog_TableDriven(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			output := captureStderr(func() {
-				verboseLog(tt.verbose, tt.message, tt.args...)
+				verboseLog(flagConfig.FlagConfig{Verbose: tt.verbose}, tt.message, tt.args...)
 			})
 
 			if output != tt.want {
@@ -156,6 +167,33 @@ func TestVerboseLog_TableDriven(t *testing.T) {
 	}
 }
 
+func TestVerboseLog_JSONFormat(t *testing.T) {
+	output := captureStderr(func() {
+		verboseLog(flagConfig.FlagConfig{Verbose: true, VerboseJSON: true}, "Processing %d files", 3)
+	})
+
+	var entry map[string]string
+	if err := json.Unmarshal([]byte(output), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", output, err)
+	}
+	if entry["level"] != "verbose" || entry["msg"] != "Processing 3 files" {
+		t.Errorf("Unexpected log entry: %v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, entry["ts"]); err != nil {
+		t.Errorf("Expected RFC3339Nano timestamp, got %q", entry["ts"])
+	}
+}
+
+func TestVerboseLog_JSONDisabledProducesNoOutput(t *testing.T) {
+	output := captureStderr(func() {
+		verboseLog(flagConfig.FlagConfig{VerboseJSON: true}, "should not appear")
+	})
+
+	if output != "" {
+		t.Errorf("Expected no stderr output when verbose=false, got %q", output)
+	}
+}
+
 // Tests for countTokensInScanResult
 
 func TestCountTokensInScanResult_ValidFiles(t *testing.T) {
@@ -178,7 +216,7 @@ func TestCountTokensInScanResult_ValidFiles(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -213,7 +251,7 @@ func TestCountTokensInScanResult_SkipsEmptyFiles(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -239,7 +277,7 @@ func TestCountTokensInScanResult_SkipsDirectories(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -265,7 +303,7 @@ func TestCountTokensInScanResult_SkipsFilesWithErrors(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -315,7 +353,7 @@ func TestCountTokensInScanResult_MixedScenario(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
@@ -340,7 +378,7 @@ func TestCountTokensInScanResult_MixedScenario(t *testing.T) {
 func TestCountTokensInScanResult_EmptyScanResult(t *testing.T) {
 	scanResult := createMockScanResult([]scanner.FileInfo{})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error for empty scan result, got %v", err)
 	}
@@ -363,7 +401,7 @@ func TestCountTokensInScanResult_LargeContent(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error for large file, got %v", err)
 	}
@@ -390,7 +428,7 @@ func TestCountTokensInScanResult_SpecialCharacters(t *testing.T) {
 		},
 	})
 
-	err := countTokensInScanResult(scanResult, false)
+	err := countTokensInScanResult(scanResult, "")
 	if err != nil {
 		t.Fatalf("Expected no error for special characters, got %v", err)
 	}
@@ -460,7 +498,7 @@ func TestCountTokensInScanResult_TableDriven(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			scanResult := createMockScanResult(tt.files)
-			err := countTokensInScanResult(scanResult, false)
+			err := countTokensInScanResult(scanResult, "")
 
 			if tt.expectError && err == nil {
 				t.Error("Expected error, got nil")
@@ -487,3 +525,1230 @@ func TestCountTokensInScanResult_TableDriven(t *testing.T) {
 		})
 	}
 }
+
+func TestCountTokensInScanResult_EncodingChangesCounts(t *testing.T) {
+	content := "你好，世界！这是一个用于比较不同编码的测试句子。"
+
+	o200k := createMockScanResult([]scanner.FileInfo{{Path: "/test/path/a.txt", RelativePath: "a.txt", Content: content}})
+	cl100k := createMockScanResult([]scanner.FileInfo{{Path: "/test/path/a.txt", RelativePath: "a.txt", Content: content}})
+
+	if err := countTokensInScanResult(o200k, "o200k_base"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := countTokensInScanResult(cl100k, "cl100k_base"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if o200k.TotalTokens == cl100k.TotalTokens {
+		t.Errorf("Expected different token counts, both got %d", o200k.TotalTokens)
+	}
+	if cl100k.TokenEncoding != "cl100k_base" {
+		t.Errorf("Expected TokenEncoding cl100k_base, got %q", cl100k.TokenEncoding)
+	}
+}
+
+func TestRun_InvalidEncodingFailsEarly(t *testing.T) {
+	err := Run(context.Background(), []string{"."}, flagConfig.FlagConfig{CountTokens: true, Encoding: "bogus"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported encoding") {
+		t.Fatalf("Expected unsupported encoding error, got %v", err)
+	}
+}
+
+// Tests for populateContributors
+
+func TestPopulateContributors_FromOneHistoryRead(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{
+		IsRepo: true,
+		Contributors: map[string][]string{
+			"main.go":     {"Alice", "Bob", "Carol"},
+			"sub/util.go": {"Bob"},
+		},
+	})
+	scanResult := createMockScanResult([]scanner.FileInfo{
+		{RelativePath: "sub", IsDir: true},
+		{RelativePath: "main.go"},
+		{RelativePath: filepath.Join("sub", "util.go")},
+		{RelativePath: "new.go"},
+	})
+
+	populateContributors(scanResult, flagConfig.FlagConfig{MaxContributors: 2})
+
+	expected := map[string]string{"main.go": "Alice,Bob", filepath.Join("sub", "util.go"): "Bob", "new.go": ""}
+	for _, file := range scanResult.Files[1:] {
+		if got := strings.Join(file.Contributors, ","); got != expected[file.RelativePath] {
+			t.Errorf("%s: expected contributors %q, got %q", file.RelativePath, expected[file.RelativePath], got)
+		}
+	}
+}
+
+// Tests for populateBlame
+
+func TestPopulateBlame_SkipsTransformedContent(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "main.go"},
+		{"-c", "user.name=Test User", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		flagCfg   flagConfig.FlagConfig
+		wantBlame bool
+	}{
+		{"unchanged content", flagConfig.FlagConfig{ShowBlame: true}, true},
+		{"strip comments", flagConfig.FlagConfig{ShowBlame: true, StripComments: true}, false},
+		{"commit", flagConfig.FlagConfig{ShowBlame: true, CommitHash: "HEAD"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanResult := &scanner.ScanResult{
+				RootPath: repo,
+				Files:    []scanner.FileInfo{{Path: filepath.Join(repo, "main.go"), RelativePath: "main.go", Content: "package main\n"}},
+			}
+			populateBlame(scanResult, tt.flagCfg)
+			if got := len(scanResult.Files[0].Blame) == 1; got != tt.wantBlame {
+				t.Errorf("Expected blame collected = %v, got %v", tt.wantBlame, scanResult.Files[0].Blame)
+			}
+		})
+	}
+}
+
+// Tests for groupFilesByExtension
+
+func TestGroupFilesByExtension_GroupsByLanguage(t *testing.T) {
+	files := []scanner.FileInfo{
+		{Path: "/test/path/a.go", RelativePath: "a.go", Language: "go"},
+		{Path: "/test/path/config.yaml", RelativePath: "config.yaml", Language: "yaml"},
+		{Path: "/test/path/sub", RelativePath: "sub", IsDir: true},
+		{Path: "/test/path/sub/b.go", RelativePath: "sub/b.go", Language: "go"},
+		{Path: "/test/path/data.json", RelativePath: "data.json"},
+	}
+
+	groups := groupFilesByExtension(files)
+
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(groups))
+	}
+
+	expected := [][]string{{"a.go", "sub/b.go"}, {"data.json"}, {"config.yaml"}}
+	for i, group := range groups {
+		if len(group) != len(expected[i]) {
+			t.Fatalf("Group %d: expected %d files, got %d", i, len(expected[i]), len(group))
+		}
+		for j, file := range group {
+			if file.RelativePath != expected[i][j] {
+				t.Errorf("Group %d file %d: expected %s, got %s", i, j, expected[i][j], file.RelativePath)
+			}
+		}
+	}
+
+	flattened := flattenGroups(groups, files)
+	if len(flattened) != len(files) {
+		t.Errorf("Expected flattened length %d, got %d", len(files), len(flattened))
+	}
+	if !flattened[len(flattened)-1].IsDir {
+		t.Error("Expected directories to be kept at the end")
+	}
+}
+
+// Tests for Run
+
+func TestRun_FailOnErrors(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	tempDir := t.TempDir()
+	if err := os.Symlink(filepath.Join(tempDir, "missing.txt"), filepath.Join(tempDir, "broken.txt")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	var err error
+	captureStderr(func() {
+		err = Run(context.Background(), []string{tempDir}, flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile})
+	})
+	if err != nil {
+		t.Fatalf("Expected no error without --fail-on-errors, got %v", err)
+	}
+
+	captureStderr(func() {
+		err = Run(context.Background(), []string{tempDir}, flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile, FailOnErrors: true})
+	})
+	if !errors.Is(err, ErrScanErrors) {
+		t.Fatalf("Expected ErrScanErrors, got %v", err)
+	}
+	if _, statErr := os.Stat(outputFile); statErr != nil {
+		t.Errorf("Expected output to be written before failing: %v", statErr)
+	}
+}
+
+func TestRun_TooManyPaths(t *testing.T) {
+	// The limit applies before the paths are checked
+	paths := []string{"missing-a", "missing-b", "missing-c"}
+
+	err := Run(context.Background(), paths, flagConfig.FlagConfig{MaxPaths: 2})
+	if err == nil || !strings.Contains(err.Error(), "too many paths specified (3). Maximum allowed: 2") {
+		t.Fatalf("Expected too many paths error, got %v", err)
+	}
+}
+
+func TestRun_MorePathsThanLegacyLimit(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	tempDir := t.TempDir()
+	var paths []string
+	for i := 0; i < legacyMaxPaths+1; i++ {
+		path := filepath.Join(tempDir, fmt.Sprintf("file%d.txt", i))
+		if err := os.WriteFile(path, []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	var err error
+	stderr := captureStderr(func() {
+		err = Run(context.Background(), paths, flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(stderr, "Note: 6 paths given. Versions before --max-paths rejected more than 5") {
+		t.Errorf("Expected a note about the old limit, got:\n%s", stderr)
+	}
+
+	// An explicit limit means the user knows about it
+	stderr = captureStderr(func() {
+		err = Run(context.Background(), paths, flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile, MaxPaths: 10})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(stderr, "Note:") {
+		t.Errorf("Expected no note with --max-paths, got:\n%s", stderr)
+	}
+}
+
+func TestRun_DefaultMaxPaths(t *testing.T) {
+	paths := make([]string, DefaultMaxPaths+1)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("missing-%d", i)
+	}
+
+	err := Run(context.Background(), paths, flagConfig.FlagConfig{})
+	if err == nil || !strings.Contains(err.Error(), "Maximum allowed: 10") {
+		t.Fatalf("Expected the default limit for a zero MaxPaths, got %v", err)
+	}
+
+	// -1 removes the limit, so the missing paths are reached
+	err = Run(context.Background(), paths, flagConfig.FlagConfig{MaxPaths: -1})
+	if err != nil && strings.Contains(err.Error(), "too many paths") {
+		t.Errorf("Expected no limit with -1, got %v", err)
+	}
+}
+
+func TestRun_CancelledContext(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Run(ctx, []string{t.TempDir()}, flagConfig.FlagConfig{NoGitignore: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRun_Timeout(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	err := Run(context.Background(), []string{t.TempDir()}, flagConfig.FlagConfig{NoGitignore: true, Timeout: time.Nanosecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "scan timed out after 1ns") {
+		t.Errorf("Expected timeout message, got %q", err.Error())
+	}
+}
+
+func TestExpandGlobs(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	defer os.Chdir(originalDir) //nolint:errcheck
+
+	var paths []string
+	stderr := captureStderr(func() {
+		paths = expandGlobs([]string{"*.go", "c.txt", "*.rs"})
+	})
+
+	expected := []string{"a.go", "b.go", "c.txt"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+	if !strings.Contains(stderr, "no files match '*.rs'") {
+		t.Errorf("Expected warning for unmatched pattern, got %q", stderr)
+	}
+}
+
+func TestSplitByTokenBudget_FirstFitDecreasing(t *testing.T) {
+	files := []scanner.FileInfo{
+		{RelativePath: "src", IsDir: true},
+		{RelativePath: "a.go", Content: "a\n", TokenCount: 30},
+		{RelativePath: "b.go", Content: "b\n", TokenCount: 60},
+		{RelativePath: "c.go", Content: "c\n", TokenCount: 40},
+		{RelativePath: "d.go", Content: "d\n", TokenCount: 70},
+	}
+
+	chunks := SplitByTokenBudget(files, 100)
+
+	// d(70) + a(30) and b(60) + c(40), in original order within each chunk
+	expected := [][]string{{"a.go", "d.go"}, {"b.go", "c.go"}}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i, chunk := range chunks {
+		var names []string
+		for _, file := range chunk {
+			names = append(names, file.RelativePath)
+		}
+		if strings.Join(names, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("Chunk %d: expected %v, got %v", i, expected[i], names)
+		}
+	}
+}
+
+func TestSplitByTokenBudget_SplitsOversizedFileAtLines(t *testing.T) {
+	content := strings.Repeat("0123456789\n", 10)
+	files := []scanner.FileInfo{
+		{RelativePath: "big.go", Content: content, TokenCount: 100},
+		{RelativePath: "small.go", Content: "x\n", TokenCount: 5},
+	}
+
+	chunks := SplitByTokenBudget(files, 40)
+
+	var rejoined strings.Builder
+	totalTokens := 0
+	for i, chunk := range chunks {
+		used := 0
+		for _, file := range chunk {
+			used += file.TokenCount
+			totalTokens += file.TokenCount
+			if file.RelativePath != "big.go" {
+				continue
+			}
+			if !strings.HasSuffix(file.Content, "\n") {
+				t.Errorf("Chunk %d: expected piece to end at a line boundary, got %q", i, file.Content)
+			}
+			rejoined.WriteString(file.Content)
+			if file.ContinuedInPart != 0 && file.ContinuedInPart != i+2 {
+				t.Errorf("Chunk %d: expected continuation in part %d, got %d", i, i+2, file.ContinuedInPart)
+			}
+		}
+		if used > 40 {
+			t.Errorf("Chunk %d exceeds budget: %d tokens", i, used)
+		}
+	}
+
+	if rejoined.String() != content {
+		t.Errorf("Expected pieces to rejoin into the original content")
+	}
+	if totalTokens != 105 {
+		t.Errorf("Expected 105 tokens across chunks, got %d", totalTokens)
+	}
+	if last := chunks[2][0]; last.ContinuedInPart != 0 {
+		t.Errorf("Expected last piece to have no continuation, got %d", last.ContinuedInPart)
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := map[string]int{
+		"":       0,
+		"a":      1,
+		"a\n":    1,
+		"a\nb":   2,
+		"a\nb\n": 2,
+		"\n\n":   2,
+	}
+	for content, expected := range tests {
+		if got := countLines(content); got != expected {
+			t.Errorf("countLines(%q): expected %d, got %d", content, expected, got)
+		}
+	}
+}
+
+func TestPartPath(t *testing.T) {
+	if got := partPath("out/context.md", 2); got != "out/context.part2.md" {
+		t.Errorf("Expected out/context.part2.md, got %s", got)
+	}
+	if got := partPath("context", 1); got != "context.part1" {
+		t.Errorf("Expected context.part1, got %s", got)
+	}
+}
+
+func TestRun_StdinContent(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	var err error
+	captureStderr(func() {
+		err = RunWithStdin(context.Background(), []string{tempDir}, flagConfig.FlagConfig{
+			NoGitignore:  true,
+			OutputFile:   outputFile,
+			StdinContent: true,
+			StdinLabel:   "test-output.txt",
+		}, strings.NewReader("FAIL: TestSomething\n"))
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	stdinIndex := strings.Index(string(output), "### File: test-output.txt")
+	mainIndex := strings.Index(string(output), "### File: main.go")
+	if stdinIndex == -1 || mainIndex == -1 || stdinIndex > mainIndex {
+		t.Errorf("Expected stdin section before main.go, got:\n%s", output)
+	}
+	if !strings.Contains(string(output), "FAIL: TestSomething") {
+		t.Errorf("Expected stdin content in output")
+	}
+	if !strings.Contains(string(output), "- Total files: 2\n") {
+		t.Errorf("Expected stdin to count toward total files, got:\n%s", output)
+	}
+}
+
+func TestRun_StdinContentWithMergedPaths(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	var err error
+	captureStderr(func() {
+		err = RunWithStdin(context.Background(), []string{filepath.Join(root, "a"), filepath.Join(root, "b")}, flagConfig.FlagConfig{
+			NoGitignore:  true,
+			OutputFile:   outputFile,
+			Merge:        true,
+			StdinContent: true,
+			StdinLabel:   "notes.txt",
+		}, strings.NewReader("piped notes\n"))
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !strings.Contains(string(output), "### File: notes.txt") || !strings.Contains(string(output), "piped notes") {
+		t.Errorf("Expected the stdin content in the merged output, got:\n%s", output)
+	}
+	if !strings.Contains(string(output), "- Total files: 3\n") {
+		t.Errorf("Expected stdin and both files in the totals, got:\n%s", output)
+	}
+}
+
+// Tests for streamsOutput
+
+func TestStreamsOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		flagCfg  flagConfig.FlagConfig
+		expected bool
+	}{
+		{"stdout", flagConfig.FlagConfig{}, true},
+		{"file", flagConfig.FlagConfig{OutputFile: "out.md"}, true},
+		{"summary to stdout", flagConfig.FlagConfig{SummaryOnly: true}, true},
+		{"clipboard", flagConfig.FlagConfig{Clipboard: true}, false},
+		{"github actions", flagConfig.FlagConfig{OutputFile: "out.md", GitHubActions: true}, false},
+		{"rst", flagConfig.FlagConfig{OutputFormat: formatter.RSTFormat}, false},
+		{"model", flagConfig.FlagConfig{Model: "gpt-4o"}, false},
+		{"append to file", flagConfig.FlagConfig{OutputFile: "out.md", OutputMode: formatter.OutputModeAppend}, false},
+		{"utf-16 file", flagConfig.FlagConfig{OutputFile: "out.md", OutputEncoding: formatter.OutputEncodingUTF16LE}, false},
+		{"utf-16 to stdout", flagConfig.FlagConfig{OutputEncoding: formatter.OutputEncodingUTF16LE}, true},
+	}
+
+	for _, tt := range tests {
+		if got := streamsOutput(tt.flagCfg); got != tt.expected {
+			t.Errorf("%s: streamsOutput() = %v, expected %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// Tests for --split-output
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		relPath  string
+		expected string
+	}{
+		{"main.go", "main.go"},
+		{filepath.Join("pkg", "core", "core.go"), "pkg_core_core.go"},
+		{".gitignore", "gitignore"},
+		{"my file$(rm).go", "myfilerm.go"},
+		{"(stdin)", "stdin"},
+		{"...", "file"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeFileName(tt.relPath); got != tt.expected {
+			t.Errorf("sanitizeFileName(%q) = %q, expected %q", tt.relPath, got, tt.expected)
+		}
+	}
+}
+
+func TestRun_SplitOutput(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	tempDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "sub", "util.go"), []byte("package sub\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	var err error
+	captureStderr(func() {
+		err = Run(context.Background(), []string{tempDir}, flagConfig.FlagConfig{
+			NoGitignore: true,
+			SplitOutput: true,
+			OutputDir:   outputDir,
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output, err := os.ReadFile(filepath.Join(outputDir, "sub_util.go.md"))
+	if err != nil {
+		t.Fatalf("Failed to read per-file output: %v", err)
+	}
+	if !strings.Contains(string(output), "## File System Location") || !strings.Contains(string(output), "package sub") {
+		t.Errorf("Expected full header and file content, got:\n%s", output)
+	}
+	if strings.Contains(string(output), "package main") {
+		t.Errorf("Expected only sub/util.go content, got:\n%s", output)
+	}
+
+	index, err := os.ReadFile(filepath.Join(outputDir, "_index.md"))
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	for _, name := range []string{"main.go.md", "sub_util.go.md"} {
+		if !strings.Contains(string(index), "["+name+"]") {
+			t.Errorf("Expected index to list %s, got:\n%s", name, index)
+		}
+	}
+}
+
+func TestRun_SplitOutputWithMultiplePaths(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	var paths []string
+	for _, name := range []string{"frontend", "backend"} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package "+name+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, dir)
+	}
+	outputDir := filepath.Join(t.TempDir(), "out")
+
+	var err error
+	captureStderr(func() {
+		err = Run(context.Background(), paths, flagConfig.FlagConfig{
+			NoGitignore: true,
+			SplitOutput: true,
+			OutputDir:   outputDir,
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"frontend", "backend"} {
+		output, err := os.ReadFile(filepath.Join(outputDir, name, "main.go.md"))
+		if err != nil {
+			t.Fatalf("Failed to read per-file output of %s: %v", name, err)
+		}
+		if !strings.Contains(string(output), "package "+name) {
+			t.Errorf("Expected %s content, got:\n%s", name, output)
+		}
+		index, err := os.ReadFile(filepath.Join(outputDir, name, "_index.md"))
+		if err != nil {
+			t.Fatalf("Failed to read index of %s: %v", name, err)
+		}
+		if !strings.Contains(string(index), name) {
+			t.Errorf("Expected index of %s to name its root, got:\n%s", name, index)
+		}
+	}
+}
+
+func TestRun_SplitOutputRequiresOutputDir(t *testing.T) {
+	err := Run(context.Background(), []string{t.TempDir()}, flagConfig.FlagConfig{SplitOutput: true})
+	if err == nil || !strings.Contains(err.Error(), "--output-dir") {
+		t.Fatalf("Expected --output-dir error, got %v", err)
+	}
+}
+
+func TestRun_MergeWritesOneDocument(t *testing.T) {
+	root := t.TempDir()
+	client := mock.Use(t, &mock.MockGitClient{})
+	client.IsRepo = true
+	client.Root = root
+	client.Info = "Commit: abc123\nBranch: main"
+	for _, name := range []string{"service-a/main.go", "service-b/main.go", "shared/util.go"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("package "+filepath.Base(filepath.Dir(path))+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	paths := []string{filepath.Join(root, "service-a"), filepath.Join(root, "service-b"), filepath.Join(root, "shared")}
+	if err := Run(context.Background(), paths, flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile, Merge: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Expected output file: %v", err)
+	}
+	output := string(data)
+	if strings.Count(output, " Repository Context\n") != 1 {
+		t.Errorf("Expected a single document, got:\n%s", output)
+	}
+	expectedTree := filepath.Base(root) + "/\n  service-a/\n    main.go\n  service-b/\n    main.go\n  shared/\n    util.go\n"
+	if !strings.Contains(output, expectedTree) {
+		t.Errorf("Expected merged tree %q, got:\n%s", expectedTree, output)
+	}
+	for _, header := range []string{"### File: service-a/main.go", "### File: service-b/main.go", "### File: shared/util.go"} {
+		if !strings.Contains(output, header) {
+			t.Errorf("Expected %q, got:\n%s", header, output)
+		}
+	}
+	if !strings.Contains(output, "- Total files: 3\n") {
+		t.Errorf("Expected summed totals, got:\n%s", output)
+	}
+	if !strings.Contains(output, "## Git Info\n\n- Commit: abc123\n- Branch: main\n") {
+		t.Errorf("Expected git info from the client, got:\n%s", output)
+	}
+}
+
+func TestRun_IncludeGitignored(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore": "*.log\n",
+		".r2cignore": "secret.txt\n",
+		"main.go":    "package main\n",
+		"debug.log":  "log line\n",
+		"secret.txt": "audit me\n",
+		"go.sum":     "example.com/m v1.0.0 h1:abc=\n",
+		"api.pb.go":  "package main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	run := func(cfg flagConfig.FlagConfig) string {
+		t.Helper()
+		cfg.OutputFile = filepath.Join(t.TempDir(), "out.md")
+		if err := Run(context.Background(), []string{root}, cfg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(cfg.OutputFile)
+		if err != nil {
+			t.Fatalf("Expected output file: %v", err)
+		}
+		return string(data)
+	}
+
+	// --no-gitignore alone still applies .r2cignore
+	output := run(flagConfig.FlagConfig{NoGitignore: true})
+	if !strings.Contains(output, "### File: debug.log") || strings.Contains(output, "### File: secret.txt") {
+		t.Errorf("Expected only .gitignore disabled, got:\n%s", output)
+	}
+
+	// --include-gitignored passes everything through, lock and generated files included
+	output = run(flagConfig.FlagConfig{IncludeGitignored: true, SkipLockFiles: true, SkipGenerated: true})
+	for _, name := range []string{"main.go", "debug.log", "secret.txt", ".gitignore", ".r2cignore", "go.sum", "api.pb.go"} {
+		if !strings.Contains(output, "### File: "+name) {
+			t.Errorf("Expected %s in the output, got:\n%s", name, output)
+		}
+	}
+}
+
+func TestRun_IncludeGitConfig(t *testing.T) {
+	root := t.TempDir()
+	client := mock.Use(t, &mock.MockGitClient{})
+	client.IsRepo = true
+	client.Root = root
+	client.Info = "Commit: abc123"
+	client.Config = map[string]string{"user.name": "Dev", "user.email": "dev@example.com"}
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	if err := Run(context.Background(), []string{root}, flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile, IncludeGitConfig: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Expected output file: %v", err)
+	}
+	if !strings.Contains(string(data), "- Commit: abc123\n- User  : Dev <dev@example.com>\n") {
+		t.Errorf("Expected the configured git user in the git info, got:\n%s", data)
+	}
+}
+
+func TestRun_OutputModes(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	t.Run("append", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "context.md")
+		cfg := flagConfig.FlagConfig{NoGitignore: true, OutputFile: outputFile, OutputMode: formatter.OutputModeAppend}
+		for i := 0; i < 2; i++ {
+			if err := Run(context.Background(), []string{root}, cfg); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			t.Fatalf("Expected output file: %v", err)
+		}
+		if count := strings.Count(string(data), " Repository Context\n"); count != 2 {
+			t.Errorf("Expected 2 appended documents, got %d", count)
+		}
+	})
+
+	t.Run("version", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "context.md")
+		cfg := flagConfig.FlagConfig{
+			NoGitignore:         true,
+			OutputFile:          outputFile,
+			OutputMode:          formatter.OutputModeVersion,
+			OutputVersionFormat: "20060102-150405.000000000",
+		}
+		for i := 0; i < 2; i++ {
+			if err := Run(context.Background(), []string{root}, cfg); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		versions, err := formatter.ListVersions(outputFile, cfg.OutputVersionFormat)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(versions) != 2 {
+			t.Errorf("Expected 2 versioned files, got %v", versions)
+		}
+		if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+			t.Errorf("Expected no unversioned %s, got err %v", outputFile, err)
+		}
+	})
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      flagConfig.FlagConfig
+		expected int
+	}{
+		{"neither", flagConfig.FlagConfig{}, 0},
+		{"model default", flagConfig.FlagConfig{Model: "gpt-4o"}, 128000},
+		{"explicit window", flagConfig.FlagConfig{ContextWindow: 32000}, 32000},
+		{"explicit window overrides model", flagConfig.FlagConfig{Model: "claude-sonnet-4", ContextWindow: 32000}, 32000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextWindow(tt.cfg); got != tt.expected {
+				t.Errorf("contextWindow() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRun_ContextWindowWithoutModelSplitsOutput(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "b.go"} {
+		content := "package main\n\n// " + strings.Repeat("word ", 80) + "\n"
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	t.Run("without model", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "out.md")
+		cfg := flagConfig.FlagConfig{OutputFile: output, ContextWindow: 150, UseEstimatedTokens: true}
+		if err := Run(context.Background(), []string{root}, cfg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, part := range []string{"out.part1.md", "out.part2.md"} {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(output), part)); err != nil {
+				t.Errorf("Expected %s: %v", part, err)
+			}
+		}
+	})
+
+	t.Run("with model", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "out.md")
+		cfg := flagConfig.FlagConfig{OutputFile: output, ContextWindow: 150, Model: "gpt-4o", UseEstimatedTokens: true}
+		if err := Run(context.Background(), []string{root}, cfg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(output); err != nil {
+			t.Errorf("Expected a single %s: %v", output, err)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(output), "out.part1.md")); !os.IsNotExist(err) {
+			t.Errorf("Expected no parts with --model, got err %v", err)
+		}
+	})
+}
+
+func TestRun_ClipboardAndOutputFile(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	if runtime.GOOS != "linux" {
+		t.Skip("fake clipboard command targets linux")
+	}
+
+	// Fake xclip that saves stdin, using only shell builtins
+	binDir := t.TempDir()
+	capture := filepath.Join(t.TempDir(), "clipboard.txt")
+	script := "#!/bin/sh\nwhile IFS= read -r line; do echo \"$line\" >> '" + capture + "'; done\n"
+	if err := os.WriteFile(filepath.Join(binDir, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to create fake xclip: %v", err)
+	}
+	t.Setenv("PATH", binDir)
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	outputFile := filepath.Join(t.TempDir(), "out.md")
+
+	var err error
+	stderr := captureStderr(func() {
+		err = Run(context.Background(), []string{tempDir}, flagConfig.FlagConfig{
+			NoGitignore: true,
+			OutputFile:  outputFile,
+			Clipboard:   true,
+		})
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	copied, err := os.ReadFile(capture)
+	if err != nil {
+		t.Fatalf("Expected clipboard command to run: %v", err)
+	}
+	saved, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Expected output file: %v", err)
+	}
+	if string(copied) != string(saved) {
+		t.Errorf("Expected clipboard and file to match\nClipboard:\n%s\nFile:\n%s", copied, saved)
+	}
+	if !strings.Contains(stderr, "to clipboard") {
+		t.Errorf("Expected copy confirmation on stderr, got %q", stderr)
+	}
+}
+
+// Tests for tokensByDirectory
+
+// Tests for token truncation
+
+// countWords is a stand-in token counter that needs no encoding data
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestTruncateToTokens_KeepsWholeLines(t *testing.T) {
+	content := "one two\nthree four five\nsix\nseven eight\n"
+
+	tests := []struct {
+		name     string
+		max      int
+		expected string
+		tokens   int
+	}{
+		{"first line fits exactly", 2, "one two\n", 2},
+		{"stops before a line that does not fit", 4, "one two\n", 2},
+		{"several lines", 6, "one two\nthree four five\nsix\n", 6},
+		{"everything fits", 100, content, 8},
+		{"first line too long", 1, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, tokens := truncateToTokens(content, tt.max, countWords)
+			if kept != tt.expected || tokens != tt.tokens {
+				t.Errorf("Expected %q (%d tokens), got %q (%d tokens)", tt.expected, tt.tokens, kept, tokens)
+			}
+		})
+	}
+}
+
+func TestTruncateToTokens_NoTrailingNewline(t *testing.T) {
+	kept, tokens := truncateToTokens("a b\nc d", 3, countWords)
+	if kept != "a b\n" || tokens != 2 {
+		t.Errorf("Expected %q (2 tokens), got %q (%d tokens)", "a b\n", kept, tokens)
+	}
+}
+
+func TestTruncateScanResult_MarksFiles(t *testing.T) {
+	skipWithoutEncoding(t)
+
+	scanResult := createMockScanResult([]scanner.FileInfo{
+		{RelativePath: "big.txt", Content: strings.Repeat("hello world\n", 200)},
+		{RelativePath: "small.txt", Content: "hello\n"},
+	})
+	count, err := countTokens(scanResult, flagConfig.FlagConfig{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	original := scanResult.Files[0].TokenCount
+	small := scanResult.Files[1].TokenCount
+
+	truncateScanResult(scanResult, count, flagConfig.FlagConfig{MaxTokensPerFile: 50})
+
+	big := scanResult.Files[0]
+	if big.TruncatedFrom != original || big.TokenCount > 50 || big.TokenCount == 0 {
+		t.Errorf("Expected truncation from %d to at most 50 tokens, got %d -> %d", original, big.TruncatedFrom, big.TokenCount)
+	}
+	marker := fmt.Sprintf("\n// [truncated: original was %d tokens, showing %d]\n", original, big.TokenCount)
+	if !strings.HasSuffix(big.Content, marker) {
+		t.Errorf("Expected content to end with %q, got %q", marker, big.Content[len(big.Content)-80:])
+	}
+	if scanResult.Files[1].TruncatedFrom != 0 {
+		t.Error("Expected small file to be left alone")
+	}
+	if scanResult.TotalTokens != big.TokenCount+small {
+		t.Errorf("Expected total of kept tokens %d, got %d", big.TokenCount+small, scanResult.TotalTokens)
+	}
+}
+
+func TestTokensByDirectory_SumsIntoAncestors(t *testing.T) {
+	files := []scanner.FileInfo{
+		{RelativePath: "main.go", TokenCount: 5},
+		{RelativePath: filepath.Join("pkg", "core", "core.go"), TokenCount: 10},
+		{RelativePath: filepath.Join("pkg", "scanner", "scanner.go"), TokenCount: 20},
+		{RelativePath: "pkg", IsDir: true},
+		{RelativePath: "empty.go"},
+	}
+
+	totals := tokensByDirectory(files)
+
+	expected := map[string]int{".": 35, "pkg/": 30, "pkg/core/": 10, "pkg/scanner/": 20}
+	if len(totals) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, totals)
+	}
+	for dir, tokens := range expected {
+		if totals[dir] != tokens {
+			t.Errorf("%s: expected %d tokens, got %d", dir, tokens, totals[dir])
+		}
+	}
+}
+
+// Tests for parallel token counting
+
+// createTokenBenchmarkFiles returns count distinct Go files for token counting
+func createTokenBenchmarkFiles(count int) []scanner.FileInfo {
+	files := make([]scanner.FileInfo, count)
+	for i := range files {
+		files[i] = scanner.FileInfo{
+			Path:         fmt.Sprintf("/test/path/file%d.go", i),
+			RelativePath: fmt.Sprintf("file%d.go", i),
+			Content:      strings.Repeat(fmt.Sprintf("func f%d() { return %d }\n", i, i), 200),
+		}
+	}
+	return files
+}
+
+// skipWithoutEncoding skips when the default encoding cannot be loaded (e.g. offline)
+func skipWithoutEncoding(tb testing.TB) {
+	tb.Helper()
+	if _, err := tokencounter.NewTokenCounter(""); err != nil {
+		tb.Skipf("encoding unavailable: %v", err)
+	}
+}
+
+func TestCountTokensWithWorkers_MatchesSequential(t *testing.T) {
+	skipWithoutEncoding(t)
+
+	files := createTokenBenchmarkFiles(50)
+	files = append(files, scanner.FileInfo{RelativePath: "dir", IsDir: true}, scanner.FileInfo{RelativePath: "empty.go"})
+
+	sequential := createMockScanResult(append([]scanner.FileInfo(nil), files...))
+	parallel := createMockScanResult(append([]scanner.FileInfo(nil), files...))
+	if _, err := countTokensWithWorkers(sequential, flagConfig.FlagConfig{TokenCountWorkers: 1}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := countTokensWithWorkers(parallel, flagConfig.FlagConfig{TokenCountWorkers: 8}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if parallel.TotalTokens != sequential.TotalTokens {
+		t.Errorf("Expected %d total tokens, got %d", sequential.TotalTokens, parallel.TotalTokens)
+	}
+	for i := range files {
+		if parallel.Files[i].TokenCount != sequential.Files[i].TokenCount {
+			t.Errorf("%s: expected %d tokens, got %d", files[i].RelativePath, sequential.Files[i].TokenCount, parallel.Files[i].TokenCount)
+		}
+	}
+}
+
+// BenchmarkCountTokensWithWorkers compares sequential counting (workers=1)
+// with the default pool of one worker per CPU on 500 files
+func BenchmarkCountTokensWithWorkers(b *testing.B) {
+	skipWithoutEncoding(b)
+	files := createTokenBenchmarkFiles(500)
+
+	for _, workers := range []int{1, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanResult := createMockScanResult(append([]scanner.FileInfo(nil), files...))
+				if _, err := countTokensWithWorkers(scanResult, flagConfig.FlagConfig{TokenCountWorkers: workers}); err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// Tests for --save-scan-result and --load-scan-result
+
+func TestRun_ScanResultCache(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	cache := filepath.Join(t.TempDir(), "cache.json.gz")
+
+	run := func(cfg flagConfig.FlagConfig) string {
+		t.Helper()
+		cfg.OutputFile = filepath.Join(t.TempDir(), "out.md")
+		if err := Run(context.Background(), []string{root}, cfg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		data, err := os.ReadFile(cfg.OutputFile)
+		if err != nil {
+			t.Fatalf("Expected output file: %v", err)
+		}
+		return string(data)
+	}
+
+	// A missing cache is scanned and saved
+	run(flagConfig.FlagConfig{LoadScanResult: cache, SaveScanResult: cache})
+	if _, err := os.Stat(cache); err != nil {
+		t.Fatalf("Expected the scan result to be saved: %v", err)
+	}
+
+	// A fresh cache is used instead of the directory
+	if err := os.WriteFile(filepath.Join(root, "added.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	output := run(flagConfig.FlagConfig{LoadScanResult: cache})
+	if !strings.Contains(output, "### File: main.go") || strings.Contains(output, "added.go") {
+		t.Errorf("Expected the cached scan, got:\n%s", output)
+	}
+
+	// A cache saved with other scan options is scanned again
+	output = run(flagConfig.FlagConfig{LoadScanResult: cache, NoContent: true})
+	if !strings.Contains(output, "added.go") {
+		t.Errorf("Expected a fresh scan with different options, got:\n%s", output)
+	}
+
+	// An expired cache is scanned again
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(cache, old, old); err != nil {
+		t.Fatal(err)
+	}
+	output = run(flagConfig.FlagConfig{LoadScanResult: cache, ScanResultTTL: time.Hour})
+	if !strings.Contains(output, "### File: added.go") {
+		t.Errorf("Expected a fresh scan, got:\n%s", output)
+	}
+
+	// One cache file cannot hold several scans
+	err := Run(context.Background(), []string{root, root}, flagConfig.FlagConfig{SaveScanResult: cache})
+	if err == nil || !strings.Contains(err.Error(), "single path") {
+		t.Errorf("Expected a single path error, got %v", err)
+	}
+
+	// Nor the scan of a single file
+	err = Run(context.Background(), []string{filepath.Join(root, "main.go")}, flagConfig.FlagConfig{LoadScanResult: cache})
+	if err == nil || !strings.Contains(err.Error(), "take a directory") {
+		t.Errorf("Expected a directory error, got %v", err)
+	}
+}
+
+// Tests for --format rst
+
+func TestRun_RSTFormatAddsExtension(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	output := filepath.Join(t.TempDir(), "context")
+
+	if err := Run(context.Background(), []string{root}, flagConfig.FlagConfig{OutputFormat: formatter.RSTFormat, OutputFile: output}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(output + ".rst")
+	if err != nil {
+		t.Fatalf("Expected output in %s.rst: %v", output, err)
+	}
+	if !strings.Contains(string(data), "File: main.go\n~~~~~~~~~~~~~\n") {
+		t.Errorf("Expected RST output, got:\n%s", data)
+	}
+}
+
+func TestRun_SanitizePaths(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	output := filepath.Join(t.TempDir(), "context.md")
+
+	flagCfg := flagConfig.FlagConfig{OutputFile: output, SanitizePaths: true, PathStyle: scanner.PathStyleAbsolute}
+	if err := Run(context.Background(), []string{root}, flagCfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if strings.Contains(string(data), root) {
+		t.Errorf("Expected %s to be hidden, got:\n%s", root, data)
+	}
+	if !strings.Contains(string(data), "## File System Location\n\n"+filepath.Base(root)+"\n") {
+		t.Errorf("Expected only the directory name as location, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "### File: main.go ") {
+		t.Errorf("Expected a relative file path, got:\n%s", data)
+	}
+}
+
+func TestRun_SanitizePathsKeepsGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Test User"},
+		{"config", "user.email", "test@example.com"},
+		{"add", "main.go"},
+		{"commit", "-q", "-m", "add main"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", root}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	output := filepath.Join(t.TempDir(), "context.md")
+
+	flagCfg := flagConfig.FlagConfig{
+		OutputFile:       output,
+		SanitizePaths:    true,
+		ShowGitLog:       true,
+		ShowBlame:        true,
+		IncludeGitConfig: true,
+	}
+	if err := Run(context.Background(), []string{root}, flagCfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	for _, want := range []string{"add main\n", "Test User | package main\n", "User  : Test User <test@example.com>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q from the real root's git history, got:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), root) {
+		t.Errorf("Expected %s to be hidden, got:\n%s", root, data)
+	}
+}
+
+func TestRun_EstimateTokens(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte(strings.Repeat("x", 400)), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	output := filepath.Join(t.TempDir(), "context.md")
+
+	if err := Run(context.Background(), []string{root}, flagConfig.FlagConfig{OutputFile: output, UseEstimatedTokens: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if !strings.Contains(string(data), "- Total tokens: ~100 (estimated)\n") {
+		t.Errorf("Expected an estimated token total, got:\n%s", data)
+	}
+}
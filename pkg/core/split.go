@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/flagConfig"
+	"github.com/BHChen24/repo2context/pkg/formatter"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// SplitByTokenBudget packs files into chunks of at most budget tokens using
+// first-fit-decreasing bin packing. Files larger than the budget are split at
+// line boundaries into consecutive chunks. Directories are dropped, and files
+// keep their original order within each chunk. A budget <= 0 returns a single chunk.
+func SplitByTokenBudget(files []scanner.FileInfo, budget int) [][]scanner.FileInfo {
+	if budget <= 0 {
+		return [][]scanner.FileInfo{files}
+	}
+
+	// packedFile remembers the original position so chunks can be reordered
+	type packedFile struct {
+		index int
+		file  scanner.FileInfo
+	}
+
+	// Largest files first
+	order := make([]int, 0, len(files))
+	for i, file := range files {
+		if !file.IsDir {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return files[order[i]].TokenCount > files[order[j]].TokenCount
+	})
+
+	var bins [][]packedFile
+	var used []int
+	for _, index := range order {
+		file := files[index]
+
+		// Oversized files take consecutive chunks of their own
+		if file.TokenCount > budget {
+			pieces := splitFileContent(file, budget)
+			for p, piece := range pieces {
+				if p < len(pieces)-1 {
+					piece.ContinuedInPart = len(bins) + 2
+				}
+				bins = append(bins, []packedFile{{index, piece}})
+				used = append(used, piece.TokenCount)
+			}
+			continue
+		}
+
+		// First chunk with enough room, or a new one
+		placed := false
+		for b := range bins {
+			if used[b]+file.TokenCount <= budget {
+				bins[b] = append(bins[b], packedFile{index, file})
+				used[b] += file.TokenCount
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			bins = append(bins, []packedFile{{index, file}})
+			used = append(used, file.TokenCount)
+		}
+	}
+
+	chunks := make([][]scanner.FileInfo, len(bins))
+	for b, bin := range bins {
+		sort.SliceStable(bin, func(i, j int) bool { return bin[i].index < bin[j].index })
+		chunks[b] = make([]scanner.FileInfo, len(bin))
+		for i, packed := range bin {
+			chunks[b][i] = packed.file
+		}
+	}
+	return chunks
+}
+
+// splitFileContent splits a file into pieces of at most budget tokens without
+// breaking lines. Per-line token counts are estimated from the file's share of
+// bytes, so the pieces' counts add up to the file's count. A single line larger
+// than the budget becomes a piece of its own.
+func splitFileContent(file scanner.FileInfo, budget int) []scanner.FileInfo {
+	total := len(file.Content)
+	if total == 0 {
+		return []scanner.FileInfo{file}
+	}
+	tokensAt := func(offset int) int {
+		return file.TokenCount * offset / total
+	}
+
+	var pieces []scanner.FileInfo
+	addPiece := func(start, end int) {
+		piece := file
+		piece.Content = file.Content[start:end]
+		piece.Size = int64(end - start)
+		piece.Lines = countLines(piece.Content)
+		piece.TokenCount = tokensAt(end) - tokensAt(start)
+		pieces = append(pieces, piece)
+	}
+
+	start, end := 0, 0
+	for _, line := range strings.SplitAfter(file.Content, "\n") {
+		if line == "" {
+			continue
+		}
+		if end > start && tokensAt(end+len(line))-tokensAt(start) > budget {
+			addPiece(start, end)
+			start = end
+		}
+		end += len(line)
+	}
+	addPiece(start, end)
+
+	return pieces
+}
+
+// writeSplitOutput writes each chunk as a complete document, to numbered
+// files next to --output (out.md becomes out.part1.md, ...), which Validate
+// requires with --token-limit
+func writeSplitOutput(contextData *formatter.ContextData, chunks [][]scanner.FileInfo, flagCfg flagConfig.FlagConfig) error {
+	verboseLog(flagCfg, "Splitting output into %d parts of at most %d tokens", len(chunks), flagCfg.TokenLimit)
+
+	// Parts would replace each other on the clipboard, so they only go to files
+	partCfg := flagCfg
+	partCfg.TokenLimit = 0
+	partCfg.Clipboard = false
+	// GitHub Actions outputs are set once for all parts
+	partCfg.GitHubActions = false
+	for i, chunk := range chunks {
+		partData := *contextData
+		partData.ScanResult = partScanResult(contextData.ScanResult, chunk)
+
+		partCfg.OutputFile = partPath(flagCfg.OutputFile, i+1)
+		if err := writeOutput(&partData, partCfg); err != nil {
+			return fmt.Errorf("failed to write part %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// partScanResult copies a scan result with only the given files, recomputing the totals
+func partScanResult(scanResult *scanner.ScanResult, files []scanner.FileInfo) *scanner.ScanResult {
+	partResult := *scanResult
+	partResult.Files = files
+	partResult.TotalFiles = len(files)
+	partResult.TotalLines = 0
+	partResult.TotalSize = 0
+	partResult.TotalTokens = 0
+	for _, file := range files {
+		partResult.TotalLines += file.Lines
+		partResult.TotalSize += file.Size
+		partResult.TotalTokens += file.TokenCount
+	}
+	if scanResult.TokensByDirectory != nil {
+		partResult.TokensByDirectory = tokensByDirectory(files)
+	}
+	return &partResult
+}
+
+// partPath inserts the part number before the extension of an output path
+func partPath(path string, part int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.part%d%s", strings.TrimSuffix(path, ext), part, ext)
+}
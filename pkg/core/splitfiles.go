@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/flagConfig"
+	"github.com/BHChen24/repo2context/pkg/formatter"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// indexFileName is the manifest written next to the per-file outputs
+const indexFileName = "_index.md"
+
+// splitFile is a generated per-file output listed in the index
+type splitFile struct {
+	name   string
+	source string
+	tokens int
+}
+
+// writePerFileOutput writes one complete document per scanned file into
+// --output-dir, plus an index listing every generated file
+func writePerFileOutput(contextData *formatter.ContextData, flagCfg flagConfig.FlagConfig) error {
+	verboseLog(flagCfg, "Writing one output file per scanned file to: %s", flagCfg.OutputDir)
+
+	var written []splitFile
+	used := make(map[string]bool)
+	for _, file := range contextData.ScanResult.Files {
+		// Skip directories and files with errors
+		if file.IsDir || file.Error != nil {
+			continue
+		}
+
+		name := uniqueName(sanitizeFileName(file.RelativePath)+outputExtension(flagCfg), used)
+
+		fileData := *contextData
+		fileData.ScanResult = partScanResult(contextData.ScanResult, []scanner.FileInfo{file})
+		fileData.ScanResult.DirectoryTree = scanner.RegenerateDirectoryTree(fileData.ScanResult)
+
+		path := filepath.Join(flagCfg.OutputDir, name)
+		if err := writeDocument(&fileData, path, flagCfg); err != nil {
+			return fmt.Errorf("failed to save output for %s: %w", file.RelativePath, err)
+		}
+		verboseLog(flagCfg, "Saved %s to %s", file.RelativePath, path)
+
+		written = append(written, splitFile{name: name, source: file.RelativePath, tokens: file.TokenCount})
+	}
+
+	indexPath := filepath.Join(flagCfg.OutputDir, indexFileName)
+	if err := formatter.WriteFileWithEncoding(formatIndex(contextData.ScanResult.RootPath, written), indexPath, flagCfg.OutputEncoding); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Output saved to: %s (%d files, index: %s)\n", flagCfg.OutputDir, len(written), indexFileName)
+	return nil
+}
+
+// outputExtension returns the file extension of the per-file outputs
+func outputExtension(flagCfg flagConfig.FlagConfig) string {
+	if rendersRST(flagCfg) {
+		return ".rst"
+	}
+	return ".md"
+}
+
+// formatIndex renders the manifest of generated files with their token counts
+func formatIndex(rootPath string, files []splitFile) string {
+	var output strings.Builder
+
+	output.WriteString("# Repository Context Index\n\n")
+	output.WriteString(fmt.Sprintf("Root: %s\n\n", rootPath))
+	output.WriteString("| Output | Source | Tokens |\n")
+	output.WriteString("|--------|--------|--------|\n")
+
+	total := 0
+	for _, file := range files {
+		output.WriteString(fmt.Sprintf("| [%s](%s) | %s | %d |\n", file.name, file.name, file.source, file.tokens))
+		total += file.tokens
+	}
+
+	output.WriteString(fmt.Sprintf("\n- Total files: %d\n", len(files)))
+	output.WriteString(fmt.Sprintf("- Total tokens: %d\n", total))
+	return output.String()
+}
+
+// sanitizeFileName flattens a relative path into a single safe file name:
+// separators become underscores, other characters outside [A-Za-z0-9._-]
+// are dropped, and leading dots are removed so names are never hidden or "..".
+func sanitizeFileName(relPath string) string {
+	flat := strings.ReplaceAll(filepath.ToSlash(relPath), "/", "_")
+
+	var name strings.Builder
+	for _, r := range flat {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			name.WriteRune(r)
+		}
+	}
+
+	sanitized := strings.TrimLeft(name.String(), ".")
+	if sanitized == "" {
+		return "file"
+	}
+	return sanitized
+}
+
+// uniqueName returns name, or name with a numeric suffix if it was already used
+func uniqueName(name string, used map[string]bool) string {
+	candidate := name
+	ext := filepath.Ext(name)
+	for n := 2; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
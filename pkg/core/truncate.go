@@ -0,0 +1,60 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/flagConfig"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// truncateScanResult cuts files with more than --max-tokens-per-file tokens at a
+// line boundary and appends a marker naming the original and kept token counts.
+// TokenCount becomes the kept count and TruncatedFrom the original one, and the
+// totals are recomputed. count must be the count the token counts were taken with.
+func truncateScanResult(scanResult *scanner.ScanResult, count func(string) int, flagCfg flagConfig.FlagConfig) {
+	maxTokens := flagCfg.MaxTokensPerFile
+	for i := range scanResult.Files {
+		file := &scanResult.Files[i]
+		if file.IsDir || file.TokenCount <= maxTokens {
+			continue
+		}
+
+		kept, keptTokens := truncateToTokens(file.Content, maxTokens, count)
+		verboseLog(flagCfg, "Truncating %s from %d to %d tokens", file.RelativePath, file.TokenCount, keptTokens)
+		file.Content = kept + fmt.Sprintf("\n// [truncated: original was %d tokens, showing %d]\n", file.TokenCount, keptTokens)
+		file.TruncatedFrom = file.TokenCount
+		file.TokenCount = keptTokens
+	}
+
+	scanResult.TotalTokens = 0
+	for _, file := range scanResult.Files {
+		scanResult.TotalTokens += file.TokenCount
+	}
+	scanResult.TokensByDirectory = tokensByDirectory(scanResult.Files)
+}
+
+// truncateToTokens returns the longest run of whole leading lines of content
+// with at most maxTokens tokens, and its token count. Lines are found by binary
+// search, re-counting each candidate prefix.
+func truncateToTokens(content string, maxTokens int, count func(string) int) (string, int) {
+	// ends[k] is the byte offset after the first k lines
+	ends := []int{0}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if line != "" {
+			ends = append(ends, ends[len(ends)-1]+len(line))
+		}
+	}
+
+	// The first line count whose prefix exceeds the budget; one fewer fits
+	lines := sort.Search(len(ends), func(k int) bool {
+		return count(content[:ends[k]]) > maxTokens
+	}) - 1
+	if lines < 0 {
+		lines = 0
+	}
+
+	kept := content[:ends[lines]]
+	return kept, count(kept)
+}
@@ -0,0 +1,453 @@
+package editorconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// FileName is the name of EditorConfig files
+const FileName = ".editorconfig"
+
+// Supported charset values (https://editorconfig.org)
+const (
+	CharsetLatin1  = "latin1"
+	CharsetUTF8    = "utf-8"
+	CharsetUTF8BOM = "utf-8-bom"
+	CharsetUTF16BE = "utf-16be"
+	CharsetUTF16LE = "utf-16le"
+)
+
+// EditorConfig holds the settings that apply to one file
+type EditorConfig struct {
+	IndentStyle string // "space" or "tab"
+	IndentSize  int    // 0 when unset
+	TabWidth    int    // defaults to IndentSize
+	EndOfLine   string // "lf", "crlf" or "cr"
+	Charset     string // see CharsetUTF8 and friends
+	// Properties holds every property that applies, including the ones above
+	Properties map[string]string
+}
+
+// section is a glob section of an EditorConfig file
+type section struct {
+	pattern    *regexp.Regexp
+	properties map[string]string
+}
+
+// configFile is a parsed EditorConfig file
+type configFile struct {
+	root     bool
+	sections []section
+}
+
+// caseInsensitive lists the properties whose values are lowercased, as the spec requires
+var caseInsensitive = map[string]bool{
+	"indent_style":             true,
+	"indent_size":              true,
+	"tab_width":                true,
+	"end_of_line":              true,
+	"charset":                  true,
+	"trim_trailing_whitespace": true,
+	"insert_final_newline":     true,
+	"root":                     true,
+}
+
+// Resolver finds the settings of files, caching the EditorConfig files it parses
+// It is safe for concurrent use
+type Resolver struct {
+	mu    sync.Mutex
+	files map[string]*configFile // keyed by directory; nil when the directory has none
+	// readFile reads EditorConfig files from a source other than the OS filesystem
+	readFile func(name string) ([]byte, error)
+}
+
+// NewResolver returns a Resolver with an empty cache
+func NewResolver() *Resolver {
+	return &Resolver{files: make(map[string]*configFile)}
+}
+
+// NewSourceResolver returns a Resolver reading EditorConfig files with readFile,
+// such as from a git commit or an archive, instead of from the OS filesystem.
+// Paths are slash-separated and relative to the root of the source, and readFile
+// returns an error wrapping fs.ErrNotExist for a missing file.
+func NewSourceResolver(readFile func(name string) ([]byte, error)) *Resolver {
+	return &Resolver{files: make(map[string]*configFile), readFile: readFile}
+}
+
+// GetFileSettings returns the settings that apply to path from the
+// .editorconfig files in its directory and the directories above it
+func GetFileSettings(path string) (EditorConfig, error) {
+	return NewResolver().GetFileSettings(path)
+}
+
+// GetFileSettings returns the settings that apply to path, searching up from
+// its directory until an EditorConfig file declares root = true
+func (r *Resolver) GetFileSettings(path string) (EditorConfig, error) {
+	if r.readFile != nil {
+		return r.sourceSettings(filepath.ToSlash(path))
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return EditorConfig{}, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	var dirs []string
+	for dir := filepath.Dir(absPath); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return r.resolve(dirs, func(dir string) (string, error) {
+		relPath, err := filepath.Rel(dir, absPath)
+		return filepath.ToSlash(relPath), err
+	})
+}
+
+// sourceSettings returns the settings of the slash-separated name in the source read by readFile
+func (r *Resolver) sourceSettings(name string) (EditorConfig, error) {
+	name = path.Clean(name)
+
+	var dirs []string
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	return r.resolve(dirs, func(dir string) (string, error) {
+		if dir == "." {
+			return name, nil
+		}
+		return strings.TrimPrefix(name, strings.TrimSuffix(dir, "/")+"/"), nil
+	})
+}
+
+// resolve merges the settings of the EditorConfig files in dirs, ordered from
+// the file's directory up, for the file at relTo(dir) below each of them
+func (r *Resolver) resolve(dirs []string, relTo func(dir string) (string, error)) (EditorConfig, error) {
+	// Collect the files from the nearest directory up to the root
+	type located struct {
+		dir  string
+		file *configFile
+	}
+	var found []located
+	for _, dir := range dirs {
+		file, err := r.load(dir)
+		if err != nil {
+			return EditorConfig{}, err
+		}
+		if file != nil {
+			found = append(found, located{dir, file})
+			if file.root {
+				break
+			}
+		}
+	}
+
+	// Nearer files override farther ones, and later sections earlier ones
+	properties := make(map[string]string)
+	for i := len(found) - 1; i >= 0; i-- {
+		relPath, err := relTo(found[i].dir)
+		if err != nil {
+			continue
+		}
+		for _, s := range found[i].file.sections {
+			if !s.pattern.MatchString(relPath) {
+				continue
+			}
+			for key, value := range s.properties {
+				properties[key] = value
+			}
+		}
+	}
+	return newEditorConfig(properties), nil
+}
+
+// load parses the EditorConfig file of a directory, once
+func (r *Resolver) load(dir string) (*configFile, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if file, ok := r.files[dir]; ok {
+		return file, nil
+	}
+
+	file, err := r.read(dir)
+	if err != nil {
+		return nil, err
+	}
+	r.files[dir] = file
+	return file, nil
+}
+
+// read parses the EditorConfig file of a directory, or returns nil when it has none
+func (r *Resolver) read(dir string) (*configFile, error) {
+	if r.readFile != nil {
+		name := path.Join(dir, FileName)
+		data, err := r.readFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		file, err := parse(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return file, nil
+	}
+
+	name := filepath.Join(dir, FileName)
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	file, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return file, nil
+}
+
+// parse reads an EditorConfig file
+// Sections with a glob that can't be compiled are skipped
+func parse(r io.Reader) (*configFile, error) {
+	file := &configFile{}
+	var current *section
+	skipping := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			pattern, err := compileGlob(line[1 : len(line)-1])
+			skipping = err != nil
+			if skipping {
+				continue
+			}
+			file.sections = append(file.sections, section{pattern: pattern, properties: make(map[string]string)})
+			current = &file.sections[len(file.sections)-1]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if caseInsensitive[key] {
+			value = strings.ToLower(value)
+		}
+
+		switch {
+		case current == nil && !skipping:
+			// The preamble before the first section only holds root
+			if key == "root" {
+				file.root = value == "true"
+			}
+		case current != nil && !skipping:
+			current.properties[key] = value
+		}
+	}
+	return file, scanner.Err()
+}
+
+// newEditorConfig fills the typed fields from the properties
+func newEditorConfig(properties map[string]string) EditorConfig {
+	config := EditorConfig{
+		IndentStyle: properties["indent_style"],
+		EndOfLine:   properties["end_of_line"],
+		Charset:     properties["charset"],
+		Properties:  properties,
+	}
+	config.TabWidth, _ = strconv.Atoi(properties["tab_width"])
+	if size := properties["indent_size"]; size == "tab" {
+		config.IndentSize = config.TabWidth
+	} else {
+		config.IndentSize, _ = strconv.Atoi(size)
+	}
+	if config.TabWidth == 0 {
+		config.TabWidth = config.IndentSize
+	}
+	return config
+}
+
+// numericRange matches the {start..end} glob syntax
+var numericRange = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// maxRangeAlternatives bounds the alternatives generated for a numeric range
+const maxRangeAlternatives = 1000
+
+// compileGlob converts an EditorConfig section glob to a regular expression
+// matched against slash-separated paths relative to the EditorConfig file.
+// A glob without a slash matches file names at any depth.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	prefix := "^"
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+	} else {
+		prefix = "^(?:.*/)?"
+	}
+	return regexp.Compile(prefix + translateGlob(glob) + "$")
+}
+
+// translateGlob converts glob syntax (* ** ? [...] {a,b} {1..3}) to regexp syntax
+func translateGlob(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			end := matchingBrace(glob, i)
+			if end < 0 {
+				b.WriteString(`\{`)
+				continue
+			}
+			b.WriteString(translateBraces(glob[i+1 : end]))
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// matchingBrace returns the index of the } closing the { at start, or -1
+func matchingBrace(glob string, start int) int {
+	depth := 0
+	for i := start; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// translateBraces converts the inside of {...}: a numeric range or comma-separated alternatives
+func translateBraces(inner string) string {
+	if m := numericRange.FindStringSubmatch(inner); m != nil {
+		start, _ := strconv.Atoi(m[1])
+		end, _ := strconv.Atoi(m[2])
+		if start > end {
+			start, end = end, start
+		}
+		if end-start >= maxRangeAlternatives {
+			return `[+-]?\d+`
+		}
+		numbers := make([]string, 0, end-start+1)
+		for n := start; n <= end; n++ {
+			numbers = append(numbers, strconv.Itoa(n))
+		}
+		return "(?:" + strings.Join(numbers, "|") + ")"
+	}
+
+	alternatives := splitAlternatives(inner)
+	if len(alternatives) == 1 {
+		// A single alternative is literal text, {a} matches "{a}"
+		return regexp.QuoteMeta("{") + translateGlob(inner) + regexp.QuoteMeta("}")
+	}
+	for i, alternative := range alternatives {
+		alternatives[i] = translateGlob(alternative)
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")"
+}
+
+// splitAlternatives splits brace contents at the commas outside nested braces
+func splitAlternatives(inner string) []string {
+	var alternatives []string
+	depth, start := 0, 0
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alternatives = append(alternatives, inner[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alternatives, inner[start:])
+}
+
+// NewReader returns a reader converting content in an EditorConfig charset to
+// UTF-8. A byte order mark is stripped; UTF-8 and unknown charsets pass through.
+func NewReader(r io.Reader, charset string) io.Reader {
+	switch charset {
+	case CharsetUTF8BOM:
+		return transform.NewReader(r, unicode.UTF8BOM.NewDecoder())
+	case CharsetUTF16LE:
+		return transform.NewReader(r, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder())
+	case CharsetUTF16BE:
+		return transform.NewReader(r, unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder())
+	case CharsetLatin1:
+		return transform.NewReader(r, charmap.ISO8859_1.NewDecoder())
+	default:
+		return r
+	}
+}
@@ -0,0 +1,174 @@
+package editorconfig
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile creates a file below root, with its directories
+func writeFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+}
+
+func TestGetFileSettings_NearestFileWins(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, ".editorconfig", `root = true
+
+[*]
+indent_style = space
+indent_size = 4
+charset = UTF-8
+
+[*.go]
+indent_style = tab
+indent_size = tab
+tab_width = 8
+`)
+	writeFile(t, root, "legacy/.editorconfig", "[*.txt]\ncharset = utf-16le\n")
+
+	tests := []struct {
+		name     string
+		expected EditorConfig
+	}{
+		{"main.py", EditorConfig{IndentStyle: "space", IndentSize: 4, TabWidth: 4, Charset: "utf-8"}},
+		{"cmd/main.go", EditorConfig{IndentStyle: "tab", IndentSize: 8, TabWidth: 8, Charset: "utf-8"}},
+		{"legacy/notes.txt", EditorConfig{IndentStyle: "space", IndentSize: 4, TabWidth: 4, Charset: "utf-16le"}},
+	}
+
+	resolver := NewResolver()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.GetFileSettings(filepath.Join(root, filepath.FromSlash(tt.name)))
+			if err != nil {
+				t.Fatalf("GetFileSettings() error = %v", err)
+			}
+			got.Properties = nil
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("GetFileSettings() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetFileSettings_NoEditorConfig(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, ".editorconfig", "root = true\n")
+
+	got, err := GetFileSettings(filepath.Join(root, "main.go"))
+	if err != nil {
+		t.Fatalf("GetFileSettings() error = %v", err)
+	}
+	if got.Charset != "" || len(got.Properties) != 0 {
+		t.Errorf("Expected no settings, got %+v", got)
+	}
+}
+
+func TestNewSourceResolver_ReadsSlashPaths(t *testing.T) {
+	files := map[string]string{
+		".editorconfig":        "root = true\n\n[*]\ncharset = latin1\n",
+		"legacy/.editorconfig": "[*.txt]\ncharset = utf-16le\n",
+	}
+	var reads []string
+	resolver := NewSourceResolver(func(name string) ([]byte, error) {
+		reads = append(reads, name)
+		content, ok := files[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(content), nil
+	})
+
+	tests := map[string]string{
+		"main.go":          "latin1",
+		"legacy/notes.txt": "utf-16le",
+		"legacy/main.go":   "latin1",
+	}
+	for name, expected := range tests {
+		got, err := resolver.GetFileSettings(name)
+		if err != nil {
+			t.Fatalf("GetFileSettings(%q) error = %v", name, err)
+		}
+		if got.Charset != expected {
+			t.Errorf("GetFileSettings(%q).Charset = %q, want %q", name, got.Charset, expected)
+		}
+	}
+
+	// Each directory's file is read once
+	if len(reads) != 2 {
+		t.Errorf("Expected 2 reads, got %v", reads)
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob    string
+		path    string
+		matches bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/core/core.go", true},
+		{"*.go", "main.gox", false},
+		{"/*.go", "pkg/core.go", false},
+		{"pkg/*.go", "pkg/core.go", true},
+		{"pkg/*.go", "pkg/core/core.go", false},
+		{"pkg/**.go", "pkg/core/core.go", true},
+		{"*.{js,ts}", "app.ts", true},
+		{"*.{js,ts}", "app.py", false},
+		{"file{1..3}.txt", "file2.txt", true},
+		{"file{1..3}.txt", "file4.txt", false},
+		{"[Mm]akefile", "makefile", true},
+		{"[!M]akefile", "Makefile", false},
+		{"?.c", "a.c", true},
+		{"{single}.txt", "{single}.txt", true},
+	}
+
+	for _, tt := range tests {
+		pattern, err := compileGlob(tt.glob)
+		if err != nil {
+			t.Fatalf("compileGlob(%q) error = %v", tt.glob, err)
+		}
+		if got := pattern.MatchString(tt.path); got != tt.matches {
+			t.Errorf("glob %q on %q = %v, want %v", tt.glob, tt.path, got, tt.matches)
+		}
+	}
+}
+
+func TestNewReader_TranscodesToUTF8(t *testing.T) {
+	tests := []struct {
+		charset string
+		input   string
+	}{
+		{CharsetUTF16LE, "\xff\xfeh\x00i\x00"},
+		{CharsetUTF16LE, "h\x00i\x00"},
+		{CharsetUTF16BE, "\x00h\x00i"},
+		{CharsetUTF8BOM, "\xef\xbb\xbfhi"},
+		{CharsetLatin1, "h\xef"},
+		{CharsetUTF8, "hi"},
+	}
+
+	for _, tt := range tests {
+		data, err := io.ReadAll(NewReader(strings.NewReader(tt.input), tt.charset))
+		if err != nil {
+			t.Fatalf("NewReader(%s) error = %v", tt.charset, err)
+		}
+		expected := "hi"
+		if tt.charset == CharsetLatin1 {
+			expected = "hï"
+		}
+		if string(data) != expected {
+			t.Errorf("NewReader(%s) = %q, want %q", tt.charset, data, expected)
+		}
+	}
+}
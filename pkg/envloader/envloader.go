@@ -0,0 +1,72 @@
+package envloader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix is the prefix of repo2context keys in .env files and the environment
+const Prefix = "R2C_"
+
+// LoadEnvFile parses a .env-style file of KEY=VALUE lines
+// Blank lines and lines starting with # are skipped, an optional "export "
+// prefix is allowed, and values may be wrapped in single or double quotes
+func LoadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file %s: %w", path, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE, got %q", path, lineNum, line)
+		}
+		values[key] = unquote(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read env file %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+// ConfigKey converts an environment key such as R2C_NO_GITIGNORE to its
+// config key (no_gitignore). It returns false for keys without the prefix
+func ConfigKey(envKey string) (string, bool) {
+	key, ok := strings.CutPrefix(envKey, Prefix)
+	if !ok || key == "" {
+		return "", false
+	}
+	return strings.ToLower(key), true
+}
+
+// EnvKey converts a config key such as no_gitignore to its environment key (R2C_NO_GITIGNORE)
+func EnvKey(configKey string) string {
+	return Prefix + strings.ToUpper(configKey)
+}
+
+// unquote removes one pair of matching surrounding quotes
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
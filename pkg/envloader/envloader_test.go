@@ -0,0 +1,90 @@
+package envloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEnvFile writes content to a temporary .env file and returns its path
+func writeEnvFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".r2c.env")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create env file: %v", err)
+	}
+	return path
+}
+
+// Tests for LoadEnvFile
+
+func TestLoadEnvFile_ParsesValues(t *testing.T) {
+	path := writeEnvFile(t, `# repo2context settings
+R2C_OUTPUT=out.md
+
+export R2C_NO_GITIGNORE=true
+R2C_PREFIX="<context> = start"
+R2C_SUFFIX='</context>'
+R2C_EMPTY=
+`)
+
+	values, err := LoadEnvFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"R2C_OUTPUT":       "out.md",
+		"R2C_NO_GITIGNORE": "true",
+		"R2C_PREFIX":       "<context> = start",
+		"R2C_SUFFIX":       "</context>",
+		"R2C_EMPTY":        "",
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+	for key, value := range expected {
+		if got, ok := values[key]; !ok || got != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, got)
+		}
+	}
+}
+
+func TestLoadEnvFile_MalformedLine(t *testing.T) {
+	path := writeEnvFile(t, "R2C_OUTPUT=out.md\nnot a pair\n")
+
+	if _, err := LoadEnvFile(path); err == nil {
+		t.Fatal("Expected error for malformed line, got nil")
+	}
+}
+
+func TestLoadEnvFile_MissingFile(t *testing.T) {
+	if _, err := LoadEnvFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Fatal("Expected error for missing file, got nil")
+	}
+}
+
+// Tests for key conversion
+
+func TestConfigKey(t *testing.T) {
+	tests := []struct {
+		envKey   string
+		expected string
+		ok       bool
+	}{
+		{"R2C_OUTPUT", "output", true},
+		{"R2C_NO_GITIGNORE", "no_gitignore", true},
+		{"OUTPUT", "", false},
+		{"R2C_", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ConfigKey(tt.envKey)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("ConfigKey(%q) = %q, %v; expected %q, %v", tt.envKey, got, ok, tt.expected, tt.ok)
+		}
+	}
+	if got := EnvKey("no_gitignore"); got != "R2C_NO_GITIGNORE" {
+		t.Errorf("EnvKey(no_gitignore) = %q", got)
+	}
+}
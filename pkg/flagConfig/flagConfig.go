@@ -1,11 +1,127 @@
 package flagConfig
 
+import "time"
+
 // FlagConfig stores configuration options
 type FlagConfig struct {
 	ConfigFile     string `mapstructure:"config"`
+	EnvFile        string `mapstructure:"env_file"`
 	NoGitignore    bool   `mapstructure:"no_gitignore"`
 	OutputFile     string `mapstructure:"output"`
 	DisplayLineNum bool   `mapstructure:"display_line_num"`
 	Verbose        bool   `mapstructure:"verbose"`
 	CountTokens    bool   `mapstructure:"count_tokens"`
+
+	IncludeLanguages []string      `mapstructure:"include_language"`
+	ExcludeLanguages []string      `mapstructure:"exclude_language"`
+	ExcludePatterns  []string      `mapstructure:"exclude"`
+	TemplatePath     string        `mapstructure:"template"`
+	Encoding         string        `mapstructure:"encoding"`
+	NoContent        bool          `mapstructure:"no_content"`
+	ShowGitLog       bool          `mapstructure:"git_log"`
+	GitLogMaxCommits int           `mapstructure:"git_log_commits"`
+	GroupByExtension bool          `mapstructure:"group_by_extension"`
+	OutputFormat     string        `mapstructure:"format"`
+	Prefix           string        `mapstructure:"prefix"`
+	Suffix           string        `mapstructure:"suffix"`
+	FailOnErrors     bool          `mapstructure:"fail_on_errors"`
+	MaxErrors        int           `mapstructure:"max_errors"`
+	AddLanguages     []string      `mapstructure:"add_language"`
+	VerboseJSON      bool          `mapstructure:"verbose_json"`
+	AbortOnError     bool          `mapstructure:"abort_on_error"`
+	NoR2cignore      bool          `mapstructure:"no_r2cignore"`
+	Timeout          time.Duration `mapstructure:"timeout"`
+	Checksum         string        `mapstructure:"checksum"`
+	LineNumberStyle  string        `mapstructure:"line_number_style"`
+	LineEnding       string        `mapstructure:"line_ending"`
+	ShowContributors bool          `mapstructure:"contributors"`
+	MaxContributors  int           `mapstructure:"max_contributors"`
+	ShowBlame        bool          `mapstructure:"blame"`
+	BlameShort       bool          `mapstructure:"blame_short"`
+	TokenLimit       int           `mapstructure:"token_limit"`
+	StdinContent     bool          `mapstructure:"stdin_content"`
+	StdinLabel       string        `mapstructure:"stdin_label"`
+	PathStyle        string        `mapstructure:"path_style"`
+	SanitizePaths    bool          `mapstructure:"sanitize_paths"`
+	CommitHash       string        `mapstructure:"commit_hash"`
+	TreeStyle        string        `mapstructure:"tree_style"`
+	SplitOutput      bool          `mapstructure:"split_output"`
+	OutputDir        string        `mapstructure:"output_dir"`
+	Clipboard        bool          `mapstructure:"clipboard"`
+	Model            string        `mapstructure:"model"`
+	FormatOverride   string        `mapstructure:"format_override"`
+	SkipGenerated    bool          `mapstructure:"skip_generated"`
+	NoSummary        bool          `mapstructure:"no_summary"`
+	NoGitInfo        bool          `mapstructure:"no_git_info"`
+	NoStructure      bool          `mapstructure:"no_structure"`
+	ShowGitStatus    bool          `mapstructure:"show_git_status"`
+	OnlyErrors       bool          `mapstructure:"only_errors"`
+	Merge            bool          `mapstructure:"merge"`
+	OutputEncoding   string        `mapstructure:"output_encoding"`
+	PruneEmptyDirs   bool          `mapstructure:"prune_empty_dirs"`
+	MaxPaths         int           `mapstructure:"max_paths"`
+	ExcludePaths     []string      `mapstructure:"exclude_path"`
+	MaxTokensPerFile int           `mapstructure:"max_tokens_per_file"`
+	StripComments    bool          `mapstructure:"strip_comments"`
+	CollapsibleFiles bool          `mapstructure:"collapsible_files"`
+	NoCollapse       []string      `mapstructure:"no_collapse"`
+	IncludeGitConfig bool          `mapstructure:"include_git_config"`
+	OutputMode       string        `mapstructure:"output_mode"`
+	ContextWindow    int           `mapstructure:"context_window"`
+	RepoName         string        `mapstructure:"repo_name"`
+	RepoDescription  string        `mapstructure:"repo_description"`
+	SummaryOnly      bool          `mapstructure:"summary_only"`
+	TokenDensity     bool          `mapstructure:"token_density"`
+	TreeShowDensity  bool          `mapstructure:"tree_show_density"`
+	GitHubActions    bool          `mapstructure:"github_actions"`
+	FollowSymlinks   bool          `mapstructure:"follow_symlinks"`
+	Retry            int           `mapstructure:"retry"`
+	RetryDelay       time.Duration `mapstructure:"retry_delay"`
+	MinFileSizeBytes int64         `mapstructure:"min_file_size"`
+
+	// Disabling every ignore file layer (.gitignore, .git/info/exclude, .r2cignore)
+	IncludeGitignored bool `mapstructure:"include_gitignored"`
+
+	// Parallel token counting (0 workers means one per CPU)
+	TokenCountWorkers int `mapstructure:"token_count_workers"`
+
+	// Approximating token counts at 4 bytes per token instead of encoding
+	UseEstimatedTokens bool `mapstructure:"estimate_tokens"`
+
+	// Timestamp layout of versioned output files (see --output-mode version)
+	OutputVersionFormat string `mapstructure:"output_version_format"`
+
+	// Reading files in the charset their .editorconfig declares
+	RespectEditorConfig bool `mapstructure:"respect_editorconfig"`
+
+	// Keeping doc comments when stripping comments
+	PreserveDocComments bool `mapstructure:"preserve_doc_comments"`
+
+	// Per-file header format (empty means the built-in header)
+	FileHeaderTemplate string `mapstructure:"file_header_template"`
+
+	// Lock file filtering
+	SkipLockFiles      bool     `mapstructure:"skip_lock_files"`
+	IncludeLockFiles   bool     `mapstructure:"include_lock_files"`
+	SkipLockFilesExtra []string `mapstructure:"skip_lock_files_extra"`
+
+	// Test file filtering (empty patterns mean scanner.DefaultTestFilePatterns)
+	ExcludeTestFiles bool     `mapstructure:"exclude_test_files"`
+	TestFilePatterns []string `mapstructure:"test_file_patterns"`
+	IncludePatterns  []string `mapstructure:"include"`
+
+	// Caching the scan of a directory (0 TTL means the cache never expires)
+	SaveScanResult string        `mapstructure:"save_scan_result"`
+	LoadScanResult string        `mapstructure:"load_scan_result"`
+	ScanResultTTL  time.Duration `mapstructure:"scan_result_ttl"`
+
+	// Cloning GitHub URLs given as paths
+	GitHubToken       string `mapstructure:"github_token"`
+	NoCloneSubmodules bool   `mapstructure:"no_clone_submodules"`
+	CloneCacheDir     string `mapstructure:"clone_cache_dir"`
+
+	// Overrides re-including paths that .gitignore commonly excludes
+	IncludeVendor      bool `mapstructure:"include_vendor"`
+	IncludeNodeModules bool `mapstructure:"include_node_modules"`
+	IncludeGenerated   bool `mapstructure:"include_generated"`
 }
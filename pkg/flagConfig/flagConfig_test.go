@@ -0,0 +1,87 @@
+package flagConfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests for FlagConfig.Validate
+
+func TestValidate_AcceptsDefaults(t *testing.T) {
+	cfg := FlagConfig{Encoding: "cl100k_base", OutputFormat: "markdown"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected zero-value options to be valid, got %v", err)
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *FlagConfig)
+		wantErr string
+	}{
+		{"timeout zero", func(cfg *FlagConfig) { cfg.Timeout = 0 }, ""},
+		{"timeout positive", func(cfg *FlagConfig) { cfg.Timeout = 30 * time.Second }, ""},
+		{"timeout negative", func(cfg *FlagConfig) { cfg.Timeout = -time.Second }, "--timeout"},
+		{"retry negative", func(cfg *FlagConfig) { cfg.Retry = -1 }, "--retry must"},
+		{"retry delay negative", func(cfg *FlagConfig) { cfg.Retry = 3; cfg.RetryDelay = -time.Millisecond }, "--retry-delay"},
+		{"scan result ttl negative", func(cfg *FlagConfig) { cfg.ScanResultTTL = -time.Hour }, "--scan-result-ttl"},
+		{"token limit with output", func(cfg *FlagConfig) { cfg.TokenLimit = 100; cfg.OutputFile = "out.md" }, ""},
+		{"token limit without output", func(cfg *FlagConfig) { cfg.TokenLimit = 100 }, "--token-limit requires --output"},
+		{"token limit negative", func(cfg *FlagConfig) { cfg.TokenLimit = -1 }, "--token-limit"},
+		{"max tokens per file negative", func(cfg *FlagConfig) { cfg.MaxTokensPerFile = -1 }, "--max-tokens-per-file"},
+		{"max errors negative", func(cfg *FlagConfig) { cfg.MaxErrors = -1 }, "--max-errors"},
+		{"max contributors negative", func(cfg *FlagConfig) { cfg.MaxContributors = -1 }, "--max-contributors"},
+		{"git log commits negative", func(cfg *FlagConfig) { cfg.GitLogMaxCommits = -1 }, "--git-log-commits"},
+		{"max paths unlimited", func(cfg *FlagConfig) { cfg.MaxPaths = -1 }, ""},
+		{"max paths below unlimited", func(cfg *FlagConfig) { cfg.MaxPaths = -2 }, "--max-paths"},
+		{"token count workers negative", func(cfg *FlagConfig) { cfg.TokenCountWorkers = -1 }, "--token-count-workers"},
+		{"negative context window", func(cfg *FlagConfig) { cfg.ContextWindow = -1 }, "--context-window must not be negative"},
+		{"split output with dir", func(cfg *FlagConfig) { cfg.SplitOutput = true; cfg.OutputDir = "out" }, ""},
+		{"split output without dir", func(cfg *FlagConfig) { cfg.SplitOutput = true }, "--split-output requires --output-dir"},
+		{"append mode with output", func(cfg *FlagConfig) { cfg.OutputMode = "append"; cfg.OutputFile = "out.md" }, ""},
+		{"version mode without output", func(cfg *FlagConfig) { cfg.OutputMode = "version" }, "--output-mode version requires --output"},
+		{"unknown output mode", func(cfg *FlagConfig) { cfg.OutputMode = "rotate" }, "unsupported output mode"},
+		{"version format with separator", func(cfg *FlagConfig) { cfg.OutputVersionFormat = "2006/01/02" }, "path separator"},
+		{"preserve doc comments with strip", func(cfg *FlagConfig) { cfg.StripComments = true; cfg.PreserveDocComments = true }, ""},
+		{"preserve doc comments without strip", func(cfg *FlagConfig) { cfg.PreserveDocComments = true }, "--preserve-doc-comments requires --strip-comments"},
+		{"summary only", func(cfg *FlagConfig) { cfg.SummaryOnly = true }, ""},
+		{"summary only with no summary", func(cfg *FlagConfig) { cfg.SummaryOnly = true; cfg.NoSummary = true }, "--summary-only cannot be combined"},
+		{"known encoding", func(cfg *FlagConfig) { cfg.Encoding = "o200k_base" }, ""},
+		{"unknown encoding", func(cfg *FlagConfig) { cfg.Encoding = "bogus" }, "bogus"},
+		{"supported format", func(cfg *FlagConfig) { cfg.OutputFormat = "json-lines" }, ""},
+		{"unsupported format", func(cfg *FlagConfig) { cfg.OutputFormat = "bogus" }, "bogus"},
+		{"supported output encoding", func(cfg *FlagConfig) { cfg.OutputEncoding = "utf-16-le" }, ""},
+		{"unsupported output encoding", func(cfg *FlagConfig) { cfg.OutputEncoding = "bogus" }, "bogus"},
+		{"unknown checksum", func(cfg *FlagConfig) { cfg.Checksum = "bogus" }, "bogus"},
+		{"unknown line number style", func(cfg *FlagConfig) { cfg.LineNumberStyle = "bogus" }, "bogus"},
+		{"unknown line ending", func(cfg *FlagConfig) { cfg.LineEnding = "bogus" }, "bogus"},
+		{"unknown path style", func(cfg *FlagConfig) { cfg.PathStyle = "bogus" }, "bogus"},
+		{"unknown tree style", func(cfg *FlagConfig) { cfg.TreeStyle = "bogus" }, "bogus"},
+		{"unknown format override", func(cfg *FlagConfig) { cfg.FormatOverride = "bogus" }, "bogus"},
+		{"broken header template", func(cfg *FlagConfig) { cfg.FileHeaderTemplate = "{{.Path" }, "template"},
+		{"unknown model", func(cfg *FlagConfig) { cfg.Model = "bogus" }, "bogus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := FlagConfig{Encoding: "cl100k_base", OutputFormat: "markdown"}
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package flagConfig
+
+import (
+	"fmt"
+
+	"github.com/BHChen24/repo2context/pkg/formatter"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
+)
+
+// FieldError is an invalid value of a named option, keyed as in the config file
+type FieldError struct {
+	Key string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks option values and combinations before any scanning, so
+// mistakes fail fast with a message naming the flag
+// It returns the first of the ValidateAll errors
+func (cfg *FlagConfig) Validate() error {
+	if errs := cfg.ValidateAll(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateAll returns every problem Validate checks for, in the same order
+// Invalid named values are reported as *FieldError
+func (cfg *FlagConfig) ValidateAll() []error {
+	var errs []error
+
+	// Counts and durations
+	if cfg.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("--timeout must not be negative (got %s)", cfg.Timeout))
+	}
+	if cfg.TokenLimit < 0 {
+		errs = append(errs, fmt.Errorf("--token-limit must not be negative (got %d)", cfg.TokenLimit))
+	}
+	if cfg.MaxTokensPerFile < 0 {
+		errs = append(errs, fmt.Errorf("--max-tokens-per-file must not be negative (got %d)", cfg.MaxTokensPerFile))
+	}
+	if cfg.MaxErrors < 0 {
+		errs = append(errs, fmt.Errorf("--max-errors must not be negative (got %d)", cfg.MaxErrors))
+	}
+	if cfg.MaxContributors < 0 {
+		errs = append(errs, fmt.Errorf("--max-contributors must not be negative (got %d)", cfg.MaxContributors))
+	}
+	if cfg.GitLogMaxCommits < 0 {
+		errs = append(errs, fmt.Errorf("--git-log-commits must not be negative (got %d)", cfg.GitLogMaxCommits))
+	}
+	if cfg.MaxPaths < -1 {
+		errs = append(errs, fmt.Errorf("--max-paths must be -1 (unlimited) or more (got %d)", cfg.MaxPaths))
+	}
+	if cfg.ContextWindow < 0 {
+		errs = append(errs, fmt.Errorf("--context-window must not be negative (got %d)", cfg.ContextWindow))
+	}
+	if cfg.TokenCountWorkers < 0 {
+		errs = append(errs, fmt.Errorf("--token-count-workers must not be negative (got %d)", cfg.TokenCountWorkers))
+	}
+	if cfg.MinFileSizeBytes < 0 {
+		errs = append(errs, fmt.Errorf("--min-file-size must not be negative (got %d)", cfg.MinFileSizeBytes))
+	}
+	if cfg.Retry < 0 {
+		errs = append(errs, fmt.Errorf("--retry must not be negative (got %d)", cfg.Retry))
+	}
+	if cfg.RetryDelay < 0 {
+		errs = append(errs, fmt.Errorf("--retry-delay must not be negative (got %s)", cfg.RetryDelay))
+	}
+	if cfg.ScanResultTTL < 0 {
+		errs = append(errs, fmt.Errorf("--scan-result-ttl must not be negative (got %s)", cfg.ScanResultTTL))
+	}
+
+	// Flag combinations
+	if cfg.TokenLimit > 0 && cfg.OutputFile == "" {
+		errs = append(errs, fmt.Errorf("--token-limit requires --output to name the part files"))
+	}
+	if cfg.SplitOutput && cfg.OutputDir == "" {
+		errs = append(errs, fmt.Errorf("--split-output requires --output-dir"))
+	}
+	if (cfg.OutputMode == formatter.OutputModeAppend || cfg.OutputMode == formatter.OutputModeVersion) && cfg.OutputFile == "" {
+		errs = append(errs, fmt.Errorf("--output-mode %s requires --output", cfg.OutputMode))
+	}
+	if cfg.SummaryOnly && (cfg.OnlyErrors || cfg.NoSummary) {
+		errs = append(errs, fmt.Errorf("--summary-only cannot be combined with --only-errors or --no-summary"))
+	}
+	if cfg.PreserveDocComments && !cfg.StripComments {
+		errs = append(errs, fmt.Errorf("--preserve-doc-comments requires --strip-comments"))
+	}
+
+	// Named values
+	if err := tokencounter.ValidateEncoding(cfg.Encoding); err != nil {
+		errs = append(errs, &FieldError{Key: "encoding", Err: err})
+	}
+	if err := formatter.ValidateFormat(cfg.OutputFormat); err != nil {
+		errs = append(errs, &FieldError{Key: "format", Err: err})
+	}
+	if err := formatter.ValidateOutputEncoding(cfg.OutputEncoding); err != nil {
+		errs = append(errs, &FieldError{Key: "output_encoding", Err: err})
+	}
+	if err := formatter.ValidateOutputMode(cfg.OutputMode); err != nil {
+		errs = append(errs, &FieldError{Key: "output_mode", Err: err})
+	}
+	if cfg.OutputVersionFormat != "" {
+		if err := formatter.ValidateVersionFormat(cfg.OutputVersionFormat); err != nil {
+			errs = append(errs, &FieldError{Key: "output_version_format", Err: err})
+		}
+	}
+	if err := scanner.ValidateChecksum(cfg.Checksum); err != nil {
+		errs = append(errs, &FieldError{Key: "checksum", Err: err})
+	}
+	if err := scanner.ValidateLineNumberStyle(cfg.LineNumberStyle); err != nil {
+		errs = append(errs, &FieldError{Key: "line_number_style", Err: err})
+	}
+	if err := scanner.ValidateLineEnding(cfg.LineEnding); err != nil {
+		errs = append(errs, &FieldError{Key: "line_ending", Err: err})
+	}
+	if err := scanner.ValidatePathStyle(cfg.PathStyle); err != nil {
+		errs = append(errs, &FieldError{Key: "path_style", Err: err})
+	}
+	if err := formatter.ValidateTreeStyle(cfg.TreeStyle); err != nil {
+		errs = append(errs, &FieldError{Key: "tree_style", Err: err})
+	}
+	if err := formatter.ValidateModelFormat(cfg.FormatOverride); err != nil {
+		errs = append(errs, &FieldError{Key: "format_override", Err: err})
+	}
+	if err := formatter.ValidateFileHeaderTemplate(cfg.FileHeaderTemplate); err != nil {
+		errs = append(errs, &FieldError{Key: "file_header_template", Err: err})
+	}
+	if cfg.Model != "" {
+		if _, err := formatter.ModelFormat(cfg.Model); err != nil {
+			errs = append(errs, &FieldError{Key: "model", Err: err})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,56 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Hooks replaced in tests to simulate failures
+var (
+	writeTemp = func(f *os.File, data []byte) error {
+		_, err := f.Write(data)
+		return err
+	}
+	rename = os.Rename
+)
+
+// writeFileAtomic writes data to a temp file in the target directory and
+// renames it over path, so readers never see a partially written file
+// The temp file is removed if any step fails
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomicFunc(path, perm, func(f *os.File) error {
+		return writeTemp(f, data)
+	})
+}
+
+// writeFileAtomicFunc is writeFileAtomic with the content produced by write
+func writeFileAtomicFunc(path string, perm os.FileMode, write func(f *os.File) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()        //nolint:errcheck
+			os.Remove(tmpPath) //nolint:errcheck
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp uses 0600, give the output the usual permissions
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+
+	// The temp file is in the target directory, so the rename never crosses filesystems
+	return rename(tmpPath, path)
+}
@@ -0,0 +1,65 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Supported output file encodings
+const (
+	OutputEncodingUTF8    = "utf-8"
+	OutputEncodingUTF8BOM = "utf-8-bom"
+	OutputEncodingUTF16LE = "utf-16-le"
+	OutputEncodingUTF16BE = "utf-16-be"
+)
+
+// SupportedOutputEncodings lists the values accepted by --output-encoding
+var SupportedOutputEncodings = []string{OutputEncodingUTF8, OutputEncodingUTF8BOM, OutputEncodingUTF16LE, OutputEncodingUTF16BE}
+
+// utf8BOM marks a file as UTF-8 for tools that don't assume it (Excel, Notepad)
+const utf8BOM = "\xef\xbb\xbf"
+
+// ValidateOutputEncoding checks that an output encoding name is supported
+func ValidateOutputEncoding(encoding string) error {
+	if encoding == "" {
+		return nil
+	}
+	for _, supported := range SupportedOutputEncodings {
+		if encoding == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output encoding %q (supported: %s)", encoding, strings.Join(SupportedOutputEncodings, ", "))
+}
+
+// EncodeOutput converts formatted content to the bytes written for an output
+// encoding. An empty encoding means plain UTF-8. UTF-16 output starts with a
+// byte order mark, as Windows applications expect.
+func EncodeOutput(content string, encoding string) ([]byte, error) {
+	return encodeOutput(content, encoding, true)
+}
+
+// encodeOutput converts content to an output encoding, with the byte order
+// mark of encodings that use one only if bom is set (not when appending)
+func encodeOutput(content string, encoding string, bom bool) ([]byte, error) {
+	bomPolicy := unicode.IgnoreBOM
+	if bom {
+		bomPolicy = unicode.UseBOM
+	}
+	switch encoding {
+	case "", OutputEncodingUTF8:
+		return []byte(content), nil
+	case OutputEncodingUTF8BOM:
+		if !bom {
+			return []byte(content), nil
+		}
+		return []byte(utf8BOM + content), nil
+	case OutputEncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, bomPolicy).NewEncoder().Bytes([]byte(content))
+	case OutputEncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, bomPolicy).NewEncoder().Bytes([]byte(content))
+	}
+	return nil, ValidateOutputEncoding(encoding)
+}
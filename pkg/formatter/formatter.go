@@ -1,12 +1,24 @@
 package formatter
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/BHChen24/repo2context/pkg/gitinfo"
+	"github.com/BHChen24/repo2context/pkg/languages"
 	"github.com/BHChen24/repo2context/pkg/scanner"
 )
 
@@ -14,6 +26,127 @@ import (
 type ContextData struct {
 	ScanResult *scanner.ScanResult
 	GitInfo    string
+	Options    FormatOptions
+	// GitPath is where git info is read from, the scan root as given even
+	// when ScanResult.RootPath is later shortened for display
+	GitPath string
+	// IsSingleFile renders the output for a single file argument:
+	// the file's own path as location, no structure, and a per-file summary
+	IsSingleFile bool
+	// NoSummary, NoGitInfo and NoStructure omit the matching sections
+	// to spend fewer tokens on metadata
+	NoSummary   bool
+	NoGitInfo   bool
+	NoStructure bool
+	// GitInfoErr records why git info could not be read when git itself
+	// failed (not installed, corrupted repository); GitInfo is a placeholder then
+	GitInfoErr error
+	// CustomSections are extra sections added by library consumers, e.g.
+	// "## Test Results", rendered by Format at their Position
+	CustomSections []Section
+}
+
+// Section is a custom markdown section written as "## Title" followed by Content
+// A Position left at 0, or set to anything but the constants below, means SectionAtEnd
+type Section struct {
+	Title    string
+	Content  string
+	Position int // one of the SectionAfter constants, or SectionAtEnd
+}
+
+// position returns where the section is written: Position, or SectionAtEnd
+// for the zero value and any value that is not a known position
+func (s Section) position() int {
+	switch s.Position {
+	case SectionAfterHeader, SectionAfterGitInfo, SectionAfterStructure, SectionAfterContents:
+		return s.Position
+	}
+	return SectionAtEnd
+}
+
+// Positions of custom sections. A section whose fixed section is omitted is
+// still written at that point; in the reduced --only-errors and
+// --summary-only outputs, sections without their fixed section come before
+// the summary.
+const (
+	SectionAfterHeader    = 1 // after the title and description
+	SectionAfterGitInfo   = 2
+	SectionAfterStructure = 3
+	SectionAfterContents  = 4 // before the summary
+	SectionAtEnd          = -1
+)
+
+// defaultTitle is the document title when no repository name is known
+const defaultTitle = "Repository Context"
+
+// Title returns the document title, "<name> Repository Context", named after
+// Options.RepoName or else the scanned directory, or "Repository Context"
+func (d *ContextData) Title() string {
+	name := strings.TrimSpace(d.Options.RepoName)
+	if name == "" && d.ScanResult != nil && d.ScanResult.RootPath != "" {
+		name = filepath.Base(d.ScanResult.RootPath)
+	}
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return defaultTitle
+	}
+	return name + " " + defaultTitle
+}
+
+// Supported output formats
+const (
+	MarkdownFormat  = "markdown"
+	JSONLinesFormat = "json-lines"
+	RSTFormat       = "rst"
+)
+
+// SupportedFormats lists the values accepted by --format
+var SupportedFormats = []string{MarkdownFormat, JSONLinesFormat, RSTFormat}
+
+// ValidateFormat checks that an output format name is supported
+func ValidateFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, supported := range SupportedFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q (supported: %s)", format, strings.Join(SupportedFormats, ", "))
+}
+
+// FormatOptions configures which sections are rendered
+type FormatOptions struct {
+	NoContent        bool
+	ShowGitLog       bool
+	GroupByLanguage  bool
+	Checksum         string
+	ShowContributors bool
+	PathStyle        string
+	TreeStyle        string
+	// ShowBlame prefixes each content line with its git author and commit;
+	// BlameShort abbreviates them to initials and a short hash
+	ShowBlame  bool
+	BlameShort bool
+	// FileHeaderTemplate replaces the built-in file header (see FileHeader)
+	FileHeaderTemplate string
+	// OnlyErrors renders only the scan errors and the summary
+	OnlyErrors bool
+	// SummaryOnly renders only the git info and the summary
+	SummaryOnly bool
+	// TokenDensity shows each file's token count and tokens per line in its header
+	TokenDensity bool
+	// CollapsibleFiles wraps each file in a <details> section, except files
+	// matching a NoCollapse glob pattern
+	CollapsibleFiles bool
+	NoCollapse       []string
+	// ContextWindow is the model's context window in tokens; when set and
+	// tokens were counted, the summary shows how much of it the output uses
+	ContextWindow int
+	// RepoName names the repository in the title (see ContextData.Title);
+	// RepoDescription is a tagline shown as a blockquote below it
+	RepoName        string
+	RepoDescription string
 }
 
 // Format generates markdown output from repository context data
@@ -24,45 +157,102 @@ func Format(data interface{}) (string, error) {
 	}
 
 	var output strings.Builder
+	if err := WriteTo(contextData, &output); err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// WriteTo writes the markdown output of Format section by section to w,
+// without holding the whole document in memory
+func WriteTo(contextData *ContextData, w io.Writer) error {
+	output := &errWriter{w: w}
 
 	// Header
-	output.WriteString("# Repository Context\n\n")
+	output.WriteString(fmt.Sprintf("# %s\n\n", contextData.Title()))
+	if description := strings.TrimSpace(contextData.Options.RepoDescription); description != "" {
+		output.WriteString(fmt.Sprintf("> %s\n\n", strings.ReplaceAll(description, "\n", "\n> ")))
+	}
+
+	singleFile := singleFileOf(contextData)
+	writeCustomSections(output, contextData, SectionAfterHeader)
+
+	// Only the scan errors and the summary, for auditing unreadable files
+	if contextData.Options.OnlyErrors {
+		output.WriteString("## Errors\n\n")
+		for _, errMsg := range contextData.ScanResult.Errors {
+			output.WriteString(fmt.Sprintf("- %s\n", errMsg))
+		}
+		if len(contextData.ScanResult.Errors) == 0 {
+			output.WriteString("- No errors\n")
+		}
+		output.WriteString("\n")
+		writeCustomSections(output, contextData, SectionAfterGitInfo, SectionAfterStructure, SectionAfterContents)
+		writeSummary(output, contextData, singleFile)
+		writeEndSections(output, contextData, true)
+		return output.err
+	}
+
+	// Only the aggregate statistics, e.g. for logging context size in CI
+	if contextData.Options.SummaryOnly {
+		if !contextData.NoGitInfo {
+			writeGitInfo(output, contextData)
+		}
+		writeCustomSections(output, contextData, SectionAfterGitInfo, SectionAfterStructure, SectionAfterContents)
+		writeSummary(output, contextData, singleFile)
+		writeEndSections(output, contextData, true)
+		return output.err
+	}
 
 	// File System Location
 	output.WriteString("## File System Location\n\n")
-	output.WriteString(fmt.Sprintf("%s\n\n", contextData.ScanResult.RootPath))
+	if singleFile != nil {
+		output.WriteString(fmt.Sprintf("%s\n\n", singleFile.Path))
+	} else {
+		output.WriteString(fmt.Sprintf("%s\n\n", contextData.ScanResult.RootPath))
+	}
 
 	// Git Info
-	output.WriteString("## Git Info\n\n")
-	if contextData.GitInfo != "" {
-		// Format git info with proper markdown list
-		gitLines := strings.Split(contextData.GitInfo, "\n")
-		for _, line := range gitLines {
-			if strings.TrimSpace(line) != "" {
-				output.WriteString(fmt.Sprintf("- %s\n", line))
-			}
-		}
-	} else {
-		output.WriteString("- Not a git repository\n")
+	if !contextData.NoGitInfo {
+		writeGitInfo(output, contextData)
 	}
-	output.WriteString("\n")
+	writeCustomSections(output, contextData, SectionAfterGitInfo)
 
-	// Structure
-	output.WriteString("## Structure\n\n")
-	output.WriteString("```\n")
-	if contextData.ScanResult.DirectoryTree != "" {
-		output.WriteString(contextData.ScanResult.DirectoryTree)
-	} else {
-		output.WriteString("(empty directory)\n")
+	// Structure is redundant for a single file
+	if singleFile == nil && !contextData.NoStructure {
+		output.WriteString("## Structure\n\n")
+		output.WriteString("```\n")
+		if contextData.ScanResult.DirectoryTree != "" {
+			output.WriteString(structureTree(contextData))
+		} else {
+			output.WriteString("(empty directory)\n")
+		}
+		output.WriteString("```\n\n")
 	}
-	output.WriteString("```\n\n")
+	writeCustomSections(output, contextData, SectionAfterStructure)
 
 	// File Contents
-	output.WriteString("## File Contents\n\n")
+	if !contextData.Options.NoContent {
+		if singleFile != nil {
+			output.WriteString("## File Content\n\n")
+		} else {
+			output.WriteString("## File Contents\n\n")
+		}
+	}
 
+	// A custom file header template replaces the built-in header
+	var headerTemplate *template.Template
+	if contextData.Options.FileHeaderTemplate != "" {
+		var err error
+		if headerTemplate, err = ParseFileHeaderTemplate(contextData.Options.FileHeaderTemplate); err != nil {
+			return err
+		}
+	}
+
+	currentLanguage := ""
 	for _, file := range contextData.ScanResult.Files {
-		// Skip directories
-		if file.IsDir {
+		// Skip directories, or every file when content is omitted
+		if file.IsDir || contextData.Options.NoContent {
 			continue
 		}
 
@@ -76,60 +266,511 @@ func Format(data interface{}) (string, error) {
 			continue
 		}
 
+		// Determine language for syntax highlighting and grouping
+		language := fileLanguage(file)
+
+		// Write language group header when files are grouped by language
+		if contextData.Options.GroupByLanguage && language != currentLanguage {
+			output.WriteString(fmt.Sprintf("### Language: %s\n\n", displayLanguage(language)))
+			currentLanguage = language
+		}
+
 		// Write file header
-		displayPath := file.RelativePath
+		displayPath := scanner.DisplayPath(file, contextData.Options.PathStyle)
 		if displayPath == "" {
 			displayPath = filepath.Base(file.Path)
 		}
-		output.WriteString(fmt.Sprintf("### File: %s (%d bytes)\t", displayPath, file.Size))
+		headerPath := displayPath
+		if file.GitStatus != "" {
+			headerPath += fmt.Sprintf(" [%s]", file.GitStatus)
+		}
 
-		// Write modified time
-		// Refer to: https://pkg.go.dev/time
-		if !file.ModTime.IsZero() {
-			output.WriteString(fmt.Sprintf("(Modified: %s)\n\n", file.ModTime.Format("2006-01-02 15:04:05")))
-		} else {
-			output.WriteString("(Modified: unknown)\n\n")
+		// A collapsible file shows its <summary> line in place of the built-in header
+		collapse := contextData.Options.CollapsibleFiles && !scanner.MatchesAnyGlob(contextData.Options.NoCollapse, file.RelativePath)
+		if collapse {
+			output.WriteString(fmt.Sprintf("<details>\n<summary>%s</summary>\n\n", collapsibleSummary(file, headerPath, contextData.Options.Checksum)))
 		}
 
-		// Determine file extension for syntax highlighting
-		ext := strings.ToLower(filepath.Ext(file.Path))
-		language := getLanguageFromExtension(ext)
+		if headerTemplate != nil {
+			if err := headerTemplate.Execute(output, newFileHeader(file, displayPath)); err != nil {
+				return fmt.Errorf("failed to render header of %s: %w", displayPath, err)
+			}
+			output.WriteString("\n\n")
+		} else if !collapse {
+			output.WriteString(fmt.Sprintf("### File: %s (%s)\t%s\n\n", headerPath, fileDetails(file, contextData.Options), modifiedTime(file)))
+		}
+
+		// Write contributors from git history
+		if contextData.Options.ShowContributors && len(file.Contributors) > 0 {
+			output.WriteString(fmt.Sprintf("**Authors:** %s\n\n", strings.Join(file.Contributors, ", ")))
+		}
+
+		// Write recent commits touching this file
+		if contextData.Options.ShowGitLog {
+			writeGitLog(output, file)
+		}
 
 		// Write file content with syntax highlighting
 		output.WriteString(fmt.Sprintf("```%s\n", language))
-		output.WriteString(file.Content)
-		if !strings.HasSuffix(file.Content, "\n") {
-			output.WriteString("\n")
+		if !contextData.Options.ShowBlame || !writeBlame(output, contextData, file) {
+			output.WriteString(file.Content)
+			if !strings.HasSuffix(file.Content, "\n") {
+				output.WriteString("\n")
+			}
+		}
+		if file.ContinuedInPart > 0 {
+			output.WriteString(fmt.Sprintf("// ... continued in part %d\n", file.ContinuedInPart))
 		}
 
 		// Write file tail
-		output.WriteString("```\n\n")
+		output.WriteString("```\n")
+		if collapse {
+			output.WriteString("</details>\n")
+		}
+		output.WriteString("\n")
 	}
 
+	writeCustomSections(output, contextData, SectionAfterContents)
+
 	// Summary
+	if !contextData.NoSummary {
+		writeSummary(output, contextData, singleFile)
+	}
+	writeEndSections(output, contextData, !contextData.NoSummary)
+
+	return output.err
+}
+
+// writeEndSections writes the SectionAtEnd custom sections, after a blank line
+// ending the summary list if one was written
+func writeEndSections(output *errWriter, contextData *ContextData, afterSummary bool) {
+	atEnd := func(section Section) bool { return section.position() == SectionAtEnd }
+	if afterSummary && slices.ContainsFunc(contextData.CustomSections, atEnd) {
+		output.WriteString("\n")
+	}
+	writeCustomSections(output, contextData, SectionAtEnd)
+}
+
+// writeCustomSections writes the custom sections at the given positions, in
+// the order they were added
+func writeCustomSections(output *errWriter, contextData *ContextData, positions ...int) {
+	for _, section := range contextData.CustomSections {
+		if !slices.Contains(positions, section.position()) {
+			continue
+		}
+		output.WriteString(fmt.Sprintf("## %s\n\n", section.Title))
+		if content := strings.TrimRight(section.Content, "\n"); content != "" {
+			output.WriteString(content + "\n\n")
+		}
+	}
+}
+
+// fileDetails returns the size, lines, token density and checksum shown in
+// the built-in file header
+func fileDetails(file scanner.FileInfo, options FormatOptions) string {
+	details := fmt.Sprintf("%d bytes", file.Size)
+	if file.Lines > 0 {
+		details += fmt.Sprintf(", %d lines", file.Lines)
+	}
+	if options.TokenDensity && file.TokenDensity > 0 {
+		details += fmt.Sprintf(", %d tokens, density: %.1f tok/line", file.TokenCount, file.TokenDensity)
+	}
+	if options.Checksum != "" && file.Hash != "" {
+		details += fmt.Sprintf(", %s: %s", options.Checksum, file.Hash)
+	}
+	return details
+}
+
+// modifiedTime returns "(Modified: 2006-01-02 15:04:05)", or "(Modified: unknown)"
+// Refer to: https://pkg.go.dev/time
+func modifiedTime(file scanner.FileInfo) string {
+	if file.ModTime.IsZero() {
+		return "(Modified: unknown)"
+	}
+	return fmt.Sprintf("(Modified: %s)", file.ModTime.Format("2006-01-02 15:04:05"))
+}
+
+// errWriter writes to an io.Writer, keeping the first error and skipping
+// the writes after it, so sections can be written without checking each write
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write implements io.Writer
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	ew.err = err
+	return n, err
+}
+
+// WriteString writes s, recording any error in ew.err
+func (ew *errWriter) WriteString(s string) {
+	ew.Write([]byte(s)) //nolint:errcheck
+}
+
+// collapsibleSummary returns the <summary> text of a collapsible file:
+// its path, size, checksum if computed and token count if counted
+func collapsibleSummary(file scanner.FileInfo, headerPath string, checksum string) string {
+	details := []string{fmt.Sprintf("%d bytes", file.Size)}
+	if checksum != "" && file.Hash != "" {
+		details = append(details, fmt.Sprintf("%s: %s", checksum, file.Hash))
+	}
+	if file.TokenCount > 0 {
+		details = append(details, fmt.Sprintf("%d tokens", file.TokenCount))
+	}
+	return fmt.Sprintf("%s (%s)", html.EscapeString(headerPath), strings.Join(details, ", "))
+}
+
+// writeGitInfo writes the Git Info section
+func writeGitInfo(output *errWriter, contextData *ContextData) {
+	output.WriteString("## Git Info\n\n")
+	if contextData.GitInfo != "" {
+		// Format git info with proper markdown list
+		gitLines := strings.Split(contextData.GitInfo, "\n")
+		for _, line := range gitLines {
+			if strings.TrimSpace(line) != "" {
+				output.WriteString(fmt.Sprintf("- %s\n", linkRemote(line)))
+			}
+		}
+	} else {
+		output.WriteString("- Not a git repository\n")
+	}
+	output.WriteString("\n")
+}
+
+// writeSummary writes the Summary section
+func writeSummary(output *errWriter, contextData *ContextData, singleFile *scanner.FileInfo) {
 	output.WriteString("## Summary\n\n")
+	writeSummaryList(output, contextData, singleFile)
+}
+
+// writeSummaryList writes the list of the Summary section, with the tokens by
+// directory as a nested list
+func writeSummaryList(output *errWriter, contextData *ContextData, singleFile *scanner.FileInfo) {
 	output.WriteString(fmt.Sprintf("- Total files: %d\n", contextData.ScanResult.TotalFiles))
-	output.WriteString(fmt.Sprintf("- Total lines: %d\n", contextData.ScanResult.TotalLines))
+	if singleFile != nil {
+		output.WriteString(fmt.Sprintf("- Language: %s\n", displayLanguage(fileLanguage(*singleFile))))
+	}
+	if contextData.Options.NoContent {
+		output.WriteString("- Total lines: (content omitted)\n")
+	} else {
+		output.WriteString(fmt.Sprintf("- Total lines: %d\n", contextData.ScanResult.TotalLines))
+	}
+	output.WriteString(fmt.Sprintf("- Total size: %s\n", humanize(contextData.ScanResult.TotalSize)))
 
 	// Add token count if available
-	if contextData.ScanResult.TotalTokens > 0 {
-		output.WriteString(fmt.Sprintf("- Total tokens: %d (o200k_base encoding)\n", contextData.ScanResult.TotalTokens))
+	if contextData.ScanResult.TotalTokens > 0 && contextData.ScanResult.TokensEstimated {
+		label := "Total tokens"
+		if singleFile != nil {
+			label = "Tokens"
+		}
+		output.WriteString(fmt.Sprintf("- %s: ~%s (estimated)\n", label, groupThousands(contextData.ScanResult.TotalTokens)))
+	} else if contextData.ScanResult.TotalTokens > 0 {
+		encoding := contextData.ScanResult.TokenEncoding
+		if encoding == "" {
+			encoding = "o200k_base"
+		}
+		if singleFile != nil {
+			output.WriteString(fmt.Sprintf("- Tokens: %d (%s encoding)\n", contextData.ScanResult.TotalTokens, encoding))
+		} else {
+			output.WriteString(fmt.Sprintf("- Total tokens: %d (%s encoding)\n", contextData.ScanResult.TotalTokens, encoding))
+		}
+	}
+
+	// Show how much of the model's context window the tokens take
+	if contextData.ScanResult.TotalTokens > 0 && contextData.Options.ContextWindow > 0 {
+		tokens, window := contextData.ScanResult.TotalTokens, contextData.Options.ContextWindow
+		output.WriteString(fmt.Sprintf("- Context usage: %s / %s (%.1f%%)\n", groupThousands(tokens), groupThousands(window), ContextUsage(tokens, window)))
+	}
+
+	// Break token counts down by directory
+	if singleFile == nil && len(contextData.ScanResult.TokensByDirectory) > 1 {
+		output.WriteString("- Tokens by directory:\n")
+		output.WriteString(formatTokensByDirectory(contextData.ScanResult.TokensByDirectory, contextData.ScanResult.TokensEstimated))
 	}
 
 	// Add errors if any
 	if len(contextData.ScanResult.Errors) > 0 {
 		output.WriteString(fmt.Sprintf("- Errors encountered: %d\n", len(contextData.ScanResult.Errors)))
 	}
+}
 
-	return output.String(), nil
+// groupThousands formats n with comma thousands separators, e.g. 12,450
+func groupThousands(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
 }
 
-// SaveToFile saves formatted data to a file
+// formatTokensByDirectory renders per-directory token totals as a nested list,
+// the root first and each directory indented under its parent
+// Estimated totals are prefixed with "~"
+func formatTokensByDirectory(totals map[string]int, estimated bool) string {
+	approx := ""
+	if estimated {
+		approx = "~"
+	}
+
+	dirs := make([]string, 0, len(totals))
+	for dir := range totals {
+		if dir != "." {
+			dirs = append(dirs, dir)
+		}
+	}
+	// Keys end in "/", so name order lists every directory before its subdirectories
+	sort.Strings(dirs)
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("  - ./: %s%d tokens\n", approx, totals["."]))
+	for _, dir := range dirs {
+		depth := strings.Count(dir, "/")
+		name := path.Base(dir) + "/"
+		output.WriteString(fmt.Sprintf("%s- %s: %s%d tokens\n", strings.Repeat("  ", depth+1), name, approx, totals[dir]))
+	}
+	return output.String()
+}
+
+// structureTree returns the directory tree in the configured tree style
+func structureTree(contextData *ContextData) string {
+	switch contextData.Options.TreeStyle {
+	case TreeStyleASCII:
+		return FormatASCIITree(contextData.ScanResult, false)
+	case TreeStyleUnicode:
+		return FormatASCIITree(contextData.ScanResult, true)
+	default:
+		return contextData.ScanResult.DirectoryTree
+	}
+}
+
+// singleFileOf returns the file of a single-file context, or nil otherwise
+func singleFileOf(contextData *ContextData) *scanner.FileInfo {
+	if !contextData.IsSingleFile || len(contextData.ScanResult.Files) != 1 {
+		return nil
+	}
+	return &contextData.ScanResult.Files[0]
+}
+
+// fileLanguage returns the language of a file, detecting it from the path if unset
+func fileLanguage(file scanner.FileInfo) string {
+	if file.Language != "" {
+		return file.Language
+	}
+	return languages.Detect(file.Path)
+}
+
+// writeBlame writes the content of a file with each line prefixed by its git
+// author and commit, reporting false when the file has no blame matching its
+// content line for line (untracked, transformed, split across parts)
+func writeBlame(output *errWriter, contextData *ContextData, file scanner.FileInfo) bool {
+	if file.ContinuedInPart > 0 {
+		return false
+	}
+	blame := file.Blame
+	lines := strings.Split(strings.TrimSuffix(file.Content, "\n"), "\n")
+	if len(blame) == 0 || len(blame) != len(lines) {
+		return false
+	}
+
+	short := contextData.Options.BlameShort
+	authors := make([]string, len(blame))
+	width := 0
+	for i, line := range blame {
+		authors[i] = line.Author
+		if short {
+			authors[i] = initials(line.Author)
+		}
+		width = max(width, utf8.RuneCountInString(authors[i]))
+	}
+
+	for i, line := range blame {
+		commit := line.Commit
+		if short && len(commit) > 7 {
+			commit = commit[:7]
+		}
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(authors[i]))
+		// A blank line gets no trailing space after the separator
+		annotated := strings.TrimRight(fmt.Sprintf("%s %s%s |", commit, authors[i], padding), " ")
+		if lines[i] != "" {
+			annotated += " " + lines[i]
+		}
+		output.WriteString(annotated + "\n")
+	}
+	return true
+}
+
+// initials abbreviates a name to the upper-cased first letter of each word
+func initials(name string) string {
+	var abbreviated strings.Builder
+	for _, word := range strings.Fields(name) {
+		first, _ := utf8.DecodeRuneInString(word)
+		abbreviated.WriteRune(unicode.ToUpper(first))
+	}
+	return abbreviated.String()
+}
+
+// writeGitLog writes the recent commits block for a file, skipping files without history
+func writeGitLog(output *errWriter, file scanner.FileInfo) {
+	if len(file.RecentCommits) == 0 {
+		return
+	}
+
+	output.WriteString("**Recent commits:**\n\n")
+	for _, commit := range file.RecentCommits {
+		output.WriteString(fmt.Sprintf("- %s\n", commit))
+	}
+	output.WriteString("\n")
+}
+
+// linkRemote renders a GitHub remote line as a clickable markdown link
+func linkRemote(line string) string {
+	remote, ok := strings.CutPrefix(line, "Remote: ")
+	if !ok {
+		return line
+	}
+
+	url := githubURL(remote)
+	if url == "" {
+		return line
+	}
+	return fmt.Sprintf("Remote: [%s](%s)", remote, url)
+}
+
+// githubURL converts a GitHub remote (https or ssh) to its web URL
+// Returns an empty string for non-GitHub remotes
+func githubURL(remote string) string {
+	var repo string
+	switch {
+	case strings.HasPrefix(remote, "git@github.com:"):
+		repo = strings.TrimPrefix(remote, "git@github.com:")
+	case strings.HasPrefix(remote, "ssh://git@github.com/"):
+		repo = strings.TrimPrefix(remote, "ssh://git@github.com/")
+	case strings.HasPrefix(remote, "https://github.com/"):
+		repo = strings.TrimPrefix(remote, "https://github.com/")
+	default:
+		return ""
+	}
+	return "https://github.com/" + strings.TrimSuffix(repo, ".git")
+}
+
+// displayLanguage capitalizes a language name for section headers (e.g. "go" -> "Go")
+func displayLanguage(language string) string {
+	if language == "" {
+		return language
+	}
+	return strings.ToUpper(language[:1]) + language[1:]
+}
+
+// humanize converts a byte count to a human readable size such as "1.2 MB"
+func humanize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	units := []string{"KB", "MB", "GB", "TB"}
+	value := float64(size) / unit
+	i := 0
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+
+	formatted := strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0")
+	return fmt.Sprintf("%s %s", formatted, units[i])
+}
+
+// Wrap surrounds content with a prefix and suffix on their own lines
+// Escape sequences such as \n and \t in the delimiters are interpreted
+func Wrap(content, prefix, suffix string) string {
+	if prefix != "" {
+		content = unescape(prefix) + "\n" + content
+	}
+	if suffix != "" {
+		content = content + "\n" + unescape(suffix)
+	}
+	return content
+}
+
+// WriteWrapped writes the content produced by write between the prefix and
+// suffix delimiters, as Wrap does for formatted content
+func WriteWrapped(w io.Writer, prefix, suffix string, write func(w io.Writer) error) error {
+	if prefix != "" {
+		if _, err := io.WriteString(w, unescape(prefix)+"\n"); err != nil {
+			return err
+		}
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	if suffix != "" {
+		if _, err := io.WriteString(w, "\n"+unescape(suffix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unescape interprets the escape sequences supported in delimiters
+func unescape(text string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\\`, `\`).Replace(text)
+}
+
+// SaveToFile saves formatted data to a file, streaming it with WriteTo
 func SaveToFile(data interface{}, path string) error {
-	// First format the data
-	content, err := Format(data)
+	contextData, ok := data.(*ContextData)
+	if !ok {
+		return fmt.Errorf("failed to format data: expected *ContextData, got %T", data)
+	}
+
+	return StreamToFile(path, func(w io.Writer) error {
+		return WriteTo(contextData, w)
+	})
+}
+
+// StreamToFile writes the content produced by write to a file as UTF-8 through
+// a buffer, so the content is never held in memory as a whole
+// Like WriteFile the write is atomic
+func StreamToFile(path string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	err := writeFileAtomicFunc(path, 0644, func(f *os.File) error {
+		buffered := bufio.NewWriter(f)
+		if err := write(buffered); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	})
 	if err != nil {
-		return fmt.Errorf("failed to format data: %w", err)
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteFile writes already formatted content to a file as UTF-8
+// The write is atomic: an interrupted write never leaves a truncated file at path
+func WriteFile(content string, path string) error {
+	return WriteFileWithEncoding(content, path, "")
+}
+
+// WriteFileWithEncoding writes already formatted content to a file in one of
+// SupportedOutputEncodings, atomically like WriteFile
+func WriteFileWithEncoding(content string, path string, encoding string) error {
+	data, err := EncodeOutput(content, encoding)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
 	}
 
 	// Ensure the directory exists
@@ -138,8 +779,8 @@ func SaveToFile(data interface{}, path string) error {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
-	// Write to file
-	err = os.WriteFile(path, []byte(content), 0644)
+	// Write to a temp file and rename it into place
+	err = writeFileAtomic(path, data, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write file %s: %w", path, err)
 	}
@@ -149,72 +790,22 @@ func SaveToFile(data interface{}, path string) error {
 
 // NewContextData creates ContextData from scan results
 func NewContextData(scanResult *scanner.ScanResult, rootPath string) (*ContextData, error) {
-	// Get git information
+	// Get git information, continuing without it on failure
 	gitInfo, err := gitinfo.GetGitInfo(rootPath)
-	if err != nil {
-		// If git info fails, continue without it
-		gitInfo = "Not a git repository or git not installed."
+	var gitInfoErr error
+	switch {
+	case errors.Is(err, gitinfo.ErrNotGitRepo):
+		// Empty git info renders as "Not a git repository"
+		gitInfo = ""
+	case err != nil:
+		gitInfo = "Git info unavailable"
+		gitInfoErr = err
 	}
 
 	return &ContextData{
 		ScanResult: scanResult,
 		GitInfo:    gitInfo,
+		GitPath:    rootPath,
+		GitInfoErr: gitInfoErr,
 	}, nil
 }
-
-// getLanguageFromExtension returns syntax highlighting language for file extensions
-// Will be used in markdown code blocks
-func getLanguageFromExtension(ext string) string {
-	languageMap := map[string]string{
-		".go":         "go",
-		".js":         "javascript",
-		".ts":         "typescript",
-		".py":         "python",
-		".java":       "java",
-		".c":          "c",
-		".cpp":        "cpp",
-		".h":          "c",
-		".hpp":        "cpp",
-		".rs":         "rust",
-		".php":        "php",
-		".rb":         "ruby",
-		".sh":         "bash",
-		".bash":       "bash",
-		".zsh":        "bash",
-		".fish":       "bash",
-		".ps1":        "powershell",
-		".html":       "html",
-		".css":        "css",
-		".scss":       "scss",
-		".sass":       "sass",
-		".json":       "json",
-		".xml":        "xml",
-		".yaml":       "yaml",
-		".yml":        "yaml",
-		".toml":       "toml",
-		".ini":        "ini",
-		".cfg":        "ini",
-		".conf":       "ini",
-		".md":         "markdown",
-		".txt":        "text",
-		".sql":        "sql",
-		".r":          "r",
-		".m":          "matlab",
-		".swift":      "swift",
-		".kt":         "kotlin",
-		".scala":      "scala",
-		".clj":        "clojure",
-		".hs":         "haskell",
-		".lua":        "lua",
-		".vim":        "vim",
-		".dockerfile": "dockerfile",
-		".makefile":   "makefile",
-	}
-
-	if language, exists := languageMap[ext]; exists {
-		return language
-	}
-
-	// Default to text for unknown extensions
-	return "text"
-}
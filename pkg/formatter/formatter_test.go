@@ -0,0 +1,1470 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BHChen24/repo2context/pkg/gitinfo"
+	"github.com/BHChen24/repo2context/pkg/gitinfo/mock"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// Helper Functions
+
+// createMockContextData creates a ContextData with a single Go file for testing
+func createMockContextData() *ContextData {
+	return &ContextData{
+		ScanResult: &scanner.ScanResult{
+			RootPath: "/test/path",
+			Files: []scanner.FileInfo{
+				{
+					Path:         "/test/path/main.go",
+					RelativePath: "main.go",
+					Size:         13,
+					Content:      "package main\n",
+					Language:     "go",
+					TokenCount:   3,
+					Lines:        1,
+				},
+			},
+			DirectoryTree: "main.go\n",
+			TotalFiles:    1,
+			TotalLines:    1,
+			TotalSize:     13,
+			Errors:        []string{},
+		},
+	}
+}
+
+// writeTemplate writes template content to a temporary file and returns its path
+func writeTemplate(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.tmpl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create template file: %v", err)
+	}
+	return path
+}
+
+// Tests for FormatWithTemplate
+
+func TestFormatWithTemplate_HelperFunctions(t *testing.T) {
+	templatePath := writeTemplate(t, `{{range .ScanResult.Files}}<file path="{{.RelativePath}}" lang="{{language .}}" ext="{{ext .}}" tokens="{{tokenCount .}}" size="{{formatSize .Size}}"/>{{end}}`)
+
+	output, err := FormatWithTemplate(createMockContextData(), templatePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `<file path="main.go" lang="go" ext=".go" tokens="3" size="13 B"/>`
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestFormatWithTemplate_MissingFile(t *testing.T) {
+	_, err := FormatWithTemplate(createMockContextData(), "/this/does/not/exist.tmpl")
+	if err == nil {
+		t.Fatal("Expected error for missing template, got nil")
+	}
+}
+
+func TestFormatWithTemplate_InvalidTemplate(t *testing.T) {
+	templatePath := writeTemplate(t, "{{.ScanResult.RootPath")
+
+	_, err := FormatWithTemplate(createMockContextData(), templatePath)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse template") {
+		t.Fatalf("Expected parse error, got %v", err)
+	}
+}
+
+func TestFormatWithTemplate_DefaultTemplateMatchesFormat(t *testing.T) {
+	data := createMockContextData()
+
+	expected, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output, err := FormatWithTemplate(data, filepath.Join("..", "..", "examples", "default.tmpl"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if output != expected {
+		t.Errorf("Default template output differs from Format output\nExpected:\n%s\nGot:\n%s", expected, output)
+	}
+}
+
+// Tests for humanize
+
+func TestHumanize_TableDriven(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{800, "800 B"},
+		{1023, "1023 B"},
+		{1024, "1 KB"},
+		{512 * 1024, "512 KB"},
+		{1536, "1.5 KB"},
+		{1258291, "1.2 MB"},
+		{5 * 1024 * 1024 * 1024, "5 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := humanize(tt.size); got != tt.expected {
+			t.Errorf("humanize(%d) = %q, expected %q", tt.size, got, tt.expected)
+		}
+	}
+}
+
+func TestFormat_SummaryIncludesTotalSize(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.TotalSize = 2048
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "- Total size: 2 KB\n") {
+		t.Errorf("Expected summary to contain total size, got:\n%s", output)
+	}
+}
+
+// Tests for Format options
+
+func TestFormat_NoContentOmitsFileContents(t *testing.T) {
+	data := createMockContextData()
+	data.Options.NoContent = true
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(output, "## File Contents") {
+		t.Error("Expected File Contents section to be omitted")
+	}
+	if !strings.Contains(output, "## Structure") {
+		t.Error("Expected Structure section to be present")
+	}
+	if !strings.Contains(output, "(content omitted)") {
+		t.Error("Expected summary to note that content was omitted")
+	}
+}
+
+func TestFormat_OmitSections(t *testing.T) {
+	data := createMockContextData()
+	data.NoSummary = true
+	data.NoGitInfo = true
+	data.NoStructure = true
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, section := range []string{"## Summary", "## Git Info", "## Structure"} {
+		if strings.Contains(output, section) {
+			t.Errorf("Expected %s section to be omitted, got:\n%s", section, output)
+		}
+	}
+	if !strings.HasPrefix(output, "# path Repository Context\n") || !strings.Contains(output, "## File Contents") {
+		t.Errorf("Expected header and File Contents section, got:\n%s", output)
+	}
+	if !strings.HasSuffix(output, "```\n\n") {
+		t.Errorf("Expected output to end with the last file, got:\n%s", output)
+	}
+}
+
+func TestFormat_OmitSingleSection(t *testing.T) {
+	data := createMockContextData()
+	data.NoGitInfo = true
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if strings.Contains(output, "## Git Info") {
+		t.Error("Expected Git Info section to be omitted")
+	}
+	if !strings.Contains(output, "## Structure") || !strings.Contains(output, "## Summary") {
+		t.Errorf("Expected other sections to be present, got:\n%s", output)
+	}
+}
+
+func TestFormat_ContributorsLine(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files[0].Contributors = []string{"Alice", "Bob"}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(output, "**Authors:**") {
+		t.Error("Expected no authors line when contributors are disabled")
+	}
+
+	data.Options.ShowContributors = true
+	output, err = Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "**Authors:** Alice, Bob\n") {
+		t.Errorf("Expected authors line, got:\n%s", output)
+	}
+}
+
+func TestFormat_ContinuedMarker(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files[0].ContinuedInPart = 3
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "package main\n// ... continued in part 3\n```") {
+		t.Errorf("Expected continuation marker inside the code block, got:\n%s", output)
+	}
+}
+
+func TestFormat_SingleFile(t *testing.T) {
+	data := createMockContextData()
+	data.IsSingleFile = true
+	data.ScanResult.TotalTokens = 3
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "## File System Location\n\n/test/path/main.go\n") {
+		t.Errorf("Expected the file path as location, got:\n%s", output)
+	}
+	if strings.Contains(output, "## Structure") {
+		t.Error("Expected Structure section to be omitted")
+	}
+	if !strings.Contains(output, "## File Content\n") {
+		t.Error("Expected singular File Content header")
+	}
+	if !strings.Contains(output, "- Language: Go\n") || !strings.Contains(output, "- Tokens: 3 (o200k_base encoding)\n") {
+		t.Errorf("Expected language and tokens in summary, got:\n%s", output)
+	}
+}
+
+// Tests for NewContextData
+
+func TestNewContextData_UsesGitInfo(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{IsRepo: true, Info: "Commit: abc123\nRemote: (none)"})
+
+	data, err := NewContextData(createMockContextData().ScanResult, "/test/path")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.GitInfo != "Commit: abc123\nRemote: (none)" {
+		t.Errorf("Expected git info from the client, got %q", data.GitInfo)
+	}
+}
+
+func TestNewContextData_OutsideRepository(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{})
+
+	data, err := NewContextData(createMockContextData().ScanResult, "/test/path")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.GitInfo != "" || data.GitInfoErr != nil {
+		t.Errorf("Expected empty git info and no error, got %q, %v", data.GitInfo, data.GitInfoErr)
+	}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "## Git Info\n\n- Not a git repository\n") {
+		t.Errorf("Expected 'Not a git repository', got:\n%s", output)
+	}
+}
+
+func TestNewContextData_GitErrorIsRecorded(t *testing.T) {
+	gitErr := errors.New("git not installed")
+	mock.Use(t, &mock.MockGitClient{Err: gitErr})
+
+	data, err := NewContextData(createMockContextData().ScanResult, "/test/path")
+	if err != nil {
+		t.Fatalf("Expected git errors not to fail, got %v", err)
+	}
+	if !errors.Is(data.GitInfoErr, gitErr) {
+		t.Errorf("Expected GitInfoErr to hold the git error, got %v", data.GitInfoErr)
+	}
+	if data.GitInfo != "Git info unavailable" {
+		t.Errorf("Expected placeholder git info, got %q", data.GitInfo)
+	}
+}
+
+// Tests for remote links
+
+func TestLinkRemote_TableDriven(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"Remote: https://github.com/owner/repo.git", "Remote: [https://github.com/owner/repo.git](https://github.com/owner/repo)"},
+		{"Remote: git@github.com:owner/repo.git", "Remote: [git@github.com:owner/repo.git](https://github.com/owner/repo)"},
+		{"Remote: https://gitlab.com/owner/repo.git", "Remote: https://gitlab.com/owner/repo.git"},
+		{"Remote: (none)", "Remote: (none)"},
+		{"Branch: main", "Branch: main"},
+	}
+
+	for _, tt := range tests {
+		if got := linkRemote(tt.line); got != tt.expected {
+			t.Errorf("linkRemote(%q) = %q, expected %q", tt.line, got, tt.expected)
+		}
+	}
+}
+
+// Tests for FormatJSONLines
+
+func TestFormatJSONLines_MetaThenFiles(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files = append(data.ScanResult.Files, scanner.FileInfo{
+		Path:         "/test/path/sub",
+		RelativePath: "sub",
+		IsDir:        true,
+	})
+
+	output, err := FormatJSONLines(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines (meta + 1 file), got %d:\n%s", len(lines), output)
+	}
+
+	var meta MetaRecord
+	if err := json.Unmarshal([]byte(lines[0]), &meta); err != nil {
+		t.Fatalf("Failed to parse meta record: %v", err)
+	}
+	if meta.Type != "meta" || meta.Root != "/test/path" {
+		t.Errorf("Unexpected meta record: %+v", meta)
+	}
+
+	var record FileRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatalf("Failed to parse file record: %v", err)
+	}
+	expected := FileRecord{Type: "file", Path: "main.go", Language: "go", Size: 13, Tokens: 3, Content: "package main\n"}
+	if record != expected {
+		t.Errorf("Expected %+v, got %+v", expected, record)
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range []string{"", MarkdownFormat, JSONLinesFormat} {
+		if err := ValidateFormat(format); err != nil {
+			t.Errorf("ValidateFormat(%q) unexpected error: %v", format, err)
+		}
+	}
+	if err := ValidateFormat("xml"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestFormat_TokensByDirectory(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.TotalTokens = 35
+	data.ScanResult.TokensByDirectory = map[string]int{".": 35, "pkg/": 30, "pkg/core/": 10, "pkg/scanner/": 20, "cmd/": 5}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "- Tokens by directory:\n" +
+		"  - ./: 35 tokens\n" +
+		"    - cmd/: 5 tokens\n" +
+		"    - pkg/: 30 tokens\n" +
+		"      - core/: 10 tokens\n" +
+		"      - scanner/: 20 tokens\n"
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected nested token breakdown, got:\n%s", output)
+	}
+
+	// A root-only breakdown repeats the total and is omitted
+	data.ScanResult.TokensByDirectory = map[string]int{".": 35}
+	output, _ = Format(data)
+	if strings.Contains(output, "Tokens by directory") {
+		t.Errorf("Expected no breakdown without subdirectories, got:\n%s", output)
+	}
+}
+
+func TestFormat_GitStatusBadges(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files[0].GitStatus = "M"
+	data.Options.TreeStyle = TreeStyleASCII
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "### File: main.go [M] (13 bytes, 1 lines)") {
+		t.Errorf("Expected status badge in the file header, got:\n%s", output)
+	}
+	if !strings.Contains(output, "\\-- main.go [M]") {
+		t.Errorf("Expected status badge in the tree, got:\n%s", output)
+	}
+}
+
+func TestFormat_OnlyErrors(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Errors = []string{"error reading /test/path/secret.key: permission denied"}
+	data.Options.OnlyErrors = true
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "## Errors\n\n- error reading /test/path/secret.key: permission denied\n") {
+		t.Errorf("Expected errors section, got:\n%s", output)
+	}
+	if !strings.Contains(output, "- Errors encountered: 1\n") {
+		t.Errorf("Expected summary, got:\n%s", output)
+	}
+	for _, section := range []string{"## Git Info", "## Structure", "## File Contents", "package main"} {
+		if strings.Contains(output, section) {
+			t.Errorf("Expected %q to be omitted, got:\n%s", section, output)
+		}
+	}
+}
+
+func TestFormat_TokenDensity(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files[0].TokenDensity = 3
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(output, "density") {
+		t.Errorf("Expected no density without the option, got:\n%s", output)
+	}
+
+	data.Options.TokenDensity = true
+	output, err = Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "### File: main.go (13 bytes, 1 lines, 3 tokens, density: 3.0 tok/line)\t") {
+		t.Errorf("Expected density in the file header, got:\n%s", output)
+	}
+}
+
+func TestFormat_SummaryOnly(t *testing.T) {
+	data := createMockContextData()
+	data.GitInfo = "Branch: main"
+	data.Options.SummaryOnly = true
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "# path Repository Context\n\n## Git Info\n\n- Branch: main\n\n## Summary\n\n- Total files: 1\n- Total lines: 1\n"
+	if !strings.HasPrefix(output, expected) {
+		t.Errorf("Expected only git info and summary, got:\n%s", output)
+	}
+	for _, section := range []string{"## File System Location", "## Structure", "## File Contents", "package main"} {
+		if strings.Contains(output, section) {
+			t.Errorf("Expected %q to be omitted, got:\n%s", section, output)
+		}
+	}
+}
+
+// Tests for FormatRST
+
+// checkRSTHeadings fails the test for any heading whose underline is not as
+// long as its title, which docutils reports as an error
+func checkRSTHeadings(t *testing.T, output string) []string {
+	t.Helper()
+	var headings []string
+	lines := strings.Split(output, "\n")
+	for i := 1; i < len(lines); i++ {
+		underline := lines[i]
+		if underline == "" || strings.Trim(underline, string(underline[0])) != "" || !strings.ContainsRune("=-~", rune(underline[0])) {
+			continue
+		}
+		title := lines[i-1]
+		if utf8.RuneCountInString(title) != len(underline) {
+			t.Errorf("Underline of %q has length %d", title, len(underline))
+		}
+		headings = append(headings, string(underline[0])+" "+title)
+	}
+	return headings
+}
+
+func TestFormatRST_Structure(t *testing.T) {
+	data := createMockContextData()
+	data.GitInfo = "Commit: abc123\nBranch: main"
+	data.ScanResult.TokensByDirectory = map[string]int{".": 3, "pkg/": 2}
+	data.ScanResult.TotalTokens = 3
+	data.Options.RepoDescription = "A CLI tool"
+
+	output, err := FormatRST(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	headings := checkRSTHeadings(t, output)
+	expected := []string{
+		"= path Repository Context",
+		"- File System Location",
+		"- Git Info",
+		"- Structure",
+		"- File Contents",
+		"~ File: main.go",
+		"- Summary",
+	}
+	if strings.Join(headings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected headings %v, got %v", expected, headings)
+	}
+
+	for _, want := range []string{
+		"   A CLI tool\n",
+		".. code-block:: text\n\n   main.go\n\n",
+		"13 bytes, 1 lines (Modified: unknown)\n\n.. code-block:: go\n\n   package main\n\n",
+		"- Commit: abc123\n- Branch: main\n",
+		"- Tokens by directory:\n\n  - ./: 3 tokens\n\n    - pkg/: 2 tokens\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatRST_IndentsBlankLinesAndGroups(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files[0].Content = "package main\n\nfunc main() {}"
+	data.Options.GroupByLanguage = true
+	data.NoGitInfo = true
+	data.NoSummary = true
+
+	output, err := FormatRST(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	checkRSTHeadings(t, output)
+
+	if !strings.Contains(output, ".. rubric:: Language: Go\n\n") {
+		t.Errorf("Expected a language rubric, got:\n%s", output)
+	}
+	if !strings.HasSuffix(output, "   package main\n\n   func main() {}\n\n") {
+		t.Errorf("Expected the indented content last, got:\n%s", output)
+	}
+	if strings.Contains(output, "Git Info") || strings.Contains(output, "Summary") {
+		t.Errorf("Expected omitted sections to be left out, got:\n%s", output)
+	}
+}
+
+// Tests for file header templates
+
+func TestFormat_FileHeaderTemplate(t *testing.T) {
+	data := createMockContextData()
+	data.Options.FileHeaderTemplate = "## {{.Path}} [{{.Language}}, {{.Lines}} lines, {{.TokenCount}} tokens]"
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "## main.go [go, 1 lines, 3 tokens]\n\n```go\n") {
+		t.Errorf("Expected custom file header, got:\n%s", output)
+	}
+	if strings.Contains(output, "### File:") {
+		t.Error("Expected built-in header to be replaced")
+	}
+}
+
+func TestFormat_DefaultFileHeaderTemplateMatchesBuiltIn(t *testing.T) {
+	data := createMockContextData()
+	builtIn, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data.Options.FileHeaderTemplate = DefaultFileHeaderTemplate
+	templated, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if templated != builtIn {
+		t.Errorf("Expected default template to match built-in header\nbuilt-in:\n%s\ntemplate:\n%s", builtIn, templated)
+	}
+}
+
+func TestValidateFileHeaderTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  bool
+	}{
+		{"", false},
+		{DefaultFileHeaderTemplate, false},
+		{"{{.Path}} {{formatSize .Size}}", false},
+		{"{{.Path", true},
+		{"{{.Author}}", true},
+		{"{{nosuchfunc .Path}}", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateFileHeaderTemplate(tt.template)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateFileHeaderTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+		}
+	}
+}
+
+// Tests for the title
+
+func TestContextData_Title(t *testing.T) {
+	tests := []struct {
+		name     string
+		rootPath string
+		repoName string
+		expected string
+	}{
+		{"inferred from directory", "/src/repo2context", "", "repo2context Repository Context"},
+		{"explicit name", "/src/repo2context", "R2C", "R2C Repository Context"},
+		{"filesystem root", "/", "", "Repository Context"},
+		{"no root", "", "", "Repository Context"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := createMockContextData()
+			data.ScanResult.RootPath = filepath.FromSlash(tt.rootPath)
+			data.Options.RepoName = tt.repoName
+			if got := data.Title(); got != tt.expected {
+				t.Errorf("Title() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormat_RepoDescription(t *testing.T) {
+	data := createMockContextData()
+	data.Options.RepoName = "r2c"
+	data.Options.RepoDescription = "Repository to LLM context"
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.HasPrefix(output, "# r2c Repository Context\n\n> Repository to LLM context\n\n## File System Location") {
+		t.Errorf("Expected the title and tagline, got:\n%s", output)
+	}
+}
+
+// Tests for collapsible files
+
+func TestFormat_CollapsibleFiles(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.Files = append(data.ScanResult.Files, scanner.FileInfo{
+		Path:         "/test/path/README.md",
+		RelativePath: "README.md",
+		Size:         8,
+		Content:      "# Title\n",
+		Language:     "markdown",
+	})
+	data.Options.CollapsibleFiles = true
+	data.Options.NoCollapse = []string{"*.md"}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	expected := "<details>\n<summary>main.go (13 bytes, 3 tokens)</summary>\n\n```go\npackage main\n```\n</details>\n\n"
+	if !strings.Contains(output, expected) {
+		t.Errorf("Expected collapsed main.go %q, got:\n%s", expected, output)
+	}
+	if strings.Contains(output, "### File: main.go") {
+		t.Errorf("Expected the summary to replace the header of main.go, got:\n%s", output)
+	}
+	if !strings.Contains(output, "### File: README.md (8 bytes)") || strings.Count(output, "<details>") != 1 {
+		t.Errorf("Expected README.md left expanded by --no-collapse, got:\n%s", output)
+	}
+	if !strings.Contains(output, "## Structure\n\n```\nmain.go") || !strings.Contains(output, "## Summary") {
+		t.Errorf("Expected the other sections uncollapsed, got:\n%s", output)
+	}
+}
+
+// Tests for Wrap
+
+func TestWrap_TableDriven(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		suffix   string
+		expected string
+	}{
+		{"no delimiters", "", "", "body"},
+		{"prefix only", "<ctx>", "", "<ctx>\nbody"},
+		{"suffix only", "", "</ctx>", "body\n</ctx>"},
+		{"both", "<ctx>", "</ctx>", "<ctx>\nbody\n</ctx>"},
+		{"escape sequences", `<ctx>\n`, `\t</ctx>`, "<ctx>\n\nbody\n\t</ctx>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Wrap("body", tt.prefix, tt.suffix); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// Tests for FormatASCIITree
+
+func createTreeScanResult() *scanner.ScanResult {
+	return &scanner.ScanResult{
+		RootPath: "/test/project",
+		Files: []scanner.FileInfo{
+			{RelativePath: "", IsDir: true},
+			{RelativePath: "README.md", TokenCount: 12},
+			{RelativePath: "cmd", IsDir: true},
+			{RelativePath: filepath.Join("cmd", "root.go"), TokenCount: 340},
+			{RelativePath: "pkg", IsDir: true},
+			{RelativePath: filepath.Join("pkg", "core"), IsDir: true},
+			{RelativePath: filepath.Join("pkg", "core", "core.go"), TokenCount: 1500},
+			{RelativePath: filepath.Join("pkg", "util.go"), TokenCount: 7},
+		},
+	}
+}
+
+func TestFormatASCIITree_Unicode(t *testing.T) {
+	expected := "" +
+		"project/\n" +
+		"├── README.md        (12 tokens)\n" +
+		"├── cmd/\n" +
+		"│   └── root.go      (340 tokens)\n" +
+		"└── pkg/\n" +
+		"    ├── core/\n" +
+		"    │   └── core.go  (1500 tokens)\n" +
+		"    └── util.go      (7 tokens)\n"
+
+	if got := FormatASCIITree(createTreeScanResult(), true); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestFormatASCIITree_ASCII(t *testing.T) {
+	expected := "" +
+		"project/\n" +
+		"+-- README.md        (12 tokens)\n" +
+		"+-- cmd/\n" +
+		"|   \\-- root.go      (340 tokens)\n" +
+		"\\-- pkg/\n" +
+		"    +-- core/\n" +
+		"    |   \\-- core.go  (1500 tokens)\n" +
+		"    \\-- util.go      (7 tokens)\n"
+
+	if got := FormatASCIITree(createTreeScanResult(), false); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestFormatASCIITree_TruncatedFile(t *testing.T) {
+	result := createTreeScanResult()
+	result.Files[3].TokenCount = 100
+	result.Files[3].TruncatedFrom = 340
+
+	got := FormatASCIITree(result, false)
+	if !strings.Contains(got, "|   \\-- root.go      (340 tokens, truncated)\n") {
+		t.Errorf("Expected the original count of the truncated file, got:\n%s", got)
+	}
+}
+
+func TestFormat_TreeStyleSelectsStructure(t *testing.T) {
+	data := createMockContextData()
+	data.Options.TreeStyle = TreeStyleUnicode
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "```\npath/\n└── main.go  (3 tokens)\n```") {
+		t.Errorf("Expected unicode tree in Structure section, got:\n%s", output)
+	}
+}
+
+func TestValidateTreeStyle(t *testing.T) {
+	for _, style := range []string{"", TreeStyleIndent, TreeStyleASCII, TreeStyleUnicode} {
+		if err := ValidateTreeStyle(style); err != nil {
+			t.Errorf("ValidateTreeStyle(%q) unexpected error: %v", style, err)
+		}
+	}
+	if err := ValidateTreeStyle("fancy"); err == nil {
+		t.Error("Expected error for unsupported tree style")
+	}
+}
+
+// Tests for context usage
+
+func TestFormat_ContextUsage(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.TotalTokens = 12450
+	data.Options.ContextWindow = 32000
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if !strings.Contains(output, "- Context usage: 12,450 / 32,000 (38.9%)\n") {
+		t.Errorf("Expected context usage in the summary, got:\n%s", output)
+	}
+
+	data.Options.ContextWindow = 0
+	output, _ = Format(data)
+	if strings.Contains(output, "Context usage") {
+		t.Errorf("Expected no context usage without a window, got:\n%s", output)
+	}
+}
+
+func TestModelContextWindow(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected int
+	}{
+		{"claude-sonnet-4", 200000},
+		{"GPT-4o-mini", 128000},
+		{"gpt-4", 8192},
+		{"gpt-4.1", 1047576},
+		{"gemini-2.5-pro", 1048576},
+		{"llama-3", 0},
+	}
+
+	for _, tt := range tests {
+		if got := ModelContextWindow(tt.model); got != tt.expected {
+			t.Errorf("ModelContextWindow(%q) = %d, want %d", tt.model, got, tt.expected)
+		}
+	}
+}
+
+func TestModelNames_KnownFormatAndWindow(t *testing.T) {
+	for _, model := range ModelNames() {
+		if _, err := ModelFormat(model); err != nil {
+			t.Errorf("ModelFormat(%q) error = %v", model, err)
+		}
+		if ModelContextWindow(model) == 0 {
+			t.Errorf("ModelContextWindow(%q) = 0, want a known window", model)
+		}
+	}
+}
+
+func TestGroupThousands(t *testing.T) {
+	tests := map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -32000: "-32,000"}
+	for n, expected := range tests {
+		if got := groupThousands(n); got != expected {
+			t.Errorf("groupThousands(%d) = %q, want %q", n, got, expected)
+		}
+	}
+}
+
+// Tests for FormatContextForModel
+
+func TestModelFormat_Families(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected string
+	}{
+		{"claude-sonnet-4", ModelFormatDocuments},
+		{"Claude-3-Opus", ModelFormatDocuments},
+		{"gpt-4o", ModelFormatMarkdown},
+		{"o3-mini", ModelFormatMarkdown},
+		{"gemini-2.5-pro", ModelFormatSections},
+	}
+
+	for _, tt := range tests {
+		got, err := ModelFormat(tt.model)
+		if err != nil || got != tt.expected {
+			t.Errorf("ModelFormat(%q) = %q, %v; expected %q", tt.model, got, err, tt.expected)
+		}
+	}
+	if _, err := ModelFormat("llama-3"); err == nil {
+		t.Error("Expected error for unknown model family")
+	}
+}
+
+func TestFormatContextForModel_Claude(t *testing.T) {
+	output, err := FormatContextForModel(createMockContextData(), "claude-sonnet-4")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "<documents>\n" +
+		"<document index=\"1\">\n" +
+		"<source>main.go</source>\n" +
+		"<document_content>\n" +
+		"package main\n" +
+		"</document_content>\n" +
+		"</document>\n" +
+		"</documents>\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestFormatContextForModel_Gemini(t *testing.T) {
+	output, err := FormatContextForModel(createMockContextData(), "gemini-2.5-pro")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "## main.go\n```\npackage main\n```\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
+
+func TestFormatContextForModel_OpenAIMatchesFormat(t *testing.T) {
+	data := createMockContextData()
+
+	expected, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	output, err := FormatContextForModel(data, "gpt-4o")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output != expected {
+		t.Errorf("Expected markdown output for OpenAI models")
+	}
+}
+
+func TestValidateModelFormat(t *testing.T) {
+	for _, format := range append([]string{""}, SupportedModelFormats...) {
+		if err := ValidateModelFormat(format); err != nil {
+			t.Errorf("ValidateModelFormat(%q) unexpected error: %v", format, err)
+		}
+	}
+	if err := ValidateModelFormat("yaml"); err == nil {
+		t.Error("Expected error for unsupported format override")
+	}
+}
+
+// Tests for output encodings
+
+func TestEncodeOutput_TableDriven(t *testing.T) {
+	tests := []struct {
+		encoding string
+		expected []byte
+	}{
+		{"", []byte("hé")},
+		{OutputEncodingUTF8, []byte("hé")},
+		{OutputEncodingUTF8BOM, []byte("\xef\xbb\xbfhé")},
+		{OutputEncodingUTF16LE, []byte{0xff, 0xfe, 'h', 0x00, 0xe9, 0x00}},
+		{OutputEncodingUTF16BE, []byte{0xfe, 0xff, 0x00, 'h', 0x00, 0xe9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			data, err := EncodeOutput("hé", tt.encoding)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(data) != string(tt.expected) {
+				t.Errorf("Expected % x, got % x", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestEncodeOutput_UnsupportedEncoding(t *testing.T) {
+	if _, err := EncodeOutput("content", "latin-1"); err == nil {
+		t.Error("Expected error for unsupported encoding")
+	}
+	if err := ValidateOutputEncoding("latin-1"); err == nil || !strings.Contains(err.Error(), "utf-8-bom") {
+		t.Errorf("Expected error listing supported encodings, got %v", err)
+	}
+}
+
+func TestWriteFileWithEncoding_WritesBOM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context.md")
+	if err := WriteFileWithEncoding("# Context\n", path, OutputEncodingUTF8BOM); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "\xef\xbb\xbf# Context\n" {
+		t.Errorf("Expected BOM-prefixed content, got %q", data)
+	}
+}
+
+// Tests for WriteTo
+
+// failingWriter accepts limit bytes and then fails every write
+type failingWriter struct {
+	limit  int
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if len(p) > w.limit {
+		return 0, errors.New("disk full")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteTo_MatchesFormat(t *testing.T) {
+	data := createMockContextData()
+	expected, err := Format(data)
+	if err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTo(data, &buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("Expected WriteTo to write the Format output, got:\n%s", buf.String())
+	}
+}
+
+func TestWriteTo_StopsAtFirstError(t *testing.T) {
+	w := &failingWriter{limit: 10}
+	err := WriteTo(createMockContextData(), w)
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("Expected the write error, got %v", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("Expected no writes after the failure, got %d writes", w.writes)
+	}
+}
+
+func TestSaveToFile_StreamsWrappedContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "context.md")
+	data := createMockContextData()
+	if err := SaveToFile(data, path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected, _ := Format(data)
+	if string(saved) != expected {
+		t.Errorf("Expected the Format output in the file, got:\n%s", saved)
+	}
+
+	var buf bytes.Buffer
+	err = WriteWrapped(&buf, "<ctx>", "</ctx>", func(w io.Writer) error {
+		return WriteTo(data, w)
+	})
+	if err != nil {
+		t.Fatalf("WriteWrapped() error = %v", err)
+	}
+	if buf.String() != Wrap(expected, "<ctx>", "</ctx>") {
+		t.Errorf("Expected WriteWrapped to match Wrap, got:\n%s", buf.String())
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+// Tests for WriteFile
+
+func TestWriteFile_ReplacesExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "context.md")
+	if err := WriteFile("first", path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := WriteFile("second", path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("Expected %q, got %q", "second", data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+func TestWriteFile_FailedWriteLeavesNoPartialFile(t *testing.T) {
+	original := writeTemp
+	defer func() { writeTemp = original }()
+	writeTemp = func(f *os.File, data []byte) error {
+		f.Write(data[:len(data)/2])
+		return errors.New("disk full")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	if err := WriteFile(strings.Repeat("x", 100), path); err == nil {
+		t.Fatal("Expected error for failed write")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no output file, got err %v", err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestWriteFile_FailedWriteKeepsPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	if err := WriteFile("complete", path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	original := writeTemp
+	defer func() { writeTemp = original }()
+	writeTemp = func(f *os.File, data []byte) error {
+		return errors.New("disk full")
+	}
+	if err := WriteFile("new content", path); err == nil {
+		t.Fatal("Expected error for failed write")
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "complete" {
+		t.Errorf("Expected previous content to be kept, got %q", data)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestWriteFile_FailedRenameKeepsPreviousFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	if err := WriteFile("complete", path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	original := rename
+	defer func() { rename = original }()
+	rename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	if err := WriteFile("new content", path); err == nil {
+		t.Fatal("Expected error for failed rename")
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "complete" {
+		t.Errorf("Expected previous content to be kept, got %q", data)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+// assertNoTempFiles fails if any temp file from writeFileAtomic is left in dir
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	matches, _ := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if len(matches) > 0 {
+		t.Errorf("Expected no temp files, found %v", matches)
+	}
+}
+
+// Tests for output modes
+
+func TestVersionedPath(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		path     string
+		layout   string
+		expected string
+	}{
+		{"context.md", "", "context-20250101-120000.md"},
+		{filepath.Join("out", "context.md"), "2006-01-02", filepath.Join("out", "context-2025-01-01.md")},
+		{"context", "", "context-20250101-120000"},
+	}
+
+	for _, tt := range tests {
+		if got := VersionedPath(tt.path, tt.layout, now); got != tt.expected {
+			t.Errorf("VersionedPath(%q, %q) = %q, want %q", tt.path, tt.layout, got, tt.expected)
+		}
+	}
+}
+
+func TestListVersions_OldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "context.md")
+	for _, name := range []string{"context-20250102-000000.md", "context-20250101-000000.md", "context-notes.md", "context.md", "other-20250101-000000.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	versions, err := ListVersions(path, DefaultVersionFormat)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "context-20250101-000000.md"), filepath.Join(dir, "context-20250102-000000.md")}
+	if strings.Join(versions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, versions)
+	}
+}
+
+func TestAppendFileWithEncoding_WritesBOMOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context.md")
+	for _, content := range []string{"first\n", "second\n"} {
+		if err := AppendFileWithEncoding(content, path, OutputEncodingUTF8BOM); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "\xef\xbb\xbffirst\nsecond\n" {
+		t.Errorf("Expected one BOM ahead of both writes, got %q", data)
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+func TestAppendFileWithEncoding_KeepsExistingContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context.md")
+	if err := os.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	if err := AppendFileWithEncoding("second\n", path, ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != "first\nsecond\n" {
+		t.Errorf("Expected appended content, got %q", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file to be appended to in place, keeping mode 0600, got %v (%v)", info.Mode(), err)
+	}
+}
+
+func TestWriteFileWithMode_VersionsInSameSecondDoNotCollide(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "context.md")
+	// A layout without seconds makes every write in this test collide
+	layout := "2006-01-02"
+
+	var written []string
+	for _, content := range []string{"first", "second", "third"} {
+		versioned, err := WriteFileWithMode(content, path, "", OutputModeVersion, layout)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		written = append(written, versioned)
+	}
+
+	stamp := time.Now().Format(layout)
+	base := filepath.Join(filepath.Dir(path), "context-"+stamp)
+	expected := []string{base + ".md", base + "-2.md", base + "-3.md"}
+	if strings.Join(written, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, written)
+	}
+	if data, err := os.ReadFile(expected[0]); err != nil || string(data) != "first" {
+		t.Errorf("Expected the first version to be kept, got %q (%v)", data, err)
+	}
+
+	versions, err := ListVersions(path, layout)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(versions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected versions %v, got %v", expected, versions)
+	}
+}
+
+func TestValidateOutputMode(t *testing.T) {
+	for _, mode := range []string{"", OutputModeOverwrite, OutputModeAppend, OutputModeVersion} {
+		if err := ValidateOutputMode(mode); err != nil {
+			t.Errorf("ValidateOutputMode(%q) error = %v", mode, err)
+		}
+	}
+	if err := ValidateOutputMode("rotate"); err == nil {
+		t.Error("Expected error for unsupported mode")
+	}
+}
+
+// Tests for custom sections
+
+func TestFormat_CustomSectionsAtPositions(t *testing.T) {
+	data := createMockContextData()
+	data.GitInfo = "Commit: abc123"
+	data.CustomSections = []Section{
+		{Title: "Deployment Info", Content: "Deployed to staging\n\n", Position: SectionAtEnd},
+		{Title: "Overview", Content: "A CLI tool", Position: SectionAfterHeader},
+		{Title: "Test Results", Content: "All 42 tests passed", Position: SectionAfterStructure},
+		{Title: "Owners", Content: "@team", Position: SectionAfterGitInfo},
+		{Title: "Notes", Position: SectionAfterContents},
+	}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	order := []string{
+		"# path Repository Context\n\n## Overview\n\nA CLI tool\n\n## File System Location",
+		"## Git Info\n\n- Commit: abc123\n\n## Owners\n\n@team\n\n## Structure",
+		"```\n\n## Test Results\n\nAll 42 tests passed\n\n## File Contents",
+		"```\n\n## Notes\n\n## Summary",
+		"- Total size: 13 B\n\n## Deployment Info\n\nDeployed to staging\n\n",
+	}
+	last := -1
+	for _, want := range order {
+		index := strings.Index(output, want)
+		if index < 0 || index < last {
+			t.Fatalf("Expected %q after the previous sections, got:\n%s", want, output)
+		}
+		last = index
+	}
+	if !strings.HasSuffix(output, "Deployed to staging\n\n") {
+		t.Errorf("Expected the end section last, got:\n%s", output)
+	}
+}
+
+func TestFormat_CustomSectionsWithoutKnownPositionAtEnd(t *testing.T) {
+	data := createMockContextData()
+	data.CustomSections = []Section{
+		{Title: "Unset", Content: "zero position"},
+		{Title: "Unknown", Content: "position 9", Position: 9},
+	}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.HasSuffix(output, "- Total size: 13 B\n\n## Unset\n\nzero position\n\n## Unknown\n\nposition 9\n\n") {
+		t.Errorf("Expected both sections at the end, got:\n%s", output)
+	}
+}
+
+func TestFormat_CustomSectionsInSummaryOnly(t *testing.T) {
+	data := createMockContextData()
+	data.NoGitInfo = true
+	data.Options.SummaryOnly = true
+	data.CustomSections = []Section{{Title: "Test Results", Content: "ok", Position: SectionAfterStructure}}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(output, "## Test Results\n\nok\n\n## Summary") {
+		t.Errorf("Expected the section before the summary, got:\n%s", output)
+	}
+}
+
+// Tests for ShowGitLog
+
+func TestFormat_GitLogListsRecentCommits(t *testing.T) {
+	data := createMockContextData()
+	data.Options.ShowGitLog = true
+	data.ScanResult.Files[0].RecentCommits = []string{"abc1234 Fix parser", "def5678 Initial commit"}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "**Recent commits:**\n\n- abc1234 Fix parser\n- def5678 Initial commit\n\n") {
+		t.Errorf("Expected the recent commits block, got:\n%s", output)
+	}
+	if strings.Count(output, "**Recent commits:**") != 1 {
+		t.Errorf("Expected no block for files without commits, got:\n%s", output)
+	}
+}
+
+// Tests for ShowBlame
+
+func TestFormat_BlameFallsBackWithoutHistory(t *testing.T) {
+	data := createMockContextData()
+	data.Options.ShowBlame = true
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "```go\n"+data.ScanResult.Files[0].Content) {
+		t.Errorf("Expected unannotated content for a file outside git, got:\n%s", output)
+	}
+}
+
+func TestFormat_BlameAnnotatesCollectedLines(t *testing.T) {
+	data := createMockContextData()
+	data.Options.ShowBlame = true
+	data.Options.BlameShort = true
+	data.ScanResult.Files[0].Blame = []gitinfo.BlameLine{
+		{LineNum: 1, Author: "Test User", Commit: "0123456789abcdef", Content: "package main"},
+	}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "```go\n0123456 TU | package main\n```") {
+		t.Errorf("Expected annotated content, got:\n%s", output)
+	}
+}
+
+func TestInitials(t *testing.T) {
+	tests := map[string]string{
+		"Test User":         "TU",
+		"jane q public":     "JQP",
+		"Ádám":              "Á",
+		"Not Committed Yet": "NCY",
+		"":                  "",
+	}
+	for name, want := range tests {
+		if got := initials(name); got != want {
+			t.Errorf("initials(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFormat_EstimatedTokens(t *testing.T) {
+	data := createMockContextData()
+	data.ScanResult.TotalTokens = 12000
+	data.ScanResult.TokensEstimated = true
+	data.ScanResult.TokensByDirectory = map[string]int{".": 12000, "pkg/": 11000}
+
+	output, err := Format(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(output, "- Total tokens: ~12,000 (estimated)\n") {
+		t.Errorf("Expected an approximate total, got:\n%s", output)
+	}
+	if !strings.Contains(output, "  - ./: ~12000 tokens\n    - pkg/: ~11000 tokens\n") {
+		t.Errorf("Expected approximate directory totals, got:\n%s", output)
+	}
+}
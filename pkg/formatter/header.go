@@ -0,0 +1,68 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// DefaultFileHeaderTemplate renders the built-in file header
+const DefaultFileHeaderTemplate = "### File: {{.Path}} ({{.Size}} bytes{{if .Lines}}, {{.Lines}} lines{{end}})\t(Modified: {{.ModTime}})"
+
+// FileHeader is the dot value of a --file-header-template
+type FileHeader struct {
+	Path       string
+	Size       int64
+	ModTime    string // "2006-01-02 15:04:05", or "unknown"
+	TokenCount int
+	Language   string
+	Lines      int // 0 when content wasn't read
+	// TokenDensity is tokens per line with --token-density or --tree-show-density, 0 otherwise
+	TokenDensity float64
+	GitStatus    string // "M", "A", "D" or "?" with --show-git-status, empty if unchanged
+}
+
+// ParseFileHeaderTemplate compiles a file header template and checks it
+// against a sample header, so unknown fields fail before scanning
+func ParseFileHeaderTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("file-header").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file header template: %w", err)
+	}
+	sample := FileHeader{Path: "main.go", Size: 1, ModTime: "unknown", Language: "go", Lines: 1}
+	if err := tmpl.Execute(&strings.Builder{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid file header template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ValidateFileHeaderTemplate checks that a file header template compiles
+// An empty template means the built-in header
+func ValidateFileHeaderTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := ParseFileHeaderTemplate(text)
+	return err
+}
+
+// newFileHeader collects the template variables of a file
+func newFileHeader(file scanner.FileInfo, displayPath string) FileHeader {
+	modTime := "unknown"
+	if !file.ModTime.IsZero() {
+		modTime = file.ModTime.Format(time.DateTime)
+	}
+	return FileHeader{
+		Path:         displayPath,
+		Size:         file.Size,
+		ModTime:      modTime,
+		TokenCount:   file.TokenCount,
+		Language:     fileLanguage(file),
+		Lines:        file.Lines,
+		TokenDensity: file.TokenDensity,
+		GitStatus:    file.GitStatus,
+	}
+}
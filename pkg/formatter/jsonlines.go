@@ -0,0 +1,80 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/languages"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// MetaRecord is the first line of JSON Lines output describing the scan
+type MetaRecord struct {
+	Type    string `json:"type"`
+	Root    string `json:"root"`
+	GitInfo string `json:"git_info"`
+}
+
+// FileRecord is a self-contained JSON Lines record for a single file
+type FileRecord struct {
+	Type     string `json:"type"`
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Size     int64  `json:"size"`
+	Tokens   int    `json:"tokens"`
+	Content  string `json:"content"`
+}
+
+// FormatJSONLines generates one JSON object per line: a meta record followed by a record per file
+func FormatJSONLines(data *ContextData) (string, error) {
+	var output strings.Builder
+
+	if err := writeJSONLine(&output, MetaRecord{
+		Type:    "meta",
+		Root:    data.ScanResult.RootPath,
+		GitInfo: data.GitInfo,
+	}); err != nil {
+		return "", err
+	}
+
+	for _, file := range data.ScanResult.Files {
+		// Skip directories and files with errors
+		if file.IsDir || file.Error != nil {
+			continue
+		}
+
+		path := scanner.DisplayPath(file, data.Options.PathStyle)
+		if path == "" {
+			path = file.Path
+		}
+		language := file.Language
+		if language == "" {
+			language = languages.Detect(file.Path)
+		}
+
+		if err := writeJSONLine(&output, FileRecord{
+			Type:     "file",
+			Path:     path,
+			Language: language,
+			Size:     file.Size,
+			Tokens:   file.TokenCount,
+			Content:  file.Content,
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	return output.String(), nil
+}
+
+// writeJSONLine marshals a record and appends it as a single line
+func writeJSONLine(output *strings.Builder, record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	output.Write(line)
+	output.WriteByte('\n')
+	return nil
+}
@@ -0,0 +1,212 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// Prompt formats preferred by model families
+const (
+	ModelFormatDocuments = "documents" // Claude: <document> XML tags
+	ModelFormatMarkdown  = "markdown"  // OpenAI: the standard markdown output
+	ModelFormatSections  = "sections"  // Gemini: a heading and code block per file
+)
+
+// SupportedModelFormats lists the values accepted by --format-override
+var SupportedModelFormats = []string{ModelFormatDocuments, ModelFormatMarkdown, ModelFormatSections}
+
+// modelFamilies maps model name prefixes to their prompt format
+var modelFamilies = []struct {
+	prefix string
+	format string
+}{
+	{"claude", ModelFormatDocuments},
+	{"gpt", ModelFormatMarkdown},
+	{"chatgpt", ModelFormatMarkdown},
+	{"openai", ModelFormatMarkdown},
+	{"o1", ModelFormatMarkdown},
+	{"o3", ModelFormatMarkdown},
+	{"o4", ModelFormatMarkdown},
+	{"gemini", ModelFormatSections},
+}
+
+// ModelFormat returns the prompt format for a model name such as
+// "claude-sonnet-4" or "gpt-4o", matched on the model family prefix
+func ModelFormat(model string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(model))
+	for _, family := range modelFamilies {
+		if strings.HasPrefix(name, family.prefix) {
+			return family.format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown model family for %q (supported: claude, gpt, o1/o3/o4, gemini)", model)
+}
+
+// modelContextWindows maps model name prefixes to their context window in
+// tokens, most specific prefix first. --context-window overrides these.
+var modelContextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude", 200000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"gemini", 1048576},
+}
+
+// ModelContextWindow returns the context window in tokens of a model such as
+// "claude-sonnet-4" or "gpt-4o", or 0 when it is not known
+func ModelContextWindow(model string) int {
+	name := strings.ToLower(strings.TrimSpace(model))
+	for _, window := range modelContextWindows {
+		if strings.HasPrefix(name, window.prefix) {
+			return window.tokens
+		}
+	}
+	return 0
+}
+
+// modelNames are concrete model IDs suggested by shell completion; any name
+// matching a family in modelFamilies is accepted
+var modelNames = []string{
+	"claude-opus-4-1",
+	"claude-sonnet-4-5",
+	"claude-sonnet-4-0",
+	"claude-haiku-4-5",
+	"claude-3-7-sonnet-latest",
+	"claude-3-5-haiku-latest",
+	"gpt-4.1",
+	"gpt-4.1-mini",
+	"gpt-4.1-nano",
+	"gpt-4o",
+	"gpt-4o-mini",
+	"gpt-4-turbo",
+	"gpt-3.5-turbo",
+	"o1",
+	"o3",
+	"o3-mini",
+	"o4-mini",
+	"gemini-2.5-pro",
+	"gemini-2.5-flash",
+	"gemini-2.0-flash",
+}
+
+// ModelNames lists concrete model IDs with a known context window, for completion
+func ModelNames() []string {
+	return append([]string(nil), modelNames...)
+}
+
+// ContextUsage returns the share of a context window taken by tokens, in percent
+func ContextUsage(tokens, window int) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return float64(tokens) * 100 / float64(window)
+}
+
+// ValidateModelFormat checks that a --format-override value is supported
+// An empty format means no override
+func ValidateModelFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, supported := range SupportedModelFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported format override %q (supported: %s)", format, strings.Join(SupportedModelFormats, ", "))
+}
+
+// FormatContextForModel renders the context in the prompt format preferred by the model's family
+func FormatContextForModel(data *ContextData, model string) (string, error) {
+	format, err := ModelFormat(model)
+	if err != nil {
+		return "", err
+	}
+	return FormatModelFormat(data, format)
+}
+
+// FormatModelFormat renders the context in a prompt format from SupportedModelFormats
+func FormatModelFormat(data *ContextData, format string) (string, error) {
+	switch format {
+	case ModelFormatDocuments:
+		return formatDocuments(data), nil
+	case ModelFormatSections:
+		return formatSections(data), nil
+	case ModelFormatMarkdown:
+		return Format(data)
+	default:
+		return "", ValidateModelFormat(format)
+	}
+}
+
+// formatDocuments wraps each file in Claude's multi-document XML structure
+func formatDocuments(data *ContextData) string {
+	var output strings.Builder
+
+	output.WriteString("<documents>\n")
+	for i, file := range promptFiles(data) {
+		output.WriteString(fmt.Sprintf("<document index=\"%d\">\n", i+1))
+		output.WriteString(fmt.Sprintf("<source>%s</source>\n", promptPath(data, file)))
+		output.WriteString("<document_content>\n")
+		output.WriteString(withTrailingNewline(file.Content))
+		output.WriteString("</document_content>\n")
+		output.WriteString("</document>\n")
+	}
+	output.WriteString("</documents>\n")
+
+	return output.String()
+}
+
+// formatSections writes each file as a heading followed by a code block
+func formatSections(data *ContextData) string {
+	var output strings.Builder
+
+	for i, file := range promptFiles(data) {
+		if i > 0 {
+			output.WriteString("\n")
+		}
+		output.WriteString(fmt.Sprintf("## %s\n```\n", promptPath(data, file)))
+		output.WriteString(withTrailingNewline(file.Content))
+		output.WriteString("```\n")
+	}
+
+	return output.String()
+}
+
+// promptFiles returns the files with content, skipping directories, errors and empty files
+func promptFiles(data *ContextData) []scanner.FileInfo {
+	var files []scanner.FileInfo
+	for _, file := range data.ScanResult.Files {
+		if file.IsDir || file.Error != nil || strings.TrimSpace(file.Content) == "" {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// promptPath returns the display path of a file in the configured path style
+func promptPath(data *ContextData, file scanner.FileInfo) string {
+	if path := scanner.DisplayPath(file, data.Options.PathStyle); path != "" {
+		return path
+	}
+	return file.Path
+}
+
+// withTrailingNewline ensures content ends with a newline
+func withTrailingNewline(content string) string {
+	if strings.HasSuffix(content, "\n") {
+		return content
+	}
+	return content + "\n"
+}
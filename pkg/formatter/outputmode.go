@@ -0,0 +1,197 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported output modes for an existing output file
+const (
+	OutputModeOverwrite = "overwrite" // replace the file
+	OutputModeAppend    = "append"    // add to the end of the file
+	OutputModeVersion   = "version"   // write a new file with a timestamp suffix
+)
+
+// DefaultVersionFormat is the Go time layout of the timestamp in versioned file names
+const DefaultVersionFormat = "20060102-150405"
+
+// ValidateOutputMode checks that an output mode is supported
+// An empty mode means OutputModeOverwrite
+func ValidateOutputMode(mode string) error {
+	switch mode {
+	case "", OutputModeOverwrite, OutputModeAppend, OutputModeVersion:
+		return nil
+	default:
+		return fmt.Errorf("unsupported output mode %q (supported: %s, %s, %s)", mode, OutputModeOverwrite, OutputModeAppend, OutputModeVersion)
+	}
+}
+
+// ValidateVersionFormat checks that a time layout makes a usable file name suffix
+func ValidateVersionFormat(layout string) error {
+	stamp := time.Now().Format(layout)
+	if strings.TrimSpace(stamp) == "" {
+		return fmt.Errorf("invalid output version format %q: it formats to an empty suffix", layout)
+	}
+	if strings.ContainsAny(stamp, `/\`) {
+		return fmt.Errorf("invalid output version format %q: file name suffix %q contains a path separator", layout, stamp)
+	}
+	return nil
+}
+
+// VersionedPath inserts a timestamp before the extension of path, e.g.
+// context.md becomes context-20250101-120000.md with DefaultVersionFormat
+func VersionedPath(path string, layout string, now time.Time) string {
+	if layout == "" {
+		layout = DefaultVersionFormat
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + now.Format(layout) + ext
+}
+
+// ListVersions returns the versioned files of path written with layout, oldest first
+// Versions written in the same second, told apart by a "-2", "-3" ... suffix,
+// follow the first one in that order
+func ListVersions(path string, layout string) ([]string, error) {
+	if layout == "" {
+		layout = DefaultVersionFormat
+	}
+	dir := filepath.Dir(path)
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type version struct {
+		path string
+		time time.Time
+		seq  int
+	}
+	var versions []version
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if t, seq, ok := parseVersionStamp(stamp, layout); ok {
+			versions = append(versions, version{filepath.Join(dir, name), t, seq})
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		if !versions[i].time.Equal(versions[j].time) {
+			return versions[i].time.Before(versions[j].time)
+		}
+		return versions[i].seq < versions[j].seq
+	})
+
+	paths := make([]string, len(versions))
+	for i, v := range versions {
+		paths[i] = v.path
+	}
+	return paths, nil
+}
+
+// parseVersionStamp parses the timestamp of a versioned file name and its
+// sequence number, 1 for the first version of a second and 2 or more for the
+// "-N" suffixed ones after it
+func parseVersionStamp(stamp, layout string) (time.Time, int, bool) {
+	if t, err := time.Parse(layout, stamp); err == nil {
+		return t, 1, true
+	}
+	i := strings.LastIndex(stamp, "-")
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(stamp[i+1:])
+	if err != nil || seq < 2 {
+		return time.Time{}, 0, false
+	}
+	t, err := time.Parse(layout, stamp[:i])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// AppendFileWithEncoding adds already formatted content to the end of a file,
+// creating it if needed. The byte order mark of the encoding is only written
+// to a new file. Unlike WriteFileWithEncoding the write is not atomic: the
+// file is opened for appending so its existing content is not read or rewritten.
+func AppendFileWithEncoding(content string, path string, encoding string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to read file %s: %w", path, err), file.Close())
+	}
+	data, err := encodeOutput(content, encoding, info.Size() == 0)
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to encode %s: %w", path, err), file.Close())
+	}
+	if _, err := file.Write(data); err != nil {
+		return errors.Join(fmt.Errorf("failed to write file %s: %w", path, err), file.Close())
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteFileWithMode writes already formatted content according to an output
+// mode and returns the path written, which differs from path in version mode
+func WriteFileWithMode(content, path, encoding, mode, versionFormat string) (string, error) {
+	switch mode {
+	case OutputModeAppend:
+		return path, AppendFileWithEncoding(content, path, encoding)
+	case OutputModeVersion:
+		return writeVersion(content, path, encoding, versionFormat)
+	default:
+		return path, WriteFileWithEncoding(content, path, encoding)
+	}
+}
+
+// writeVersion writes content to a new versioned file of path and returns its
+// name. A version written earlier in the same second is never replaced: the
+// new one gets a "-2", "-3" ... suffix, claimed by creating the file exclusively.
+func writeVersion(content, path, encoding, versionFormat string) (string, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	versioned := VersionedPath(path, versionFormat, time.Now())
+	ext := filepath.Ext(versioned)
+	candidate := versioned
+	for seq := 2; ; seq++ {
+		file, err := os.OpenFile(candidate, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close() //nolint:errcheck
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", fmt.Errorf("failed to create file %s: %w", candidate, err)
+		}
+		candidate = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(versioned, ext), seq, ext)
+	}
+
+	if err := WriteFileWithEncoding(content, candidate, encoding); err != nil {
+		os.Remove(candidate) //nolint:errcheck
+		return "", err
+	}
+	return candidate, nil
+}
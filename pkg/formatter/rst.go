@@ -0,0 +1,171 @@
+package formatter
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// RST heading underlines by level
+const (
+	rstTitle      = '='
+	rstSection    = '-'
+	rstSubsection = '~'
+)
+
+// FormatRST generates reStructuredText output for Sphinx documentation, with
+// the same sections as the markdown output. Code and the directory tree are
+// rendered as code-block directives.
+func FormatRST(data *ContextData) (string, error) {
+	var buf strings.Builder
+	output := &errWriter{w: &buf}
+
+	writeRSTHeading(output, data.Title(), rstTitle)
+	if description := strings.TrimSpace(data.Options.RepoDescription); description != "" {
+		output.WriteString(indentRST(description) + "\n")
+	}
+
+	singleFile := singleFileOf(data)
+
+	// File System Location
+	writeRSTHeading(output, "File System Location", rstSection)
+	if singleFile != nil {
+		output.WriteString(fmt.Sprintf("%s\n\n", singleFile.Path))
+	} else {
+		output.WriteString(fmt.Sprintf("%s\n\n", data.ScanResult.RootPath))
+	}
+
+	// Git Info
+	if !data.NoGitInfo {
+		writeRSTHeading(output, "Git Info", rstSection)
+		if data.GitInfo != "" {
+			for _, line := range strings.Split(data.GitInfo, "\n") {
+				if strings.TrimSpace(line) != "" {
+					output.WriteString(fmt.Sprintf("- %s\n", line))
+				}
+			}
+		} else {
+			output.WriteString("- Not a git repository\n")
+		}
+		output.WriteString("\n")
+	}
+
+	// Structure is redundant for a single file
+	if singleFile == nil && !data.NoStructure {
+		writeRSTHeading(output, "Structure", rstSection)
+		tree := "(empty directory)\n"
+		if data.ScanResult.DirectoryTree != "" {
+			tree = structureTree(data)
+		}
+		writeRSTCodeBlock(output, "text", tree)
+	}
+
+	// File Contents
+	if !data.Options.NoContent {
+		if singleFile != nil {
+			writeRSTHeading(output, "File Content", rstSection)
+		} else {
+			writeRSTHeading(output, "File Contents", rstSection)
+		}
+
+		currentLanguage := ""
+		for _, file := range promptFiles(data) {
+			language := fileLanguage(file)
+
+			// A rubric labels a language group without adding a heading level
+			if data.Options.GroupByLanguage && language != currentLanguage {
+				output.WriteString(fmt.Sprintf(".. rubric:: Language: %s\n\n", displayLanguage(language)))
+				currentLanguage = language
+			}
+
+			displayPath := scanner.DisplayPath(file, data.Options.PathStyle)
+			if displayPath == "" {
+				displayPath = filepath.Base(file.Path)
+			}
+			if file.GitStatus != "" {
+				displayPath += fmt.Sprintf(" [%s]", file.GitStatus)
+			}
+			writeRSTHeading(output, "File: "+displayPath, rstSubsection)
+			output.WriteString(fmt.Sprintf("%s %s\n\n", fileDetails(file, data.Options), modifiedTime(file)))
+
+			if data.Options.ShowContributors && len(file.Contributors) > 0 {
+				output.WriteString(fmt.Sprintf("**Authors:** %s\n\n", strings.Join(file.Contributors, ", ")))
+			}
+			if data.Options.ShowGitLog {
+				writeGitLog(output, file)
+			}
+
+			content := withTrailingNewline(file.Content)
+			if file.ContinuedInPart > 0 {
+				content += fmt.Sprintf("// ... continued in part %d\n", file.ContinuedInPart)
+			}
+			if language == "" {
+				language = "text"
+			}
+			writeRSTCodeBlock(output, language, content)
+		}
+	}
+
+	// Summary
+	if !data.NoSummary {
+		writeRSTHeading(output, "Summary", rstSection)
+		var list strings.Builder
+		writeSummaryList(&errWriter{w: &list}, data, singleFile)
+		output.WriteString(separateNestedLists(list.String()))
+	}
+
+	if output.err != nil {
+		return "", output.err
+	}
+	return buf.String(), nil
+}
+
+// writeRSTHeading writes a heading underlined with marker to its full length
+func writeRSTHeading(output *errWriter, title string, marker rune) {
+	title = strings.ReplaceAll(title, "\n", " ")
+	output.WriteString(fmt.Sprintf("%s\n%s\n\n", title, strings.Repeat(string(marker), utf8.RuneCountInString(title))))
+}
+
+// writeRSTCodeBlock writes content as an indented code-block directive
+func writeRSTCodeBlock(output *errWriter, language, content string) {
+	output.WriteString(fmt.Sprintf(".. code-block:: %s\n\n", language))
+	output.WriteString(indentRST(content))
+	output.WriteString("\n")
+}
+
+// indentRST indents each non-blank line by three spaces, the body of a
+// directive or block quote
+func indentRST(text string) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "   " + line
+		} else {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// separateNestedLists puts a blank line wherever the indentation of a list
+// changes, since RST only nests a list separated from its parent item
+func separateNestedLists(list string) string {
+	var output strings.Builder
+	previousIndent := 0
+	for _, line := range strings.SplitAfter(list, "\n") {
+		if line == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent != previousIndent {
+			output.WriteString("\n")
+		}
+		previousIndent = indent
+		output.WriteString(line)
+	}
+	output.WriteString("\n")
+	return output.String()
+}
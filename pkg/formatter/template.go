@@ -0,0 +1,60 @@
+package formatter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/BHChen24/repo2context/pkg/languages"
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// templateFuncs are the helper functions available inside custom templates
+var templateFuncs = template.FuncMap{
+	"tokenCount": func(file scanner.FileInfo) int {
+		return file.TokenCount
+	},
+	"language": func(file scanner.FileInfo) string {
+		if file.Language != "" {
+			return file.Language
+		}
+		return languages.Detect(file.Path)
+	},
+	"ext": func(file scanner.FileInfo) string {
+		return strings.ToLower(filepath.Ext(file.Path))
+	},
+	"formatSize": humanize,
+	"lines": func(text string) []string {
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	},
+	"trim": strings.TrimSpace,
+}
+
+// FormatWithTemplate generates output by executing a custom text/template file
+// with the context data as the dot value
+func FormatWithTemplate(data *ContextData, templatePath string) (string, error) {
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template %s: %w", templatePath, err)
+	}
+
+	tmpl, err := template.New(filepath.Base(templatePath)).Funcs(templateFuncs).Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template %s: %w", templatePath, err)
+	}
+
+	var output strings.Builder
+	if err := tmpl.Execute(&output, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", templatePath, err)
+	}
+
+	return output.String(), nil
+}
@@ -0,0 +1,151 @@
+package formatter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// Supported directory tree styles
+const (
+	TreeStyleIndent  = "indent"  // two-space indentation (scanner.ScanResult.DirectoryTree)
+	TreeStyleASCII   = "ascii"   // +-- and \-- connectors
+	TreeStyleUnicode = "unicode" // ├── and └── connectors
+)
+
+// ValidateTreeStyle checks that a tree style is supported
+// An empty style means the default indent style
+func ValidateTreeStyle(style string) error {
+	switch style {
+	case "", TreeStyleIndent, TreeStyleASCII, TreeStyleUnicode:
+		return nil
+	default:
+		return fmt.Errorf("unsupported tree style %q (supported: %s, %s, %s)",
+			style, TreeStyleIndent, TreeStyleASCII, TreeStyleUnicode)
+	}
+}
+
+// treeConnectors are the line prefixes of one tree style
+type treeConnectors struct {
+	branch, last, pipe, space string
+}
+
+var (
+	unicodeConnectors = treeConnectors{"├── ", "└── ", "│   ", "    "}
+	asciiConnectors   = treeConnectors{"+-- ", "\\-- ", "|   ", "    "}
+)
+
+// treeNode is a file or directory in the rendered tree
+type treeNode struct {
+	name     string
+	isDir    bool
+	tokens   int
+	status   string
+	children map[string]*treeNode
+
+	// truncated marks tokens as the count before a per-file token limit cut the file
+	truncated bool
+}
+
+// treeLine is a rendered entry and its annotation, aligned when joined
+type treeLine struct {
+	text       string
+	annotation string
+}
+
+// FormatASCIITree renders the scan result as a tree drawn with box-drawing
+// characters, or with ASCII characters when useUnicode is false
+// Token counts are aligned in a column after the names
+func FormatASCIITree(result *scanner.ScanResult, useUnicode bool) string {
+	connectors := asciiConnectors
+	if useUnicode {
+		connectors = unicodeConnectors
+	}
+
+	// Build the tree from relative paths, creating parent directories as needed
+	root := &treeNode{name: filepath.Base(result.RootPath), isDir: true, children: map[string]*treeNode{}}
+	for _, file := range result.Files {
+		if file.RelativePath == "" {
+			continue
+		}
+		node := root
+		parts := strings.Split(filepath.ToSlash(file.RelativePath), "/")
+		for i, part := range parts {
+			child, ok := node.children[part]
+			if !ok {
+				child = &treeNode{name: part, isDir: true, children: map[string]*treeNode{}}
+				node.children[part] = child
+			}
+			if i == len(parts)-1 {
+				child.isDir = file.IsDir
+				child.tokens = file.TokenCount
+				child.status = file.GitStatus
+				if file.TruncatedFrom > 0 {
+					child.tokens, child.truncated = file.TruncatedFrom, true
+				}
+			}
+			node = child
+		}
+	}
+
+	lines := []treeLine{{text: root.name + "/"}}
+	lines = appendTreeLines(lines, root, "", connectors)
+
+	// Align annotations in a column after the widest entry
+	width := 0
+	for _, line := range lines {
+		if line.annotation != "" && utf8.RuneCountInString(line.text) > width {
+			width = utf8.RuneCountInString(line.text)
+		}
+	}
+
+	var output strings.Builder
+	for _, line := range lines {
+		output.WriteString(line.text)
+		if line.annotation != "" {
+			output.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(line.text)+2))
+			output.WriteString(line.annotation)
+		}
+		output.WriteString("\n")
+	}
+	return output.String()
+}
+
+// appendTreeLines renders the children of node in name order
+func appendTreeLines(lines []treeLine, node *treeNode, prefix string, connectors treeConnectors) []treeLine {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		child := node.children[name]
+		connector, childPrefix := connectors.branch, prefix+connectors.pipe
+		if i == len(names)-1 {
+			connector, childPrefix = connectors.last, prefix+connectors.space
+		}
+
+		line := treeLine{text: prefix + connector + child.name}
+		if child.isDir {
+			line.text += "/"
+		} else if child.status != "" {
+			line.text += fmt.Sprintf(" [%s]", child.status)
+		}
+		if !child.isDir && child.tokens > 0 && child.truncated {
+			line.annotation = fmt.Sprintf("(%d tokens, truncated)", child.tokens)
+		} else if !child.isDir && child.tokens > 0 {
+			line.annotation = fmt.Sprintf("(%d tokens)", child.tokens)
+		}
+		lines = append(lines, line)
+
+		if child.isDir {
+			lines = appendTreeLines(lines, child, childPrefix, connectors)
+		}
+	}
+	return lines
+}
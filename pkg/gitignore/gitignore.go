@@ -2,6 +2,8 @@ package gitignore
 
 import (
 	"bufio"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,34 +11,71 @@ import (
 
 // GitIgnore represents a parsed .gitignore file
 type GitIgnore struct {
-	patterns []string
+	patterns []pattern
 	basePath string
 }
 
-// NewGitIgnore creates a GitIgnore instance from a .gitignore file
-func NewGitIgnore(basePath string) (*GitIgnore, error) {
-	gi := &GitIgnore{
-		basePath: basePath,
-		patterns: make([]string, 0),
+// pattern is a parsed ignore pattern and where it came from
+type pattern struct {
+	text   string // as written, e.g. "!build/"
+	glob   string // without the "!" and surrounding slashes
+	negate bool
+	line   int    // line in the ignore file, or 0 for patterns added with AddPattern
+	source string // ignore file named by Explain, empty for the file gi was loaded from
+}
+
+// String returns the pattern as written
+func (p pattern) String() string {
+	return p.text
+}
+
+// position describes where the pattern came from for Explain
+func (p pattern) position() string {
+	if p.line == 0 {
+		return "added by a command-line override"
+	}
+	if p.source != "" {
+		return fmt.Sprintf("at position %d in %s", p.line, p.source)
 	}
+	return fmt.Sprintf("at position %d", p.line)
+}
 
-	gitignorePath := filepath.Join(basePath, ".gitignore")
+// NewGitIgnore creates a GitIgnore instance from a .gitignore file
+func NewGitIgnore(basePath string) (*GitIgnore, error) {
+	return NewGitIgnoreFromFile(basePath, filepath.Join(basePath, ".gitignore"))
+}
 
-	// Check if .gitignore exists
+// NewGitIgnoreFromFile creates a GitIgnore instance from any file using
+// .gitignore syntax (e.g. .r2cignore), with patterns relative to basePath
+func NewGitIgnoreFromFile(basePath, gitignorePath string) (*GitIgnore, error) {
+	// Check if the ignore file exists
 	if _, err := os.Stat(gitignorePath); os.IsNotExist(err) {
-		// Return empty GitIgnore if no .gitignore file
-		return gi, nil
+		// Return empty GitIgnore if no ignore file
+		return NewGitIgnoreFromReader(basePath, strings.NewReader(""))
 	}
 
 	// Read and parse .gitignore file
 	file, err := os.Open(gitignorePath)
 	if err != nil {
-		return gi, err // Return empty GitIgnore on error
+		return &GitIgnore{basePath: basePath, patterns: make([]pattern, 0)}, err // Return empty GitIgnore on error
 	}
 	defer file.Close() //nolint:errcheck
 
-	bufScanner := bufio.NewScanner(file)
+	return NewGitIgnoreFromReader(basePath, file)
+}
+
+// NewGitIgnoreFromReader creates a GitIgnore instance from content in
+// .gitignore syntax, e.g. an ignore file read from an fs.FS
+func NewGitIgnoreFromReader(basePath string, r io.Reader) (*GitIgnore, error) {
+	gi := &GitIgnore{
+		basePath: basePath,
+		patterns: make([]pattern, 0),
+	}
+
+	bufScanner := bufio.NewScanner(r)
+	lineNum := 0
 	for bufScanner.Scan() {
+		lineNum++
 		line := strings.TrimSpace(bufScanner.Text())
 
 		// Skip empty lines and comments
@@ -44,50 +83,221 @@ func NewGitIgnore(basePath string) (*GitIgnore, error) {
 			continue
 		}
 
-		// Remove leading/trailing slashes for simpler matching
-		line = strings.Trim(line, "/")
-		if line != "" {
-			gi.patterns = append(gi.patterns, line)
-		}
+		gi.addPattern(line, lineNum)
 	}
 
 	return gi, bufScanner.Err()
 }
 
+// AddPattern appends a pattern after those loaded from the ignore file
+// A pattern starting with "!" re-includes paths matched by earlier patterns
+func (gi *GitIgnore) AddPattern(pattern string) {
+	gi.addPattern(pattern, 0)
+}
+
+// Prepend inserts the patterns of a lower-precedence ignore file (such as
+// .git/info/exclude) before those of gi, so patterns of gi override them
+// source names that file in Explain output
+func (gi *GitIgnore) Prepend(other *GitIgnore, source string) {
+	if other == nil || len(other.patterns) == 0 {
+		return
+	}
+	patterns := make([]pattern, 0, len(other.patterns)+len(gi.patterns))
+	for _, p := range other.patterns {
+		p.source = source
+		patterns = append(patterns, p)
+	}
+	gi.patterns = append(patterns, gi.patterns...)
+}
+
+// WithSource returns a copy of gi whose patterns from its own ignore file are
+// named source in Explain output, like the patterns added with Prepend
+// It returns nil for a nil gi, so the result can be passed to Merge directly
+func (gi *GitIgnore) WithSource(source string) *GitIgnore {
+	if gi == nil {
+		return nil
+	}
+	named := &GitIgnore{basePath: gi.basePath, patterns: make([]pattern, 0, len(gi.patterns))}
+	for _, p := range gi.patterns {
+		if p.line > 0 && p.source == "" {
+			p.source = source
+		}
+		named.patterns = append(named.patterns, p)
+	}
+	return named
+}
+
+// Merge returns a GitIgnore with the patterns of gi followed by those of
+// other, so a later "!" pattern of other re-includes paths gi ignores
+// Patterns stay relative to the base path of gi; gi and other are unchanged
+func (gi *GitIgnore) Merge(other *GitIgnore) *GitIgnore {
+	return Merge(gi, other)
+}
+
+// Merge combines ignore instances in order of increasing precedence, skipping
+// nil ones, with the base path of the first. Returns nil if all are nil.
+func Merge(gis ...*GitIgnore) *GitIgnore {
+	var merged *GitIgnore
+	for _, gi := range gis {
+		if gi == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &GitIgnore{basePath: gi.basePath, patterns: make([]pattern, 0, len(gi.patterns))}
+		}
+		merged.patterns = append(merged.patterns, gi.patterns...)
+	}
+	return merged
+}
+
+// addPattern parses a pattern from line of the ignore file (0 if not from the file)
+func (gi *GitIgnore) addPattern(text string, line int) {
+	glob := text
+	negate := strings.HasPrefix(glob, "!")
+	if negate {
+		glob = glob[1:]
+	}
+
+	// Remove leading/trailing slashes for simpler matching
+	glob = strings.Trim(glob, "/")
+	if glob == "" {
+		return
+	}
+	gi.patterns = append(gi.patterns, pattern{text: text, glob: glob, negate: negate, line: line})
+}
+
 // IsIgnored checks if a path should be ignored based on gitignore rules
 func (gi *GitIgnore) IsIgnored(relativePath string, isDir bool) bool {
+	last := gi.lastMatch(relativePath)
+	return last != nil && !last.negate
+}
+
+// Explain describes why a path is or isn't ignored, naming the deciding pattern
+// and its position (line) in the ignore file, e.g.
+// "ignored by pattern '*.log' at position 3", or "not ignored" when nothing matches.
+// When a negation pattern re-included the path, the pattern it overrode is named too.
+func (gi *GitIgnore) Explain(relativePath string, isDir bool) string {
 	if relativePath == "" || relativePath == "." {
-		return false
+		return "not ignored"
 	}
 
-	// Normalize path separators
-	relativePath = filepath.ToSlash(relativePath)
+	var matches []pattern
+	for _, p := range gi.patterns {
+		if matchesPattern(p.glob, filepath.ToSlash(relativePath)) {
+			matches = append(matches, p)
+		}
+	}
+	if len(matches) == 0 {
+		return "not ignored"
+	}
 
-	// Check each pattern
-	for _, pattern := range gi.patterns {
-		// Check exact match
-		if matched, _ := filepath.Match(pattern, relativePath); matched {
-			return true
+	last := matches[len(matches)-1]
+	if !last.negate {
+		return fmt.Sprintf("ignored by pattern '%s' %s", last, last.position())
+	}
+
+	// Find the positive match the negation overrode
+	for i := len(matches) - 2; i >= 0; i-- {
+		if !matches[i].negate {
+			return fmt.Sprintf("not ignored: re-included by negation pattern '%s' %s after pattern '%s' %s",
+				last, last.position(), matches[i], matches[i].position())
 		}
+	}
+	return fmt.Sprintf("not ignored: only matched negation pattern '%s' %s", last, last.position())
+}
 
-		// Check if filename matches pattern
-		filename := filepath.Base(relativePath)
-		if matched, _ := filepath.Match(pattern, filename); matched {
-			return true
+// lastMatch returns the last pattern matching the path, which decides whether it is ignored
+func (gi *GitIgnore) lastMatch(relativePath string) *pattern {
+	if relativePath == "" || relativePath == "." {
+		return nil
+	}
+
+	// Normalize path separators
+	relativePath = filepath.ToSlash(relativePath)
+
+	// Check each pattern in order; the last matching pattern wins
+	var last *pattern
+	for i := range gi.patterns {
+		if matchesPattern(gi.patterns[i].glob, relativePath) {
+			last = &gi.patterns[i]
 		}
+	}
+	return last
+}
 
-		// Check if any path segment matches
+// matchesPattern checks a single pattern against a slash-separated relative path
+func matchesPattern(pattern, relativePath string) bool {
+	if strings.Contains(pattern, "**") {
+		// A match on a directory also covers everything below it
 		pathParts := strings.Split(relativePath, "/")
-		for _, part := range pathParts {
-			if matched, _ := filepath.Match(pattern, part); matched {
+		for i := 1; i <= len(pathParts); i++ {
+			if matchPattern(pattern, strings.Join(pathParts[:i], "/")) {
 				return true
 			}
 		}
+		return false
+	}
+
+	// Check exact match
+	if matched, _ := filepath.Match(pattern, relativePath); matched {
+		return true
+	}
+
+	// Check if filename matches pattern
+	filename := filepath.Base(relativePath)
+	if matched, _ := filepath.Match(pattern, filename); matched {
+		return true
+	}
+
+	// Check if any path segment matches
+	pathParts := strings.Split(relativePath, "/")
+	for _, part := range pathParts {
+		if matched, _ := filepath.Match(pattern, part); matched {
+			return true
+		}
 	}
 
 	return false
 }
 
+// matchPattern matches a pattern containing "**" against a whole path
+// A leading "**/" matches in all directories, a trailing "/**" matches
+// everything inside a directory, and "/**/" matches zero or more directories,
+// so "a/**/b" matches "a/b", "a/x/b" and "a/x/y/b"
+func matchPattern(pattern, relativePath string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relativePath, "/"))
+}
+
+// matchSegments matches pattern segments against path segments
+func matchSegments(patternParts, pathParts []string) bool {
+	for len(patternParts) > 0 {
+		if patternParts[0] != "**" {
+			if len(pathParts) == 0 {
+				return false
+			}
+			if matched, _ := filepath.Match(patternParts[0], pathParts[0]); !matched {
+				return false
+			}
+			patternParts, pathParts = patternParts[1:], pathParts[1:]
+			continue
+		}
+
+		rest := patternParts[1:]
+		if len(rest) == 0 {
+			// A trailing "**" needs something inside the directory
+			return len(pathParts) > 0
+		}
+		// Let "**" absorb zero or more directories
+		for skip := 0; skip <= len(pathParts); skip++ {
+			if matchSegments(rest, pathParts[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(pathParts) == 0
+}
+
 // ShouldIgnoreFile is a convenience function that checks if a file should be ignored
 // based on its absolute path and the base directory containing .gitignore
 func ShouldIgnoreFile(basePath, filePath string, isDir bool) (bool, error) {
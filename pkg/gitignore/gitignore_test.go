@@ -0,0 +1,154 @@
+package gitignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern_DoubleStar(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		// Leading **/ matches at any depth
+		{"**/foo", "foo", true},
+		{"**/foo", "a/foo", true},
+		{"**/foo", "a/b/foo", true},
+		{"**/foo", "a/foobar", false},
+		{"**/foo/bar", "x/foo/bar", true},
+		{"**/foo/bar", "foo/bar", true},
+		{"**/foo/bar", "foo/x/bar", false},
+		{"**/*.o", "obj/main.o", true},
+
+		// Trailing /** matches everything inside
+		{"foo/**", "foo/a", true},
+		{"foo/**", "foo/a/b", true},
+		{"foo/**", "foo", false},
+		{"foo/**", "bar/a", false},
+
+		// /**/ matches zero or more directories
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"a/**/b", "x/a/b", false},
+		{"build/**/*.o", "build/main.o", true},
+		{"build/**/*.o", "build/x/y/main.o", true},
+		{"build/**/*.o", "build/x/main.c", false},
+
+		// Several double stars
+		{"**/a/**/b", "x/a/y/b", true},
+		{"**/a/**/b", "a/b", true},
+		{"**", "anything/at/all", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.path); got != tt.matches {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.matches)
+		}
+	}
+}
+
+func TestIsIgnored_DoubleStar(t *testing.T) {
+	base := t.TempDir()
+	content := "**/node_modules\nbuild/**/*.o\nlogs/**\n!logs/**/keep.log\n"
+	if err := os.WriteFile(filepath.Join(base, ".gitignore"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+
+	gi, err := NewGitIgnore(base)
+	if err != nil {
+		t.Fatalf("NewGitIgnore() error = %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"node_modules", true, true},
+		{"web/app/node_modules", true, true},
+		{"web/app/node_modules/react/index.js", false, true},
+		{"build/main.o", false, true},
+		{"build/arm64/main.o", false, true},
+		{"build/arm64/main.c", false, false},
+		{"logs", true, false},
+		{"logs/app.log", false, true},
+		{"logs/2024/keep.log", false, false},
+		{"src/main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := gi.IsIgnored(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("IsIgnored(%q) = %v, want %v", tt.path, got, tt.ignored)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	gi, err := NewGitIgnoreFromReader("/repo", strings.NewReader("*.log\nbuild/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri, err := NewGitIgnoreFromReader("/other", strings.NewReader("testdata\n!keep.log\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := Merge(nil, gi, nil, ri)
+
+	tests := []struct {
+		path    string
+		ignored bool
+	}{
+		{"debug.log", true},
+		{"build", true},
+		{"testdata", true},
+		{"keep.log", false}, // re-included by the later instance
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if got := merged.IsIgnored(tt.path, false); got != tt.ignored {
+			t.Errorf("IsIgnored(%q) = %v, want %v", tt.path, got, tt.ignored)
+		}
+	}
+	if merged.basePath != "/repo" {
+		t.Errorf("Expected the first base path, got %q", merged.basePath)
+	}
+	if len(gi.patterns) != 2 || len(ri.patterns) != 2 {
+		t.Error("Expected the merged instances to be unchanged")
+	}
+	if !gi.Merge(ri).IsIgnored("testdata", true) {
+		t.Error("Expected the method to merge like the function")
+	}
+	if Merge(nil, nil) != nil {
+		t.Error("Expected nil when every instance is nil")
+	}
+}
+
+func TestWithSource(t *testing.T) {
+	gi, err := NewGitIgnoreFromReader("/repo", strings.NewReader("*.log\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gi.AddPattern("vendor/")
+
+	named := gi.WithSource(".r2cignore")
+
+	if got := named.Explain("debug.log", false); got != "ignored by pattern '*.log' at position 1 in .r2cignore" {
+		t.Errorf("Explain() = %q", got)
+	}
+	if got := named.Explain("vendor", true); got != "ignored by pattern 'vendor/' added by a command-line override" {
+		t.Errorf("Expected overrides to stay unnamed, got %q", got)
+	}
+	if got := gi.Explain("debug.log", false); got != "ignored by pattern '*.log' at position 1" {
+		t.Errorf("Expected the original to be unchanged, got %q", got)
+	}
+	var none *GitIgnore
+	if none.WithSource(".gitignore") != nil {
+		t.Error("Expected nil for a nil instance")
+	}
+}
@@ -0,0 +1,47 @@
+package gitinfo
+
+import "time"
+
+// GitClient is the set of repository queries r2c makes while scanning and
+// formatting. Tests can substitute a fake with SetClient so they don't
+// depend on a git installation.
+type GitClient interface {
+	IsGitRepository(path string) (bool, error)
+	GetGitRoot(path string) (string, error)
+	GetGitInfo(path string) (string, error)
+	GetGitInfoAtCommit(path, rev string) (string, error)
+	GetRemoteURL(path string) (string, error)
+	GetGitTags(repoPath string) (string, error)
+	GetGitConfig(repoPath, key string) (string, error)
+	GetGlobalGitIgnorePath(repoPath string) (string, error)
+	GetModifiedFiles(repoPath string) ([]string, error)
+	GetGitStatus(repoPath string) (map[string]string, error)
+	GetContributors(repoPath, filePath string) ([]string, error)
+	GetAllContributors(repoPath string) (map[string][]string, error)
+	GetGitLog(repoPath, filePath string, maxCommits int) (string, error)
+	GetGitLogs(repoPath string, maxCommits int) (map[string][]string, error)
+	GetGitBlame(repoPath, filePath string) ([]BlameLine, error)
+
+	// Commit queries behind --commit-hash
+	ResolveCommit(repoPath, commit string) (string, error)
+	ListFilesAtCommit(repoPath, commitHash, dir string) ([]string, error)
+	ReadFileAtCommit(repoPath, relFilePath, commitHash string) (string, error)
+	GetCommitTime(repoPath, commitHash string) (time.Time, error)
+}
+
+// ExecGitClient implements GitClient by running the git binary
+type ExecGitClient struct{}
+
+// client answers the package-level queries
+var client GitClient = ExecGitClient{}
+
+// SetClient replaces the client behind the package-level functions and returns
+// the previous one so callers can restore it. A nil client restores ExecGitClient.
+func SetClient(c GitClient) GitClient {
+	previous := client
+	if c == nil {
+		c = ExecGitClient{}
+	}
+	client = c
+	return previous
+}
@@ -0,0 +1,115 @@
+package gitinfo_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BHChen24/repo2context/pkg/gitinfo"
+	"github.com/BHChen24/repo2context/pkg/gitinfo/mock"
+)
+
+// Tests for SetClient
+
+func TestSetClient_RoutesPackageFunctions(t *testing.T) {
+	previous := gitinfo.SetClient(&mock.MockGitClient{
+		IsRepo:        true,
+		Root:          "/repo",
+		Info:          "Commit: abc123",
+		RemoteURL:     "https://github.com/owner/repo.git",
+		ModifiedFiles: []string{"main.go"},
+	})
+	t.Cleanup(func() { gitinfo.SetClient(previous) })
+
+	if isRepo, err := gitinfo.IsGitRepository("/anywhere"); err != nil || !isRepo {
+		t.Errorf("Expected a repository, got %t, %v", isRepo, err)
+	}
+	if root, err := gitinfo.GetGitRoot("/anywhere"); err != nil || root != "/repo" {
+		t.Errorf("Expected root /repo, got %q, %v", root, err)
+	}
+	if info, err := gitinfo.GetGitInfo("/anywhere"); err != nil || info != "Commit: abc123" {
+		t.Errorf("Expected mocked info, got %q, %v", info, err)
+	}
+	if remote, err := gitinfo.GetRemoteURL("/anywhere"); err != nil || remote != "https://github.com/owner/repo.git" {
+		t.Errorf("Expected mocked remote, got %q, %v", remote, err)
+	}
+	if files, err := gitinfo.GetModifiedFiles("/anywhere"); err != nil || len(files) != 1 || files[0] != "main.go" {
+		t.Errorf("Expected [main.go], got %v, %v", files, err)
+	}
+}
+
+func TestSetClient_RoutesHistoryAndCommitQueries(t *testing.T) {
+	mock.Use(t, &mock.MockGitClient{
+		IsRepo:       true,
+		Info:         "Commit: abc123",
+		Status:       map[string]string{"main.go": "M"},
+		Contributors: map[string][]string{"main.go": {"Dev"}},
+		Logs:         map[string][]string{"main.go": {"abc123 second", "def456 first"}},
+		Blame:        map[string][]gitinfo.BlameLine{"main.go": {{LineNum: 1, Author: "Dev"}}},
+		Commit:       "abc123",
+		CommitFiles:  map[string]string{"main.go": "package main\n"},
+	})
+
+	if info, err := gitinfo.GetGitInfoAtCommit("/anywhere", "v1"); err != nil || info != "Commit: abc123" {
+		t.Errorf("Expected mocked info, got %q, %v", info, err)
+	}
+	if status, err := gitinfo.GetGitStatus("/anywhere"); err != nil || status["main.go"] != "M" {
+		t.Errorf("Expected main.go modified, got %v, %v", status, err)
+	}
+	if authors, err := gitinfo.GetContributors("/anywhere", "main.go"); err != nil || len(authors) != 1 {
+		t.Errorf("Expected one contributor, got %v, %v", authors, err)
+	}
+	if all, err := gitinfo.GetAllContributors("/anywhere"); err != nil || len(all["main.go"]) != 1 {
+		t.Errorf("Expected one contributor of main.go, got %v, %v", all, err)
+	}
+	if log, err := gitinfo.GetGitLog("/anywhere", "main.go", 1); err != nil || log != "abc123 second" {
+		t.Errorf("Expected the newest commit, got %q, %v", log, err)
+	}
+	if blame, err := gitinfo.GetGitBlame("/anywhere", "main.go"); err != nil || len(blame) != 1 {
+		t.Errorf("Expected one blamed line, got %v, %v", blame, err)
+	}
+	if hash, err := gitinfo.ResolveCommit("/anywhere", "v1"); err != nil || hash != "abc123" {
+		t.Errorf("Expected abc123, got %q, %v", hash, err)
+	}
+	if content, err := gitinfo.ReadFileAtCommit("/anywhere", "main.go", "abc123"); err != nil || content != "package main\n" {
+		t.Errorf("Expected mocked content, got %q, %v", content, err)
+	}
+}
+
+func TestSetClient_NilRestoresExecClient(t *testing.T) {
+	previous := gitinfo.SetClient(&mock.MockGitClient{})
+	t.Cleanup(func() { gitinfo.SetClient(previous) })
+
+	gitinfo.SetClient(nil)
+	if current := gitinfo.SetClient(nil); current != (gitinfo.ExecGitClient{}) {
+		t.Errorf("Expected ExecGitClient after SetClient(nil), got %T", current)
+	}
+}
+
+func TestMockGitClient_OutsideRepository(t *testing.T) {
+	client := &mock.MockGitClient{}
+
+	if isRepo, err := client.IsGitRepository("/anywhere"); isRepo || !errors.Is(err, gitinfo.ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %t, %v", isRepo, err)
+	}
+	if _, err := client.GetGitInfo("/anywhere"); !errors.Is(err, gitinfo.ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %v", err)
+	}
+	if _, err := client.GetGitRoot("/anywhere"); !errors.Is(err, gitinfo.ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %v", err)
+	}
+}
+
+func TestMockGitClient_ErrFailsEveryQuery(t *testing.T) {
+	boom := errors.New("boom")
+	client := &mock.MockGitClient{IsRepo: true, Err: boom}
+
+	if _, err := client.IsGitRepository("/anywhere"); !errors.Is(err, boom) {
+		t.Errorf("IsGitRepository: expected boom, got %v", err)
+	}
+	if _, err := client.GetGitInfo("/anywhere"); !errors.Is(err, boom) {
+		t.Errorf("GetGitInfo: expected boom, got %v", err)
+	}
+	if _, err := client.GetModifiedFiles("/anywhere"); !errors.Is(err, boom) {
+		t.Errorf("GetModifiedFiles: expected boom, got %v", err)
+	}
+}
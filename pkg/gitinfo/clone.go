@@ -0,0 +1,119 @@
+package gitinfo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CloneOptions configures CloneGitHubRepo
+type CloneOptions struct {
+	// Token authenticates the clone of a private repository
+	Token string
+	// NoSubmodules skips cloning submodules
+	NoSubmodules bool
+	// CacheDir keeps clones in CacheDir/owner/repo and reuses them; empty means a temp dir
+	CacheDir string
+}
+
+// ParseGitHubURL recognizes https://github.com/owner/repo, github.com/owner/repo
+// and github:owner/repo, returning the owner and repository name
+func ParseGitHubURL(arg string) (owner, repo string, ok bool) {
+	rest := ""
+	switch {
+	case strings.HasPrefix(arg, "github:"):
+		rest = strings.TrimPrefix(arg, "github:")
+	case strings.HasPrefix(arg, "https://github.com/"):
+		rest = strings.TrimPrefix(arg, "https://github.com/")
+	case strings.HasPrefix(arg, "http://github.com/"):
+		rest = strings.TrimPrefix(arg, "http://github.com/")
+	case strings.HasPrefix(arg, "github.com/"):
+		rest = strings.TrimPrefix(arg, "github.com/")
+	default:
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], strings.TrimSuffix(parts[1], ".git"), true
+}
+
+// CloneGitHubRepo shallow-clones a GitHub repository and returns its directory
+// The returned cleanup removes a temporary clone; it does nothing for cached clones
+func CloneGitHubRepo(owner, repo string, opts CloneOptions) (string, func(), error) {
+	cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
+
+	noop := func() {}
+
+	// Reuse a cached clone when one exists
+	if opts.CacheDir != "" {
+		dir := filepath.Join(opts.CacheDir, owner, repo)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, noop, nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", noop, fmt.Errorf("error creating clone cache directory: %w", err)
+		}
+		if err := cloneRepo(cloneURL, dir, opts.Token, opts.NoSubmodules); err != nil {
+			os.RemoveAll(dir) //nolint:errcheck
+			return "", noop, fmt.Errorf("error cloning %s/%s: %w", owner, repo, err)
+		}
+		return dir, noop, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "r2c-clone-*")
+	if err != nil {
+		return "", noop, fmt.Errorf("error creating temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) } //nolint:errcheck
+
+	dir := filepath.Join(tempDir, repo)
+	if err := cloneRepo(cloneURL, dir, opts.Token, opts.NoSubmodules); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("error cloning %s/%s: %w", owner, repo, err)
+	}
+	return dir, cleanup, nil
+}
+
+// cloneRepo runs a shallow git clone of cloneURL into dir
+// A token is sent as an HTTP authorization header set through the environment
+// of the git process, so it never shows in its arguments, the clone's config
+// or the origin URL
+func cloneRepo(cloneURL, dir, token string, noSubmodules bool) error {
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if !noSubmodules {
+		args = append(args, "--recurse-submodules", "--shallow-submodules")
+	}
+	args = append(args, cloneURL, dir)
+
+	cmd := exec.Command("git", args...)
+	if token != "" {
+		cmd.Env = append(os.Environ(), authHeaderEnv(token)...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// authHeaderEnv returns the environment entries that make git send token as
+// basic authentication to github.com, added after any GIT_CONFIG_COUNT
+// entries already set
+func authHeaderEnv(token string) []string {
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	credentials := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token))
+	return []string{
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", count+1),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=http.https://github.com/.extraHeader", count),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=Authorization: Basic %s", count, credentials),
+	}
+}
@@ -0,0 +1,76 @@
+package gitinfo
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ResolveCommit returns the full hash of a commit, failing if it does not exist
+func ResolveCommit(repoPath, commit string) (string, error) {
+	return client.ResolveCommit(repoPath, commit)
+}
+
+// ResolveCommit returns the full hash of a commit, failing if it does not exist
+func (ExecGitClient) ResolveCommit(repoPath, commit string) (string, error) {
+	hash, err := runGitCommand(repoPath, revParse, "--verify", "--quiet", commit+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown commit %q: %w", commit, err)
+	}
+	return hash, nil
+}
+
+// ListFilesAtCommit returns the files tracked at a commit below dir,
+// as slash-separated paths relative to the repository root (empty dir means all files)
+func ListFilesAtCommit(repoPath, commitHash, dir string) ([]string, error) {
+	return client.ListFilesAtCommit(repoPath, commitHash, dir)
+}
+
+// ListFilesAtCommit returns the files tracked at a commit below dir,
+// as slash-separated paths relative to the repository root (empty dir means all files)
+func (ExecGitClient) ListFilesAtCommit(repoPath, commitHash, dir string) ([]string, error) {
+	args := []string{"ls-tree", "-r", "--name-only", "--full-tree", commitHash}
+	if dir != "" {
+		args = append(args, "--", filepath.ToSlash(dir))
+	}
+
+	out, err := runGitCommand(repoPath, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing files at %s: %w", commitHash, err)
+	}
+	if out == "" {
+		return []string{}, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// ReadFileAtCommit returns a file's content at a commit without checking it out
+// relFilePath is relative to the repository root
+func ReadFileAtCommit(repoPath, relFilePath, commitHash string) (string, error) {
+	return client.ReadFileAtCommit(repoPath, relFilePath, commitHash)
+}
+
+// ReadFileAtCommit returns a file's content at a commit without checking it out
+// relFilePath is relative to the repository root
+func (ExecGitClient) ReadFileAtCommit(repoPath, relFilePath, commitHash string) (string, error) {
+	content, err := runGitCommandRaw(repoPath, "show", commitHash+":"+filepath.ToSlash(relFilePath))
+	if err != nil {
+		return "", fmt.Errorf("error reading %s at %s: %w", relFilePath, commitHash, err)
+	}
+	return content, nil
+}
+
+// GetCommitTime returns the committer date of a commit
+func GetCommitTime(repoPath, commitHash string) (time.Time, error) {
+	return client.GetCommitTime(repoPath, commitHash)
+}
+
+// GetCommitTime returns the committer date of a commit
+func (ExecGitClient) GetCommitTime(repoPath, commitHash string) (time.Time, error) {
+	out, err := runGitCommand(repoPath, "show", "-s", "--format=%cI", commitHash)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting commit time: %w", err)
+	}
+	return time.Parse(time.RFC3339, out)
+}
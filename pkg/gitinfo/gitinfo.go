@@ -2,8 +2,13 @@ package gitinfo
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -11,20 +16,57 @@ import (
 // There will be a command collector if needed in later version
 const revParse = "rev-parse"
 
-// IsGitRepository checks if a path is within a Git repository
+// ErrNotGitRepo reports that a path is not inside a git repository, as opposed
+// to git itself failing (not installed, corrupted repository, ...)
+var ErrNotGitRepo = errors.New("not a git repository")
+
+// IsGitRepository checks if a path is within a Git repository, returning
+// ErrNotGitRepo when it is not
 func IsGitRepository(path string) (bool, error) {
+	return client.IsGitRepository(path)
+}
+
+// IsGitRepository checks if a path is within a Git repository
+func (ExecGitClient) IsGitRepository(path string) (bool, error) {
 	cmd := exec.Command("git", "-C", path, revParse, "--is-inside-work-tree")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	err := cmd.Run()
-	return err == nil, nil
+	if err == nil {
+		return true, nil
+	}
+	// Only git refusing the path means it is outside a repository; a missing
+	// git, a missing path or a corrupted repository is a failure of its own
+	if strings.Contains(stderr.String(), "not a git repository") {
+		return false, ErrNotGitRepo
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return false, fmt.Errorf("failed to run git: %w: %s", err, message)
+	}
+	return false, fmt.Errorf("failed to run git: %w", err)
 }
 
 // GetGitRoot returns the root directory of the git repository
 func GetGitRoot(path string) (string, error) {
+	return client.GetGitRoot(path)
+}
+
+// GetGitRoot returns the root directory of the git repository
+func (ExecGitClient) GetGitRoot(path string) (string, error) {
 	return runGitCommand(path, revParse, "--show-toplevel")
 }
 
 // runGitCommand executes git commands in a specific directory
 func runGitCommand(path string, args ...string) (string, error) {
+	out, err := runGitCommandRaw(path, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// runGitCommandRaw executes a git command and returns its output untrimmed
+func runGitCommandRaw(path string, args ...string) (string, error) {
 	gitArgs := append([]string{"-C", path}, args...)
 	cmd := exec.Command("git", gitArgs...)
 	// For saving dynamic output
@@ -34,20 +76,407 @@ func runGitCommand(path string, args ...string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(out.String()), nil
+	return out.String(), nil
+}
+
+// GetGlobalGitIgnorePath returns the global excludes file git uses for a
+// repository: core.excludesFile if configured, otherwise the default
+// $XDG_CONFIG_HOME/git/ignore (~/.config/git/ignore). The file may not exist.
+func GetGlobalGitIgnorePath(repoPath string) (string, error) {
+	return client.GetGlobalGitIgnorePath(repoPath)
+}
+
+// GetGlobalGitIgnorePath returns the global excludes file git uses for a
+// repository: core.excludesFile if configured, otherwise the default
+// $XDG_CONFIG_HOME/git/ignore (~/.config/git/ignore). The file may not exist.
+func (ExecGitClient) GetGlobalGitIgnorePath(repoPath string) (string, error) {
+	path, err := readGitConfig(repoPath, "core.excludesFile", "--path")
+	if err != nil {
+		return "", err
+	}
+	if path != "" {
+		return path, nil
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate global gitignore: %w", err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore"), nil
 }
 
-// GetGitInfo retrieves Git information for a repository
+// GetGitConfig returns the value of a git config key as git sees it from a
+// repository, combining its local, global and system config
+// An unset key returns an empty value without error
+func GetGitConfig(repoPath, key string) (string, error) {
+	return client.GetGitConfig(repoPath, key)
+}
+
+// GetGitConfig returns the value of a git config key as git sees it from a
+// repository, combining its local, global and system config
+// An unset key returns an empty value without error
+func (ExecGitClient) GetGitConfig(repoPath, key string) (string, error) {
+	return readGitConfig(repoPath, key)
+}
+
+// readGitConfig runs git config --get for a key, with extra options such as
+// --path to expand ~ in path values
+func readGitConfig(repoPath, key string, options ...string) (string, error) {
+	args := append([]string{"config"}, options...)
+	value, err := runGitCommand(repoPath, append(args, "--get", key)...)
+	// git config exits with status 1 when the key is unset
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// GetRemoteURL returns the URL of the origin remote
+func GetRemoteURL(path string) (string, error) {
+	return client.GetRemoteURL(path)
+}
+
+// GetRemoteURL returns the URL of the origin remote
+func (ExecGitClient) GetRemoteURL(path string) (string, error) {
+	return runGitCommand(path, "remote", "get-url", "origin")
+}
+
+// GetGitTags describes HEAD by its most recent tag: the tag itself (v1.2.3)
+// when HEAD is tagged, otherwise the tag, the number of commits since and the
+// abbreviated commit (v1.2.3-5-gabcdef). Repositories without tags are an error.
+func GetGitTags(repoPath string) (string, error) {
+	return client.GetGitTags(repoPath)
+}
+
+// GetGitTags describes HEAD by its most recent tag: the tag itself (v1.2.3)
+// when HEAD is tagged, otherwise the tag, the number of commits since and the
+// abbreviated commit (v1.2.3-5-gabcdef). Repositories without tags are an error.
+func (ExecGitClient) GetGitTags(repoPath string) (string, error) {
+	return describeTags(repoPath, "HEAD")
+}
+
+// describeTags runs git describe --tags for a revision
+func describeTags(repoPath, rev string) (string, error) {
+	tag, err := runGitCommand(repoPath, "describe", "--tags", rev)
+	if err != nil {
+		return "", fmt.Errorf("error describing %s: %w", rev, err)
+	}
+	return tag, nil
+}
+
+// GetGitLog returns the most recent commits touching a file, one per line
+func GetGitLog(repoPath, filePath string, maxCommits int) (string, error) {
+	return client.GetGitLog(repoPath, filePath, maxCommits)
+}
+
+// GetGitLog returns the most recent commits touching a file, one per line
+func (ExecGitClient) GetGitLog(repoPath, filePath string, maxCommits int) (string, error) {
+	if maxCommits <= 0 {
+		maxCommits = 5
+	}
+
+	log, err := runGitCommand(repoPath, "log", "--oneline", fmt.Sprintf("-%d", maxCommits), "--", filePath)
+	if err != nil {
+		return "", fmt.Errorf("error getting log for %s: %w", filePath, err)
+	}
+	return log, nil
+}
+
+// GetGitLogs returns the most recent commits (short hash and subject, newest
+// first) of every file under repoPath that has history, keeping at most
+// maxCommits per file (5 if maxCommits <= 0)
+// Files are keyed by slash-separated path relative to repoPath, and the whole
+// history is read with a single git log
+func GetGitLogs(repoPath string, maxCommits int) (map[string][]string, error) {
+	return client.GetGitLogs(repoPath, maxCommits)
+}
+
+// GetGitLogs returns the most recent commits (short hash and subject, newest
+// first) of every file under repoPath that has history, keeping at most
+// maxCommits per file (5 if maxCommits <= 0)
+// Files are keyed by slash-separated path relative to repoPath, and the whole
+// history is read with a single git log
+func (ExecGitClient) GetGitLogs(repoPath string, maxCommits int) (map[string][]string, error) {
+	if maxCommits <= 0 {
+		maxCommits = 5
+	}
+
+	out, err := runGitCommandRaw(repoPath, "-c", "core.quotepath=off", "log", "--relative", "--name-only", "--format=%x00%h %s")
+	if err != nil {
+		return nil, fmt.Errorf("error getting log: %w", err)
+	}
+
+	// Each commit is a NUL, its "hash subject" line and the files it changed
+	logs := make(map[string][]string)
+	for _, commit := range strings.Split(out, "\x00") {
+		header, files, _ := strings.Cut(commit, "\n")
+		if header == "" {
+			continue
+		}
+		for _, file := range strings.Split(files, "\n") {
+			if file != "" && len(logs[file]) < maxCommits {
+				logs[file] = append(logs[file], header)
+			}
+		}
+	}
+	return logs, nil
+}
+
+// GetContributors returns the distinct authors of a file, most frequent committer first
+func GetContributors(repoPath, filePath string) ([]string, error) {
+	return client.GetContributors(repoPath, filePath)
+}
+
+// GetContributors returns the distinct authors of a file, most frequent committer first
+func (ExecGitClient) GetContributors(repoPath, filePath string) ([]string, error) {
+	log, err := runGitCommand(repoPath, "log", "--format=%an", "--", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contributors for %s: %w", filePath, err)
+	}
+	if log == "" {
+		return []string{}, nil
+	}
+	return rankAuthors(strings.Split(log, "\n")), nil
+}
+
+// GetAllContributors returns the distinct authors of every file under repoPath
+// that has history, most frequent committer first
+// Files are keyed by slash-separated path relative to repoPath, and the whole
+// history is read with a single git log
+func GetAllContributors(repoPath string) (map[string][]string, error) {
+	return client.GetAllContributors(repoPath)
+}
+
+// GetAllContributors returns the distinct authors of every file under repoPath
+// that has history, most frequent committer first
+// Files are keyed by slash-separated path relative to repoPath, and the whole
+// history is read with a single git log
+func (ExecGitClient) GetAllContributors(repoPath string) (map[string][]string, error) {
+	out, err := runGitCommandRaw(repoPath, "-c", "core.quotepath=off", "log", "--relative", "--name-only", "--format=%x00%an")
+	if err != nil {
+		return nil, fmt.Errorf("error getting contributors: %w", err)
+	}
+
+	// Each commit is a NUL, its author line and the files it changed
+	commits := make(map[string][]string)
+	for _, commit := range strings.Split(out, "\x00") {
+		author, files, _ := strings.Cut(commit, "\n")
+		if author == "" {
+			continue
+		}
+		for _, file := range strings.Split(files, "\n") {
+			if file != "" {
+				commits[file] = append(commits[file], author)
+			}
+		}
+	}
+
+	contributors := make(map[string][]string, len(commits))
+	for file, authors := range commits {
+		contributors[file] = rankAuthors(authors)
+	}
+	return contributors, nil
+}
+
+// rankAuthors returns the distinct authors of commits, given one author per
+// commit, ordered by commit count, then by name for stable output
+func rankAuthors(commitAuthors []string) []string {
+	counts := make(map[string]int)
+	var authors []string
+	for _, author := range commitAuthors {
+		if counts[author] == 0 {
+			authors = append(authors, author)
+		}
+		counts[author]++
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		if counts[authors[i]] != counts[authors[j]] {
+			return counts[authors[i]] > counts[authors[j]]
+		}
+		return authors[i] < authors[j]
+	})
+	return authors
+}
+
+// BlameLine is the authorship of one line of a file, as reported by git blame
+type BlameLine struct {
+	LineNum int
+	Author  string
+	Commit  string
+	Content string
+}
+
+// GetGitBlame returns the author and last commit of each line of a file
+// Lines not committed yet carry the all-zero commit hash
+func GetGitBlame(repoPath, filePath string) ([]BlameLine, error) {
+	return client.GetGitBlame(repoPath, filePath)
+}
+
+// GetGitBlame returns the author and last commit of each line of a file
+// Lines not committed yet carry the all-zero commit hash
+func (ExecGitClient) GetGitBlame(repoPath, filePath string) ([]BlameLine, error) {
+	out, err := runGitCommandRaw(repoPath, "blame", "--line-porcelain", "--", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error getting blame for %s: %w", filePath, err)
+	}
+	return parseBlamePorcelain(out), nil
+}
+
+// parseBlamePorcelain parses git blame --line-porcelain output, where each
+// line is a "<commit> <orig line> <final line>" header, then key-value
+// headers, then the content prefixed with a tab
+func parseBlamePorcelain(out string) []BlameLine {
+	lines := make([]BlameLine, 0)
+	var current BlameLine
+	for _, line := range strings.Split(out, "\n") {
+		if content, ok := strings.CutPrefix(line, "\t"); ok {
+			current.Content = content
+			lines = append(lines, current)
+			current = BlameLine{}
+			continue
+		}
+		if author, ok := strings.CutPrefix(line, "author "); ok {
+			current.Author = author
+			continue
+		}
+
+		// Only a header line starts with a full commit hash
+		fields := strings.Fields(line)
+		if current.Commit == "" && len(fields) >= 3 && len(fields[0]) >= 40 {
+			current.Commit = fields[0]
+			current.LineNum, _ = strconv.Atoi(fields[2])
+		}
+	}
+	return lines
+}
+
+// GetGitStatus returns the status of changed and untracked files, keyed by
+// slash-separated path relative to the repository root: "M" (modified),
+// "A" (added, renamed or copied), "D" (deleted) or "?" (untracked)
+func GetGitStatus(repoPath string) (map[string]string, error) {
+	return client.GetGitStatus(repoPath)
+}
+
+// GetGitStatus returns the status of changed and untracked files, keyed by
+// slash-separated path relative to the repository root: "M" (modified),
+// "A" (added, renamed or copied), "D" (deleted) or "?" (untracked)
+func (ExecGitClient) GetGitStatus(repoPath string) (map[string]string, error) {
+	out, err := runGitCommandRaw(repoPath, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, fmt.Errorf("error getting status: %w", err)
+	}
+
+	// Entries are "XY path" separated by NUL; renames and copies are
+	// followed by an extra entry holding the original path
+	status := make(map[string]string)
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+		if code := statusCode(entry[0], entry[1]); code != "" {
+			status[entry[3:]] = code
+		}
+	}
+	return status, nil
+}
+
+// GetModifiedFiles returns the changed and untracked files of a repository,
+// sorted, as slash-separated paths relative to the repository root
+func GetModifiedFiles(repoPath string) ([]string, error) {
+	return client.GetModifiedFiles(repoPath)
+}
+
+// GetModifiedFiles returns the changed and untracked files of a repository,
+// sorted, as slash-separated paths relative to the repository root
+func (g ExecGitClient) GetModifiedFiles(repoPath string) ([]string, error) {
+	status, err := g.GetGitStatus(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(status))
+	for path := range status {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// statusCode reduces the index (x) and worktree (y) status of a porcelain entry
+// to a single code, preferring the index status
+func statusCode(x, y byte) string {
+	if x == '?' {
+		return "?"
+	}
+	for _, c := range []byte{x, y} {
+		switch c {
+		case 'M', 'T', 'U':
+			return "M"
+		case 'A', 'R', 'C':
+			return "A"
+		case 'D':
+			return "D"
+		}
+	}
+	return ""
+}
+
+// GetGitInfo retrieves Git information for a repository, returning
+// ErrNotGitRepo when the path is not inside one
 func GetGitInfo(path string) (string, error) {
-	isRepo, err := IsGitRepository(path)
-	if err != nil || !isRepo {
-		return "Not a git repository or git not installed.", nil
+	return client.GetGitInfo(path)
+}
+
+// GetGitInfoAtomic retrieves Git information for the HEAD commit, reading its
+// hash, branch, author and date with one git log, and the nearest tag of that
+// hash rather than of HEAD, so all of them describe the same commit even if
+// the repository changes concurrently. It runs four git processes: rev-parse
+// (the repository check), log, remote get-url and describe.
+func GetGitInfoAtomic(path string) (string, error) {
+	return client.GetGitInfo(path)
+}
+
+// GetGitInfo retrieves Git information for the HEAD commit, see GetGitInfoAtomic
+func (g ExecGitClient) GetGitInfo(path string) (string, error) {
+	if err := g.checkGitRepository(path); err != nil {
+		return "", err
 	}
 
-	// Get commit ref
-	commit, err := runGitCommand(path, "log", "-1", "--pretty=%H")
+	info, err := readCommitInfo(path, "HEAD")
 	if err != nil {
-		return "", fmt.Errorf("error getting commit: %w", err)
+		return "", err
+	}
+	return g.formatGitInfo(path, info.commit, branchFromRefs(info.refs), info.author, info.date), nil
+}
+
+// GetGitInfoAtCommit retrieves Git information describing a specific commit
+func GetGitInfoAtCommit(path, rev string) (string, error) {
+	return client.GetGitInfoAtCommit(path, rev)
+}
+
+// GetGitInfoAtCommit retrieves Git information describing a specific commit
+func (g ExecGitClient) GetGitInfoAtCommit(path, rev string) (string, error) {
+	if err := g.checkGitRepository(path); err != nil {
+		return "", err
+	}
+
+	info, err := readCommitInfo(path, rev)
+	if err != nil {
+		return "", err
 	}
 
 	// Get branch name
@@ -56,17 +485,64 @@ func GetGitInfo(path string) (string, error) {
 		return "", fmt.Errorf("error getting branch: %w", err)
 	}
 
-	// Get author name
-	author, err := runGitCommand(path, "log", "-1", "--pretty=%an <%ae>")
+	return g.formatGitInfo(path, info.commit, branch, info.author, info.date), nil
+}
+
+// checkGitRepository returns ErrNotGitRepo unless path is inside a repository
+func (g ExecGitClient) checkGitRepository(path string) error {
+	_, err := g.IsGitRepository(path)
+	return err
+}
+
+// commitInfo holds the fields of one commit read by readCommitInfo
+type commitInfo struct {
+	commit string
+	refs   string // decorations, e.g. "HEAD -> main, origin/main, tag: v1.0"
+	author string
+	date   string
+}
+
+// commitInfoFormat separates the fields with NUL bytes, which can't appear in them
+const commitInfoFormat = "--pretty=format:%H%x00%D%x00%an <%ae>%x00%ad"
+
+// readCommitInfo reads the fields of a commit with one git log invocation
+func readCommitInfo(path, rev string) (commitInfo, error) {
+	out, err := runGitCommand(path, "log", "-1", commitInfoFormat, rev)
 	if err != nil {
-		return "", fmt.Errorf("error getting author: %w", err)
+		return commitInfo{}, fmt.Errorf("error getting commit: %w", err)
+	}
+	fields := strings.Split(out, "\x00")
+	if len(fields) != 4 {
+		return commitInfo{}, fmt.Errorf("error getting commit: unexpected git log output %q", out)
 	}
+	return commitInfo{commit: fields[0], refs: fields[1], author: fields[2], date: fields[3]}, nil
+}
 
-	// Get date
-	date, err := runGitCommand(path, "log", "-1", "--pretty=%ad")
-	if err != nil {
-		return "", fmt.Errorf("error getting date: %w", err)
+// branchFromRefs returns the branch HEAD points to from %D decorations, or
+// "HEAD" for a detached HEAD as git rev-parse --abbrev-ref HEAD does
+func branchFromRefs(refs string) string {
+	for _, ref := range strings.Split(refs, ", ") {
+		if branch, ok := strings.CutPrefix(ref, "HEAD -> "); ok {
+			return branch
+		}
+	}
+	return "HEAD"
+}
+
+// formatGitInfo renders the Git Info lines, looking up the remote and the
+// nearest tag of commit, the full hash read by readCommitInfo
+func (g ExecGitClient) formatGitInfo(path, commit, branch, author, date string) string {
+	// Get remote URL, a missing origin is not an error
+	remote, err := g.GetRemoteURL(path)
+	if err != nil || remote == "" {
+		remote = "(none)"
+	}
+
+	// Get the nearest tag, an untagged history is not an error
+	tag, err := describeTags(path, commit)
+	if err != nil || tag == "" {
+		tag = "(none)"
 	}
 
-	return fmt.Sprintf("Commit: %s\nBranch: %s\nAuthor: %s\nDate  : %s", commit, branch, author, date), nil
+	return fmt.Sprintf("Commit: %s\nBranch: %s\nTag   : %s\nAuthor: %s\nDate  : %s\nRemote: %s", commit, branch, tag, author, date, remote)
 }
@@ -0,0 +1,710 @@
+package gitinfo
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Helper Functions
+
+// initTestRepo creates a temporary git repository with the given number of
+// commits to a single file and returns the repository path
+func initTestRepo(t *testing.T, commits int) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repoPath := t.TempDir()
+	runGit(t, repoPath, "init", "-q")
+	runGit(t, repoPath, "config", "user.name", "Test User")
+	runGit(t, repoPath, "config", "user.email", "test@example.com")
+	runGit(t, repoPath, "config", "commit.gpgsign", "false")
+
+	filePath := filepath.Join(repoPath, "main.go")
+	for i := 0; i < commits; i++ {
+		content := strings.Repeat("// change\n", i+1)
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		runGit(t, repoPath, "add", "main.go")
+		runGit(t, repoPath, "commit", "-q", "-m", "commit "+strings.Repeat("x", i+1))
+	}
+
+	return repoPath
+}
+
+// runGit runs a git command in the given directory and fails the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+// Tests for GetGitLog
+
+func TestGetGitLog_LimitsCommits(t *testing.T) {
+	repoPath := initTestRepo(t, 4)
+
+	log, err := GetGitLog(repoPath, filepath.Join(repoPath, "main.go"), 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(log, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 commits, got %d: %q", len(lines), log)
+	}
+	if !strings.HasSuffix(lines[0], "commit xxxx") {
+		t.Errorf("Expected newest commit first, got %q", lines[0])
+	}
+}
+
+func TestGetGitLog_DefaultsToFiveCommits(t *testing.T) {
+	repoPath := initTestRepo(t, 7)
+
+	log, err := GetGitLog(repoPath, "main.go", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if lines := strings.Split(log, "\n"); len(lines) != 5 {
+		t.Errorf("Expected 5 commits, got %d", len(lines))
+	}
+}
+
+func TestGetGitLog_UntrackedFile(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+
+	log, err := GetGitLog(repoPath, "untracked.go", 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if log != "" {
+		t.Errorf("Expected empty log for untracked file, got %q", log)
+	}
+}
+
+// Tests for GetGitLogs
+
+func TestGetGitLogs_LimitsCommitsPerFile(t *testing.T) {
+	repoPath := initTestRepo(t, 4)
+	if err := os.MkdirAll(filepath.Join(repoPath, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "pkg", "util.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	runGit(t, repoPath, "add", "pkg/util.go")
+	runGit(t, repoPath, "commit", "-q", "-m", "add util")
+
+	logs, err := GetGitLogs(repoPath, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(logs["main.go"]) != 2 {
+		t.Fatalf("Expected 2 commits for main.go, got %q", logs["main.go"])
+	}
+	if !strings.HasSuffix(logs["main.go"][0], "commit xxxx") {
+		t.Errorf("Expected newest commit first, got %q", logs["main.go"][0])
+	}
+	if got := logs["pkg/util.go"]; len(got) != 1 || !strings.HasSuffix(got[0], "add util") {
+		t.Errorf("Expected one commit for pkg/util.go, got %q", got)
+	}
+}
+
+func TestGetGitLogs_RelativeToSubdirectory(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	if err := os.MkdirAll(filepath.Join(repoPath, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "pkg", "util.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	runGit(t, repoPath, "add", "pkg/util.go")
+	runGit(t, repoPath, "commit", "-q", "-m", "add util")
+
+	logs, err := GetGitLogs(filepath.Join(repoPath, "pkg"), 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(logs) != 1 || len(logs["util.go"]) != 1 {
+		t.Errorf("Expected only util.go keyed relative to pkg, got %q", logs)
+	}
+}
+
+// Tests for GetRemoteURL
+
+func TestGetRemoteURL_ConfiguredRemote(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	runGit(t, repoPath, "remote", "add", "origin", "https://github.com/example/project.git")
+
+	remote, err := GetRemoteURL(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if remote != "https://github.com/example/project.git" {
+		t.Errorf("Expected configured remote, got %q", remote)
+	}
+}
+
+func TestGetRemoteURL_NoRemote(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+
+	if _, err := GetRemoteURL(repoPath); err == nil {
+		t.Error("Expected error when no origin remote is configured")
+	}
+}
+
+func TestGetGitInfo_IncludesRemote(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+
+	info, err := GetGitInfo(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(info, "Remote: (none)") {
+		t.Errorf("Expected 'Remote: (none)', got:\n%s", info)
+	}
+
+	runGit(t, repoPath, "remote", "add", "origin", "git@github.com:example/project.git")
+
+	info, err = GetGitInfo(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(info, "Remote: git@github.com:example/project.git") {
+		t.Errorf("Expected remote URL in git info, got:\n%s", info)
+	}
+}
+
+func TestGetGitInfo_IncludesTag(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+
+	info, err := GetGitInfo(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(info, "Tag   : (none)") {
+		t.Errorf("Expected 'Tag   : (none)', got:\n%s", info)
+	}
+
+	runGit(t, repoPath, "tag", "v1.2.3")
+
+	info, err = GetGitInfo(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(info, "Tag   : v1.2.3\n") {
+		t.Errorf("Expected tag in git info, got:\n%s", info)
+	}
+}
+
+func TestGetGitInfoAtomic_MatchesAtCommit(t *testing.T) {
+	repoPath := initTestRepo(t, 2)
+	runGit(t, repoPath, "checkout", "-q", "-b", "feature")
+	runGit(t, repoPath, "tag", "v1.0.0")
+
+	atomic, err := GetGitInfoAtomic(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	atCommit, err := GetGitInfoAtCommit(repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atomic != atCommit {
+		t.Errorf("Expected the same info as GetGitInfoAtCommit\natomic:\n%s\nat commit:\n%s", atomic, atCommit)
+	}
+	if !strings.Contains(atomic, "Branch: feature\n") || !strings.Contains(atomic, "Author: Test User <test@example.com>\n") {
+		t.Errorf("Expected branch and author, got:\n%s", atomic)
+	}
+
+	// A detached HEAD reports "HEAD" as git rev-parse --abbrev-ref does
+	runGit(t, repoPath, "checkout", "-q", "--detach")
+	atomic, err = GetGitInfoAtomic(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(atomic, "Branch: HEAD\n") {
+		t.Errorf("Expected detached HEAD, got:\n%s", atomic)
+	}
+}
+
+func TestBranchFromRefs(t *testing.T) {
+	tests := map[string]string{
+		"HEAD -> main":                             "main",
+		"HEAD -> feature/x, origin/main":           "feature/x",
+		"HEAD, tag: v1, main":                      "HEAD",
+		"tag: v1, HEAD -> release, origin/release": "release",
+	}
+	for refs, expected := range tests {
+		if got := branchFromRefs(refs); got != expected {
+			t.Errorf("branchFromRefs(%q) = %q, want %q", refs, got, expected)
+		}
+	}
+}
+
+// Tests for GetGitTags
+
+func TestGetGitTags_ExactAndDescribed(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	runGit(t, repoPath, "tag", "v1.2.3")
+
+	tag, err := GetGitTags(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tag != "v1.2.3" {
+		t.Errorf("Expected v1.2.3 on the tagged commit, got %q", tag)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoPath, "main.go"), []byte("// later\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	runGit(t, repoPath, "commit", "-q", "-am", "later")
+
+	tag, err = GetGitTags(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^v1\.2\.3-1-g[0-9a-f]+$`).MatchString(tag) {
+		t.Errorf("Expected v1.2.3-1-g<hash> after one more commit, got %q", tag)
+	}
+}
+
+func TestGetGitTags_NoTags(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+
+	if _, err := GetGitTags(repoPath); err == nil {
+		t.Error("Expected error without tags")
+	}
+}
+
+func TestGetGitInfo_NotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	if _, err := GetGitInfo(t.TempDir()); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("Expected ErrNotGitRepo, got %v", err)
+	}
+}
+
+func TestIsGitRepository_GitMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	isRepo, err := IsGitRepository(t.TempDir())
+	if isRepo || err == nil || errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("Expected a git failure distinct from ErrNotGitRepo, got %t, %v", isRepo, err)
+	}
+}
+
+func TestIsGitRepository_MissingPathIsNotErrNotGitRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	isRepo, err := IsGitRepository(filepath.Join(t.TempDir(), "missing"))
+	if isRepo || err == nil || errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("Expected a git failure distinct from ErrNotGitRepo, got %t, %v", isRepo, err)
+	}
+}
+
+// Tests for GetContributors
+
+func TestGetContributors_OrderedByCommitCount(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	filePath := filepath.Join(repoPath, "main.go")
+
+	// Two more commits by a second author
+	for i := 0; i < 2; i++ {
+		if err := os.WriteFile(filePath, []byte(strings.Repeat("// more\n", i+5)), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		runGit(t, repoPath, "add", "main.go")
+		runGit(t, repoPath, "-c", "user.name=Second Author", "commit", "-q", "-m", "second")
+	}
+
+	contributors, err := GetContributors(repoPath, filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"Second Author", "Test User"}
+	if len(contributors) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, contributors)
+	}
+	for i := range expected {
+		if contributors[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, contributors)
+			break
+		}
+	}
+}
+
+func TestGetContributors_UntrackedFile(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+
+	contributors, err := GetContributors(repoPath, "untracked.go")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(contributors) != 0 {
+		t.Errorf("Expected no contributors, got %v", contributors)
+	}
+}
+
+func TestGetAllContributors_MatchesPerFileContributors(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	if err := os.MkdirAll(filepath.Join(repoPath, "pkg"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := os.WriteFile(filepath.Join(repoPath, "pkg", "util.go"), []byte(strings.Repeat("// more\n", i+1)), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		runGit(t, repoPath, "add", ".")
+		runGit(t, repoPath, "-c", "user.name=Second Author", "commit", "-q", "-m", "util")
+	}
+
+	all, err := GetAllContributors(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, name := range []string{"main.go", "pkg/util.go"} {
+		expected, err := GetContributors(repoPath, filepath.Join(repoPath, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if strings.Join(all[name], ",") != strings.Join(expected, ",") {
+			t.Errorf("%s: expected %v, got %v", name, expected, all[name])
+		}
+	}
+	if got := all["pkg/util.go"]; len(got) != 1 || got[0] != "Second Author" {
+		t.Errorf("Expected only Second Author for pkg/util.go, got %v", got)
+	}
+}
+
+// Tests for GetGitBlame
+
+func TestGetGitBlame_AttributesEachLine(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	filePath := filepath.Join(repoPath, "main.go")
+
+	// A second author appends a line
+	if err := os.WriteFile(filePath, []byte("// change\n// second\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	runGit(t, repoPath, "add", "main.go")
+	runGit(t, repoPath, "-c", "user.name=Second Author", "commit", "-q", "-m", "second")
+
+	blame, err := GetGitBlame(repoPath, filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []BlameLine{
+		{LineNum: 1, Author: "Test User", Content: "// change"},
+		{LineNum: 2, Author: "Second Author", Content: "// second"},
+	}
+	if len(blame) != len(expected) {
+		t.Fatalf("Expected %d lines, got %+v", len(expected), blame)
+	}
+	for i, want := range expected {
+		got := blame[i]
+		if got.LineNum != want.LineNum || got.Author != want.Author || got.Content != want.Content {
+			t.Errorf("Line %d: expected %+v, got %+v", i+1, want, got)
+		}
+		if len(got.Commit) < 40 {
+			t.Errorf("Line %d: expected a full commit hash, got %q", i+1, got.Commit)
+		}
+	}
+	if blame[0].Commit == blame[1].Commit {
+		t.Errorf("Expected lines from different commits, got %s for both", blame[0].Commit)
+	}
+}
+
+func TestGetGitBlame_UntrackedFile(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	if err := os.WriteFile(filepath.Join(repoPath, "untracked.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	if _, err := GetGitBlame(repoPath, "untracked.go"); err == nil {
+		t.Error("Expected an error for an untracked file")
+	}
+}
+
+// Tests for cloning GitHub repositories
+
+func TestParseGitHubURL_TableDriven(t *testing.T) {
+	tests := []struct {
+		input string
+		owner string
+		repo  string
+		ok    bool
+	}{
+		{"https://github.com/owner/repo", "owner", "repo", true},
+		{"https://github.com/owner/repo.git", "owner", "repo", true},
+		{"https://github.com/owner/repo/", "owner", "repo", true},
+		{"github.com/owner/repo", "owner", "repo", true},
+		{"github:owner/repo", "owner", "repo", true},
+		{"https://github.com/owner", "", "", false},
+		{"https://github.com/owner/repo/tree/main", "", "", false},
+		{"https://gitlab.com/owner/repo", "", "", false},
+		{"./src", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			owner, repo, ok := ParseGitHubURL(tt.input)
+			if ok != tt.ok || owner != tt.owner || repo != tt.repo {
+				t.Errorf("ParseGitHubURL(%q) = (%q, %q, %t), expected (%q, %q, %t)",
+					tt.input, owner, repo, ok, tt.owner, tt.repo, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCloneRepo_KeepsTokenOutOfConfig(t *testing.T) {
+	source := initTestRepo(t, 2)
+	dir := filepath.Join(t.TempDir(), "clone")
+
+	// A file:// URL makes git honor --depth for a local clone
+	if err := cloneRepo("file://"+source, dir, "secret-token", true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "main.go")); err != nil {
+		t.Errorf("Expected cloned file: %v", err)
+	}
+	config, err := os.ReadFile(filepath.Join(dir, ".git", "config"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString([]byte("x-access-token:secret-token"))
+	if strings.Contains(string(config), "secret-token") || strings.Contains(string(config), encoded) {
+		t.Errorf("Expected no credentials in the clone's config, got:\n%s", config)
+	}
+	log, err := runGitCommand(dir, "log", "--oneline")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lines := strings.Split(log, "\n"); len(lines) != 1 {
+		t.Errorf("Expected a shallow clone with 1 commit, got %d", len(lines))
+	}
+}
+
+func TestAuthHeaderEnv_AppendsToExistingConfig(t *testing.T) {
+	t.Setenv("GIT_CONFIG_COUNT", "2")
+
+	env := authHeaderEnv("abc")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("x-access-token:abc"))
+	expected := []string{
+		"GIT_CONFIG_COUNT=3",
+		"GIT_CONFIG_KEY_2=http.https://github.com/.extraHeader",
+		"GIT_CONFIG_VALUE_2=Authorization: Basic " + encoded,
+	}
+	if strings.Join(env, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, env)
+	}
+}
+
+// Tests for reading commits
+
+func TestReadFileAtCommit(t *testing.T) {
+	repoPath := initTestRepo(t, 3)
+	first, err := runGitCommand(repoPath, revParse, "HEAD~2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := ReadFileAtCommit(repoPath, "main.go", first)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content != "// change\n" {
+		t.Errorf("Expected content at first commit, got %q", content)
+	}
+
+	if _, err := ReadFileAtCommit(repoPath, "missing.go", first); err == nil {
+		t.Error("Expected error for a file missing at the commit")
+	}
+}
+
+func TestListFilesAtCommit(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	if err := os.MkdirAll(filepath.Join(repoPath, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "sub", "util.go"), []byte("package sub\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	runGit(t, repoPath, "add", ".")
+	runGit(t, repoPath, "commit", "-q", "-m", "add sub")
+
+	hash, err := ResolveCommit(repoPath, "HEAD")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	all, err := ListFilesAtCommit(repoPath, hash, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(all, ",") != "main.go,sub/util.go" {
+		t.Errorf("Expected all tracked files, got %v", all)
+	}
+
+	sub, err := ListFilesAtCommit(repoPath, hash, "sub")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(sub, ",") != "sub/util.go" {
+		t.Errorf("Expected files below sub, got %v", sub)
+	}
+
+	if _, err := ResolveCommit(repoPath, "does-not-exist"); err == nil {
+		t.Error("Expected error for unknown commit")
+	}
+}
+
+// Tests for GetGitStatus
+
+func TestGetGitStatus_StatusCodes(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(repoPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+	write("old.go", "package old\n")
+	runGit(t, repoPath, "add", "old.go")
+	runGit(t, repoPath, "commit", "-q", "-m", "add old.go")
+
+	write("main.go", "// modified\n")
+	write("added.go", "package added\n")
+	runGit(t, repoPath, "add", "added.go")
+	write(filepath.Join("new dir", "untracked.go"), "package untracked\n")
+	runGit(t, repoPath, "mv", "old.go", "renamed.go")
+
+	status, err := GetGitStatus(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"main.go":              "M",
+		"added.go":             "A",
+		"renamed.go":           "A",
+		"new dir/untracked.go": "?",
+	}
+	if len(status) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, status)
+	}
+	for path, code := range expected {
+		if status[path] != code {
+			t.Errorf("%s: expected status %q, got %q", path, code, status[path])
+		}
+	}
+}
+
+func TestGetGitStatus_NotARepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if _, err := GetGitStatus(t.TempDir()); err == nil {
+		t.Error("Expected error outside a git repository")
+	}
+}
+
+// Tests for GetModifiedFiles
+
+func TestGetModifiedFiles_SortedPaths(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	for _, name := range []string{"main.go", "b.go", "a.go"} {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte("// changed\n"), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+	}
+
+	files, err := GetModifiedFiles(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"a.go", "b.go", "main.go"}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+}
+
+func TestGetGlobalGitIgnorePath(t *testing.T) {
+	repoPath := initTestRepo(t, 1)
+	globalConfig := filepath.Join(t.TempDir(), "gitconfig")
+	t.Setenv("GIT_CONFIG_GLOBAL", globalConfig)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+	// Unset falls back to git's default location
+	path, err := GetGlobalGitIgnorePath(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != filepath.Join("/xdg", "git", "ignore") {
+		t.Errorf("Expected default path, got %q", path)
+	}
+
+	if err := os.WriteFile(globalConfig, []byte("[core]\n\texcludesFile = /custom/ignore\n"), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	path, err = GetGlobalGitIgnorePath(repoPath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != "/custom/ignore" {
+		t.Errorf("Expected configured path, got %q", path)
+	}
+}
+
+// Tests for GetGitConfig
+
+func TestGetGitConfig(t *testing.T) {
+	repoPath := initTestRepo(t, 0)
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(t.TempDir(), "gitconfig"))
+
+	name, err := GetGitConfig(repoPath, "user.name")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if name != "Test User" {
+		t.Errorf("Expected repository user.name, got %q", name)
+	}
+
+	// Unset keys are empty, not an error
+	value, err := GetGitConfig(repoPath, "diff.context")
+	if err != nil {
+		t.Fatalf("Unexpected error for an unset key: %v", err)
+	}
+	if value != "" {
+		t.Errorf("Expected empty value for an unset key, got %q", value)
+	}
+}
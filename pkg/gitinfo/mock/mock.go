@@ -0,0 +1,240 @@
+// Package mock provides an in-memory gitinfo.GitClient for tests that should
+// not depend on a git installation
+package mock
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BHChen24/repo2context/pkg/gitinfo"
+)
+
+// MockGitClient answers every query from its fields, whatever the path.
+// The zero value behaves like a directory outside any repository: queries
+// return gitinfo.ErrNotGitRepo.
+type MockGitClient struct {
+	IsRepo        bool
+	Root          string
+	Info          string // returned for HEAD and for any commit
+	RemoteURL     string
+	Tag           string
+	Config        map[string]string // git config values by key
+	ModifiedFiles []string
+	Status        map[string]string // status codes by path, as GetGitStatus
+	Contributors  map[string][]string
+	Logs          map[string][]string // recent commits by path, newest first
+	Blame         map[string][]gitinfo.BlameLine
+
+	// Commit is the hash every revision resolves to, and CommitFiles the
+	// content of the files tracked at it, by slash-separated path
+	Commit      string
+	CommitFiles map[string]string
+	CommitTime  time.Time
+
+	// Err, when set, is returned by every query
+	Err error
+}
+
+var _ gitinfo.GitClient = (*MockGitClient)(nil)
+
+// Use answers the gitinfo package-level queries from client for the rest of
+// the test and returns it
+func Use(t testing.TB, client *MockGitClient) *MockGitClient {
+	t.Helper()
+	previous := gitinfo.SetClient(client)
+	t.Cleanup(func() { gitinfo.SetClient(previous) })
+	return client
+}
+
+// IsGitRepository reports IsRepo
+func (m *MockGitClient) IsGitRepository(path string) (bool, error) {
+	if err := m.check(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetGitRoot returns Root
+func (m *MockGitClient) GetGitRoot(path string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	return m.Root, nil
+}
+
+// GetGitInfo returns Info
+func (m *MockGitClient) GetGitInfo(path string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	return m.Info, nil
+}
+
+// GetGitInfoAtCommit returns Info
+func (m *MockGitClient) GetGitInfoAtCommit(path, rev string) (string, error) {
+	return m.GetGitInfo(path)
+}
+
+// GetRemoteURL returns RemoteURL, failing like git when no remote is set
+func (m *MockGitClient) GetRemoteURL(path string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	if m.RemoteURL == "" {
+		return "", errors.New("no such remote 'origin'")
+	}
+	return m.RemoteURL, nil
+}
+
+// GetGitTags returns Tag, failing like git when there are no tags
+func (m *MockGitClient) GetGitTags(repoPath string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	if m.Tag == "" {
+		return "", errors.New("no names found, cannot describe anything")
+	}
+	return m.Tag, nil
+}
+
+// GetGitConfig returns the Config value of key, empty when unset
+func (m *MockGitClient) GetGitConfig(repoPath, key string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	return m.Config[key], nil
+}
+
+// GetGlobalGitIgnorePath returns the core.excludesFile value of Config
+func (m *MockGitClient) GetGlobalGitIgnorePath(repoPath string) (string, error) {
+	return m.GetGitConfig(repoPath, "core.excludesFile")
+}
+
+// GetModifiedFiles returns ModifiedFiles
+func (m *MockGitClient) GetModifiedFiles(repoPath string) ([]string, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.ModifiedFiles, nil
+}
+
+// GetGitStatus returns Status
+func (m *MockGitClient) GetGitStatus(repoPath string) (map[string]string, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.Status, nil
+}
+
+// GetContributors returns the Contributors of filePath
+func (m *MockGitClient) GetContributors(repoPath, filePath string) ([]string, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.Contributors[filePath], nil
+}
+
+// GetAllContributors returns Contributors
+func (m *MockGitClient) GetAllContributors(repoPath string) (map[string][]string, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.Contributors, nil
+}
+
+// GetGitLog returns at most maxCommits Logs of filePath, one per line
+func (m *MockGitClient) GetGitLog(repoPath, filePath string, maxCommits int) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	log := m.Logs[filePath]
+	if maxCommits > 0 && len(log) > maxCommits {
+		log = log[:maxCommits]
+	}
+	return strings.Join(log, "\n"), nil
+}
+
+// GetGitLogs returns at most maxCommits Logs per file
+func (m *MockGitClient) GetGitLogs(repoPath string, maxCommits int) (map[string][]string, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	logs := make(map[string][]string, len(m.Logs))
+	for path, log := range m.Logs {
+		if maxCommits > 0 && len(log) > maxCommits {
+			log = log[:maxCommits]
+		}
+		logs[path] = log
+	}
+	return logs, nil
+}
+
+// GetGitBlame returns the Blame of filePath
+func (m *MockGitClient) GetGitBlame(repoPath, filePath string) ([]gitinfo.BlameLine, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m.Blame[filePath], nil
+}
+
+// ResolveCommit returns Commit, failing like git when it is not set
+func (m *MockGitClient) ResolveCommit(repoPath, commit string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	if m.Commit == "" {
+		return "", fmt.Errorf("unknown commit %q", commit)
+	}
+	return m.Commit, nil
+}
+
+// ListFilesAtCommit returns the sorted CommitFiles below dir
+func (m *MockGitClient) ListFilesAtCommit(repoPath, commitHash, dir string) ([]string, error) {
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	files := []string{}
+	for path := range m.CommitFiles {
+		if dir == "" || strings.HasPrefix(path, prefix) {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadFileAtCommit returns the CommitFiles content of relFilePath
+func (m *MockGitClient) ReadFileAtCommit(repoPath, relFilePath, commitHash string) (string, error) {
+	if err := m.check(); err != nil {
+		return "", err
+	}
+	content, ok := m.CommitFiles[relFilePath]
+	if !ok {
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", relFilePath, commitHash)
+	}
+	return content, nil
+}
+
+// GetCommitTime returns CommitTime
+func (m *MockGitClient) GetCommitTime(repoPath, commitHash string) (time.Time, error) {
+	if err := m.check(); err != nil {
+		return time.Time{}, err
+	}
+	return m.CommitTime, nil
+}
+
+// check returns the error shared by queries that need a repository
+func (m *MockGitClient) check() error {
+	if m.Err != nil {
+		return m.Err
+	}
+	if !m.IsRepo {
+		return gitinfo.ErrNotGitRepo
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package languages
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// languageMap maps file extensions to syntax highlighting labels
+// Used in markdown code blocks and for language filtering
+var languageMap = map[string]string{
+	".go":         "go",
+	".js":         "javascript",
+	".ts":         "typescript",
+	".py":         "python",
+	".java":       "java",
+	".c":          "c",
+	".cpp":        "cpp",
+	".h":          "c",
+	".hpp":        "cpp",
+	".rs":         "rust",
+	".php":        "php",
+	".rb":         "ruby",
+	".sh":         "bash",
+	".bash":       "bash",
+	".zsh":        "bash",
+	".fish":       "bash",
+	".ps1":        "powershell",
+	".html":       "html",
+	".css":        "css",
+	".scss":       "scss",
+	".sass":       "sass",
+	".json":       "json",
+	".xml":        "xml",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".toml":       "toml",
+	".ini":        "ini",
+	".cfg":        "ini",
+	".conf":       "ini",
+	".md":         "markdown",
+	".txt":        "text",
+	".sql":        "sql",
+	".r":          "r",
+	".m":          "matlab",
+	".swift":      "swift",
+	".kt":         "kotlin",
+	".scala":      "scala",
+	".clj":        "clojure",
+	".hs":         "haskell",
+	".lua":        "lua",
+	".vim":        "vim",
+	".dockerfile": "dockerfile",
+	".makefile":   "makefile",
+	".dart":       "dart",
+	".ex":         "elixir",
+	".exs":        "elixir",
+	".jl":         "julia",
+	".nim":        "nim",
+	".cr":         "crystal",
+	".tf":         "hcl",
+	".hcl":        "hcl",
+	".proto":      "protobuf",
+	".graphql":    "graphql",
+	".gql":        "graphql",
+	".wasm":       "wasm",
+	".zig":        "zig",
+	".odin":       "odin",
+	".v":          "v",
+	".svelte":     "svelte",
+	".vue":        "vue",
+	".astro":      "astro",
+	".tsx":        "tsx",
+	".jsx":        "jsx",
+	".mjs":        "javascript",
+	".cjs":        "javascript",
+	".env":        "dotenv",
+	".lock":       "text",
+	".gradle":     "groovy",
+	".pom":        "xml",
+	".cmake":      "cmake",
+	".bazel":      "starlark",
+	".starlark":   "starlark",
+	".nix":        "nix",
+	".dhall":      "dhall",
+}
+
+// overrides holds user-defined extension mappings registered at runtime
+var (
+	overrides   = make(map[string]string)
+	overridesMu sync.RWMutex
+)
+
+// Detect returns the language name for a file based on its extension
+func Detect(path string) string {
+	return FromExtension(filepath.Ext(path))
+}
+
+// FromExtension returns the syntax highlighting language for a file extension
+// User-defined overrides take precedence over the built-in map
+func FromExtension(ext string) string {
+	ext = strings.ToLower(ext)
+
+	overridesMu.RLock()
+	language, exists := overrides[ext]
+	overridesMu.RUnlock()
+	if exists {
+		return language
+	}
+
+	if language, exists := languageMap[ext]; exists {
+		return language
+	}
+
+	// Default to text for unknown extensions
+	return "text"
+}
+
+// Register adds or replaces the language label for an extension
+func Register(ext, language string) {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	overridesMu.Lock()
+	overrides[ext] = language
+	overridesMu.Unlock()
+}
+
+// ParseMapping parses an "ext=label" override such as "tpl=html"
+func ParseMapping(mapping string) (string, string, error) {
+	ext, language, ok := strings.Cut(mapping, "=")
+	ext = strings.TrimSpace(ext)
+	language = strings.TrimSpace(language)
+	if !ok || ext == "" || language == "" {
+		return "", "", fmt.Errorf("invalid language mapping %q (expected ext=label)", mapping)
+	}
+	return ext, language, nil
+}
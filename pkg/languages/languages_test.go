@@ -0,0 +1,65 @@
+package languages
+
+import "testing"
+
+func TestDetect_KnownAndUnknownExtensions(t *testing.T) {
+	tests := map[string]string{
+		"main.go":        "go",
+		"script.PY":      "python",
+		"component.tsx":  "tsx",
+		"main.tf":        "hcl",
+		"schema.graphql": "graphql",
+		"notes.unknown":  "text",
+		"Makefile":       "text",
+	}
+
+	for path, expected := range tests {
+		if got := Detect(path); got != expected {
+			t.Errorf("Detect(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
+
+func TestRegister_OverridesBuiltIn(t *testing.T) {
+	Register("tpl", "html")
+	Register(".JS", "js")
+	defer func() {
+		overridesMu.Lock()
+		delete(overrides, ".tpl")
+		delete(overrides, ".js")
+		overridesMu.Unlock()
+	}()
+
+	if got := Detect("index.tpl"); got != "html" {
+		t.Errorf("Expected registered language html, got %q", got)
+	}
+	if got := Detect("app.js"); got != "js" {
+		t.Errorf("Expected override js, got %q", got)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		mapping     string
+		ext         string
+		language    string
+		expectError bool
+	}{
+		{"tpl=html", "tpl", "html", false},
+		{" .mdx = markdown ", ".mdx", "markdown", false},
+		{"tpl", "", "", true},
+		{"=html", "", "", true},
+		{"tpl=", "", "", true},
+	}
+
+	for _, tt := range tests {
+		ext, language, err := ParseMapping(tt.mapping)
+		if (err != nil) != tt.expectError {
+			t.Errorf("ParseMapping(%q) error = %v, expectError %v", tt.mapping, err, tt.expectError)
+			continue
+		}
+		if ext != tt.ext || language != tt.language {
+			t.Errorf("ParseMapping(%q) = (%q, %q), expected (%q, %q)", tt.mapping, ext, language, tt.ext, tt.language)
+		}
+	}
+}
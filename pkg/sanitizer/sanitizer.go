@@ -0,0 +1,106 @@
+package sanitizer
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+// SanitizeScanResult strips filesystem locations from a scan result before it
+// is shared: the root becomes its base name, file paths become relative to it,
+// and any other mention of the root or of homeDir in errors and the directory
+// tree is replaced by the root name or "~"
+// An empty homeDir leaves home directory paths outside the root untouched
+func SanitizeScanResult(result *scanner.ScanResult, homeDir string) {
+	if result == nil {
+		return
+	}
+
+	root := filepath.Clean(result.RootPath)
+	name := filepath.Base(root)
+	replacer := pathReplacer(root, name, homeDir)
+
+	for i := range result.Files {
+		result.Files[i].Path = relativePath(result.Files[i], root, replacer)
+	}
+	for i, message := range result.Errors {
+		result.Errors[i] = replacer.Replace(message)
+	}
+	result.DirectoryTree = replacer.Replace(result.DirectoryTree)
+	result.RootPath = name
+}
+
+// pathReplacer replaces the root with its name and homeDir with "~"
+// The root comes first so paths inside it are not shown under "~"
+func pathReplacer(root, name, homeDir string) *boundedReplacer {
+	pairs := [][2]string{{root, name}}
+	if homeDir = filepath.Clean(homeDir); homeDir != "." && homeDir != string(filepath.Separator) {
+		pairs = append(pairs, [2]string{homeDir, "~"})
+	}
+	return &boundedReplacer{pairs: pairs}
+}
+
+// boundedReplacer replaces whole paths only: a match must not be part of a longer
+// path, so /x/proj does not rewrite /x/project2 or /a/x/proj
+type boundedReplacer struct {
+	pairs [][2]string // old, new; earlier pairs win
+}
+
+// Replace returns text with every whole-path occurrence of each old path replaced
+func (r *boundedReplacer) Replace(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		matched := false
+		for _, pair := range r.pairs {
+			old := pair[0]
+			if strings.HasPrefix(text[i:], old) && startsPath(text, i) && endsPath(text, i+len(old)) {
+				out.WriteString(pair[1])
+				i += len(old)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out.WriteByte(text[i])
+			i++
+		}
+	}
+	return out.String()
+}
+
+// startsPath reports whether a path can start at text[i], i.e. the byte before
+// it is not part of a file name or a separator
+func startsPath(text string, i int) bool {
+	return i == 0 || !(isNameByte(text[i-1]) || isSeparator(text[i-1]))
+}
+
+// endsPath reports whether a path can end before text[i], i.e. text[i] is a
+// separator or not part of a file name
+func endsPath(text string, i int) bool {
+	return i == len(text) || isSeparator(text[i]) || !isNameByte(text[i])
+}
+
+func isSeparator(b byte) bool {
+	return b == '/' || b == filepath.Separator
+}
+
+// isNameByte reports whether b can continue a file name; bytes of multi-byte
+// characters count as name bytes
+func isNameByte(b byte) bool {
+	return b >= utf8.RuneSelf || b == '.' || b == '-' || b == '_' ||
+		'0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// relativePath returns the path of a file relative to the root, falling back
+// to replacing known prefixes for files outside it (merged scans, archives)
+func relativePath(file scanner.FileInfo, root string, replacer *boundedReplacer) string {
+	if !filepath.IsAbs(file.Path) {
+		return file.Path
+	}
+	if rel, err := filepath.Rel(root, file.Path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return replacer.Replace(file.Path)
+}
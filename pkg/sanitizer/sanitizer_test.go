@@ -0,0 +1,78 @@
+package sanitizer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/BHChen24/repo2context/pkg/scanner"
+)
+
+func TestSanitizeScanResult(t *testing.T) {
+	home := filepath.Join(string(filepath.Separator), "home", "alice")
+	root := filepath.Join(home, "company", "secret-project")
+	result := &scanner.ScanResult{
+		RootPath: root,
+		Files: []scanner.FileInfo{
+			{Path: filepath.Join(root, "cmd", "main.go"), RelativePath: filepath.Join("cmd", "main.go")},
+			{Path: filepath.Join(home, "notes.txt"), RelativePath: "notes.txt"},
+			{Path: "(stdin)", RelativePath: "(stdin)"},
+		},
+		Errors:        []string{"error reading " + filepath.Join(root, "key.pem") + ": permission denied"},
+		DirectoryTree: root + "\n",
+	}
+
+	SanitizeScanResult(result, home)
+
+	if result.RootPath != "secret-project" {
+		t.Errorf("Expected root name secret-project, got %q", result.RootPath)
+	}
+	expectedPaths := []string{filepath.Join("cmd", "main.go"), filepath.Join("~", "notes.txt"), "(stdin)"}
+	for i, want := range expectedPaths {
+		if got := result.Files[i].Path; got != want {
+			t.Errorf("File %d: expected path %q, got %q", i, want, got)
+		}
+	}
+	if want := "error reading " + filepath.Join("secret-project", "key.pem") + ": permission denied"; result.Errors[0] != want {
+		t.Errorf("Expected error %q, got %q", want, result.Errors[0])
+	}
+	if result.DirectoryTree != "secret-project\n" {
+		t.Errorf("Expected sanitized tree, got %q", result.DirectoryTree)
+	}
+}
+
+func TestSanitizeScanResult_NoHomeDir(t *testing.T) {
+	outside := filepath.Join(string(filepath.Separator), "home", "alice", "notes.txt")
+	result := &scanner.ScanResult{
+		RootPath: filepath.Join(string(filepath.Separator), "srv", "repo"),
+		Files:    []scanner.FileInfo{{Path: outside}},
+	}
+
+	SanitizeScanResult(result, "")
+
+	if result.Files[0].Path != outside {
+		t.Errorf("Expected %q to be kept without a home directory, got %q", outside, result.Files[0].Path)
+	}
+}
+
+func TestSanitizeScanResult_WholePathsOnly(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "x", "proj")
+	sibling := filepath.Join(string(filepath.Separator), "x", "project2", "main.go")
+	nested := filepath.Join(string(filepath.Separator), "a", "x", "proj", "main.go")
+	result := &scanner.ScanResult{
+		RootPath: root,
+		Errors: []string{
+			"see " + sibling,
+			"see " + nested,
+			"see " + filepath.Join(root, "main.go"),
+		},
+	}
+
+	SanitizeScanResult(result, "")
+
+	expected := []string{"see " + sibling, "see " + nested, "see " + filepath.Join("proj", "main.go")}
+	for i, want := range expected {
+		if result.Errors[i] != want {
+			t.Errorf("Error %d: expected %q, got %q", i, want, result.Errors[i])
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BHChen24/repo2context/pkg/editorconfig"
+	"github.com/BHChen24/repo2context/pkg/languages"
+
+	"github.com/ulikunitz/xz"
+)
+
+// archiveExtensions are the archive suffixes ScanArchive can read
+var archiveExtensions = []string{".zip", ".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tar.xz"}
+
+// IsArchive reports whether a path names an archive ScanArchive can read
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Limits on the uncompressed bytes read from an archive, so a small
+// compressed archive can't expand to fill memory (a decompression bomb)
+// Variables so tests can lower them
+var (
+	maxArchiveEntrySize int64 = 64 << 20 // per file
+	maxArchiveTotalSize int64 = 1 << 30  // all files together
+)
+
+// archiveEntry is a file or directory read from an archive
+type archiveEntry struct {
+	name    string // slash-separated path inside the archive
+	isDir   bool
+	modTime time.Time
+	data    []byte
+	err     error // the file exceeded a size limit and was not read
+}
+
+// archiveBudget tracks the bytes left under maxArchiveTotalSize
+type archiveBudget struct {
+	remaining int64
+}
+
+// read reads an entry of at most maxArchiveEntrySize bytes and within the
+// remaining total, returning an error instead of the data when it is larger
+func (b *archiveBudget) read(name string, r io.Reader) ([]byte, error) {
+	limit := min(maxArchiveEntrySize, b.remaining)
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if int64(len(data)) > limit {
+		if limit < maxArchiveEntrySize {
+			return nil, fmt.Errorf("archive exceeds %d bytes uncompressed, skipping %s", maxArchiveTotalSize, name)
+		}
+		return nil, fmt.Errorf("%s exceeds %d bytes uncompressed, skipping it", name, maxArchiveEntrySize)
+	}
+	b.remaining -= int64(len(data))
+	return data, nil
+}
+
+// ScanArchive scans a zip or tar archive as if it were a directory, without
+// extracting it. RootPath is the archive path and the directory tree mirrors
+// the archive's internal structure. The allowlist, language filters and
+// Filters apply as in ScanDirectoryWithOptions; ignore files do not, since
+// there is no working tree to read them from.
+func ScanArchive(archivePath string, options ScanOptions) (*ScanResult, error) {
+	absPath, err := GetEntryPoint(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := readArchive(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", absPath, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+
+	result := &ScanResult{
+		RootPath: absPath,
+		Files:    []FileInfo{{Path: absPath, IsDir: true}},
+		Errors:   make([]string, 0),
+	}
+
+	allowedFiles, allowedDirs := buildAllowList(options.AllowList)
+
+	// Charsets come from the .editorconfig files in the archive
+	if options.RespectEditorConfig {
+		options.editorConfig = archiveEditorConfig(entries)
+	}
+
+	// Filters excluding a directory also exclude everything below it, including
+	// directories that only exist implicitly as a prefix of entry names
+	excludedDirs := make(map[string]bool)
+	checkedDirs := make(map[string]bool)
+	isBelowExcluded := func(name string) bool {
+		for i := range name {
+			if name[i] != '/' {
+				continue
+			}
+			dir := name[:i]
+			if !checkedDirs[dir] {
+				checkedDirs[dir] = true
+				dirEntry := virtualFile{name: path.Base(dir), dir: true}
+				excludedDirs[dir] = excludedByFilters(options.Filters, filepath.FromSlash(dir), dirEntry)
+			}
+			if excludedDirs[dir] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, entry := range entries {
+		if options.Context != nil {
+			if ctxErr := options.Context.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("error scanning archive: %w", ctxErr)
+			}
+		}
+		if isBelowExcluded(entry.name) {
+			continue
+		}
+
+		relPath := filepath.FromSlash(entry.name)
+		virtualPath := filepath.Join(absPath, relPath)
+		if isExcludedPath(options.ExcludePaths, relPath) {
+			continue
+		}
+
+		if allowedFiles != nil {
+			if entry.isDir && !allowedDirs[relPath] {
+				continue
+			}
+			if !entry.isDir && !allowedFiles[relPath] {
+				continue
+			}
+		}
+		if !entry.isDir && !matchesLanguageFilter(languages.Detect(virtualPath), options) {
+			continue
+		}
+
+		dirEntry := virtualFile{name: path.Base(entry.name), size: int64(len(entry.data)), modTime: entry.modTime, dir: entry.isDir}
+		if entry.isDir {
+			checkedDirs[entry.name] = true
+		}
+		if excludedByFilters(options.Filters, relPath, dirEntry) {
+			excludedDirs[entry.name] = entry.isDir
+			continue
+		}
+		if !entry.isDir && entry.err == nil && excludedByContent(options.Filters, relPath, string(entry.data)) {
+			continue
+		}
+
+		fileInfo := FileInfo{
+			Path:         virtualPath,
+			RelativePath: relPath,
+			IsDir:        entry.isDir,
+			ModTime:      entry.modTime,
+		}
+		if entry.isDir {
+			result.Files = append(result.Files, fileInfo)
+			continue
+		}
+		if entry.err != nil {
+			fileInfo.Error = entry.err
+			result.Errors = append(result.Errors, entry.err.Error())
+			if options.AbortOnError {
+				return nil, fmt.Errorf("error scanning archive: %w", entry.err)
+			}
+			result.Files = append(result.Files, fileInfo)
+			continue
+		}
+		if int64(len(entry.data)) < options.MinFileSizeBytes {
+			result.SkippedSmallFiles = append(result.SkippedSmallFiles, relPath)
+			continue
+		}
+
+		fileInfo.Size = int64(len(entry.data))
+		fileInfo.Language = languages.Detect(virtualPath)
+		result.TotalSize += fileInfo.Size
+		if !options.NoContent {
+			format := options.lineFormat(fileInfo.Language)
+			format.charset = options.charsetOf(entry.name)
+			content, lines, readErr := formatContent(editorconfig.NewReader(bytes.NewReader(entry.data), format.charset), format)
+			if readErr != nil {
+				fileInfo.Error = readErr
+				result.Errors = append(result.Errors, fmt.Sprintf("error reading %s: %v", virtualPath, readErr))
+				if options.AbortOnError {
+					return nil, fmt.Errorf("error reading %s: %w", virtualPath, readErr)
+				}
+			} else {
+				fileInfo.Content = content
+				fileInfo.Lines = lines
+				result.TotalLines += lines
+			}
+		}
+		if options.Checksum != "" {
+			fileInfo.Hash, _ = hashBytes(entry.data, options.Checksum)
+		}
+
+		result.Files = append(result.Files, fileInfo)
+		result.TotalFiles++
+		if options.ProgressCallback != nil {
+			options.ProgressCallback(result.TotalFiles, -1, relPath)
+		}
+	}
+
+	if tooManyErrors(result, options) {
+		return nil, fmt.Errorf("error scanning archive: too many errors (%d), maximum allowed: %d", len(result.Errors), options.MaxErrors)
+	}
+	if options.ProgressCallback != nil {
+		options.ProgressCallback(result.TotalFiles, result.TotalFiles, "")
+	}
+
+	if options.PruneEmptyDirs {
+		result.Files = pruneEmptyDirs(result.Files)
+	}
+	result.DirectoryTree = generateDirectoryTree(result.Files, absPath)
+	return result, nil
+}
+
+// archiveEditorConfig returns a Resolver reading the .editorconfig files among
+// the entries, looked up by entry name
+func archiveEditorConfig(entries []archiveEntry) *editorconfig.Resolver {
+	configs := make(map[string][]byte)
+	for _, entry := range entries {
+		if !entry.isDir && entry.err == nil && path.Base(entry.name) == editorconfig.FileName {
+			configs[entry.name] = entry.data
+		}
+	}
+
+	return editorconfig.NewSourceResolver(func(name string) ([]byte, error) {
+		data, ok := configs[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return data, nil
+	})
+}
+
+// readArchive reads every entry of a zip or tar archive
+func readArchive(archivePath string) ([]archiveEntry, error) {
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		return readZip(archivePath)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	var r io.Reader = file
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close() //nolint:errcheck
+		r = gz
+	case strings.HasSuffix(lower, ".tar.bz2"):
+		r = bzip2.NewReader(file)
+	case strings.HasSuffix(lower, ".tar.xz"):
+		xzReader, err := xz.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		r = xzReader
+	}
+	return readTar(r)
+}
+
+// readZip reads the entries of a zip archive
+func readZip(archivePath string) ([]archiveEntry, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close() //nolint:errcheck
+
+	budget := &archiveBudget{remaining: maxArchiveTotalSize}
+	var entries []archiveEntry
+	for _, f := range zr.File {
+		name, ok := cleanEntryName(f.Name)
+		if !ok {
+			continue
+		}
+		entry := archiveEntry{name: name, isDir: f.FileInfo().IsDir(), modTime: f.Modified}
+		if !entry.isDir {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+			}
+			entry.data, entry.err = budget.read(f.Name, rc)
+			rc.Close() //nolint:errcheck
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readTar reads the regular files and directories of a tar stream
+func readTar(r io.Reader) ([]archiveEntry, error) {
+	tr := tar.NewReader(r)
+	budget := &archiveBudget{remaining: maxArchiveTotalSize}
+
+	var entries []archiveEntry
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := cleanEntryName(header.Name)
+		if !ok {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entries = append(entries, archiveEntry{name: name, isDir: true, modTime: header.ModTime})
+		case tar.TypeReg:
+			data, err := budget.read(header.Name, tr)
+			entries = append(entries, archiveEntry{name: name, modTime: header.ModTime, data: data, err: err})
+		}
+	}
+	return entries, nil
+}
+
+// cleanEntryName normalizes an archive entry name to a clean relative path
+// Entries that are empty or escape the archive root (e.g. "../x") are rejected
+func cleanEntryName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrScanResultExpired is returned by LoadScanResult for a cache older than its TTL
+var ErrScanResultExpired = errors.New("cached scan result expired")
+
+// cachedFile is a FileInfo with its error kept as a message, since JSON
+// cannot restore an error value
+type cachedFile struct {
+	FileInfo
+	Error string `json:",omitempty"`
+}
+
+// cachedScanResult is the gzipped JSON document written by SaveScanResult
+type cachedScanResult struct {
+	*ScanResult
+	Files []cachedFile
+}
+
+// SaveScanResult writes a scan result to path as gzipped JSON
+func SaveScanResult(result *ScanResult, path string) error {
+	cached := cachedScanResult{ScanResult: result, Files: make([]cachedFile, len(result.Files))}
+	for i, file := range result.Files {
+		cached.Files[i].FileInfo = file
+		if file.Error != nil {
+			cached.Files[i].Error = file.Error.Error()
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to save scan result: %w", err)
+	}
+	defer out.Close() //nolint:errcheck
+
+	gz := gzip.NewWriter(out)
+	if err := json.NewEncoder(gz).Encode(cached); err != nil {
+		return fmt.Errorf("failed to save scan result: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to save scan result: %w", err)
+	}
+	return out.Close()
+}
+
+// LoadScanResult reads a scan result written by SaveScanResult
+// A cache modified more than ttl ago returns ErrScanResultExpired (0 means it never expires)
+func LoadScanResult(path string, ttl time.Duration) (*ScanResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scan result: %w", err)
+	}
+	if ttl > 0 && time.Since(info.ModTime()) > ttl {
+		return nil, fmt.Errorf("%w: %s is older than %s", ErrScanResultExpired, path, ttl)
+	}
+
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scan result: %w", err)
+	}
+	defer in.Close() //nolint:errcheck
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scan result: %w", err)
+	}
+	defer gz.Close() //nolint:errcheck
+
+	cached := cachedScanResult{ScanResult: &ScanResult{}}
+	if err := json.NewDecoder(gz).Decode(&cached); err != nil {
+		return nil, fmt.Errorf("failed to load scan result: %w", err)
+	}
+
+	result := cached.ScanResult
+	result.Files = make([]FileInfo, len(cached.Files))
+	for i, file := range cached.Files {
+		result.Files[i] = file.FileInfo
+		if file.Error != "" {
+			result.Files[i].Error = errors.New(file.Error)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package scanner
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Supported checksum algorithms
+const (
+	ChecksumMD5    = "md5"
+	ChecksumSHA256 = "sha256"
+)
+
+// ValidateChecksum checks that a checksum algorithm is supported
+// An empty algorithm means checksums are disabled
+func ValidateChecksum(algorithm string) error {
+	switch algorithm {
+	case "", ChecksumMD5, ChecksumSHA256:
+		return nil
+	default:
+		return fmt.Errorf("unsupported checksum %q (supported: %s, %s)", algorithm, ChecksumMD5, ChecksumSHA256)
+	}
+}
+
+// HashFile returns the hex-encoded hash of a file's raw bytes
+func HashFile(path string, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if h == nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+
+	return hashReader(h, file)
+}
+
+// hashFSFile returns the hex-encoded hash of the raw bytes of a file in fsys
+func hashFSFile(fsys fs.FS, name string, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if h == nil {
+		return "", err
+	}
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+
+	return hashReader(h, file)
+}
+
+// hashReader returns the hex-encoded hash h computes over everything read from r
+func hashReader(h hash.Hash, r io.Reader) (string, error) {
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashBytes returns the hex-encoded hash of data
+func hashBytes(data []byte, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if h == nil {
+		return "", err
+	}
+	h.Write(data) //nolint:errcheck
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newHash returns a hash for the algorithm, or nil if it is unsupported or empty
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, ValidateChecksum(algorithm)
+	}
+}
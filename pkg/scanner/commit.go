@@ -0,0 +1,182 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/editorconfig"
+	"github.com/BHChen24/repo2context/pkg/gitignore"
+	"github.com/BHChen24/repo2context/pkg/gitinfo"
+	"github.com/BHChen24/repo2context/pkg/languages"
+)
+
+// ScanCommit scans the files under rootPath as they were at a git commit,
+// reading content with git instead of from the working tree
+// Ignore files, allowlist, language filters and Filters apply as in ScanDirectoryWithOptions
+func ScanCommit(rootPath, commit string, options ScanOptions) (*ScanResult, error) {
+	absRoot, err := GetEntryPoint(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	gitRoot, err := gitinfo.GetGitRoot(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", absRoot, err)
+	}
+	commitHash, err := gitinfo.ResolveCommit(gitRoot, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files are listed relative to the repository root
+	prefix, err := filepath.Rel(gitRoot, absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path relative to repository: %w", err)
+	}
+	if prefix == "." {
+		prefix = ""
+	}
+	prefix = filepath.ToSlash(prefix)
+
+	paths, err := gitinfo.ListFilesAtCommit(gitRoot, commitHash, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every file carries the commit time as its modification time
+	commitTime, _ := gitinfo.GetCommitTime(gitRoot, commitHash)
+
+	result := &ScanResult{
+		RootPath: absRoot,
+		Files:    make([]FileInfo, 0, len(paths)),
+		Errors:   make([]string, 0),
+	}
+
+	gi, ri, _ := loadIgnoreFiles(absRoot, options, result)
+	ignore := gitignore.Merge(gi, ri)
+	allowedFiles, _ := buildAllowList(options.AllowList)
+
+	// Charsets come from the .editorconfig files committed with the content
+	if options.RespectEditorConfig {
+		resolver, err := commitEditorConfig(gitRoot, commitHash)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("warning: could not list .editorconfig files: %v", err))
+			options.RespectEditorConfig = false
+		}
+		options.editorConfig = resolver
+	}
+
+	for _, repoPath := range paths {
+		if options.Context != nil {
+			if ctxErr := options.Context.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("error scanning commit: %w", ctxErr)
+			}
+		}
+
+		relPath := filepath.FromSlash(strings.TrimPrefix(repoPath, prefix+"/"))
+		if prefix == "" {
+			relPath = filepath.FromSlash(repoPath)
+		}
+		absPath := filepath.Join(absRoot, relPath)
+		if isExcludedPath(options.ExcludePaths, relPath) {
+			continue
+		}
+
+		// Tracked files can still be excluded by the ignore files
+		if isIgnoredBy(ignore, filepath.FromSlash(repoPath), false) {
+			continue
+		}
+		if allowedFiles != nil && !allowedFiles[relPath] {
+			continue
+		}
+		if !matchesLanguageFilter(languages.Detect(absPath), options) {
+			continue
+		}
+
+		raw, err := gitinfo.ReadFileAtCommit(gitRoot, repoPath, commitHash)
+		entry := virtualFile{name: path.Base(repoPath), size: int64(len(raw)), modTime: commitTime}
+		if excludedByFilters(options.Filters, relPath, entry) {
+			continue
+		}
+		if err == nil && excludedByContent(options.Filters, relPath, raw) {
+			continue
+		}
+
+		fileInfo := FileInfo{
+			Path:         absPath,
+			RelativePath: relPath,
+			Size:         int64(len(raw)),
+			Language:     languages.Detect(absPath),
+			ModTime:      commitTime,
+		}
+
+		if err != nil {
+			fileInfo.Error = err
+			result.Errors = append(result.Errors, err.Error())
+			if options.AbortOnError {
+				return nil, fmt.Errorf("error scanning commit: %w", err)
+			}
+			result.Files = append(result.Files, fileInfo)
+			continue
+		}
+		if int64(len(raw)) < options.MinFileSizeBytes {
+			result.SkippedSmallFiles = append(result.SkippedSmallFiles, relPath)
+			continue
+		}
+
+		result.TotalSize += fileInfo.Size
+		if !options.NoContent {
+			format := options.lineFormat(fileInfo.Language)
+			format.charset = options.charsetOf(repoPath)
+			content, lines, _ := formatContent(editorconfig.NewReader(strings.NewReader(raw), format.charset), format)
+			fileInfo.Content = content
+			fileInfo.Lines = lines
+			result.TotalLines += lines
+		}
+		if options.Checksum != "" {
+			fileInfo.Hash, _ = hashBytes([]byte(raw), options.Checksum)
+		}
+
+		result.Files = append(result.Files, fileInfo)
+		result.TotalFiles++
+		if options.ProgressCallback != nil {
+			options.ProgressCallback(result.TotalFiles, -1, relPath)
+		}
+	}
+
+	if tooManyErrors(result, options) {
+		return nil, fmt.Errorf("error scanning commit: too many errors (%d), maximum allowed: %d", len(result.Errors), options.MaxErrors)
+	}
+	if options.ProgressCallback != nil {
+		options.ProgressCallback(result.TotalFiles, result.TotalFiles, "")
+	}
+
+	result.DirectoryTree = generateDirectoryTree(result.Files, absRoot)
+	return result, nil
+}
+
+// commitEditorConfig returns a Resolver reading the .editorconfig files of a
+// commit, looked up by paths relative to the repository root
+func commitEditorConfig(gitRoot, commitHash string) (*editorconfig.Resolver, error) {
+	paths, err := gitinfo.ListFilesAtCommit(gitRoot, commitHash, "")
+	if err != nil {
+		return nil, err
+	}
+	committed := make(map[string]bool)
+	for _, repoPath := range paths {
+		if path.Base(repoPath) == editorconfig.FileName {
+			committed[repoPath] = true
+		}
+	}
+
+	return editorconfig.NewSourceResolver(func(name string) ([]byte, error) {
+		if !committed[name] {
+			return nil, fs.ErrNotExist
+		}
+		content, err := gitinfo.ReadFileAtCommit(gitRoot, name, commitHash)
+		return []byte(content), err
+	}), nil
+}
@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BHChen24/repo2context/pkg/gitignore"
+)
+
+// ExplainIgnore reports why a path is or isn't excluded by .gitignore and
+// .r2cignore, loading and merging them as a scan would (including the --vendor
+// style overrides in options), so a "!" pattern of .r2cignore can re-include a
+// path .gitignore matches. No scan is performed.
+func ExplainIgnore(path string, options ScanOptions) (string, error) {
+	absPath, err := GetEntryPoint(path)
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("error checking path: %w", err)
+	}
+
+	// Ignore files are loaded from the git root, or outside a repository from the
+	// scan root, assumed to be the working directory when it contains the path
+	root := absPath
+	if !stat.IsDir() {
+		root = filepath.Dir(absPath)
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, absPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			root = cwd
+		}
+	}
+	result := &ScanResult{}
+	gi, ri, basePath := loadIgnoreFiles(root, options, result)
+
+	relPath, err := filepath.Rel(basePath, absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to get path relative to %s: %w", basePath, err)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("%s (relative to %s)\n", filepath.ToSlash(relPath), basePath))
+	for _, layer := range []struct {
+		name string
+		gi   *gitignore.GitIgnore
+	}{{".gitignore", gi}, {".r2cignore", ri}} {
+		if layer.gi != nil {
+			output.WriteString(fmt.Sprintf("%s: enabled\n", layer.name))
+		} else {
+			output.WriteString(fmt.Sprintf("%s: disabled\n", layer.name))
+		}
+	}
+
+	// The scan decides on the merged patterns, each named by the file it came from
+	ignore := gitignore.Merge(gi.WithSource(".gitignore"), ri.WithSource(".r2cignore"))
+	if ignore != nil {
+		output.WriteString(fmt.Sprintf("Result: %s\n", ignore.Explain(relPath, stat.IsDir())))
+	} else {
+		output.WriteString("Result: not ignored\n")
+	}
+	for _, warning := range result.Errors {
+		output.WriteString(fmt.Sprintf("Warning: %s\n", warning))
+	}
+	return output.String(), nil
+}
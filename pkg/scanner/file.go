@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BHChen24/repo2context/pkg/languages"
+)
+
+// ScanFile scans a single file into a ScanResult rooted at its parent directory
+// Content, line numbers, line endings and checksums follow options; ignore files,
+// the allowlist, language filters and Filters don't apply to a file named explicitly
+func ScanFile(filePath string, options ScanOptions) (*ScanResult, error) {
+	absPath, err := GetEntryPoint(filePath)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	if stat.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", absPath)
+	}
+
+	parentDir := filepath.Dir(absPath)
+	fileInfo := FileInfo{
+		Path:         absPath,
+		RelativePath: filepath.Base(absPath),
+		Size:         stat.Size(),
+		Language:     languages.Detect(absPath),
+		ModTime:      stat.ModTime(),
+	}
+
+	// Read the content unless only metadata was requested, and hash the raw bytes if requested
+	read := readFile(os.DirFS(parentDir), fileInfo.RelativePath, absPath, options)
+	if read.readErr != nil {
+		return nil, fmt.Errorf("failed to read file: %w", read.readErr)
+	}
+	if read.hashErr != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", read.hashErr)
+	}
+	fileInfo.Content, fileInfo.Lines, fileInfo.Hash = read.content, read.lines, read.hash
+
+	files := []FileInfo{fileInfo}
+	return &ScanResult{
+		RootPath:      parentDir,
+		Files:         files,
+		DirectoryTree: generateDirectoryTree(files, parentDir),
+		TotalFiles:    1,
+		TotalLines:    fileInfo.Lines,
+		TotalSize:     stat.Size(),
+		Errors:        []string{},
+	}, nil
+}
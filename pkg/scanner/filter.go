@@ -0,0 +1,324 @@
+package scanner
+
+import (
+	"bufio"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileFilter excludes entries from a scan in addition to the ignore files
+type FileFilter interface {
+	// Exclude reports whether the entry at relPath (relative to the scan root) is skipped
+	// Excluding a directory skips everything below it
+	Exclude(relPath string, d fs.DirEntry) bool
+}
+
+// ContentFilter is a FileFilter that also decides from what a file contains
+// Scans call ExcludeContent for files Exclude kept, with the content they scan:
+// the working tree file, the blob at the commit or the archive entry
+type ContentFilter interface {
+	FileFilter
+	// ExcludeContent reports whether the file at relPath is skipped given its
+	// raw content, of which only the first generatedHeaderLines lines may be passed
+	ExcludeContent(relPath, content string) bool
+}
+
+// lockFileNames are dependency lock files that rarely help an LLM
+var lockFileNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"Cargo.lock":          true,
+	"go.sum":              true,
+	"Pipfile.lock":        true,
+	"poetry.lock":         true,
+	"composer.lock":       true,
+	"Gemfile.lock":        true,
+	"mix.lock":            true,
+	"pubspec.lock":        true,
+	"packages.lock.json":  true,
+	"flake.lock":          true,
+}
+
+// LockFileFilter excludes known lock files, any *.lock file, and the Extra file names
+type LockFileFilter struct {
+	Extra []string
+}
+
+// Exclude implements FileFilter
+func (f LockFileFilter) Exclude(relPath string, d fs.DirEntry) bool {
+	if d.IsDir() {
+		return false
+	}
+
+	name := d.Name()
+	if lockFileNames[name] || filepath.Ext(name) == ".lock" {
+		return true
+	}
+	for _, extra := range f.Extra {
+		if name == extra {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobExcludeFilter excludes entries matching glob patterns, like --exclude
+// Patterns without a slash match the entry name, others the whole relative path
+// A pattern starting with "!" re-includes entries excluded by earlier patterns,
+// and the last matching pattern wins, mirroring .gitignore negation.
+// As with .gitignore, files below an excluded directory cannot be re-included.
+type GlobExcludeFilter struct {
+	Patterns []string
+}
+
+// Exclude implements FileFilter
+func (f GlobExcludeFilter) Exclude(relPath string, d fs.DirEntry) bool {
+	relPath = filepath.ToSlash(relPath)
+
+	excluded := false
+	for _, pattern := range f.Patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		if negate {
+			pattern = pattern[1:]
+		}
+		if matchesGlob(strings.Trim(pattern, "/"), relPath) {
+			excluded = !negate
+		}
+	}
+	return excluded
+}
+
+// matchesGlob matches a slash-separated relative path against an exclude pattern
+func matchesGlob(pattern, relPath string) bool {
+	if pattern == "" {
+		return false
+	}
+	if strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, relPath)
+		return matched
+	}
+	matched, _ := path.Match(pattern, path.Base(relPath))
+	return matched
+}
+
+// isExcludedPath reports whether relPath is one of the excluded paths or lies
+// below one. Paths are relative to the scan root and may use either separator
+// or a trailing slash; unlike globs, "docs" never matches "docs-old".
+func isExcludedPath(excludePaths []string, relPath string) bool {
+	for _, excluded := range excludePaths {
+		excluded = filepath.Clean(filepath.FromSlash(excluded))
+		if excluded == "." {
+			continue
+		}
+		if relPath == excluded || strings.HasPrefix(relPath, excluded+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTestFilePatterns are the test file conventions excluded by TestFileFilter
+// Patterns ending in "/" name test directories, others match file names
+var DefaultTestFilePatterns = []string{
+	"*_test.go",
+	"*.test.js", "*.test.jsx", "*.test.ts", "*.test.tsx",
+	"*.spec.js", "*.spec.jsx", "*.spec.ts", "*.spec.tsx",
+	"test_*.py", "*_test.py",
+	"__tests__/", "test/", "tests/", "spec/",
+}
+
+// TestFileFilter excludes test files: files matching one of Patterns, or
+// DefaultTestFilePatterns when empty, and files inside test directories
+// Directories themselves are kept so IncludeOverrideFilter can re-include files below them
+type TestFileFilter struct {
+	Patterns []string
+}
+
+// Exclude implements FileFilter
+func (f TestFileFilter) Exclude(relPath string, d fs.DirEntry) bool {
+	if d.IsDir() {
+		return false
+	}
+
+	patterns := f.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultTestFilePatterns
+	}
+	relPath = filepath.ToSlash(relPath)
+	dirs := strings.Split(path.Dir(relPath), "/")
+	for _, pattern := range patterns {
+		if dirName, isDir := strings.CutSuffix(pattern, "/"); isDir {
+			for _, dir := range dirs {
+				if matched, _ := path.Match(dirName, dir); matched {
+					return true
+				}
+			}
+			continue
+		}
+		if matchesGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// IncludeOverrideFilter applies Filters except to files matching one of the
+// Include glob patterns, so naming a file with --include beats the exclusion flags
+// Directories excluded by Filters stay excluded, with everything below them
+type IncludeOverrideFilter struct {
+	Include []string
+	Filters []FileFilter
+}
+
+// Exclude implements FileFilter
+func (f IncludeOverrideFilter) Exclude(relPath string, d fs.DirEntry) bool {
+	if !d.IsDir() && MatchesAnyGlob(f.Include, relPath) {
+		return false
+	}
+	return excludedByFilters(f.Filters, relPath, d)
+}
+
+// ExcludeContent implements ContentFilter
+func (f IncludeOverrideFilter) ExcludeContent(relPath, content string) bool {
+	if MatchesAnyGlob(f.Include, relPath) {
+		return false
+	}
+	return excludedByContent(f.Filters, relPath, content)
+}
+
+// MatchesAnyGlob reports whether a relative path matches one of the glob patterns
+// Patterns without a slash match the file name, others the whole relative path
+func MatchesAnyGlob(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if matchesGlob(strings.Trim(filepath.ToSlash(pattern), "/"), filepath.ToSlash(relPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedHeaderLines is how many leading lines are checked for a generated-code marker
+const generatedHeaderLines = 5
+
+// IsGeneratedFile reports whether a file was produced by a code generator:
+// its name matches a known generated pattern (*.pb.go, *_gen.go, mock_*.go, ...)
+// or one of its first lines is a "// Code generated" or "/* AUTO-GENERATED */" marker
+func IsGeneratedFile(path string, content string) bool {
+	name := filepath.Base(path)
+	for _, pattern := range generatedFilePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	lines := strings.SplitN(content, "\n", generatedHeaderLines+1)
+	for i, line := range lines {
+		if i == generatedHeaderLines {
+			break
+		}
+		if isGeneratedMarker(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGeneratedMarker reports whether a line marks generated code
+func isGeneratedMarker(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "// Code generated") || strings.Contains(line, "/* AUTO-GENERATED */")
+}
+
+// GeneratedFileFilter excludes generated files (see IsGeneratedFile)
+// Exclude matches names only; the header marker is checked by ExcludeContent
+type GeneratedFileFilter struct{}
+
+// Exclude implements FileFilter
+func (f GeneratedFileFilter) Exclude(relPath string, d fs.DirEntry) bool {
+	return !d.IsDir() && IsGeneratedFile(relPath, "")
+}
+
+// ExcludeContent implements ContentFilter
+func (f GeneratedFileFilter) ExcludeContent(relPath, content string) bool {
+	return IsGeneratedFile(relPath, content)
+}
+
+// readHeader returns the first generatedHeaderLines lines of the file name in
+// fsys, or "" if it cannot be read
+func readHeader(fsys fs.FS, name string) string {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer file.Close() //nolint:errcheck
+
+	var header strings.Builder
+	lineScanner := bufio.NewScanner(file)
+	for i := 0; i < generatedHeaderLines && lineScanner.Scan(); i++ {
+		header.WriteString(lineScanner.Text())
+		header.WriteByte('\n')
+	}
+	return header.String()
+}
+
+// excludedByFilters reports whether any filter excludes the entry
+func excludedByFilters(filters []FileFilter, relPath string, d fs.DirEntry) bool {
+	for _, filter := range filters {
+		if filter.Exclude(relPath, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasContentFilter reports whether any filter is a ContentFilter, so scans
+// only read headers ahead of time when something looks at them
+func hasContentFilter(filters []FileFilter) bool {
+	for _, filter := range filters {
+		if _, ok := filter.(ContentFilter); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedByContent reports whether any ContentFilter excludes the file given its content
+func excludedByContent(filters []FileFilter, relPath, content string) bool {
+	for _, filter := range filters {
+		if contentFilter, ok := filter.(ContentFilter); ok && contentFilter.ExcludeContent(relPath, content) {
+			return true
+		}
+	}
+	return false
+}
+
+// virtualFile describes a file or directory that is not in the working tree,
+// such as a file in a commit or an archive, for filters expecting fs.DirEntry
+type virtualFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (f virtualFile) Name() string               { return f.name }
+func (f virtualFile) IsDir() bool                { return f.dir }
+func (f virtualFile) Info() (fs.FileInfo, error) { return f, nil }
+func (f virtualFile) Size() int64                { return f.size }
+func (f virtualFile) ModTime() time.Time         { return f.modTime }
+func (f virtualFile) Sys() any                   { return nil }
+
+func (f virtualFile) Type() fs.FileMode { return f.Mode().Type() }
+
+func (f virtualFile) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
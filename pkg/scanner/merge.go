@@ -0,0 +1,101 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// MergeScanResults combines the scans of several roots into one result rooted
+// at their deepest common directory. File paths become relative to that
+// directory, so service-a/main.go and service-b/main.go stay distinct, and
+// files of overlapping roots are listed once. Totals are recomputed from the
+// files kept, and the errors of the results are concatenated.
+// The directory tree is headed by rootLabel, with each scanned root below it;
+// an empty rootLabel uses the name of the common directory.
+func MergeScanResults(results []*ScanResult, rootLabel string) *ScanResult {
+	merged := &ScanResult{
+		Files:  make([]FileInfo, 0),
+		Errors: make([]string, 0),
+	}
+	if len(results) == 0 {
+		return merged
+	}
+
+	roots := make([]string, len(results))
+	for i, result := range results {
+		roots[i] = result.RootPath
+	}
+	merged.RootPath = commonDir(roots)
+	if rootLabel == "" {
+		rootLabel = filepath.Base(merged.RootPath)
+	}
+
+	seen := make(map[string]bool)
+	for _, result := range results {
+		prefix, err := filepath.Rel(merged.RootPath, result.RootPath)
+		if err != nil {
+			prefix = filepath.Base(result.RootPath)
+		}
+
+		for _, file := range result.Files {
+			relPath := filepath.Join(prefix, file.RelativePath)
+			if relPath == "." || seen[relPath] {
+				continue
+			}
+			seen[relPath] = true
+
+			file.RelativePath = relPath
+			merged.Files = append(merged.Files, file)
+			if file.IsDir {
+				continue
+			}
+			merged.TotalFiles++
+			merged.TotalLines += file.Lines
+			merged.TotalTokens += file.TokenCount
+			// As in a scan, unreadable files don't add to the size
+			if file.Error == nil {
+				merged.TotalSize += file.Size
+			}
+		}
+
+		merged.Errors = append(merged.Errors, result.Errors...)
+		merged.TokensEstimated = merged.TokensEstimated || result.TokensEstimated
+		if merged.TokenEncoding == "" {
+			merged.TokenEncoding = result.TokenEncoding
+		}
+	}
+
+	// Head the tree with the label and indent the merged roots below it
+	var tree strings.Builder
+	tree.WriteString(rootLabel + "/\n")
+	for _, line := range strings.SplitAfter(generateDirectoryTree(merged.Files, merged.RootPath), "\n") {
+		if line != "" {
+			tree.WriteString("  " + line)
+		}
+	}
+	merged.DirectoryTree = tree.String()
+
+	return merged
+}
+
+// commonDir returns the deepest directory containing all the given paths
+func commonDir(paths []string) string {
+	common := filepath.Clean(paths[0])
+	for _, path := range paths[1:] {
+		path = filepath.Clean(path)
+		for !isWithin(common, path) {
+			parent := filepath.Dir(common)
+			if parent == common {
+				return common
+			}
+			common = parent
+		}
+	}
+	return common
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Supported styles for displaying file paths
+const (
+	PathStyleRelative = "relative" // relative to the scan root
+	PathStyleAbsolute = "absolute" // FileInfo.Path
+	PathStyleCwd      = "cwd"      // relative to the current working directory
+)
+
+// ValidatePathStyle checks that a path style is supported
+// An empty style means the default relative style
+func ValidatePathStyle(style string) error {
+	switch style {
+	case "", PathStyleRelative, PathStyleAbsolute, PathStyleCwd:
+		return nil
+	default:
+		return fmt.Errorf("unsupported path style %q (supported: %s, %s, %s)",
+			style, PathStyleRelative, PathStyleAbsolute, PathStyleCwd)
+	}
+}
+
+// DisplayPath returns the path of a file in the given style
+// It falls back to the relative path when the style cannot be applied
+func DisplayPath(file FileInfo, style string) string {
+	switch style {
+	case PathStyleAbsolute:
+		if file.Path != "" {
+			return file.Path
+		}
+	case PathStyleCwd:
+		cwd, err := os.Getwd()
+		if err == nil && filepath.IsAbs(file.Path) {
+			if rel, err := filepath.Rel(cwd, file.Path); err == nil {
+				return rel
+			}
+		}
+	}
+	return file.RelativePath
+}
+
+// RegenerateDirectoryTreeWithStyle rebuilds the directory tree using paths in the given style
+func RegenerateDirectoryTreeWithStyle(scanResult *ScanResult, style string) string {
+	if style == "" || style == PathStyleRelative {
+		return RegenerateDirectoryTree(scanResult)
+	}
+
+	styled := make([]FileInfo, len(scanResult.Files))
+	for i, file := range scanResult.Files {
+		styled[i] = file
+		// Keep the scan root out of the tree
+		if file.RelativePath != "" {
+			styled[i].RelativePath = DisplayPath(file, style)
+		}
+	}
+	return renderDirectoryTree(styled, scanResult.RootPath, scanResult.TreeShowDensity)
+}
@@ -2,47 +2,189 @@ package scanner
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/BHChen24/repo2context/pkg/editorconfig"
 	"github.com/BHChen24/repo2context/pkg/gitignore"
 	"github.com/BHChen24/repo2context/pkg/gitinfo"
+	"github.com/BHChen24/repo2context/pkg/languages"
+	"github.com/BHChen24/repo2context/pkg/stripper"
+
+	"golang.org/x/sync/semaphore"
 )
 
 // FileInfo represents a single file or directory
+// Size and Hash cover the bytes as stored, before any .editorconfig charset
+// conversion to UTF-8, so Hash matches e.g. sha256sum of the file, not of Content
 type FileInfo struct {
 	Path         string
 	RelativePath string
 	IsDir        bool
 	Size         int64
 	Content      string
+	Language     string
+	Hash         string
+	Contributors []string
 	ModTime      time.Time
 	TokenCount   int
+	Lines        int // lines of Content, 0 when content wasn't read
+	// TokenDensity is TokenCount per line (see ComputeTokenDensity), 0 when not computed
+	TokenDensity float64
 	Error        error
+	// ContinuedInPart is the output part holding the rest of a split file (0 if not split)
+	ContinuedInPart int
+	// GitStatus is the file's working tree status ("M", "A", "D" or "?"), empty if unchanged
+	GitStatus string
+	// TruncatedFrom is the token count before the content was cut to a per-file
+	// token limit (0 if not truncated); TokenCount then counts the kept content
+	TruncatedFrom int
+	// RecentCommits are the newest commits touching the file ("hash subject"),
+	// filled in when the git log is requested
+	RecentCommits []string
+	// Blame holds the author and commit of each content line, filled in when
+	// blame is requested and the content is the committed file unchanged
+	Blame []gitinfo.BlameLine
 }
 
 // ScanResult contains directory scan results
 type ScanResult struct {
 	RootPath      string
 	Files         []FileInfo
-	DirectoryTree string
+	DirectoryTree string // see RegenerateDirectoryTree for adding token counts
 	TotalFiles    int
 	TotalLines    int
+	TotalSize     int64
 	TotalTokens   int
+	TokenEncoding string
 	Errors        []string
+	// TokensEstimated reports token counts approximated by
+	// tokencounter.EstimateTokens rather than encoded
+	TokensEstimated bool
+	// TokensByDirectory sums token counts per directory including subdirectories,
+	// keyed "." for the root and "pkg/", "pkg/core/" below it
+	TokensByDirectory map[string]int
+	// TreeShowDensity annotates files in the directory tree with their TokenDensity
+	TreeShowDensity bool
+	// SkippedSmallFiles are the files left out for being below ScanOptions.MinFileSizeBytes
+	SkippedSmallFiles []string
+	// OptionsHash identifies the options the scan was made with when it is
+	// saved for reuse (see SaveScanResult); empty otherwise
+	OptionsHash string
 }
 
 // ScanOptions configures directory scanning
 type ScanOptions struct {
-	NoGitignore    bool
-	DisplayLineNum bool
+	NoGitignore     bool
+	NoR2cignore     bool
+	DisplayLineNum  bool
+	LineNumberStyle string
+	// LineEnding normalizes line endings in content ("lf" or "crlf"); empty keeps the default LF
+	LineEnding       string
+	IncludeLanguages []string
+	ExcludeLanguages []string
+	NoContent        bool
+	// AllowList restricts the scan to these paths relative to the scan root
+	AllowList []string
+	// ExcludePaths skips these paths relative to the scan root, and everything below them
+	ExcludePaths []string
+	// MaxErrors aborts the scan once more errors accumulate (0 means unlimited)
+	MaxErrors int
+	// ProgressCallback is called after each file is processed with total = -1,
+	// and once more when the walk finishes with the real total
+	ProgressCallback func(scanned, total int, currentPath string)
+	// AbortOnError stops the walk at the first file error instead of skipping it
+	AbortOnError bool
+	// Context cancels the walk when done; nil means no cancellation
+	Context context.Context
+	// Checksum computes a hash of each file ("md5" or "sha256"); empty disables it
+	Checksum string
+	// Filters exclude further entries, e.g. LockFileFilter
+	Filters []FileFilter
+	// Workers bounds the goroutines reading file content (0 means runtime.NumCPU())
+	Workers int
+	// IncludeVendor, IncludeNodeModules and IncludeGenerated re-include paths
+	// that .gitignore commonly excludes
+	IncludeVendor      bool
+	IncludeNodeModules bool
+	IncludeGenerated   bool
+	// NoGitInfoExclude skips .git/info/exclude and the global excludes file
+	// (core.excludesFile), otherwise applied along with .gitignore as git does
+	NoGitInfoExclude bool
+	// PruneEmptyDirs drops directories with no file below them (everything in
+	// them ignored or filtered out) from the result and tree; the CLI sets it by default
+	PruneEmptyDirs bool
+	// StripComments removes comments from Go, Python and JavaScript/TypeScript
+	// content before line numbering; PreserveDocComments keeps doc comments
+	StripComments       bool
+	PreserveDocComments bool
+	// RespectEditorConfig reads each file in the charset its .editorconfig
+	// declares (utf-16le, utf-16be, latin1, utf-8-bom), converting it to UTF-8
+	RespectEditorConfig bool
+	// SummaryOnly only counts the lines of each file, after StripComments,
+	// without keeping its content, for output that shows nothing but the totals
+	SummaryOnly bool
+	// FollowSymlinks descends into symlinked directories, stopping with a
+	// warning at circular symlinks and below maxWalkDepth levels
+	FollowSymlinks bool
+	// RetryCount reads a file up to this many more times after a transient
+	// error (see isTransientError), waiting RetryDelay in between; 0 disables retries
+	RetryCount int
+	RetryDelay time.Duration
+	// MinFileSizeBytes skips files smaller than this many bytes, listing them
+	// in ScanResult.SkippedSmallFiles; 0 means no minimum
+	MinFileSizeBytes int64
+
+	// editorConfig caches the .editorconfig files parsed during one scan
+	editorConfig *editorconfig.Resolver
 }
 
+// maxWalkDepth bounds the directory depth walked with FollowSymlinks, for
+// symlink loops that cycle detection can't see (an fs.FS without real paths)
+const maxWalkDepth = 100
+
+// generatedFilePatterns match files produced by code generators
+var generatedFilePatterns = []string{"*.pb.go", "*.pb.gw.go", "*_gen.go", "mock_*.go", "zz_generated.*.go"}
+
+// ignoreOverrides returns the negated patterns appended to .gitignore for the include flags
+func ignoreOverrides(options ScanOptions) []string {
+	var overrides []string
+	if options.IncludeVendor {
+		overrides = append(overrides, "!vendor/")
+	}
+	if options.IncludeNodeModules {
+		overrides = append(overrides, "!node_modules/")
+	}
+	if options.IncludeGenerated {
+		for _, pattern := range generatedFilePatterns {
+			overrides = append(overrides, "!"+pattern)
+		}
+	}
+	return overrides
+}
+
+// Supported line number styles
+const (
+	LineNumberTab     = "tab"     // "12:\t"
+	LineNumberSpace   = "space"   // "12: "
+	LineNumberBracket = "bracket" // "[12] "
+	LineNumberPadded  = "padded"  // "012: "
+)
+
 // GetEntryPoint validates a need-to-be-processed target and returns its absolute path
 func GetEntryPoint(path string) (string, error) {
 	absPath, err := filepath.Abs(path)
@@ -60,7 +202,7 @@ func GetEntryPoint(path string) (string, error) {
 // ScanDirectory scans a directory recursively
 // Ignores files/directories specified in .gitignore by default
 func ScanDirectory(rootPath string) (*ScanResult, error) {
-	return ScanDirectoryWithOptions(rootPath, ScanOptions{NoGitignore: false})
+	return ScanDirectoryWithOptions(rootPath, ScanOptions{NoGitignore: false, PruneEmptyDirs: true})
 }
 
 // ScanDirectoryWithOptions scans a directory with custom options
@@ -69,62 +211,164 @@ func ScanDirectoryWithOptions(rootPath string, options ScanOptions) (*ScanResult
 	if err != nil {
 		return nil, err
 	}
+	return scanFS(os.DirFS(absRoot), ".", absRoot, options)
+}
+
+// ScanFS scans the tree at rootPath in fsys, such as an fstest.MapFS, an
+// embed.FS or a zip.Reader. rootPath is slash-separated, "." for the root of fsys.
+// Paths in the result are fsys paths. The .gitignore and .r2cignore at rootPath
+// apply unless disabled, and .editorconfig files are read from fsys; git
+// excludes are only read by ScanDirectoryWithOptions, which scans the OS filesystem.
+func ScanFS(fsys fs.FS, rootPath string, options ScanOptions) (*ScanResult, error) {
+	if !fs.ValidPath(rootPath) {
+		return nil, fmt.Errorf("invalid path %q", rootPath)
+	}
+	if _, err := fs.Stat(fsys, rootPath); err != nil {
+		return nil, fmt.Errorf("path does not exist: %w", err)
+	}
+	if options.RespectEditorConfig {
+		options.editorConfig = editorconfig.NewSourceResolver(func(name string) ([]byte, error) {
+			return fs.ReadFile(fsys, name)
+		})
+	}
+	return scanFS(fsys, rootPath, "", options)
+}
 
+// scanFS scans root in fsys. osRoot is the OS path of root when fsys is the
+// OS filesystem, naming files by their OS path, or "" to use fsys paths.
+func scanFS(fsys fs.FS, root, osRoot string, options ScanOptions) (*ScanResult, error) {
+	scanRoot := osRoot
+	if scanRoot == "" {
+		scanRoot = root
+	}
 	result := &ScanResult{
-		RootPath: absRoot,
+		RootPath: scanRoot,
 		Files:    make([]FileInfo, 0),
 		Errors:   make([]string, 0),
 	}
 
-	var gi *gitignore.GitIgnore
+	// Ignore rules are relative to gitignoreBasePath, or to root when it is ""
+	// .r2cignore patterns follow those of .gitignore, so its "!" patterns win
+	var gi, ri *gitignore.GitIgnore
 	var gitignoreBasePath string
-	// Initialize gitignore instance if requested
-	if !options.NoGitignore {
-		// Try to find git repository root first
-		gitRoot, gitErr := gitinfo.GetGitRoot(absRoot)
-		if gitErr == nil {
-			// Use git repository root if we're in a git repo
-			gitignoreBasePath = gitRoot
-		} else {
-			// Fall back to scan directory if not in git repo
-			gitignoreBasePath = absRoot
+	if osRoot != "" {
+		gi, ri, gitignoreBasePath = loadIgnoreFiles(osRoot, options, result)
+	} else {
+		gi, ri = loadFSIgnoreFiles(fsys, root, options, result)
+	}
+	ignore := gitignore.Merge(gi, ri)
+
+	if options.RespectEditorConfig && options.editorConfig == nil {
+		options.editorConfig = editorconfig.NewResolver()
+	}
+
+	// Build allowlist lookups: allowed files and the directories leading to them
+	allowedFiles, allowedDirs := buildAllowList(options.AllowList)
+	// Content filters get the first lines of each file during the walk
+	contentFilters := hasContentFilter(options.Filters)
+
+	// Phase 1: walk the tree collecting metadata only
+	// pending holds the indexes in result.Files of files whose content must be read
+	var pending []int
+	// realDirs maps the walked directories to their real path with FollowSymlinks
+	realDirs := make(map[string]string)
+	var walk fs.WalkDirFunc
+	walk = func(name string, d fs.DirEntry, err error) error {
+		relPath := fsRelPath(root, name)
+		path := name
+		if osRoot != "" {
+			path = filepath.Join(osRoot, relPath)
 		}
 
-		gi, err = gitignore.NewGitIgnore(gitignoreBasePath)
-		if err != nil {
-			// Log warning but continue without gitignore
-			result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load .gitignore: %v", err))
+		// Stop walking once the context is cancelled
+		if options.Context != nil {
+			if ctxErr := options.Context.Err(); ctxErr != nil {
+				return ctxErr
+			}
+		}
+
+		if tooManyErrors(result, options) {
+			return fmt.Errorf("too many errors (%d), maximum allowed: %d", len(result.Errors), options.MaxErrors)
 		}
-	}
 
-	err = filepath.WalkDir(absRoot, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			errMsg := fmt.Sprintf("error accessing %s: %v", path, err)
 			result.Errors = append(result.Errors, errMsg)
+			if options.AbortOnError {
+				return fmt.Errorf("error accessing %s: %w", path, err)
+			}
 			return nil // Continue walking
 		}
 
-		relPath, _ := filepath.Rel(absRoot, path)
-		if relPath == "." {
-			relPath = ""
+		// Skip excluded paths and everything below them
+		if relPath != "" && isExcludedPath(options.ExcludePaths, relPath) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
 		}
 
-		// Check gitignore rules if enabled
-		// !options.NoGitignore => enable gitignore
-		if !options.NoGitignore && gi != nil && relPath != "" {
+		// Check gitignore and r2cignore rules if enabled
+		if ignore != nil && relPath != "" {
 			// Calculate relative path from gitignore base path (git root or scan directory)
-			gitignoreRelPath, gitignoreRelErr := filepath.Rel(gitignoreBasePath, path)
-			if gitignoreRelErr == nil && gitignoreRelPath != "." && gitignoreRelPath != "" {
-				if gi.IsIgnored(gitignoreRelPath, d.IsDir()) {
+			gitignoreRelPath := relPath
+			if gitignoreBasePath != "" {
+				var relErr error
+				if gitignoreRelPath, relErr = filepath.Rel(gitignoreBasePath, path); relErr != nil {
+					gitignoreRelPath = ""
+				}
+			}
+			if gitignoreRelPath != "." && gitignoreRelPath != "" {
+				if ignore.IsIgnored(gitignoreRelPath, d.IsDir()) {
 					// Skip this file/directory
 					if d.IsDir() {
-						return filepath.SkipDir
+						return fs.SkipDir
 					}
 					return nil
 				}
 			}
 		}
 
+		// Check allowlist if provided
+		if allowedFiles != nil && relPath != "" {
+			if d.IsDir() && !allowedDirs[relPath] {
+				return fs.SkipDir
+			}
+			if !d.IsDir() && !allowedFiles[relPath] {
+				return nil
+			}
+		}
+
+		// Check language filters for files
+		if !d.IsDir() && !matchesLanguageFilter(languages.Detect(path), options) {
+			return nil
+		}
+
+		// Check additional filters
+		if relPath != "" && excludedByFilters(options.Filters, relPath, d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if contentFilters && !d.IsDir() && excludedByContent(options.Filters, relPath, readHeader(fsys, name)) {
+			return nil
+		}
+
+		if options.FollowSymlinks {
+			// Walk a symlinked directory as if it were a directory; its root
+			// entry is then reported with the target's info
+			if d.Type()&fs.ModeSymlink != 0 {
+				if target, statErr := fs.Stat(fsys, name); statErr == nil && target.IsDir() {
+					return fs.WalkDir(fsys, name, walk)
+				}
+			}
+			if d.IsDir() && isSymlinkCycle(realDirs, root, name, path, osRoot != "") {
+				result.Errors = append(result.Errors, fmt.Sprintf("Possible circular symlink detected at %s, stopping traversal", path))
+				return fs.SkipDir
+			}
+		}
+
 		info, infoErr := d.Info()
 
 		fileInfo := FileInfo{
@@ -136,40 +380,373 @@ func ScanDirectoryWithOptions(rootPath string, options ScanOptions) (*ScanResult
 		if infoErr != nil {
 			fileInfo.Error = infoErr
 			result.Errors = append(result.Errors, fmt.Sprintf("error getting file info for %s: %v", path, infoErr))
+			if options.AbortOnError {
+				return fmt.Errorf("error getting file info for %s: %w", path, infoErr)
+			}
 		} else {
 			fileInfo.ModTime = info.ModTime()
 		}
 
-		if !d.IsDir() && infoErr == nil {
-			fileInfo.Size = info.Size()
+		if !d.IsDir() {
+			fileInfo.Language = languages.Detect(path)
+		}
 
-			// Read file content
-			content, lines, err := readFileContent(path, options.DisplayLineNum)
-			if err != nil {
-				fileInfo.Error = err
-				result.Errors = append(result.Errors, fmt.Sprintf("error reading %s: %v", path, err))
-			} else {
-				fileInfo.Content = content
-				result.TotalLines += lines
-			}
+		if !d.IsDir() && infoErr == nil && info.Size() < options.MinFileSizeBytes {
+			result.SkippedSmallFiles = append(result.SkippedSmallFiles, relPath)
+			return nil
+		}
 
-			result.TotalFiles++
+		if !d.IsDir() && infoErr == nil {
+			fileInfo.Size = info.Size()
+			// Content is read in the second phase
+			pending = append(pending, len(result.Files))
 		}
 
 		result.Files = append(result.Files, fileInfo)
 		return nil
-	})
+	}
+	err := fs.WalkDir(fsys, root, walk)
+
+	// Phase 2: read content and hashes concurrently
+	if err == nil {
+		err = readPendingFiles(result, fsys, root, pending, options)
+	}
 
+	if err == nil && tooManyErrors(result, options) {
+		err = fmt.Errorf("too many errors (%d), maximum allowed: %d", len(result.Errors), options.MaxErrors)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error walking directory: %w", err)
 	}
 
+	if options.ProgressCallback != nil {
+		options.ProgressCallback(result.TotalFiles, result.TotalFiles, "")
+	}
+
+	if options.PruneEmptyDirs {
+		result.Files = pruneEmptyDirs(result.Files)
+	}
+
 	// Generate directory tree
-	result.DirectoryTree = generateDirectoryTree(result.Files, absRoot)
+	result.DirectoryTree = generateDirectoryTree(result.Files, scanRoot)
 
 	return result, nil
 }
 
+// isSymlinkCycle reports whether the directory name leads back into one of
+// the directories containing it, comparing real paths when resolvable (osPath
+// is on the OS filesystem), or is more than maxWalkDepth levels below root.
+// The real path of name is recorded in realDirs.
+func isSymlinkCycle(realDirs map[string]string, root, name, osPath string, resolvable bool) bool {
+	if name != root && strings.Count(strings.TrimPrefix(name, root+"/"), "/")+1 > maxWalkDepth {
+		return true
+	}
+
+	realPath := name
+	if resolvable {
+		if resolved, err := filepath.EvalSymlinks(osPath); err == nil {
+			realPath = resolved
+		}
+	}
+	for parent := name; parent != root && parent != "."; {
+		parent = path.Dir(parent)
+		if realDirs[parent] == realPath {
+			return true
+		}
+	}
+	realDirs[name] = realPath
+	return false
+}
+
+// fsRelPath returns the OS-style path of name relative to root in an fs.FS,
+// or "" for root itself
+func fsRelPath(root, name string) string {
+	switch {
+	case name == root:
+		return ""
+	case root == ".":
+		return filepath.FromSlash(name)
+	default:
+		return filepath.FromSlash(strings.TrimPrefix(name, root+"/"))
+	}
+}
+
+// fileRead holds what the second scan phase learns about one file
+type fileRead struct {
+	content string
+	lines   int
+	readErr error
+	hash    string
+	hashErr error
+}
+
+// readPendingFiles reads the pending files below root in fsys with at most
+// options.Workers goroutines, then merges the results into result in walk order
+func readPendingFiles(result *ScanResult, fsys fs.FS, root string, pending []int, options ScanOptions) error {
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	sem := semaphore.NewWeighted(int64(workers))
+
+	// Each goroutine writes only its own index, so no lock is needed
+	reads := make([]fileRead, len(pending))
+	var wg sync.WaitGroup
+	for i, index := range pending {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, file FileInfo) {
+			defer wg.Done()
+			defer sem.Release(1)
+			name := path.Join(root, filepath.ToSlash(file.RelativePath))
+			reads[i] = readFile(fsys, name, file.Path, options)
+			// Stop starting new reads once the scan is going to abort
+			if options.AbortOnError && reads[i].readErr != nil {
+				cancel()
+			}
+		}(i, result.Files[index])
+	}
+	wg.Wait()
+
+	if options.Context != nil {
+		if err := options.Context.Err(); err != nil {
+			return err
+		}
+	}
+
+	// Merge in walk order so errors and progress are reported deterministically
+	for i, index := range pending {
+		fileInfo := &result.Files[index]
+		read := reads[i]
+
+		if options.NoContent {
+			result.TotalSize += fileInfo.Size
+		} else if read.readErr != nil {
+			fileInfo.Error = read.readErr
+			result.Errors = append(result.Errors, fmt.Sprintf("error reading %s: %v", fileInfo.Path, read.readErr))
+			if options.AbortOnError {
+				return fmt.Errorf("error reading %s: %w", fileInfo.Path, read.readErr)
+			}
+		} else {
+			fileInfo.Content = read.content
+			fileInfo.Lines = read.lines
+			result.TotalLines += read.lines
+			result.TotalSize += fileInfo.Size
+		}
+
+		if options.Checksum != "" {
+			if read.hashErr != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("error hashing %s: %v", fileInfo.Path, read.hashErr))
+			} else {
+				fileInfo.Hash = read.hash
+			}
+		}
+
+		result.TotalFiles++
+		if options.ProgressCallback != nil {
+			options.ProgressCallback(result.TotalFiles, -1, fileInfo.RelativePath)
+		}
+	}
+
+	return nil
+}
+
+// readFile reads the content (unless NoContent) and hash (if Checksum is set)
+// of the file name in fsys. path names the file for language detection and
+// .editorconfig lookup. Transient errors are retried options.RetryCount times.
+func readFile(fsys fs.FS, name, path string, options ScanOptions) fileRead {
+	read := readFileOnce(fsys, name, path, options)
+	for retry := 0; retry < options.RetryCount && (isTransientError(read.readErr) || isTransientError(read.hashErr)); retry++ {
+		time.Sleep(options.RetryDelay)
+		read = readFileOnce(fsys, name, path, options)
+	}
+	return read
+}
+
+// isTransientError reports whether a read may succeed when tried again, as
+// after a network filesystem (NFS, SMB) hiccup. Errors such as a missing file
+// or denied permission are permanent.
+func isTransientError(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EIO) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// readFileOnce makes one attempt of readFile
+func readFileOnce(fsys fs.FS, name, path string, options ScanOptions) fileRead {
+	var read fileRead
+	// Counting newlines is only exact when comments are kept
+	if options.SummaryOnly && !options.NoContent && !options.StripComments {
+		read.lines, read.readErr = countFileLines(fsys, name)
+	} else if !options.NoContent {
+		format := options.lineFormat(languages.Detect(path))
+		format.charset = options.charsetOf(path)
+		read.content, read.lines, read.readErr = readFSContent(fsys, name, format)
+		if options.SummaryOnly {
+			read.content = ""
+		}
+	}
+	if options.Checksum != "" {
+		read.hash, read.hashErr = hashFSFile(fsys, name, options.Checksum)
+	}
+	return read
+}
+
+// loadIgnoreFiles loads .gitignore and .r2cignore as enabled by options, from
+// the git repository root or the scan root outside a repository
+// Load failures are recorded as warnings in result
+func loadIgnoreFiles(absRoot string, options ScanOptions, result *ScanResult) (gi, ri *gitignore.GitIgnore, basePath string) {
+	if options.NoGitignore && options.NoR2cignore {
+		return nil, nil, ""
+	}
+
+	// Try to find git repository root first
+	basePath = absRoot
+	if gitRoot, err := gitinfo.GetGitRoot(absRoot); err == nil {
+		basePath = gitRoot
+	}
+
+	var err error
+	if !options.NoGitignore {
+		gi, err = gitignore.NewGitIgnore(basePath)
+		if err != nil {
+			// Log warning but continue without gitignore
+			result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load .gitignore: %v", err))
+		}
+		if !options.NoGitInfoExclude {
+			loadGitExcludes(gi, basePath, result)
+		}
+		for _, pattern := range ignoreOverrides(options) {
+			gi.AddPattern(pattern)
+		}
+	}
+
+	// .r2cignore is an additional layer for context-specific ignores
+	if !options.NoR2cignore {
+		ri, err = gitignore.NewGitIgnoreFromFile(basePath, filepath.Join(basePath, ".r2cignore"))
+		if err != nil {
+			// Log warning but continue without r2cignore
+			result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load .r2cignore: %v", err))
+		}
+	}
+
+	return gi, ri, basePath
+}
+
+// loadGitExcludes merges the repository's .git/info/exclude and the global
+// excludes file into gi, with lower precedence than .gitignore as in git
+func loadGitExcludes(gi *gitignore.GitIgnore, basePath string, result *ScanResult) {
+	excludePath := filepath.Join(basePath, ".git", "info", "exclude")
+	exclude, err := gitignore.NewGitIgnoreFromFile(basePath, excludePath)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load .git/info/exclude: %v", err))
+	}
+	gi.Prepend(exclude, ".git/info/exclude")
+
+	globalPath, err := gitinfo.GetGlobalGitIgnorePath(basePath)
+	if err != nil {
+		return
+	}
+	global, err := gitignore.NewGitIgnoreFromFile(basePath, globalPath)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load %s: %v", globalPath, err))
+	}
+	gi.Prepend(global, globalPath)
+}
+
+// loadFSIgnoreFiles loads .gitignore and .r2cignore at root in fsys, as
+// enabled by options. Load failures are recorded as warnings in result
+func loadFSIgnoreFiles(fsys fs.FS, root string, options ScanOptions, result *ScanResult) (gi, ri *gitignore.GitIgnore) {
+	var err error
+	if !options.NoGitignore {
+		gi, err = loadFSIgnoreFile(fsys, path.Join(root, ".gitignore"))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load .gitignore: %v", err))
+		}
+		for _, pattern := range ignoreOverrides(options) {
+			gi.AddPattern(pattern)
+		}
+	}
+	if !options.NoR2cignore {
+		ri, err = loadFSIgnoreFile(fsys, path.Join(root, ".r2cignore"))
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("warning: could not load .r2cignore: %v", err))
+		}
+	}
+	return gi, ri
+}
+
+// loadFSIgnoreFile parses an ignore file in fsys; a missing file ignores nothing
+func loadFSIgnoreFile(fsys fs.FS, name string) (*gitignore.GitIgnore, error) {
+	file, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return gitignore.NewGitIgnoreFromReader(path.Dir(name), strings.NewReader(""))
+	}
+	if err != nil {
+		empty, _ := gitignore.NewGitIgnoreFromReader(path.Dir(name), strings.NewReader(""))
+		return empty, err
+	}
+	defer file.Close() //nolint:errcheck
+	return gitignore.NewGitIgnoreFromReader(path.Dir(name), file)
+}
+
+// isIgnoredBy checks a path against an optional ignore instance
+func isIgnoredBy(gi *gitignore.GitIgnore, relPath string, isDir bool) bool {
+	return gi != nil && gi.IsIgnored(relPath, isDir)
+}
+
+// tooManyErrors reports whether the scan exceeded its error limit
+func tooManyErrors(result *ScanResult, options ScanOptions) bool {
+	return options.MaxErrors > 0 && len(result.Errors) > options.MaxErrors
+}
+
+// buildAllowList converts allowlisted paths into lookups of allowed files and
+// of every parent directory needed to reach them
+// Returns nil maps when the allowlist is empty
+func buildAllowList(allowList []string) (map[string]bool, map[string]bool) {
+	if len(allowList) == 0 {
+		return nil, nil
+	}
+
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range allowList {
+		path = filepath.Clean(filepath.FromSlash(path))
+		files[path] = true
+		for dir := filepath.Dir(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	return files, dirs
+}
+
+// matchesLanguageFilter reports whether a language passes the include/exclude filters
+func matchesLanguageFilter(language string, options ScanOptions) bool {
+	for _, excluded := range options.ExcludeLanguages {
+		if strings.EqualFold(excluded, language) {
+			return false
+		}
+	}
+
+	if len(options.IncludeLanguages) == 0 {
+		return true
+	}
+
+	for _, included := range options.IncludeLanguages {
+		if strings.EqualFold(included, language) {
+			return true
+		}
+	}
+	return false
+}
+
 // Walk returns just the directory tree structure for a path
 func Walk(path string) (string, error) {
 	result, err := ScanDirectory(path)
@@ -179,13 +756,40 @@ func Walk(path string) (string, error) {
 	return result.DirectoryTree, nil
 }
 
-// RegenerateDirectoryTree regenerates the directory tree from scan result
+// RegenerateDirectoryTree rebuilds the directory tree from the current state of a scan result
+//
+// ScanDirectoryWithOptions builds the tree before any token counting, so it never
+// shows token counts. Callers that fill in FileInfo.TokenCount afterwards (as
+// --count-tokens does) call this to get a tree annotated with "(N tokens)":
+//
+//	result, err := scanner.ScanDirectoryWithOptions(root, scanner.ScanOptions{})
+//	// ... set result.Files[i].TokenCount ...
+//	result.DirectoryTree = scanner.RegenerateDirectoryTree(result)
 func RegenerateDirectoryTree(scanResult *ScanResult) string {
-	return generateDirectoryTree(scanResult.Files, scanResult.RootPath)
+	return renderDirectoryTree(scanResult.Files, scanResult.RootPath, scanResult.TreeShowDensity)
 }
 
-// Peek reads a single file's content
+// PeekOptions configures Peek for both files and directories
+type PeekOptions struct {
+	// DisplayLineNum prefixes line numbers when peeking at a file
+	DisplayLineNum bool
+	// LineNumberStyle selects the line number format (see LineNumberTab and friends)
+	LineNumberStyle string
+	// LineEnding normalizes line endings when peeking at a file (see LineEndingLF)
+	LineEnding string
+	// ScanOptions configures the scan when peeking at a directory
+	ScanOptions ScanOptions
+}
+
+// Peek reads a single file's content, or returns the directory tree for a directory
+// Kept for backward compatibility, see PeekWithOptions
 func Peek(path string, displayLineNum bool) (string, error) {
+	return PeekWithOptions(path, PeekOptions{DisplayLineNum: displayLineNum})
+}
+
+// PeekWithOptions returns what is at a path:
+// for a file, its content; for a directory, its directory tree (not file contents)
+func PeekWithOptions(path string, opts PeekOptions) (string, error) {
 	absPath, err := GetEntryPoint(path)
 	if err != nil {
 		return "", err
@@ -197,44 +801,211 @@ func Peek(path string, displayLineNum bool) (string, error) {
 	}
 
 	if stat.IsDir() {
-		return "", fmt.Errorf("path is a directory, not a file")
+		result, err := ScanDirectoryWithOptions(absPath, opts.ScanOptions)
+		if err != nil {
+			return "", err
+		}
+		return result.DirectoryTree, nil
 	}
 
-	content, _, err := readFileContent(absPath, displayLineNum)
+	content, _, err := readFileContent(absPath, lineFormat{displayLineNum: opts.DisplayLineNum, lineNumberStyle: opts.LineNumberStyle, lineEnding: opts.LineEnding})
 	return content, err
 }
 
-// readFileContent reads a file's content and counts lines
-func readFileContent(path string, displayLineNum bool) (string, int, error) {
+// lineFormat controls how formatContent writes each line
+type lineFormat struct {
+	displayLineNum  bool
+	lineNumberStyle string
+	lineEnding      string
+	// charset is the encoding of the file as declared by .editorconfig (empty means UTF-8)
+	charset string
+	// language selects the comment syntax removed when stripComments is set
+	language            string
+	stripComments       bool
+	preserveDocComments bool
+}
+
+// lineFormat returns the line formatting selected by the scan options for a
+// file in the given language
+func (options ScanOptions) lineFormat(language string) lineFormat {
+	return lineFormat{
+		displayLineNum:      options.DisplayLineNum,
+		lineNumberStyle:     options.LineNumberStyle,
+		lineEnding:          options.LineEnding,
+		language:            language,
+		stripComments:       options.StripComments,
+		preserveDocComments: options.PreserveDocComments,
+	}
+}
+
+// charsetOf returns the charset .editorconfig declares for a file when
+// RespectEditorConfig is set. An unreadable .editorconfig means the default UTF-8
+func (options ScanOptions) charsetOf(path string) string {
+	if !options.RespectEditorConfig {
+		return ""
+	}
+	resolver := options.editorConfig
+	if resolver == nil {
+		resolver = editorconfig.NewResolver()
+	}
+	settings, err := resolver.GetFileSettings(path)
+	if err != nil {
+		return ""
+	}
+	return settings.Charset
+}
+
+// readFileContent reads a file's content, converted to UTF-8 from format.charset, and counts lines
+func readFileContent(path string, format lineFormat) (string, int, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return "", 0, err
 	}
 	defer file.Close() //nolint:errcheck
 
-	var content strings.Builder
-	bufScanner := bufio.NewScanner(file)
-	lineCount := 0
+	return formatContent(editorconfig.NewReader(file, format.charset), format)
+}
 
-	for bufScanner.Scan() {
-		// Display line number (Use tab instead of space for alignment)
-		if displayLineNum {
-			content.WriteString(fmt.Sprintf("%d:\t", lineCount+1))
+// readFSContent reads the file name in fsys like readFileContent
+func readFSContent(fsys fs.FS, name string, format lineFormat) (string, int, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	return formatContent(editorconfig.NewReader(file, format.charset), format)
+}
+
+// countFileLines counts the lines of the file name in fsys as readFileContent
+// would, without decoding or keeping the content
+func countFileLines(fsys fs.FS, name string) (int, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	lines := 0
+	last := byte('\n')
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
 		}
-		content.WriteString(bufScanner.Text())
-		content.WriteByte('\n')
-		lineCount++
+	}
+	// A last line without a newline still counts
+	if last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// formatContent reads text line by line, normalizing line endings and adding
+// line numbers if requested, and returns the text with its line count
+func formatContent(r io.Reader, format lineFormat) (string, int, error) {
+	var lines []string
+	bufScanner := bufio.NewScanner(r)
+	for bufScanner.Scan() {
+		lines = append(lines, bufScanner.Text())
 	}
 
 	if err := bufScanner.Err(); err != nil {
 		return "", 0, err
 	}
 
-	return content.String(), lineCount, nil
+	// Strip comments before numbering so the numbers match the lines shown
+	// Languages without known comment syntax are left as they are
+	if format.stripComments && len(lines) > 0 {
+		opts := stripper.Options{PreserveDocComments: format.preserveDocComments}
+		if stripped, err := stripper.StripCommentsWithOptions(strings.Join(lines, "\n"), format.language, opts); err == nil {
+			lines = strings.Split(stripped, "\n")
+			if stripped == "" {
+				lines = nil
+			}
+		}
+	}
+
+	// Padded line numbers are as wide as the largest line number
+	width := len(strconv.Itoa(len(lines)))
+
+	// bufio.Scanner drops the \r of \r\n, but a file read as binary can still
+	// end lines with stray carriage returns, so strip them before re-terminating
+	eol := "\n"
+	if format.lineEnding == LineEndingCRLF {
+		eol = "\r\n"
+	}
+
+	var content strings.Builder
+	for i, line := range lines {
+		if format.displayLineNum {
+			content.WriteString(formatLineNumber(i+1, width, format.lineNumberStyle))
+		}
+		if format.lineEnding != "" {
+			line = strings.TrimRight(line, "\r")
+		}
+		content.WriteString(line)
+		content.WriteString(eol)
+	}
+
+	return content.String(), len(lines), nil
+}
+
+// formatLineNumber returns the line number prefix for a line in the given style
+func formatLineNumber(lineNum, width int, style string) string {
+	switch style {
+	case LineNumberSpace:
+		return fmt.Sprintf("%d: ", lineNum)
+	case LineNumberBracket:
+		return fmt.Sprintf("[%d] ", lineNum)
+	case LineNumberPadded:
+		return fmt.Sprintf("%0*d: ", width, lineNum)
+	default:
+		// Use tab instead of space for alignment
+		return fmt.Sprintf("%d:\t", lineNum)
+	}
+}
+
+// Supported line ending normalizations
+const (
+	LineEndingLF   = "lf"   // "\n"
+	LineEndingCRLF = "crlf" // "\r\n"
+)
+
+// ValidateLineEnding checks that a line ending is supported
+// An empty line ending means no explicit normalization
+func ValidateLineEnding(ending string) error {
+	switch ending {
+	case "", LineEndingLF, LineEndingCRLF:
+		return nil
+	default:
+		return fmt.Errorf("unsupported line ending %q (supported: %s, %s)", ending, LineEndingLF, LineEndingCRLF)
+	}
+}
+
+// ValidateLineNumberStyle checks that a line number style is supported
+// An empty style means the default tab style
+func ValidateLineNumberStyle(style string) error {
+	switch style {
+	case "", LineNumberTab, LineNumberSpace, LineNumberBracket, LineNumberPadded:
+		return nil
+	default:
+		return fmt.Errorf("unsupported line number style %q (supported: %s, %s, %s, %s)",
+			style, LineNumberTab, LineNumberSpace, LineNumberBracket, LineNumberPadded)
+	}
 }
 
-// Helper function for generating directory tree
-func buildPathMap(files []FileInfo) map[string]bool {
+// BuildPathMap maps each relative path to whether it is a directory
+// The scan root (empty relative path) is excluded
+func BuildPathMap(files []FileInfo) map[string]bool {
 	pathMap := make(map[string]bool)
 	for _, file := range files {
 		if file.RelativePath != "" {
@@ -244,28 +1015,185 @@ func buildPathMap(files []FileInfo) map[string]bool {
 	return pathMap
 }
 
+// pruneEmptyDirs returns files without the directories that have no file
+// below them. The scan root is always kept.
+func pruneEmptyDirs(files []FileInfo) []FileInfo {
+	// Mark the ancestors of every file as non-empty
+	pathMap := BuildPathMap(files)
+	nonEmpty := make(map[string]bool)
+	for path, isDir := range pathMap {
+		if isDir {
+			continue
+		}
+		for dir := filepath.Dir(path); dir != "." && !nonEmpty[dir]; dir = filepath.Dir(dir) {
+			nonEmpty[dir] = true
+		}
+	}
+
+	kept := make([]FileInfo, 0, len(files))
+	for _, file := range files {
+		if file.IsDir && file.RelativePath != "" && !nonEmpty[file.RelativePath] {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+// BuildFileSet maps each relative path in a scan result to its FileInfo for O(1) lookup
+// The scan root (empty relative path) is excluded
+func BuildFileSet(result *ScanResult) map[string]FileInfo {
+	fileSet := make(map[string]FileInfo, len(result.Files))
+	for _, file := range result.Files {
+		if file.RelativePath != "" {
+			fileSet[file.RelativePath] = file
+		}
+	}
+	return fileSet
+}
+
+// FilesByExtension groups the files of a scan result by lowercase extension
+// such as ".go", in scan order; files without one are keyed "". Directories
+// are left out. The groups are computed on each call.
+func (r *ScanResult) FilesByExtension() map[string][]FileInfo {
+	groups := make(map[string][]FileInfo)
+	for _, file := range r.Files {
+		if file.IsDir {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(file.Path))
+		groups[ext] = append(groups[ext], file)
+	}
+	return groups
+}
+
+// Extensions returns the sorted unique extensions of FilesByExtension
+func (r *ScanResult) Extensions() []string {
+	groups := r.FilesByExtension()
+	extensions := make([]string, 0, len(groups))
+	for ext := range groups {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
 // Helper function to build token count map
+// Truncated files map to their original count
 func buildTokenCountMap(files []FileInfo) map[string]int {
 	tokenMap := make(map[string]int)
 	for _, file := range files {
 		if file.RelativePath != "" && !file.IsDir {
 			tokenMap[file.RelativePath] = file.TokenCount
+			if file.TruncatedFrom > 0 {
+				tokenMap[file.RelativePath] = file.TruncatedFrom
+			}
 		}
 	}
 	return tokenMap
 }
 
+// ComputeTokenDensity sets the TokenDensity of each file with counted tokens
+// and lines. Truncated files use their original count, as their lines are
+// counted before truncation.
+func ComputeTokenDensity(result *ScanResult) {
+	for i := range result.Files {
+		file := &result.Files[i]
+		tokens := file.TokenCount
+		if file.TruncatedFrom > 0 {
+			tokens = file.TruncatedFrom
+		}
+		if file.IsDir || tokens == 0 || file.Lines == 0 {
+			continue
+		}
+		file.TokenDensity = float64(tokens) / float64(file.Lines)
+	}
+}
+
+// Helper function to build the set of truncated files
+func buildTruncatedSet(files []FileInfo) map[string]bool {
+	truncated := make(map[string]bool)
+	for _, file := range files {
+		if file.TruncatedFrom > 0 && !file.IsDir {
+			truncated[file.RelativePath] = true
+		}
+	}
+	return truncated
+}
+
+// Helper function to build git status map
+func buildGitStatusMap(files []FileInfo) map[string]string {
+	statusMap := make(map[string]string)
+	for _, file := range files {
+		if file.GitStatus != "" && !file.IsDir {
+			statusMap[file.RelativePath] = file.GitStatus
+		}
+	}
+	return statusMap
+}
+
 func generateDirectoryTree(files []FileInfo, rootPath string) string {
+	return renderDirectoryTree(files, rootPath, false)
+}
+
+// caseInsensitiveTree sorts tree entries ignoring case, as the file managers of
+// the usual case-insensitive filesystems (Windows, macOS) list them
+var caseInsensitiveTree = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// withSlashPaths returns a copy of files with slash-separated relative paths
+func withSlashPaths(files []FileInfo) []FileInfo {
+	slashed := make([]FileInfo, len(files))
+	for i, file := range files {
+		slashed[i] = file
+		slashed[i].RelativePath = filepath.ToSlash(file.RelativePath)
+	}
+	return slashed
+}
+
+// treePathLess orders slash-separated paths level by level, so a directory's
+// entries directly follow it ("a", "a/x", "a.txt" rather than "a", "a.txt", "a/x")
+func treePathLess(a, b string) bool {
+	aParts, bParts := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+		if caseInsensitiveTree {
+			if aLower, bLower := strings.ToLower(aParts[i]), strings.ToLower(bParts[i]); aLower != bLower {
+				return aLower < bLower
+			}
+		}
+		return aParts[i] < bParts[i]
+	}
+	return len(aParts) < len(bParts)
+}
+
+// renderDirectoryTree renders the tree, with each file's token density when showDensity is set
+func renderDirectoryTree(files []FileInfo, rootPath string, showDensity bool) string {
+	// Key everything by slash-separated paths, so paths with mixed separators
+	// split into the same levels on every OS
+	files = withSlashPaths(files)
+
 	// Build a map of all paths for easy lookup
-	pathMap := buildPathMap(files)
+	pathMap := BuildPathMap(files)
 	tokenMap := buildTokenCountMap(files)
+	statusMap := buildGitStatusMap(files)
+	truncated := buildTruncatedSet(files)
+	density := make(map[string]float64)
+	if showDensity {
+		for _, file := range files {
+			if file.TokenDensity > 0 && !file.IsDir {
+				density[file.RelativePath] = file.TokenDensity
+			}
+		}
+	}
 
 	// Get all unique directory paths and sort them
 	var allPaths []string
 	for path := range pathMap {
 		allPaths = append(allPaths, path)
 	}
-	sort.Strings(allPaths)
+	sort.Slice(allPaths, func(i, j int) bool { return treePathLess(allPaths[i], allPaths[j]) })
 
 	var result strings.Builder
 
@@ -273,11 +1201,11 @@ func generateDirectoryTree(files []FileInfo, rootPath string) string {
 	processedDirs := make(map[string]bool)
 
 	for _, path := range allPaths {
-		parts := strings.Split(path, string(filepath.Separator))
+		parts := strings.Split(path, "/")
 
 		// Build each directory level
 		for i := 0; i < len(parts); i++ {
-			currentPath := strings.Join(parts[:i+1], string(filepath.Separator))
+			currentPath := strings.Join(parts[:i+1], "/")
 
 			if processedDirs[currentPath] {
 				continue
@@ -295,10 +1223,24 @@ func generateDirectoryTree(files []FileInfo, rootPath string) string {
 					result.WriteString(fmt.Sprintf("%s%s/\n", indent, parts[i]))
 				} else {
 					// This is a file - check if we have token count
-					if tokenCount, hasTokens := tokenMap[currentPath]; hasTokens && tokenCount > 0 {
-						result.WriteString(fmt.Sprintf("%s%s (%d tokens)\n", indent, parts[i], tokenCount))
+					name := parts[i]
+					if status := statusMap[currentPath]; status != "" {
+						name += fmt.Sprintf(" [%s]", status)
+					}
+					var notes []string
+					if tokenCount := tokenMap[currentPath]; tokenCount > 0 {
+						notes = append(notes, fmt.Sprintf("%d tokens", tokenCount))
+						if truncated[currentPath] {
+							notes = append(notes, "truncated")
+						}
+					}
+					if tokenDensity := density[currentPath]; tokenDensity > 0 {
+						notes = append(notes, fmt.Sprintf("%.1f tok/line", tokenDensity))
+					}
+					if len(notes) > 0 {
+						result.WriteString(fmt.Sprintf("%s%s (%s)\n", indent, name, strings.Join(notes, ", ")))
 					} else {
-						result.WriteString(fmt.Sprintf("%s%s\n", indent, parts[i]))
+						result.WriteString(fmt.Sprintf("%s%s\n", indent, name))
 					}
 				}
 			} else {
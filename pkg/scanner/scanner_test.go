@@ -1,10 +1,26 @@
 package scanner
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"syscall"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/BHChen24/repo2context/pkg/gitinfo/mock"
 )
 
 // =============================================================================
@@ -143,7 +159,7 @@ func TestGetEntryPoint_EmptyPath(t *testing.T) {
 }
 
 // =============================================================================
-// Tests for buildPathMap()
+// Tests for BuildPathMap()
 // =============================================================================
 
 func TestBuildPathMap_EmptySlice(t *testing.T) {
@@ -153,7 +169,7 @@ func TestBuildPathMap_EmptySlice(t *testing.T) {
 	emptySlice := []FileInfo{}
 
 	// When
-	result := buildPathMap(emptySlice)
+	result := BuildPathMap(emptySlice)
 
 	// Then
 	if result == nil {
@@ -175,7 +191,7 @@ func TestBuildPathMap_SingleFile(t *testing.T) {
 	}
 
 	// When
-	result := buildPathMap(files)
+	result := BuildPathMap(files)
 
 	// Then
 
@@ -208,7 +224,7 @@ func TestBuildPathMap_EmptyRelativePath(t *testing.T) {
 	}
 
 	// When
-	result := buildPathMap(files)
+	result := BuildPathMap(files)
 
 	// Then
 	if result == nil {
@@ -242,7 +258,7 @@ func TestBuildPathMap_MixedFilesAndDirs(t *testing.T) {
 	}
 
 	// When
-	result := buildPathMap(files)
+	result := BuildPathMap(files)
 
 	// Then
 	if result == nil {
@@ -284,7 +300,7 @@ func TestBuildPathMap_DuplicatePaths(t *testing.T) {
 	}
 
 	// When
-	result := buildPathMap(files)
+	result := BuildPathMap(files)
 
 	// Then
 	if result == nil {
@@ -305,6 +321,68 @@ func TestBuildPathMap_DuplicatePaths(t *testing.T) {
 	}
 }
 
+func TestBuildPathMap_MatchesScanResult(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "sub", "a.go"), []byte("package sub\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// When
+	pathMap := BuildPathMap(result.Files)
+
+	// Then
+	expected := map[string]bool{"sub": true, filepath.Join("sub", "a.go"): false}
+	if len(pathMap) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %v", len(expected), len(pathMap), pathMap)
+	}
+	for path, isDir := range expected {
+		if got, ok := pathMap[path]; !ok || got != isDir {
+			t.Errorf("Expected %s isDir=%t, got %t (present=%t)", path, isDir, got, ok)
+		}
+	}
+}
+
+// =============================================================================
+// Tests for BuildFileSet()
+// =============================================================================
+
+func TestBuildFileSet_LookupByRelativePath(t *testing.T) {
+	// Given
+	result := &ScanResult{
+		Files: []FileInfo{
+			{RelativePath: "", IsDir: true},
+			{RelativePath: "main.go", Size: 10},
+			{RelativePath: "pkg", IsDir: true},
+			{RelativePath: "pkg/util.go", Size: 20},
+		},
+	}
+
+	// When
+	fileSet := BuildFileSet(result)
+
+	// Then
+	if len(fileSet) != 3 {
+		t.Fatalf("Expected 3 entries (root excluded), got %d", len(fileSet))
+	}
+	if fileSet["pkg/util.go"].Size != 20 {
+		t.Errorf("Expected pkg/util.go size 20, got %d", fileSet["pkg/util.go"].Size)
+	}
+	if !fileSet["pkg"].IsDir {
+		t.Error("Expected pkg to be a directory")
+	}
+	if _, ok := fileSet[""]; ok {
+		t.Error("Expected scan root to be excluded")
+	}
+}
+
 // =============================================================================
 // Tests for GenerateDirectoryTree()
 // =============================================================================
@@ -383,6 +461,27 @@ func TestGenerateDirectoryTree_FileWithTokens(t *testing.T) {
 	}
 }
 
+func TestGenerateDirectoryTree_FileWithGitStatus(t *testing.T) {
+	// Expected: Git status badge before the token count
+
+	// Given
+	files := []FileInfo{
+		{RelativePath: "changed.go", TokenCount: 5, GitStatus: "M"},
+		{RelativePath: "new.go", GitStatus: "?"},
+		{RelativePath: "same.go"},
+	}
+	rootPath := "/dummy"
+
+	// When
+	result := generateDirectoryTree(files, rootPath)
+
+	// Then
+	expected := "changed.go [M] (5 tokens)\nnew.go [?]\nsame.go\n"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
 func TestGenerateDirectoryTree_FileWithZeroTokens(t *testing.T) {
 	// Expected: File without token count (since 0)
 
@@ -463,3 +562,2558 @@ func TestGenerateDirectoryTree_Sorting(t *testing.T) {
 		t.Errorf("Expected %q, got %q", expected, result)
 	}
 }
+
+// =============================================================================
+// Tests for language detection
+// =============================================================================
+
+func TestScanDirectoryWithOptions_PopulatesLanguage(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, file := range result.Files {
+		if file.RelativePath == "main.go" && file.Language != "go" {
+			t.Errorf("Expected language 'go', got %q", file.Language)
+		}
+	}
+}
+
+func TestScanDirectoryWithOptions_LanguageFilters(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	for _, name := range []string{"main.go", "notes.txt", "app.py"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		options  ScanOptions
+		expected int
+	}{
+		{"no filters", ScanOptions{NoGitignore: true}, 3},
+		{"include go", ScanOptions{NoGitignore: true, IncludeLanguages: []string{"go"}}, 1},
+		{"exclude text", ScanOptions{NoGitignore: true, ExcludeLanguages: []string{"text"}}, 2},
+		{"include and exclude", ScanOptions{NoGitignore: true, IncludeLanguages: []string{"go", "python"}, ExcludeLanguages: []string{"python"}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			result, err := ScanDirectoryWithOptions(tempDir, tt.options)
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.TotalFiles != tt.expected {
+				t.Errorf("Expected %d files, got %d", tt.expected, result.TotalFiles)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Tests for ScanDirectoryWithOptions() totals
+// =============================================================================
+
+func TestScanDirectoryWithOptions_TotalSize(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	subDir := filepath.Join(tempDir, "sub")
+	if err := os.Mkdir(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	files := map[string]string{
+		filepath.Join(tempDir, "a.txt"): "hello\n",          // 6 bytes
+		filepath.Join(subDir, "b.txt"):  "hello world\n",    // 12 bytes
+		filepath.Join(subDir, "c.go"):   "package main\n\n", // 14 bytes
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalSize != 32 {
+		t.Errorf("Expected TotalSize 32, got %d", result.TotalSize)
+	}
+}
+
+func TestScanDirectoryWithOptions_FileLines(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"a.txt": "one\n",
+		"b.go":  "package main\n\nfunc main() {}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := map[string]int{}
+	for _, file := range result.Files {
+		lines[file.RelativePath] = file.Lines
+	}
+	if lines["a.txt"] != 1 || lines["b.go"] != 3 {
+		t.Errorf("Expected 1 and 3 lines, got %v", lines)
+	}
+	if result.TotalLines != 4 {
+		t.Errorf("Expected TotalLines 4, got %d", result.TotalLines)
+	}
+}
+
+func TestScanDirectoryWithOptions_SummaryOnly(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"a.txt":    "one\ntwo",
+		"b.go":     "package main\n\nfunc main() {}\n",
+		"empty.md": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	summary, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, SummaryOnly: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	full, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Then
+	if summary.TotalLines != full.TotalLines || summary.TotalSize != full.TotalSize || summary.TotalFiles != full.TotalFiles {
+		t.Errorf("Expected the totals of a full scan %d/%d/%d, got %d/%d/%d",
+			full.TotalFiles, full.TotalLines, full.TotalSize, summary.TotalFiles, summary.TotalLines, summary.TotalSize)
+	}
+	for _, file := range summary.Files {
+		if file.Content != "" {
+			t.Errorf("Expected no content for %s, got %q", file.RelativePath, file.Content)
+		}
+	}
+}
+
+func TestScanDirectoryWithOptions_NoContent(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, NoContent: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected 1 file, got %d", result.TotalFiles)
+	}
+	if result.TotalLines != 0 {
+		t.Errorf("Expected TotalLines 0, got %d", result.TotalLines)
+	}
+	if result.TotalSize != 13 {
+		t.Errorf("Expected TotalSize 13, got %d", result.TotalSize)
+	}
+	for _, file := range result.Files {
+		if file.Content != "" {
+			t.Errorf("Expected no content for %s, got %q", file.RelativePath, file.Content)
+		}
+	}
+}
+
+func TestScanDirectoryWithOptions_AllowList(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	for _, dir := range []string{"src/utils", "docs"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+	}
+	for _, name := range []string{"main.go", "src/app.go", "src/utils/helper.go", "docs/README.md"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{
+		NoGitignore: true,
+		AllowList:   []string{"src/utils/helper.go", "main.go"},
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalFiles != 2 {
+		t.Errorf("Expected 2 files, got %d", result.TotalFiles)
+	}
+
+	expectedTree := "main.go\nsrc/\n  utils/\n    helper.go\n"
+	if result.DirectoryTree != expectedTree {
+		t.Errorf("Expected tree:\n%s\nGot:\n%s", expectedTree, result.DirectoryTree)
+	}
+}
+
+func TestScanDirectoryWithOptions_MaxErrors(t *testing.T) {
+	// Given: broken symlinks fail when their content is read
+	tempDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.Symlink(filepath.Join(tempDir, "missing-"+name), filepath.Join(tempDir, name)); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	// When: the limit is not exceeded
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, MaxErrors: 3})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Errors) != 3 {
+		t.Errorf("Expected 3 errors, got %d", len(result.Errors))
+	}
+
+	// When: the limit is exceeded
+	_, err = ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, MaxErrors: 1})
+
+	// Then
+	if err == nil || !strings.Contains(err.Error(), "too many errors") {
+		t.Fatalf("Expected too many errors, got %v", err)
+	}
+}
+
+func TestScanDirectoryWithOptions_ProgressCallback(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	type call struct {
+		scanned, total int
+		path           string
+	}
+	var calls []call
+
+	// When
+	_, err := ScanDirectoryWithOptions(tempDir, ScanOptions{
+		NoGitignore: true,
+		ProgressCallback: func(scanned, total int, currentPath string) {
+			calls = append(calls, call{scanned, total, currentPath})
+		},
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []call{{1, -1, "a.txt"}, {2, -1, "b.txt"}, {3, -1, "c.txt"}, {3, 3, ""}}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected %d callback calls, got %d: %v", len(expected), len(calls), calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Call %d: expected %v, got %v", i, expected[i], calls[i])
+		}
+	}
+}
+
+func TestScanDirectoryWithOptions_AbortOnError(t *testing.T) {
+	// Given: a broken symlink fails when its content is read
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "a.txt"), []byte("content\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(tempDir, "missing.txt"), filepath.Join(tempDir, "broken.txt")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	// When: skipping errors (default)
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected 1 error, got %d", len(result.Errors))
+	}
+
+	// When: aborting on error
+	result, err = ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, AbortOnError: true})
+
+	// Then
+	if err == nil || !strings.Contains(err.Error(), "broken.txt") {
+		t.Fatalf("Expected error mentioning broken.txt, got %v", err)
+	}
+	if result != nil {
+		t.Error("Expected nil result when aborting")
+	}
+}
+
+func TestScanDirectoryWithOptions_R2cignore(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	files := map[string]string{
+		".r2cignore": "*.csv\n",
+		"main.go":    "package main\n",
+		"data.csv":   "a,b\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		options    ScanOptions
+		expectsCSV bool
+	}{
+		{"r2cignore applies with gitignore disabled", ScanOptions{NoGitignore: true}, false},
+		{"r2cignore disabled", ScanOptions{NoGitignore: true, NoR2cignore: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			result, err := ScanDirectoryWithOptions(tempDir, tt.options)
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if hasCSV := strings.Contains(result.DirectoryTree, "data.csv"); hasCSV != tt.expectsCSV {
+				t.Errorf("Expected data.csv present=%t, tree:\n%s", tt.expectsCSV, result.DirectoryTree)
+			}
+		})
+	}
+}
+
+func TestScanDirectoryWithOptions_IgnoreOverrides(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	files := map[string]string{
+		".gitignore":          "vendor/\nnode_modules/\n*.pb.go\n",
+		"main.go":             "package main\n",
+		"vendor/dep/dep.go":   "package dep\n",
+		"node_modules/x/x.js": "x\n",
+		"api/service.pb.go":   "package api\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		options  ScanOptions
+		included []string
+		excluded []string
+	}{
+		{"gitignore only", ScanOptions{}, nil, []string{"dep.go", "x.js", "service.pb.go"}},
+		{"include vendor", ScanOptions{IncludeVendor: true}, []string{"dep.go"}, []string{"x.js", "service.pb.go"}},
+		{"include node_modules", ScanOptions{IncludeNodeModules: true}, []string{"x.js"}, []string{"dep.go", "service.pb.go"}},
+		{"include generated", ScanOptions{IncludeGenerated: true}, []string{"service.pb.go"}, []string{"dep.go", "x.js"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			result, err := ScanDirectoryWithOptions(tempDir, tt.options)
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, name := range tt.included {
+				if !strings.Contains(result.DirectoryTree, name) {
+					t.Errorf("Expected %s to be included, tree:\n%s", name, result.DirectoryTree)
+				}
+			}
+			for _, name := range tt.excluded {
+				if strings.Contains(result.DirectoryTree, name) {
+					t.Errorf("Expected %s to be excluded, tree:\n%s", name, result.DirectoryTree)
+				}
+			}
+		})
+	}
+}
+
+func TestScanDirectoryWithOptions_CancelledContext(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "a.txt"), []byte("content\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, Context: ctx})
+
+	// Then
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if result != nil {
+		t.Error("Expected nil result when cancelled")
+	}
+}
+
+// =============================================================================
+// Tests for checksums
+// =============================================================================
+
+func TestHashFile_KnownDigests(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		algorithm string
+		expected  string
+	}{
+		{ChecksumMD5, "b1946ac92492d2347c6235b4d2611184"},
+		{ChecksumSHA256, "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+	}
+
+	for _, tt := range tests {
+		// When
+		hash, err := HashFile(path, tt.algorithm)
+
+		// Then
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", tt.algorithm, err)
+		}
+		if hash != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.algorithm, tt.expected, hash)
+		}
+	}
+
+	if _, err := HashFile(path, "crc32"); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+func TestScanDirectoryWithOptions_ChecksumIgnoresLineNumbers(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "hello.txt"), []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, DisplayLineNum: true, Checksum: ChecksumMD5})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, file := range result.Files {
+		if file.RelativePath == "hello.txt" && file.Hash != "b1946ac92492d2347c6235b4d2611184" {
+			t.Errorf("Expected hash of raw bytes, got %s", file.Hash)
+		}
+	}
+}
+
+// =============================================================================
+// Tests for Peek()
+// =============================================================================
+
+func TestPeek_File(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	content, err := Peek(path, true)
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content != "1:\thello\n2:\tworld\n" {
+		t.Errorf("Unexpected content: %q", content)
+	}
+}
+
+func TestPeek_DirectoryReturnsTree(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tempDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create test directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "sub", "a.txt"), []byte("content\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	tree, err := PeekWithOptions(tempDir, PeekOptions{ScanOptions: ScanOptions{NoGitignore: true}})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tree != "sub/\n  a.txt\n" {
+		t.Errorf("Expected directory tree, got %q", tree)
+	}
+}
+
+func TestReadFileContent_LineNumberStyles(t *testing.T) {
+	// Given: a file with 10 lines so padded numbers are two digits wide
+	path := filepath.Join(t.TempDir(), "lines.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x\n", 10)), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		style     string
+		firstLine string
+	}{
+		{"", "1:\tx"},
+		{LineNumberTab, "1:\tx"},
+		{LineNumberSpace, "1: x"},
+		{LineNumberBracket, "[1] x"},
+		{LineNumberPadded, "01: x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			// When
+			content, lines, err := readFileContent(path, lineFormat{displayLineNum: true, lineNumberStyle: tt.style})
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if lines != 10 {
+				t.Errorf("Expected 10 lines, got %d", lines)
+			}
+			if first := strings.SplitN(content, "\n", 2)[0]; first != tt.firstLine {
+				t.Errorf("Expected first line %q, got %q", tt.firstLine, first)
+			}
+		})
+	}
+
+	if err := ValidateLineNumberStyle("roman"); err == nil {
+		t.Error("Expected error for unsupported style")
+	}
+}
+
+func TestScanDirectoryWithOptions_LineEnding(t *testing.T) {
+	// Given: a file with mixed CRLF, LF and stray CR line endings
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "mixed.txt"), []byte("one\r\ntwo\nthree\r\r\nfour"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		ending   string
+		expected string
+	}{
+		{"", "one\ntwo\nthree\r\nfour\n"},
+		{LineEndingLF, "one\ntwo\nthree\nfour\n"},
+		{LineEndingCRLF, "one\r\ntwo\r\nthree\r\nfour\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ending, func(t *testing.T) {
+			// When
+			result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, LineEnding: tt.ending})
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			content := BuildFileSet(result)["mixed.txt"].Content
+			if content != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, content)
+			}
+			if result.TotalLines != 4 {
+				t.Errorf("Expected 4 lines, got %d", result.TotalLines)
+			}
+		})
+	}
+
+	if err := ValidateLineEnding("cr"); err == nil {
+		t.Error("Expected error for unsupported line ending")
+	}
+}
+
+// ============================================================================
+// Tests for path styles
+// ============================================================================
+
+func TestDisplayPath_Styles(t *testing.T) {
+	// Given
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	file := FileInfo{
+		Path:         filepath.Join(cwd, "sub", "main.go"),
+		RelativePath: "main.go",
+	}
+
+	tests := []struct {
+		style    string
+		expected string
+	}{
+		{"", "main.go"},
+		{PathStyleRelative, "main.go"},
+		{PathStyleAbsolute, file.Path},
+		{PathStyleCwd, filepath.Join("sub", "main.go")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			// When
+			got := DisplayPath(file, tt.style)
+
+			// Then
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if err := ValidatePathStyle("home"); err == nil {
+		t.Error("Expected error for unsupported path style")
+	}
+}
+
+func TestRegenerateDirectoryTree_AfterTokenCounting(t *testing.T) {
+	// Given: a scan whose token counts are filled in afterwards, as a library caller would
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(result.DirectoryTree, "tokens") {
+		t.Fatalf("Expected initial tree without token counts, got:\n%s", result.DirectoryTree)
+	}
+	for i := range result.Files {
+		if !result.Files[i].IsDir {
+			result.Files[i].TokenCount = 42
+		}
+	}
+
+	// When
+	tree := RegenerateDirectoryTree(result)
+
+	// Then
+	if !strings.Contains(tree, "main.go (42 tokens)") {
+		t.Errorf("Expected tree with token counts, got:\n%s", tree)
+	}
+}
+
+func TestRegenerateDirectoryTree_TruncatedFiles(t *testing.T) {
+	// Given: a file cut from 900 to 100 tokens
+	result := &ScanResult{
+		RootPath: "/test/path",
+		Files: []FileInfo{
+			{RelativePath: "go.sum", TokenCount: 100, TruncatedFrom: 900},
+			{RelativePath: "main.go", TokenCount: 42},
+		},
+	}
+
+	// When
+	tree := RegenerateDirectoryTree(result)
+
+	// Then
+	if tree != "go.sum (900 tokens, truncated)\nmain.go (42 tokens)\n" {
+		t.Errorf("Expected the original count of the truncated file, got:\n%s", tree)
+	}
+}
+
+func TestRegenerateDirectoryTree_TokenDensity(t *testing.T) {
+	// Given: files with counted tokens and lines, one truncated, one empty
+	result := &ScanResult{
+		RootPath: "/test/path",
+		Files: []FileInfo{
+			{RelativePath: "data.json", TokenCount: 90, Lines: 2},
+			{RelativePath: "go.sum", TokenCount: 100, TruncatedFrom: 900, Lines: 300},
+			{RelativePath: "empty.txt"},
+		},
+		TreeShowDensity: true,
+	}
+
+	// When
+	ComputeTokenDensity(result)
+	tree := RegenerateDirectoryTree(result)
+
+	// Then
+	if result.Files[0].TokenDensity != 45 || result.Files[1].TokenDensity != 3 || result.Files[2].TokenDensity != 0 {
+		t.Errorf("Unexpected densities: %v, %v, %v", result.Files[0].TokenDensity, result.Files[1].TokenDensity, result.Files[2].TokenDensity)
+	}
+	expected := "data.json (90 tokens, 45.0 tok/line)\nempty.txt\ngo.sum (900 tokens, truncated, 3.0 tok/line)\n"
+	if tree != expected {
+		t.Errorf("Expected tree with densities:\n%s\ngot:\n%s", expected, tree)
+	}
+}
+
+func TestRegenerateDirectoryTreeWithStyle_Absolute(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// When
+	tree := RegenerateDirectoryTreeWithStyle(result, PathStyleAbsolute)
+
+	// Then
+	if !strings.Contains(tree, filepath.Base(tempDir)+"/\n") {
+		t.Errorf("Expected tree to contain the absolute scan root, got:\n%s", tree)
+	}
+	if relTree := RegenerateDirectoryTreeWithStyle(result, PathStyleRelative); relTree != RegenerateDirectoryTree(result) {
+		t.Errorf("Expected relative style to match the default tree")
+	}
+}
+
+// ============================================================================
+// Tests for concurrent reads
+// ============================================================================
+
+// createBenchmarkTree creates count small files spread over a few directories
+func createBenchmarkTree(tb testing.TB, count int) string {
+	tb.Helper()
+	root := tb.TempDir()
+	content := []byte(strings.Repeat("line of content\n", 50))
+	for i := 0; i < count; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", i%10))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			tb.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), content, 0644); err != nil {
+			tb.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	return root
+}
+
+func TestScanDirectoryWithOptions_WorkersMatchSequential(t *testing.T) {
+	// Given
+	root := createBenchmarkTree(t, 50)
+
+	// When
+	sequential, err := ScanDirectoryWithOptions(root, ScanOptions{NoGitignore: true, Workers: 1, Checksum: ChecksumMD5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	concurrent, err := ScanDirectoryWithOptions(root, ScanOptions{NoGitignore: true, Workers: 8, Checksum: ChecksumMD5})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Then
+	if len(sequential.Files) != len(concurrent.Files) {
+		t.Fatalf("Expected %d files, got %d", len(sequential.Files), len(concurrent.Files))
+	}
+	for i := range sequential.Files {
+		s, c := sequential.Files[i], concurrent.Files[i]
+		if s.RelativePath != c.RelativePath || s.Content != c.Content || s.Hash != c.Hash {
+			t.Errorf("File %d differs: %s vs %s", i, s.RelativePath, c.RelativePath)
+		}
+	}
+	if sequential.TotalLines != concurrent.TotalLines || sequential.TotalSize != concurrent.TotalSize {
+		t.Errorf("Expected equal totals, got lines %d/%d size %d/%d",
+			sequential.TotalLines, concurrent.TotalLines, sequential.TotalSize, concurrent.TotalSize)
+	}
+}
+
+func BenchmarkScanDirectoryWithOptions_Workers(b *testing.B) {
+	root := createBenchmarkTree(b, 1000)
+
+	for _, workers := range []int{1, 4, 0} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := ScanDirectoryWithOptions(root, ScanOptions{NoGitignore: true, Workers: workers}); err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// ============================================================================
+// Benchmarks for scanning large trees
+// ============================================================================
+
+// benchmarkScanDirectory scans a synthetic tree of count files per iteration
+func benchmarkScanDirectory(b *testing.B, count int) {
+	root := createBenchmarkTree(b, count)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ScanDirectoryWithOptions(root, ScanOptions{NoGitignore: true}); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkScanDirectorySmall(b *testing.B)  { benchmarkScanDirectory(b, 10) }
+func BenchmarkScanDirectoryMedium(b *testing.B) { benchmarkScanDirectory(b, 100) }
+func BenchmarkScanDirectoryLarge(b *testing.B)  { benchmarkScanDirectory(b, 1000) }
+
+// syntheticFileInfos returns count files spread over directories ten to a
+// level, with the directory entries ahead of the files as a scan yields them
+func syntheticFileInfos(count int) []FileInfo {
+	files := make([]FileInfo, 0, count+count/10)
+	for i := 0; i < count; i++ {
+		dir := filepath.Join(fmt.Sprintf("dir%d", i/100), fmt.Sprintf("sub%d", i/10%10))
+		if i%10 == 0 {
+			files = append(files, FileInfo{RelativePath: dir, IsDir: true})
+		}
+		files = append(files, FileInfo{RelativePath: filepath.Join(dir, fmt.Sprintf("file%d.go", i))})
+	}
+	return files
+}
+
+func BenchmarkGenerateDirectoryTree(b *testing.B) {
+	files := syntheticFileInfos(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		generateDirectoryTree(files, "/bench")
+	}
+}
+
+func BenchmarkBuildPathMap(b *testing.B) {
+	files := syntheticFileInfos(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildPathMap(files)
+	}
+}
+
+// ============================================================================
+// Tests for filters
+// ============================================================================
+
+func TestScanDirectoryWithOptions_LockFileFilter(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	for _, name := range []string{"main.go", "go.sum", "yarn.lock", "deps.lock", "custom.lockfile", "package.json"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{
+		NoGitignore: true,
+		Filters:     []FileFilter{LockFileFilter{Extra: []string{"custom.lockfile"}}},
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, name := range []string{"go.sum", "yarn.lock", "deps.lock", "custom.lockfile"} {
+		if strings.Contains(result.DirectoryTree, name) {
+			t.Errorf("Expected %s to be excluded, tree:\n%s", name, result.DirectoryTree)
+		}
+	}
+	for _, name := range []string{"main.go", "package.json"} {
+		if !strings.Contains(result.DirectoryTree, name) {
+			t.Errorf("Expected %s to be included, tree:\n%s", name, result.DirectoryTree)
+		}
+	}
+	if result.TotalFiles != 2 {
+		t.Errorf("Expected 2 files, got %d", result.TotalFiles)
+	}
+}
+
+// ============================================================================
+// Tests for generated files
+// ============================================================================
+
+func TestIsGeneratedFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		content  string
+		expected bool
+	}{
+		{"api/service.pb.go", "package api\n", true},
+		{"api/service.pb.gw.go", "package api\n", true},
+		{"types_gen.go", "package types\n", true},
+		{"mock_store.go", "package store\n", true},
+		{"zz_generated.deepcopy.go", "package v1\n", true},
+		{"parser.go", "// Code generated by goyacc. DO NOT EDIT.\n\npackage parser\n", true},
+		{"schema.ts", "/* eslint-disable */\n/* AUTO-GENERATED */\nexport {}\n", true},
+		{"main.go", "package main\n", false},
+		{"late.go", "package late\n\n\n\n\n// Code generated by tool.\n", false},
+		{"doc.go", "// This comment mentions // Code generated mid-line\npackage doc\n", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsGeneratedFile(tt.path, tt.content); got != tt.expected {
+			t.Errorf("IsGeneratedFile(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestScanDirectoryWithOptions_GeneratedFileFilter(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.go":        "package main\n",
+		"service.pb.go":  "package main\n",
+		"parser.go":      "// Code generated by goyacc. DO NOT EDIT.\npackage main\n",
+		"mocks/store.go": "// Code generated by MockGen. DO NOT EDIT.\npackage mocks\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{
+		NoGitignore: true,
+		Filters:     []FileFilter{GeneratedFileFilter{}},
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalFiles != 1 || !strings.Contains(result.DirectoryTree, "main.go") {
+		t.Errorf("Expected only main.go, tree:\n%s", result.DirectoryTree)
+	}
+}
+
+// ============================================================================
+// Tests for ExplainIgnore
+// ============================================================================
+
+func TestExplainIgnore_NamesDecidingPattern(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("# logs\n*.log\n\n!keep.log\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+	for _, name := range []string{"app.log", "keep.log", "main.go"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"app.log", "Result: ignored by pattern '*.log' at position 2 in .gitignore\n"},
+		{"keep.log", "Result: not ignored: re-included by negation pattern '!keep.log' at position 4 in .gitignore after pattern '*.log' at position 2 in .gitignore\n"},
+		{"main.go", "Result: not ignored\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// When
+			explanation, err := ExplainIgnore(filepath.Join(tempDir, tt.name), ScanOptions{NoR2cignore: true})
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(explanation, tt.expected) {
+				t.Errorf("Expected %q in explanation, got:\n%s", tt.expected, explanation)
+			}
+			if !strings.Contains(explanation, ".r2cignore: disabled\n") {
+				t.Errorf("Expected .r2cignore to be reported as disabled, got:\n%s", explanation)
+			}
+		})
+	}
+}
+
+func TestExplainIgnore_R2cignoreNegationOverridesGitignore(t *testing.T) {
+	// Given: .gitignore ignores *.js and .r2cignore re-includes app.js
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("*.js\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, ".r2cignore"), []byte("!app.js\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .r2cignore: %v", err)
+	}
+	for _, name := range []string{"app.js", "vendor.js"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	explanation, err := ExplainIgnore(filepath.Join(tempDir, "app.js"), ScanOptions{})
+
+	// Then: the explanation agrees with the scan, which includes app.js
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "Result: not ignored: re-included by negation pattern '!app.js' at position 1 in .r2cignore after pattern '*.js' at position 1 in .gitignore\n"
+	if !strings.Contains(explanation, expected) {
+		t.Errorf("Expected %q in explanation, got:\n%s", expected, explanation)
+	}
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files := BuildFileSet(result)
+	if _, ok := files["app.js"]; !ok {
+		t.Errorf("Expected the scan to include app.js, tree:\n%s", result.DirectoryTree)
+	}
+	if _, ok := files["vendor.js"]; ok {
+		t.Errorf("Expected the scan to exclude vendor.js, tree:\n%s", result.DirectoryTree)
+	}
+}
+
+// ============================================================================
+// Tests for GlobExcludeFilter
+// ============================================================================
+
+// scanWithExcludes scans dir with the given exclude patterns and returns the scanned file paths
+func scanWithExcludes(t *testing.T, dir string, noGitignore bool, patterns ...string) map[string]bool {
+	t.Helper()
+	result, err := ScanDirectoryWithOptions(dir, ScanOptions{
+		NoGitignore: noGitignore,
+		NoR2cignore: true,
+		Filters:     []FileFilter{GlobExcludeFilter{Patterns: patterns}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files := make(map[string]bool)
+	for _, file := range result.Files {
+		if !file.IsDir {
+			files[filepath.ToSlash(file.RelativePath)] = true
+		}
+	}
+	return files
+}
+
+// createExcludeTree creates a small project used by the exclude tests
+func createExcludeTree(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	for _, name := range []string{"main.go", "important.go", "util.go", "README.md", "pkg/a.go", "pkg/important.go", "gen/out.go"} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	return tempDir
+}
+
+func TestGlobExcludeFilter_Negation(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{"plain exclude", []string{"*.go"}, []string{"README.md"}},
+		{"negation re-includes by name", []string{"*.go", "!important.go"}, []string{"README.md", "important.go", "pkg/important.go"}},
+		{"negation with path", []string{"*.go", "!pkg/important.go"}, []string{"README.md", "pkg/important.go"}},
+		{"last match wins", []string{"*.go", "!important.go", "important.go"}, []string{"README.md"}},
+		{"negation before exclude has no effect", []string{"!main.go", "*.go"}, []string{"README.md"}},
+		{"excluded directory cannot be re-included", []string{"gen", "!gen/out.go"}, []string{"README.md", "important.go", "main.go", "pkg/a.go", "pkg/important.go", "util.go"}},
+		{"negation alone excludes nothing", []string{"!main.go"}, []string{"README.md", "gen/out.go", "important.go", "main.go", "pkg/a.go", "pkg/important.go", "util.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			tempDir := createExcludeTree(t)
+
+			// When
+			files := scanWithExcludes(t, tempDir, true, tt.patterns...)
+
+			// Then
+			if len(files) != len(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, files)
+			}
+			for _, name := range tt.expected {
+				if !files[name] {
+					t.Errorf("Expected %s to be included, got %v", name, files)
+				}
+			}
+		})
+	}
+}
+
+func TestGlobExcludeFilter_NegationDoesNotOverrideGitignore(t *testing.T) {
+	// Given: .gitignore ignores important.go, exclude patterns try to re-include it
+	tempDir := createExcludeTree(t)
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("important.go\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+
+	// When
+	files := scanWithExcludes(t, tempDir, false, "*.go", "!important.go")
+
+	// Then: .gitignore applies first, so the negation cannot bring the file back
+	if files["important.go"] || files["pkg/important.go"] {
+		t.Errorf("Expected gitignored files to stay excluded, got %v", files)
+	}
+	if files["main.go"] || !files["README.md"] {
+		t.Errorf("Expected only non-Go files, got %v", files)
+	}
+}
+
+func TestGlobExcludeFilter_NegationWithinGitignoreIncludes(t *testing.T) {
+	// Given: .gitignore re-includes a file its own pattern ignored
+	tempDir := createExcludeTree(t)
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("*.md\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+
+	// When: --exclude negation only re-includes what --exclude excluded
+	files := scanWithExcludes(t, tempDir, false, "*.go", "!main.go", "!README.md")
+
+	// Then
+	if !files["main.go"] || files["util.go"] {
+		t.Errorf("Expected main.go re-included and util.go excluded, got %v", files)
+	}
+	if files["README.md"] {
+		t.Errorf("Expected README.md to stay gitignored, got %v", files)
+	}
+}
+
+func TestGlobExcludeFilter_NoGitignoreAllowsReinclude(t *testing.T) {
+	// Given
+	tempDir := createExcludeTree(t)
+	if err := os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("important.go\n"), 0644); err != nil {
+		t.Fatalf("Failed to create .gitignore: %v", err)
+	}
+
+	// When: gitignore filtering is disabled
+	files := scanWithExcludes(t, tempDir, true, "*.go", "!important.go")
+
+	// Then
+	if !files["important.go"] || !files["pkg/important.go"] || files["main.go"] {
+		t.Errorf("Expected only important.go files among Go files, got %v", files)
+	}
+}
+
+// ============================================================================
+// Tests for ScanArchive
+// ============================================================================
+
+// archiveFiles are the entries written by the archive helpers
+var archiveFiles = []struct {
+	name    string
+	content string
+}{
+	{"proj/", ""},
+	{"proj/README.md", "# Project\n"},
+	{"proj/src/main.go", "package main\n\nfunc main() {}\n"},
+	{"../escape.txt", "outside\n"},
+}
+
+// createZipArchive writes archiveFiles to a zip file and returns its path
+func createZipArchive(t *testing.T) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "project.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	zw := zip.NewWriter(file)
+	for _, entry := range archiveFiles {
+		w, err := zw.Create(entry.name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", entry.name, err)
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", entry.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to finish archive: %v", err)
+	}
+	return archivePath
+}
+
+// writeZip writes files, keyed by entry name, to a zip file and returns its path
+func writeZip(t *testing.T, files map[string]string) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "files.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	zw := zip.NewWriter(file)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to finish archive: %v", err)
+	}
+	return archivePath
+}
+
+// createTarGzArchive writes archiveFiles to a .tar.gz file and returns its path
+func createTarGzArchive(t *testing.T) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "project.tar.gz")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, entry := range archiveFiles {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(entry.name, "/") {
+			header = &tar.Header{Name: entry.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to add %s: %v", entry.name, err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", entry.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to finish tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to finish gzip: %v", err)
+	}
+	return archivePath
+}
+
+func TestScanArchive_ZipAndTarGz(t *testing.T) {
+	archives := map[string]func(*testing.T) string{
+		"zip":    createZipArchive,
+		"tar.gz": createTarGzArchive,
+	}
+
+	for name, create := range archives {
+		t.Run(name, func(t *testing.T) {
+			// Given
+			archivePath := create(t)
+
+			// When
+			result, err := ScanArchive(archivePath, ScanOptions{})
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.RootPath != archivePath {
+				t.Errorf("Expected RootPath %s, got %s", archivePath, result.RootPath)
+			}
+			expectedTree := "proj/\n  README.md\n  src/\n    main.go\n"
+			if result.DirectoryTree != expectedTree {
+				t.Errorf("Expected tree:\n%s\ngot:\n%s", expectedTree, result.DirectoryTree)
+			}
+			if result.TotalFiles != 2 || result.TotalLines != 4 {
+				t.Errorf("Expected 2 files and 4 lines, got %d files and %d lines", result.TotalFiles, result.TotalLines)
+			}
+
+			files := BuildFileSet(result)
+			mainFile := files[filepath.Join("proj", "src", "main.go")]
+			if mainFile.Language != "go" || !strings.Contains(mainFile.Content, "func main()") {
+				t.Errorf("Expected main.go content and language, got %+v", mainFile)
+			}
+			if _, ok := files["escape.txt"]; ok {
+				t.Error("Expected entries escaping the archive root to be skipped")
+			}
+		})
+	}
+}
+
+func TestScanArchive_AppliesFilters(t *testing.T) {
+	// Given
+	archivePath := createZipArchive(t)
+
+	// When
+	byLanguage, err := ScanArchive(archivePath, ScanOptions{IncludeLanguages: []string{"go"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	byPattern, err := ScanArchive(archivePath, ScanOptions{
+		Filters: []FileFilter{GlobExcludeFilter{Patterns: []string{"src"}}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Then
+	if byLanguage.TotalFiles != 1 || strings.Contains(byLanguage.DirectoryTree, "README.md") {
+		t.Errorf("Expected only main.go, tree:\n%s", byLanguage.DirectoryTree)
+	}
+	if byPattern.TotalFiles != 1 || strings.Contains(byPattern.DirectoryTree, "main.go") {
+		t.Errorf("Expected the excluded directory to be skipped, tree:\n%s", byPattern.DirectoryTree)
+	}
+}
+
+func TestScanArchive_SizeLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		entryLimit int64
+		totalLimit int64
+		wantError  string
+	}{
+		{"entry over limit", 16, 1 << 20, "exceeds 16 bytes"},
+		{"archive over total", 1 << 20, 16, "archive exceeds 16 bytes"},
+	}
+
+	for _, tt := range tests {
+		for name, create := range map[string]func(*testing.T) string{"zip": createZipArchive, "tar.gz": createTarGzArchive} {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				// Given: README.md (10 bytes) fits, main.go (29 bytes) doesn't
+				entryLimit, totalLimit := maxArchiveEntrySize, maxArchiveTotalSize
+				maxArchiveEntrySize, maxArchiveTotalSize = tt.entryLimit, tt.totalLimit
+				t.Cleanup(func() { maxArchiveEntrySize, maxArchiveTotalSize = entryLimit, totalLimit })
+
+				// When
+				result, err := ScanArchive(create(t), ScanOptions{})
+
+				// Then
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				files := BuildFileSet(result)
+				if readme := files[filepath.Join("proj", "README.md")]; readme.Content != "# Project\n" {
+					t.Errorf("Expected README.md to be read, got %+v", readme)
+				}
+				mainFile := files[filepath.Join("proj", "src", "main.go")]
+				if mainFile.Error == nil || mainFile.Content != "" {
+					t.Errorf("Expected main.go to be skipped with an error, got %+v", mainFile)
+				}
+				if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], tt.wantError) {
+					t.Errorf("Expected an error containing %q, got %v", tt.wantError, result.Errors)
+				}
+			})
+		}
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	for _, path := range []string{"a.zip", "a.tar", "a.tar.gz", "a.TGZ", "a.tar.bz2", "a.tar.xz"} {
+		if !IsArchive(path) {
+			t.Errorf("Expected %s to be an archive", path)
+		}
+	}
+	for _, path := range []string{"a.gz", "a.go", "zip"} {
+		if IsArchive(path) {
+			t.Errorf("Expected %s not to be an archive", path)
+		}
+	}
+}
+
+// ============================================================================
+// Tests for ScanCommit
+// ============================================================================
+
+func TestScanCommit_ReadsCommitState(t *testing.T) {
+	// Given: a repository whose working tree differs from its first commit
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=Test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "first")
+	if err := os.WriteFile(filepath.Join(repo, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "new.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	result, err := ScanCommit(repo, "HEAD", ScanOptions{Checksum: ChecksumMD5})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Fatalf("Expected only the committed file, got %d files:\n%s", result.TotalFiles, result.DirectoryTree)
+	}
+	file := result.Files[0]
+	if file.RelativePath != "main.go" || file.Content != "package main\n" {
+		t.Errorf("Expected main.go as committed, got %s: %q", file.RelativePath, file.Content)
+	}
+	if file.Hash == "" || file.ModTime.IsZero() {
+		t.Errorf("Expected hash and commit time, got %q and %v", file.Hash, file.ModTime)
+	}
+
+	if _, err := ScanCommit(repo, "no-such-commit", ScanOptions{}); err == nil {
+		t.Error("Expected error for unknown commit")
+	}
+}
+
+func TestScanCommit_GeneratedFileFilterReadsCommittedContent(t *testing.T) {
+	// Given: main.go is generated only in the working tree and parser.go only at the commit
+	repo := t.TempDir()
+	mock.Use(t, &mock.MockGitClient{
+		IsRepo: true,
+		Root:   repo,
+		Commit: "abc123",
+		CommitFiles: map[string]string{
+			"main.go":   "package main\n",
+			"parser.go": "// Code generated by goyacc. DO NOT EDIT.\npackage main\n",
+		},
+	})
+	files := map[string]string{
+		"main.go":   "// Code generated by hand. DO NOT EDIT.\npackage main\n",
+		"parser.go": "package main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanCommit(repo, "HEAD", ScanOptions{Filters: []FileFilter{GeneratedFileFilter{}}})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalFiles != 1 || result.Files[0].RelativePath != "main.go" {
+		t.Errorf("Expected only main.go, tree:\n%s", result.DirectoryTree)
+	}
+}
+
+// ============================================================================
+// Tests for .git/info/exclude and the global excludes file
+// ============================================================================
+
+func TestScanDirectoryWithOptions_GitExcludes(t *testing.T) {
+	// Given: a repository with patterns in .git/info/exclude, a global
+	// excludes file and a .gitignore negation overriding one of them
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+
+	globalIgnore := filepath.Join(t.TempDir(), "global-ignore")
+	globalConfig := filepath.Join(t.TempDir(), "gitconfig")
+	files := map[string]string{
+		filepath.Join(repo, ".git", "info", "exclude"): "*.log\n",
+		filepath.Join(repo, ".gitignore"):              "!keep.log\n",
+		globalIgnore:                                   "*.tmp\n",
+		globalConfig:                                   "[core]\n\texcludesFile = " + filepath.ToSlash(globalIgnore) + "\n",
+		filepath.Join(repo, "main.go"):                 "package main\n",
+		filepath.Join(repo, "debug.log"):               "log\n",
+		filepath.Join(repo, "keep.log"):                "log\n",
+		filepath.Join(repo, "scratch.tmp"):             "tmp\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", globalConfig)
+
+	// When
+	result, err := ScanDirectoryWithOptions(repo, ScanOptions{})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	paths := map[string]bool{}
+	for _, file := range result.Files {
+		paths[file.RelativePath] = true
+	}
+	if !paths["main.go"] || !paths["keep.log"] {
+		t.Errorf("Expected main.go and re-included keep.log, got:\n%s", result.DirectoryTree)
+	}
+	if paths["debug.log"] || paths["scratch.tmp"] {
+		t.Errorf("Expected debug.log and scratch.tmp to be excluded, got:\n%s", result.DirectoryTree)
+	}
+
+	// And: with NoGitInfoExclude only .gitignore applies
+	result, err = ScanDirectoryWithOptions(repo, ScanOptions{NoGitInfoExclude: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	paths = map[string]bool{}
+	for _, file := range result.Files {
+		paths[file.RelativePath] = true
+	}
+	if !paths["debug.log"] || !paths["scratch.tmp"] {
+		t.Errorf("Expected debug.log and scratch.tmp without git excludes, got:\n%s", result.DirectoryTree)
+	}
+}
+
+// ============================================================================
+// Tests for TestFileFilter and IncludeOverrideFilter
+// ============================================================================
+
+func TestTestFileFilter_DefaultPatterns(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"pkg/core/core_test.go", true},
+		{"web/app.test.ts", true},
+		{"web/app.spec.js", true},
+		{"tools/test_parse.py", true},
+		{"web/__tests__/app.js", true},
+		{"test/fixtures/data.json", true},
+		{"pkg/tests/helper.go", true},
+		{"spec/models/user_spec.rb", true},
+		{"pkg/core/core.go", false},
+		{"web/app.ts", false},
+		{"testdata/input.txt", false},
+		{"contest/main.go", false},
+	}
+
+	filter := TestFileFilter{}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Given
+			entry := virtualFile{name: filepath.Base(tt.path)}
+
+			// When
+			excluded := filter.Exclude(filepath.FromSlash(tt.path), entry)
+
+			// Then
+			if excluded != tt.expected {
+				t.Errorf("Exclude(%q) = %v, expected %v", tt.path, excluded, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTestFileFilter_CustomPatternsReplaceDefaults(t *testing.T) {
+	// Given
+	filter := TestFileFilter{Patterns: []string{"*Test.java", "fixtures/"}}
+
+	// When / Then
+	if !filter.Exclude("src/UserTest.java", virtualFile{name: "UserTest.java"}) {
+		t.Error("Expected custom file pattern to exclude UserTest.java")
+	}
+	if !filter.Exclude(filepath.FromSlash("src/fixtures/a.json"), virtualFile{name: "a.json"}) {
+		t.Error("Expected custom directory pattern to exclude files in fixtures/")
+	}
+	if filter.Exclude("core_test.go", virtualFile{name: "core_test.go"}) {
+		t.Error("Expected default patterns to be replaced")
+	}
+	if filter.Exclude("fixtures", virtualFile{name: "fixtures", dir: true}) {
+		t.Error("Expected directories to be kept")
+	}
+}
+
+func TestIncludeOverrideFilter_IncludeBeatsExclude(t *testing.T) {
+	// Given: a project with test files in a test directory and next to the code
+	tempDir := t.TempDir()
+	for _, name := range []string{"main.go", "main_test.go", "util_test.go", "test/e2e.go", "test/helper.go"} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{
+		NoGitignore: true,
+		NoR2cignore: true,
+		Filters: []FileFilter{IncludeOverrideFilter{
+			Include: []string{"main_test.go", "test/helper.go"},
+			Filters: []FileFilter{TestFileFilter{}},
+		}},
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	files := make(map[string]bool)
+	for _, file := range result.Files {
+		if !file.IsDir {
+			files[filepath.ToSlash(file.RelativePath)] = true
+		}
+	}
+	expected := []string{"main.go", "main_test.go", "test/helper.go"}
+	if len(files) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+	for _, name := range expected {
+		if !files[name] {
+			t.Errorf("Expected %s to be included, got %v", name, files)
+		}
+	}
+}
+
+// ============================================================================
+// Tests for ExcludePaths
+// ============================================================================
+
+func TestScanDirectoryWithOptions_ExcludePaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		paths    []string
+		expected []string
+	}{
+		{"directory", []string{"pkg"}, []string{"README.md", "gen/out.go", "important.go", "main.go", "pkg-old/b.go", "util.go"}},
+		{"trailing slash", []string{"pkg/"}, []string{"README.md", "gen/out.go", "important.go", "main.go", "pkg-old/b.go", "util.go"}},
+		{"single file", []string{"pkg/a.go"}, []string{"README.md", "gen/out.go", "important.go", "main.go", "pkg-old/b.go", "pkg/important.go", "util.go"}},
+		{"several paths", []string{"gen", "pkg-old", "main.go"}, []string{"README.md", "important.go", "pkg/a.go", "pkg/important.go", "util.go"}},
+		{"no partial name match", []string{"pkg/a"}, []string{"README.md", "gen/out.go", "important.go", "main.go", "pkg-old/b.go", "pkg/a.go", "pkg/important.go", "util.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			tempDir := createExcludeTree(t)
+			if err := os.MkdirAll(filepath.Join(tempDir, "pkg-old"), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(tempDir, "pkg-old", "b.go"), []byte("x\n"), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			// When
+			result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, ExcludePaths: tt.paths})
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var files []string
+			for _, file := range result.Files {
+				if !file.IsDir {
+					files = append(files, filepath.ToSlash(file.RelativePath))
+				}
+			}
+			sort.Strings(files)
+			if strings.Join(files, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, files)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// Tests for PruneEmptyDirs
+// ============================================================================
+
+// createPruneTree creates src/main.go next to directories that hold no Go files
+func createPruneTree(t *testing.T) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	for _, dir := range []string{"src", "build", "empty", filepath.Join("nested", "deep")} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	for name, content := range map[string]string{
+		filepath.Join("src", "main.go"):     "package main\n",
+		filepath.Join("build", "notes.txt"): "artifact\n",
+	} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	return tempDir
+}
+
+func TestScanDirectoryWithOptions_PruneEmptyDirs(t *testing.T) {
+	// Given
+	tempDir := createPruneTree(t)
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, IncludeLanguages: []string{"go"}, PruneEmptyDirs: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.DirectoryTree != "src/\n  main.go\n" {
+		t.Errorf("Expected only src/main.go in the tree, got:\n%s", result.DirectoryTree)
+	}
+	for _, file := range result.Files {
+		if file.IsDir && file.RelativePath != "" && file.RelativePath != "src" {
+			t.Errorf("Expected %s to be pruned", file.RelativePath)
+		}
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected 1 file, got %d", result.TotalFiles)
+	}
+}
+
+func TestScanDirectoryWithOptions_KeepsEmptyDirsByDefault(t *testing.T) {
+	// Given
+	tempDir := createPruneTree(t)
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, IncludeLanguages: []string{"go"}})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, dir := range []string{"build/\n", "empty/\n", "nested/\n  deep/\n"} {
+		if !strings.Contains(result.DirectoryTree, dir) {
+			t.Errorf("Expected %q in the tree, got:\n%s", dir, result.DirectoryTree)
+		}
+	}
+}
+
+// ============================================================================
+// Tests for ScanFile
+// ============================================================================
+
+func TestScanFile_PopulatesResult(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "main.go")
+	if err := os.WriteFile(filePath, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	result, err := ScanFile(filePath, ScanOptions{DisplayLineNum: true, Checksum: ChecksumMD5})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.RootPath != tempDir || result.TotalFiles != 1 || len(result.Files) != 1 {
+		t.Fatalf("Expected one file rooted at %s, got %+v", tempDir, result)
+	}
+	file := result.Files[0]
+	if file.RelativePath != "main.go" || file.Language != "go" || file.Size != 29 {
+		t.Errorf("Unexpected file info: %+v", file)
+	}
+	if !strings.HasPrefix(file.Content, "1:\tpackage main\n") {
+		t.Errorf("Expected numbered content, got %q", file.Content)
+	}
+	if file.Hash == "" || file.ModTime.IsZero() {
+		t.Errorf("Expected hash and modification time, got %q and %v", file.Hash, file.ModTime)
+	}
+	if result.TotalLines != 3 || file.Lines != 3 || result.TotalSize != 29 {
+		t.Errorf("Expected 3 lines and 29 bytes, got %d (file %d) and %d", result.TotalLines, file.Lines, result.TotalSize)
+	}
+	if result.DirectoryTree != "main.go\n" {
+		t.Errorf("Expected tree with the file, got %q", result.DirectoryTree)
+	}
+}
+
+func TestScanFile_NoContent(t *testing.T) {
+	// Given
+	filePath := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(filePath, []byte("a\nb\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// When
+	result, err := ScanFile(filePath, ScanOptions{NoContent: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Files[0].Content != "" || result.TotalLines != 0 || result.TotalSize != 4 {
+		t.Errorf("Expected metadata only, got %+v", result)
+	}
+}
+
+func TestScanFile_Errors(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+
+	// When / Then
+	if _, err := ScanFile(filepath.Join(tempDir, "missing.go"), ScanOptions{}); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+	if _, err := ScanFile(tempDir, ScanOptions{}); err == nil {
+		t.Error("Expected error for a directory")
+	}
+}
+
+// ============================================================================
+// Tests for MergeScanResults
+// ============================================================================
+
+func TestMergeScanResults_CombinesResults(t *testing.T) {
+	// Given: scans of two sibling directories, with a file scanned by both
+	root := filepath.Join(string(filepath.Separator), "work")
+	a := &ScanResult{
+		RootPath: filepath.Join(root, "a"),
+		Files: []FileInfo{
+			{RelativePath: "main.go", Lines: 6, Size: 60, TokenCount: 3},
+			{RelativePath: "lib", IsDir: true},
+			{RelativePath: filepath.Join("lib", "lib.go"), Lines: 4, Size: 40, TokenCount: 2},
+		},
+		TotalFiles: 2, TotalLines: 10, TotalSize: 100, TotalTokens: 5, TokenEncoding: "o200k_base",
+		Errors: []string{"error reading a"},
+	}
+	b := &ScanResult{
+		RootPath:   filepath.Join(root, "b"),
+		Files:      []FileInfo{{RelativePath: "main.go", Lines: 5, Size: 50, TokenCount: 4}},
+		TotalFiles: 1, TotalLines: 5, TotalSize: 50, TotalTokens: 4,
+		Errors: []string{"error reading b"},
+	}
+	overlap := &ScanResult{
+		RootPath:   filepath.Join(root, "a", "lib"),
+		Files:      []FileInfo{{RelativePath: "lib.go", Lines: 4, Size: 40, TokenCount: 2}},
+		TotalFiles: 1, TotalLines: 4, TotalSize: 40, TotalTokens: 2,
+	}
+
+	// When
+	merged := MergeScanResults([]*ScanResult{a, b, overlap}, "services")
+
+	// Then
+	if merged.RootPath != root {
+		t.Errorf("Expected common root %s, got %s", root, merged.RootPath)
+	}
+	var paths []string
+	for _, file := range merged.Files {
+		paths = append(paths, filepath.ToSlash(file.RelativePath))
+	}
+	expectedPaths := []string{"a/main.go", "a/lib", "a/lib/lib.go", "b/main.go"}
+	if strings.Join(paths, ",") != strings.Join(expectedPaths, ",") {
+		t.Errorf("Expected files %v, got %v", expectedPaths, paths)
+	}
+	if merged.TotalFiles != 3 || merged.TotalLines != 15 || merged.TotalSize != 150 || merged.TotalTokens != 9 {
+		t.Errorf("Expected the totals of the files listed once, got %+v", merged)
+	}
+	if merged.TokenEncoding != "o200k_base" || len(merged.Errors) != 2 {
+		t.Errorf("Expected encoding and both errors, got %q and %v", merged.TokenEncoding, merged.Errors)
+	}
+	expectedTree := "services/\n  a/\n    lib/\n      lib.go (2 tokens)\n    main.go (3 tokens)\n  b/\n    main.go (4 tokens)\n"
+	if merged.DirectoryTree != expectedTree {
+		t.Errorf("Expected tree %q, got %q", expectedTree, merged.DirectoryTree)
+	}
+}
+
+func TestMergeScanResults_DefaultLabel(t *testing.T) {
+	// Given
+	root := filepath.Join(string(filepath.Separator), "work")
+	results := []*ScanResult{
+		{RootPath: filepath.Join(root, "a"), Files: []FileInfo{{RelativePath: "a.go"}}},
+		{RootPath: filepath.Join(root, "b"), Files: []FileInfo{{RelativePath: "b.go"}}},
+	}
+
+	// When
+	merged := MergeScanResults(results, "")
+
+	// Then
+	if !strings.HasPrefix(merged.DirectoryTree, "work/\n") {
+		t.Errorf("Expected tree headed by the common directory, got %q", merged.DirectoryTree)
+	}
+}
+
+// ============================================================================
+// Tests for StripComments
+// ============================================================================
+
+func TestScanDirectoryWithOptions_StripComments(t *testing.T) {
+	// Given: a Go file with comments and a text file that merely looks like one
+	tempDir := t.TempDir()
+	goSrc := "package main\n\n// main runs\nfunc main() {} // done\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte(goSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "notes.txt"), []byte("// kept\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{StripComments: true, DisplayLineNum: true})
+
+	// Then: comments are gone from the Go file before numbering, other files are unchanged
+	if err != nil {
+		t.Fatalf("ScanDirectoryWithOptions() error = %v", err)
+	}
+	contents := make(map[string]string)
+	for _, file := range result.Files {
+		contents[file.RelativePath] = file.Content
+	}
+	if want := "1:\tpackage main\n2:\t\n3:\tfunc main() {}\n"; contents["main.go"] != want {
+		t.Errorf("Expected stripped main.go %q, got %q", want, contents["main.go"])
+	}
+	if want := "1:\t// kept\n"; contents["notes.txt"] != want {
+		t.Errorf("Expected notes.txt unchanged %q, got %q", want, contents["notes.txt"])
+	}
+	if result.TotalLines != 4 {
+		t.Errorf("Expected 4 lines after stripping, got %d", result.TotalLines)
+	}
+}
+
+func TestScanDirectoryWithOptions_SummaryOnlyCountsStrippedLines(t *testing.T) {
+	// Given
+	tempDir := t.TempDir()
+	goSrc := "package main\n\n// main runs\nfunc main() {} // done\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte(goSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{StripComments: true, SummaryOnly: true})
+
+	// Then: the lines are counted after stripping, and no content is kept
+	if err != nil {
+		t.Fatalf("ScanDirectoryWithOptions() error = %v", err)
+	}
+	if result.TotalLines != 3 {
+		t.Errorf("Expected 3 lines after stripping, got %d", result.TotalLines)
+	}
+	for _, file := range result.Files {
+		if file.Content != "" {
+			t.Errorf("Expected no content for %s, got %q", file.RelativePath, file.Content)
+		}
+	}
+}
+
+// ============================================================================
+// Tests for RespectEditorConfig
+// ============================================================================
+
+func TestScanDirectoryWithOptions_RespectEditorConfig(t *testing.T) {
+	// Given: a UTF-16 file declared as such in .editorconfig
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, ".editorconfig"), []byte("root = true\n\n[*.txt]\ncharset = utf-16le\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	utf16 := "\xff\xfeh\x00i\x00\n\x00"
+	if err := os.WriteFile(filepath.Join(tempDir, "legacy.txt"), []byte(utf16), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, respect := range []bool{false, true} {
+		// When
+		result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, RespectEditorConfig: respect})
+		if err != nil {
+			t.Fatalf("ScanDirectoryWithOptions() error = %v", err)
+		}
+
+		// Then: the content is converted to UTF-8 only when .editorconfig is respected
+		var content string
+		for _, file := range result.Files {
+			if file.RelativePath == "legacy.txt" {
+				content = file.Content
+			}
+		}
+		if converted := content == "hi\n"; converted != respect {
+			t.Errorf("RespectEditorConfig=%v: got content %q", respect, content)
+		}
+	}
+}
+
+func TestScanCommit_RespectEditorConfig(t *testing.T) {
+	// Given: the .editorconfig declaring UTF-16 exists only at the commit
+	repo := t.TempDir()
+	utf16 := "\xff\xfeh\x00i\x00\n\x00"
+	mock.Use(t, &mock.MockGitClient{
+		IsRepo: true,
+		Root:   repo,
+		Commit: "abc123",
+		CommitFiles: map[string]string{
+			".editorconfig": "root = true\n\n[*.txt]\ncharset = utf-16le\n",
+			"legacy.txt":    utf16,
+		},
+	})
+
+	// When
+	result, err := ScanCommit(repo, "HEAD", ScanOptions{RespectEditorConfig: true, Checksum: "sha256"})
+
+	// Then: the content is converted, while Size and Hash cover the committed bytes
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file := BuildFileSet(result)["legacy.txt"]
+	if file.Content != "hi\n" {
+		t.Errorf("Expected converted content, got %q", file.Content)
+	}
+	rawHash, _ := hashBytes([]byte(utf16), "sha256")
+	if file.Size != int64(len(utf16)) || file.Hash != rawHash {
+		t.Errorf("Expected size %d and hash of the committed bytes, got %d and %s", len(utf16), file.Size, file.Hash)
+	}
+}
+
+func TestScanArchive_RespectEditorConfig(t *testing.T) {
+	// Given: a zip with a UTF-16 file declared as such by its .editorconfig
+	archivePath := writeZip(t, map[string]string{
+		"proj/.editorconfig": "root = true\n\n[*.txt]\ncharset = utf-16le\n",
+		"proj/legacy.txt":    "\xff\xfeh\x00i\x00\n\x00",
+	})
+
+	for _, respect := range []bool{false, true} {
+		// When
+		result, err := ScanArchive(archivePath, ScanOptions{RespectEditorConfig: respect})
+		if err != nil {
+			t.Fatalf("ScanArchive() error = %v", err)
+		}
+
+		// Then
+		content := BuildFileSet(result)[filepath.Join("proj", "legacy.txt")].Content
+		if converted := content == "hi\n"; converted != respect {
+			t.Errorf("RespectEditorConfig=%v: got content %q", respect, content)
+		}
+	}
+}
+
+// =============================================================================
+// Tests for ScanFS()
+// =============================================================================
+
+func TestScanFS_MapFS(t *testing.T) {
+	// Given
+	fsys := fstest.MapFS{
+		"main.go":            {Data: []byte("package main\n\nfunc main() {}\n")},
+		"docs/README.md":     {Data: []byte("# Docs\n")},
+		"build/output.bin":   {Data: []byte("binary\n")},
+		"debug.log":          {Data: []byte("log\n")},
+		".gitignore":         {Data: []byte("build/\n*.log\n")},
+		"docs/.hidden/x.txt": {Data: []byte("x\n")},
+	}
+
+	// When
+	result, err := ScanFS(fsys, ".", ScanOptions{NoR2cignore: true, Checksum: ChecksumSHA256})
+
+	// Then
+	if err != nil {
+		t.Fatalf("ScanFS() error = %v", err)
+	}
+	files := BuildFileSet(result)
+	for _, ignored := range []string{"build", filepath.Join("build", "output.bin"), "debug.log"} {
+		if _, ok := files[ignored]; ok {
+			t.Errorf("Expected %s to be ignored by .gitignore", ignored)
+		}
+	}
+	main, ok := files["main.go"]
+	if !ok {
+		t.Fatalf("Expected main.go in the result, got %v", files)
+	}
+	if main.Content != "package main\n\nfunc main() {}\n" || main.Lines != 3 || main.Language != "go" || main.Hash == "" {
+		t.Errorf("Unexpected file info: %+v", main)
+	}
+	if main.Path != "main.go" || result.RootPath != "." {
+		t.Errorf("Expected fsys paths, got %q rooted at %q", main.Path, result.RootPath)
+	}
+	if _, ok := files[filepath.Join("docs", "README.md")]; !ok {
+		t.Errorf("Expected docs/README.md in the result, got %v", files)
+	}
+	if result.TotalFiles != 4 || result.TotalLines != 7 {
+		t.Errorf("Expected 4 files and 7 lines, got %d and %d", result.TotalFiles, result.TotalLines)
+	}
+}
+
+func TestScanFS_SubdirectoryRoot(t *testing.T) {
+	// Given
+	fsys := fstest.MapFS{
+		"repo/src/app.py": {Data: []byte("print('hi')\n")},
+		"repo/.gitignore": {Data: []byte("*.tmp\n")},
+		"repo/cache.tmp":  {Data: []byte("tmp\n")},
+		"other/skip.go":   {Data: []byte("package skip\n")},
+	}
+
+	// When
+	result, err := ScanFS(fsys, "repo", ScanOptions{NoR2cignore: true})
+
+	// Then
+	if err != nil {
+		t.Fatalf("ScanFS() error = %v", err)
+	}
+	var relPaths []string
+	for _, file := range result.Files {
+		if !file.IsDir {
+			relPaths = append(relPaths, filepath.ToSlash(file.RelativePath))
+		}
+	}
+	sort.Strings(relPaths)
+	if strings.Join(relPaths, ",") != ".gitignore,src/app.py" {
+		t.Errorf("Expected .gitignore and src/app.py, got %v", relPaths)
+	}
+	if result.DirectoryTree != ".gitignore\nsrc/\n  app.py\n" {
+		t.Errorf("Unexpected tree:\n%s", result.DirectoryTree)
+	}
+}
+
+func TestScanFS_MissingRoot(t *testing.T) {
+	// When
+	_, err := ScanFS(fstest.MapFS{}, "missing", ScanOptions{})
+
+	// Then
+	if err == nil || !strings.Contains(err.Error(), "path does not exist") {
+		t.Errorf("Expected a missing path error, got %v", err)
+	}
+	if _, err := ScanFS(fstest.MapFS{}, "../escape", ScanOptions{}); err == nil {
+		t.Error("Expected an invalid path error")
+	}
+}
+
+// =============================================================================
+// Tests for directory tree ordering
+// =============================================================================
+
+func TestRenderDirectoryTree_MixedCaseNames(t *testing.T) {
+	// Given: mixed-case names, OS-separated paths and a file sorting between
+	// a directory and its entries ("a.txt" < "a/x" bytewise)
+	files := []FileInfo{
+		{RelativePath: "b.txt"},
+		{RelativePath: "a.txt"},
+		{RelativePath: "Zeta.md"},
+		{RelativePath: "a", IsDir: true},
+		{RelativePath: filepath.Join("a", "x.go")},
+		{RelativePath: filepath.Join("a", "Y.go")},
+	}
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		want            string
+	}{
+		{"case-sensitive", false, "Zeta.md\na/\n  Y.go\n  x.go\na.txt\nb.txt\n"},
+		{"case-insensitive", true, "a/\n  x.go\n  Y.go\na.txt\nb.txt\nZeta.md\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := caseInsensitiveTree
+			caseInsensitiveTree = tt.caseInsensitive
+			defer func() { caseInsensitiveTree = saved }()
+
+			// When
+			tree := renderDirectoryTree(files, "/repo", false)
+
+			// Then: entries stay under their directory at the right indent
+			if tree != tt.want {
+				t.Errorf("Unexpected tree:\n%s\nwant:\n%s", tree, tt.want)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Tests for SaveScanResult() and LoadScanResult()
+// =============================================================================
+
+func TestSaveScanResult_RoundTrip(t *testing.T) {
+	// Given
+	result := &ScanResult{
+		RootPath: "/repo",
+		Files: []FileInfo{
+			{Path: "/repo/main.go", RelativePath: "main.go", Size: 13, Content: "package main\n", Language: "go", Lines: 1, TokenCount: 3},
+			{Path: "/repo/locked", RelativePath: "locked", Error: errors.New("permission denied")},
+		},
+		DirectoryTree:     "locked\nmain.go\n",
+		TotalFiles:        2,
+		TotalLines:        1,
+		TotalTokens:       3,
+		Errors:            []string{"locked: permission denied"},
+		TokensByDirectory: map[string]int{".": 3},
+	}
+	path := filepath.Join(t.TempDir(), "cache.json.gz")
+
+	// When
+	if err := SaveScanResult(result, path); err != nil {
+		t.Fatalf("SaveScanResult() error = %v", err)
+	}
+	loaded, err := LoadScanResult(path, time.Hour)
+
+	// Then
+	if err != nil {
+		t.Fatalf("LoadScanResult() error = %v", err)
+	}
+	if loaded.RootPath != "/repo" || loaded.DirectoryTree != result.DirectoryTree || loaded.TotalTokens != 3 || loaded.TokensByDirectory["."] != 3 {
+		t.Errorf("Unexpected scan result: %+v", loaded)
+	}
+	if len(loaded.Files) != 2 || loaded.Files[0].Content != "package main\n" || loaded.Files[0].Lines != 1 {
+		t.Fatalf("Unexpected files: %+v", loaded.Files)
+	}
+	if loaded.Files[0].Error != nil || loaded.Files[1].Error == nil || loaded.Files[1].Error.Error() != "permission denied" {
+		t.Errorf("Expected file errors to round-trip, got %v and %v", loaded.Files[0].Error, loaded.Files[1].Error)
+	}
+}
+
+func TestLoadScanResult_Expired(t *testing.T) {
+	// Given: a cache modified two hours ago
+	path := filepath.Join(t.TempDir(), "cache.json.gz")
+	if err := SaveScanResult(&ScanResult{RootPath: "/repo"}, path); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	_, err := LoadScanResult(path, time.Hour)
+
+	// Then
+	if !errors.Is(err, ErrScanResultExpired) {
+		t.Errorf("Expected ErrScanResultExpired, got %v", err)
+	}
+	if _, err := LoadScanResult(path, 0); err != nil {
+		t.Errorf("Expected no expiry without a TTL, got %v", err)
+	}
+}
+
+// =============================================================================
+// Tests for FollowSymlinks
+// =============================================================================
+
+func TestScanDirectoryWithOptions_FollowSymlinksStopsAtLoops(t *testing.T) {
+	// Given: a/to-b -> ../b and b/to-a -> ../a, plus a/self -> .. back to the root
+	tempDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, dir, dir+".txt"), []byte(dir+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{"a/to-b": "../b", "b/to-a": "../a", "a/self": ".."}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(tempDir, filepath.FromSlash(link))); err != nil {
+			t.Skipf("Symlinks not supported: %v", err)
+		}
+	}
+
+	// When
+	result, err := ScanDirectoryWithOptions(tempDir, ScanOptions{NoGitignore: true, NoR2cignore: true, FollowSymlinks: true})
+
+	// Then: the scan finishes, following each link once before the loop closes
+	if err != nil {
+		t.Fatalf("ScanDirectoryWithOptions() error = %v", err)
+	}
+	files := BuildFileSet(result)
+	for _, expected := range []string{"a/a.txt", "a/to-b/b.txt", "b/b.txt", "b/to-a/a.txt"} {
+		if _, ok := files[filepath.FromSlash(expected)]; !ok {
+			t.Errorf("Expected %s in the result", expected)
+		}
+	}
+	for _, looped := range []string{"a/to-b/to-a/a.txt", "a/self/a/a.txt"} {
+		if _, ok := files[filepath.FromSlash(looped)]; ok {
+			t.Errorf("Expected %s to be cut off as a loop", looped)
+		}
+	}
+	warnings := 0
+	for _, errMsg := range result.Errors {
+		if strings.HasPrefix(errMsg, "Possible circular symlink detected at ") && strings.HasSuffix(errMsg, ", stopping traversal") {
+			warnings++
+		}
+	}
+	if warnings != 4 {
+		t.Errorf("Expected 4 circular symlink warnings (a/self, a/to-b/to-a, b/to-a/self, b/to-a/to-b), got %v", result.Errors)
+	}
+}
+
+func TestIsSymlinkCycle_DepthLimit(t *testing.T) {
+	// Given: an fs.FS without real paths, nested one level past the limit
+	realDirs := make(map[string]string)
+	name := "."
+	for i := 0; i < maxWalkDepth; i++ {
+		name = path.Join(name, "d")
+		if isSymlinkCycle(realDirs, ".", name, name, false) {
+			t.Fatalf("Unexpected cycle at depth %d", i+1)
+		}
+	}
+
+	// When
+	cycle := isSymlinkCycle(realDirs, ".", path.Join(name, "d"), "", false)
+
+	// Then
+	if !cycle {
+		t.Error("Expected the depth limit to stop the walk")
+	}
+}
+
+// =============================================================================
+// Tests for RetryCount
+// =============================================================================
+
+// flakyFS fails the first failures opens of each file with err
+type flakyFS struct {
+	fstest.MapFS
+	err      error
+	failures int
+	opens    map[string]int
+}
+
+func (f *flakyFS) Open(name string) (fs.File, error) {
+	if info, err := fs.Stat(f.MapFS, name); err == nil && !info.IsDir() {
+		f.opens[name]++
+		if f.opens[name] <= f.failures {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: f.err}
+		}
+	}
+	return f.MapFS.Open(name)
+}
+
+func TestScanFS_RetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryCount int
+		wantOpens  int
+		wantError  bool
+	}{
+		{"transient error retried", syscall.EIO, 3, 3, false},
+		{"too few retries", syscall.ETIMEDOUT, 1, 2, true},
+		{"retries disabled", syscall.EAGAIN, 0, 1, true},
+		{"permanent error not retried", fs.ErrPermission, 3, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given: a file failing its first two reads
+			fsys := &flakyFS{
+				MapFS:    fstest.MapFS{"main.go": {Data: []byte("package main\n")}},
+				err:      tt.err,
+				failures: 2,
+				opens:    make(map[string]int),
+			}
+
+			// When
+			result, err := ScanFS(fsys, ".", ScanOptions{NoGitignore: true, NoR2cignore: true, Workers: 1, RetryCount: tt.retryCount, RetryDelay: time.Millisecond})
+
+			// Then
+			if err != nil {
+				t.Fatalf("ScanFS() error = %v", err)
+			}
+			if fsys.opens["main.go"] != tt.wantOpens {
+				t.Errorf("Expected %d opens, got %d", tt.wantOpens, fsys.opens["main.go"])
+			}
+			main := BuildFileSet(result)["main.go"]
+			if gotError := main.Error != nil; gotError != tt.wantError {
+				t.Errorf("Expected error %v, got %v (content %q)", tt.wantError, main.Error, main.Content)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Tests for MinFileSizeBytes
+// =============================================================================
+
+func TestScanFS_MinFileSizeBytes(t *testing.T) {
+	// Given: an empty __init__.py, a small stub and a real source file
+	fsys := fstest.MapFS{
+		"pkg/__init__.py": {Data: []byte("")},
+		"pkg/stub.py":     {Data: []byte("pass\n")},
+		"pkg/main.py":     {Data: []byte("print('hello world')\n")},
+	}
+
+	// When
+	result, err := ScanFS(fsys, ".", ScanOptions{NoGitignore: true, NoR2cignore: true, MinFileSizeBytes: 10})
+
+	// Then
+	if err != nil {
+		t.Fatalf("ScanFS() error = %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected 1 file, got %d", result.TotalFiles)
+	}
+	if _, ok := BuildFileSet(result)[filepath.Join("pkg", "main.py")]; !ok {
+		t.Errorf("Expected pkg/main.py to be scanned, got %v", result.Files)
+	}
+	want := []string{filepath.Join("pkg", "__init__.py"), filepath.Join("pkg", "stub.py")}
+	if !slices.Equal(result.SkippedSmallFiles, want) {
+		t.Errorf("Expected skipped files %v, got %v", want, result.SkippedSmallFiles)
+	}
+}
+
+func TestScanArchive_MinFileSizeBytes(t *testing.T) {
+	// Given
+	archivePath := writeZip(t, map[string]string{
+		"pkg/__init__.py": "",
+		"pkg/stub.py":     "pass\n",
+		"pkg/main.py":     "print('hello world')\n",
+	})
+
+	// When
+	result, err := ScanArchive(archivePath, ScanOptions{MinFileSizeBytes: 10})
+
+	// Then
+	if err != nil {
+		t.Fatalf("ScanArchive() error = %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected 1 file, got %d", result.TotalFiles)
+	}
+	want := []string{filepath.Join("pkg", "__init__.py"), filepath.Join("pkg", "stub.py")}
+	if !slices.Equal(result.SkippedSmallFiles, want) {
+		t.Errorf("Expected skipped files %v, got %v", want, result.SkippedSmallFiles)
+	}
+}
+
+func TestScanCommit_MinFileSizeBytes(t *testing.T) {
+	// Given
+	repo := t.TempDir()
+	mock.Use(t, &mock.MockGitClient{
+		IsRepo: true,
+		Root:   repo,
+		Commit: "abc123",
+		CommitFiles: map[string]string{
+			"pkg/__init__.py": "",
+			"pkg/stub.py":     "pass\n",
+			"pkg/main.py":     "print('hello world')\n",
+		},
+	})
+
+	// When
+	result, err := ScanCommit(repo, "HEAD", ScanOptions{MinFileSizeBytes: 10})
+
+	// Then
+	if err != nil {
+		t.Fatalf("ScanCommit() error = %v", err)
+	}
+	if result.TotalFiles != 1 {
+		t.Errorf("Expected 1 file, got %d", result.TotalFiles)
+	}
+	want := []string{filepath.Join("pkg", "__init__.py"), filepath.Join("pkg", "stub.py")}
+	if !slices.Equal(result.SkippedSmallFiles, want) {
+		t.Errorf("Expected skipped files %v, got %v", want, result.SkippedSmallFiles)
+	}
+}
+
+// =============================================================================
+// Tests for FilesByExtension() and Extensions()
+// =============================================================================
+
+func TestScanResult_FilesByExtension(t *testing.T) {
+	// Given: files with mixed-case, missing and repeated extensions
+	result := &ScanResult{Files: []FileInfo{
+		{Path: "/repo", IsDir: true},
+		{Path: "/repo/main.go", RelativePath: "main.go"},
+		{Path: "/repo/README.MD", RelativePath: "README.MD"},
+		{Path: "/repo/Makefile", RelativePath: "Makefile"},
+		{Path: "/repo/pkg", RelativePath: "pkg", IsDir: true},
+		{Path: "/repo/pkg/util.go", RelativePath: "pkg/util.go"},
+		{Path: "/repo/docs/guide.md", RelativePath: "docs/guide.md"},
+	}}
+
+	// When
+	groups := result.FilesByExtension()
+
+	// Then
+	want := map[string][]string{
+		".go": {"main.go", "pkg/util.go"},
+		".md": {"README.MD", "docs/guide.md"},
+		"":    {"Makefile"},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("Expected %d groups, got %v", len(want), groups)
+	}
+	for ext, paths := range want {
+		var got []string
+		for _, file := range groups[ext] {
+			got = append(got, file.RelativePath)
+		}
+		if !slices.Equal(got, paths) {
+			t.Errorf("Extension %q: expected %v, got %v", ext, paths, got)
+		}
+	}
+
+	if extensions := result.Extensions(); !slices.Equal(extensions, []string{"", ".go", ".md"}) {
+		t.Errorf("Expected sorted extensions, got %v", extensions)
+	}
+}
+
+func TestScanResult_ExtensionsEmpty(t *testing.T) {
+	result := &ScanResult{}
+	if extensions := result.Extensions(); len(extensions) != 0 {
+		t.Errorf("Expected no extensions, got %v", extensions)
+	}
+}
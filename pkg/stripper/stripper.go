@@ -0,0 +1,366 @@
+package stripper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedLanguage is returned for languages whose comment syntax is not known
+var ErrUnsupportedLanguage = errors.New("comment stripping not supported")
+
+// Options controls which comments StripCommentsWithOptions keeps
+type Options struct {
+	// PreserveDocComments keeps doc comments: Go comment groups directly above
+	// a declaration, JavaScript/TypeScript /** */ blocks and Python docstrings
+	PreserveDocComments bool
+}
+
+// quote describes a string literal delimiter
+type quote struct {
+	char      byte
+	escapes   bool // backslash escapes the next byte
+	multiline bool // the literal may span lines
+}
+
+// syntax describes a language's comments and string literals
+type syntax struct {
+	lineComment string
+	blockStart  string
+	blockEnd    string
+	quotes      []quote
+	// tripleQuotes treats """ and ''' strings as Python docstrings when they stand alone
+	tripleQuotes bool
+	// lineDocs treats full-line comment groups directly above code as doc comments (Go)
+	lineDocs bool
+	// blockDocs treats block comments starting with /** as doc comments (JSDoc)
+	blockDocs bool
+}
+
+var (
+	goSyntax = syntax{
+		lineComment: "//",
+		blockStart:  "/*",
+		blockEnd:    "*/",
+		quotes:      []quote{{'"', true, false}, {'\'', true, false}, {'`', false, true}},
+		lineDocs:    true,
+	}
+	jsSyntax = syntax{
+		lineComment: "//",
+		blockStart:  "/*",
+		blockEnd:    "*/",
+		quotes:      []quote{{'"', true, false}, {'\'', true, false}, {'`', true, true}},
+		blockDocs:   true,
+	}
+	pythonSyntax = syntax{
+		lineComment:  "#",
+		quotes:       []quote{{'"', true, false}, {'\'', true, false}},
+		tripleQuotes: true,
+	}
+)
+
+// syntaxFor returns the comment syntax for a language label as reported by languages.Detect
+func syntaxFor(language string) (syntax, bool) {
+	switch strings.ToLower(language) {
+	case "go":
+		return goSyntax, true
+	case "javascript", "typescript", "jsx", "tsx":
+		return jsSyntax, true
+	case "python":
+		return pythonSyntax, true
+	default:
+		return syntax{}, false
+	}
+}
+
+// StripComments removes all comments from Go, Python and JavaScript/TypeScript source
+func StripComments(content, language string) (string, error) {
+	return StripCommentsWithOptions(content, language, Options{})
+}
+
+// StripCommentsWithOptions removes comments from source code, keeping string
+// literals intact. Lines left blank by a removed comment are dropped, while
+// blank lines already in the source are kept. Build directives (//go:) and
+// shebang lines are kept because they change how the file is run.
+// The scan is lexical only, so unusual constructs such as JavaScript regex
+// literals containing // may be misread.
+func StripCommentsWithOptions(content, language string, opts Options) (string, error) {
+	syn, ok := syntaxFor(language)
+	if !ok {
+		return "", fmt.Errorf("%w for language %q", ErrUnsupportedLanguage, language)
+	}
+
+	s := &stripper{
+		src:     content,
+		syn:     syn,
+		opts:    opts,
+		touched: make(map[int]bool),
+	}
+	if syn.lineDocs && opts.PreserveDocComments {
+		s.docLines = lineDocGroups(content, syn.lineComment)
+	}
+	s.run()
+	return s.result(), nil
+}
+
+// stripper holds the state of a single pass over the source
+type stripper struct {
+	src  string
+	syn  syntax
+	opts Options
+	out  strings.Builder
+
+	line        int          // current line number (0-based)
+	lineHasCode bool         // the current line has kept content so far
+	last        byte         // last non-whitespace code byte, 0 at the start
+	touched     map[int]bool // lines a comment was removed from
+	docLines    map[int]bool // lines that belong to a doc comment group
+}
+
+func (s *stripper) run() {
+	for i := 0; i < len(s.src); {
+		rest := s.src[i:]
+		switch {
+		case rest[0] == '\n':
+			s.newline()
+			i++
+		case strings.HasPrefix(rest, s.syn.lineComment):
+			i = s.lineComment(i)
+		case s.syn.blockStart != "" && strings.HasPrefix(rest, s.syn.blockStart):
+			i = s.blockComment(i)
+		case s.syn.tripleQuotes && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+			i = s.tripleString(i)
+		default:
+			if q, ok := s.quoteAt(rest[0]); ok {
+				i = s.quoted(i, q)
+				continue
+			}
+			s.code(rest[0])
+			i++
+		}
+	}
+}
+
+// quoteAt returns the string delimiter starting with c, if any
+func (s *stripper) quoteAt(c byte) (quote, bool) {
+	for _, q := range s.syn.quotes {
+		if q.char == c {
+			return q, true
+		}
+	}
+	return quote{}, false
+}
+
+// newline ends the current line
+func (s *stripper) newline() {
+	s.out.WriteByte('\n')
+	s.line++
+	s.lineHasCode = false
+}
+
+// code keeps a byte of source code
+func (s *stripper) code(c byte) {
+	s.out.WriteByte(c)
+	if c != ' ' && c != '\t' && c != '\r' {
+		s.lineHasCode = true
+		s.last = c
+	}
+}
+
+// keep writes text unchanged, which may span lines
+func (s *stripper) keep(text string) {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			s.newline()
+			continue
+		}
+		s.code(text[i])
+	}
+}
+
+// drop removes text, marking every line it touches
+// Newlines inside the text are kept so line positions stay aligned with the source
+func (s *stripper) drop(text string) {
+	s.touched[s.line] = true
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			s.newline()
+			s.touched[s.line] = true
+		}
+	}
+}
+
+// quoted copies a string literal starting at i and returns the index after it
+// An unterminated single-line literal ends at the newline
+func (s *stripper) quoted(i int, q quote) int {
+	s.code(q.char)
+	for j := i + 1; j < len(s.src); j++ {
+		c := s.src[j]
+		switch {
+		case q.escapes && c == '\\' && j+1 < len(s.src) && s.src[j+1] != '\n':
+			s.code(c)
+			s.code(s.src[j+1])
+			j++
+		case c == q.char:
+			s.code(c)
+			return j + 1
+		case c == '\n':
+			if !q.multiline {
+				return j
+			}
+			s.newline()
+			s.lineHasCode = true
+		default:
+			s.code(c)
+		}
+	}
+	return len(s.src)
+}
+
+// lineComment handles a comment running to the end of the line
+func (s *stripper) lineComment(i int) int {
+	end := strings.IndexByte(s.src[i:], '\n')
+	if end < 0 {
+		end = len(s.src)
+	} else {
+		end += i
+	}
+	text := s.src[i:end]
+
+	keep := isDirective(text, s.line, s.lineHasCode) ||
+		(s.opts.PreserveDocComments && !s.lineHasCode && s.docLines[s.line])
+	if keep {
+		s.out.WriteString(text)
+		s.lineHasCode = true
+	} else {
+		s.drop(text)
+	}
+	return end
+}
+
+// blockComment handles a delimited comment, which may span lines
+func (s *stripper) blockComment(i int) int {
+	start := i + len(s.syn.blockStart)
+	end := len(s.src)
+	if idx := strings.Index(s.src[start:], s.syn.blockEnd); idx >= 0 {
+		end = start + idx + len(s.syn.blockEnd)
+	}
+	text := s.src[i:end]
+
+	isDoc := s.syn.blockDocs && strings.HasPrefix(text, "/**") && text != "/**/"
+	if s.opts.PreserveDocComments && isDoc {
+		s.out.WriteString(text)
+		s.line += strings.Count(text, "\n")
+		s.lineHasCode = true
+		return end
+	}
+
+	inline := s.lineHasCode
+	s.drop(text)
+	// Keep tokens on either side of an inline comment apart, as in a/**/b
+	if inline && end < len(s.src) && !isSpace(s.src[end]) {
+		s.out.WriteByte(' ')
+	}
+	return end
+}
+
+// tripleString handles a Python triple-quoted string. One standing alone as a
+// statement is a docstring (or a block used as a comment) and is removed;
+// one that is part of an expression is code
+func (s *stripper) tripleString(i int) int {
+	delim := s.src[i : i+3]
+	end := len(s.src)
+	for j := i + 3; j < len(s.src); j++ {
+		if s.src[j] == '\\' {
+			j++
+			continue
+		}
+		if strings.HasPrefix(s.src[j:], delim) {
+			end = j + 3
+			break
+		}
+	}
+	text := s.src[i:end]
+
+	statement := !s.lineHasCode && restOfLineBlank(s.src[end:], s.syn.lineComment) &&
+		!strings.ContainsRune("([{,=+\\", rune(s.last))
+	// A docstring is the first statement of a module, class or function
+	isDoc := s.last == 0 || s.last == ':'
+	if !statement || (s.opts.PreserveDocComments && isDoc) {
+		s.keep(text)
+		return end
+	}
+	s.drop(text)
+	return end
+}
+
+// lineDocGroups returns the lines of full-line comment groups directly
+// followed by a non-blank line of code, which is how Go doc comments are written
+func lineDocGroups(src, marker string) map[int]bool {
+	lines := strings.Split(src, "\n")
+	isComment := func(line string) bool {
+		return strings.HasPrefix(strings.TrimSpace(line), marker)
+	}
+
+	docs := make(map[int]bool)
+	for i := 0; i < len(lines); {
+		if !isComment(lines[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(lines) && isComment(lines[j]) {
+			j++
+		}
+		if j < len(lines) && strings.TrimSpace(lines[j]) != "" {
+			for k := i; k < j; k++ {
+				docs[k] = true
+			}
+		}
+		i = j
+	}
+	return docs
+}
+
+// isDirective reports whether a line comment is a build directive or shebang
+func isDirective(text string, line int, afterCode bool) bool {
+	if afterCode {
+		return false
+	}
+	return strings.HasPrefix(text, "//go:") ||
+		(line == 0 && strings.HasPrefix(text, "#!"))
+}
+
+// restOfLineBlank reports whether only whitespace or a line comment follows on the line
+func restOfLineBlank(rest, lineComment string) bool {
+	if end := strings.IndexByte(rest, '\n'); end >= 0 {
+		rest = rest[:end]
+	}
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, lineComment)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// result drops the lines emptied by a removed comment and trims the trailing
+// whitespace left before removed end-of-line comments
+func (s *stripper) result() string {
+	lines := strings.Split(s.out.String(), "\n")
+	kept := lines[:0]
+	for i, line := range lines {
+		if s.touched[i] {
+			cr := strings.HasSuffix(line, "\r")
+			line = strings.TrimRight(line, " \t\r")
+			if line == "" {
+				continue
+			}
+			if cr {
+				line += "\r"
+			}
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
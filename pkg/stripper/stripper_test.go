@@ -0,0 +1,135 @@
+package stripper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		input    string
+		want     string
+	}{
+		{
+			name:     "go line and block comments",
+			language: "go",
+			input:    "package main\n\n// Add adds\nfunc Add(a, b int) int {\n\treturn a + b // sum\n}\n/* trailing\nblock */\n",
+			want:     "package main\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n",
+		},
+		{
+			name:     "go strings keep comment markers",
+			language: "go",
+			input:    "s := \"http://x /* y */\"\nr := `// raw\n/* still raw */`\nc := '/'\n",
+			want:     "s := \"http://x /* y */\"\nr := `// raw\n/* still raw */`\nc := '/'\n",
+		},
+		{
+			name:     "go escaped quote",
+			language: "go",
+			input:    "s := \"a\\\"// b\" // c\n",
+			want:     "s := \"a\\\"// b\"\n",
+		},
+		{
+			name:     "go inline block comment keeps tokens apart",
+			language: "go",
+			input:    "x := a/* c */b\n",
+			want:     "x := a b\n",
+		},
+		{
+			name:     "go build directive kept",
+			language: "go",
+			input:    "//go:build linux\n\n// Package p\npackage p\n",
+			want:     "//go:build linux\n\npackage p\n",
+		},
+		{
+			name:     "python comments and docstrings",
+			language: "python",
+			input:    "#!/usr/bin/env python\n\"\"\"Module doc.\"\"\"\n# comment\ndef f():\n    \"\"\"Doc\n    more.\"\"\"\n    return '#' # trailing\n",
+			want:     "#!/usr/bin/env python\ndef f():\n    return '#'\n",
+		},
+		{
+			name:     "python triple string in expression kept",
+			language: "python",
+			input:    "x = \"\"\"# not a comment\"\"\"\ncall(\n    \"\"\"arg\"\"\"\n)\n",
+			want:     "x = \"\"\"# not a comment\"\"\"\ncall(\n    \"\"\"arg\"\"\"\n)\n",
+		},
+		{
+			name:     "javascript comments",
+			language: "javascript",
+			input:    "/**\n * Greet.\n */\nfunction greet() { // hi\n  return `// ${name}`; /* x */\n}\n",
+			want:     "function greet() {\n  return `// ${name}`;\n}\n",
+		},
+		{
+			name:     "typescript label",
+			language: "typescript",
+			input:    "const a: string = '/*'; // c\n",
+			want:     "const a: string = '/*';\n",
+		},
+		{
+			name:     "blank lines in source are kept",
+			language: "go",
+			input:    "a := 1\n\n\nb := 2\n",
+			want:     "a := 1\n\n\nb := 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripComments(tt.input, tt.language)
+			if err != nil {
+				t.Fatalf("StripComments() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("StripComments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripCommentsWithOptions_PreserveDocComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		input    string
+		want     string
+	}{
+		{
+			name:     "go doc comment above declaration",
+			language: "go",
+			input:    "// Add adds\n// two ints\nfunc Add() {}\n\n// stray note\n\nvar x = 1 // inline\n",
+			want:     "// Add adds\n// two ints\nfunc Add() {}\n\n\nvar x = 1\n",
+		},
+		{
+			name:     "jsdoc block",
+			language: "tsx",
+			input:    "/** Props */\ntype P = {}; /* plain */\n// line\n",
+			want:     "/** Props */\ntype P = {};\n",
+		},
+		{
+			name:     "python docstrings",
+			language: "python",
+			input:    "\"\"\"Module.\"\"\"\nx = 1\n\"\"\"not a docstring\"\"\"\nclass C:\n    \"\"\"Class doc.\"\"\"\n",
+			want:     "\"\"\"Module.\"\"\"\nx = 1\nclass C:\n    \"\"\"Class doc.\"\"\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripCommentsWithOptions(tt.input, tt.language, Options{PreserveDocComments: true})
+			if err != nil {
+				t.Fatalf("StripCommentsWithOptions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("StripCommentsWithOptions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripComments_UnsupportedLanguage(t *testing.T) {
+	_, err := StripComments("# heading", "markdown")
+	if !errors.Is(err, ErrUnsupportedLanguage) {
+		t.Errorf("StripComments() error = %v, want ErrUnsupportedLanguage", err)
+	}
+}
@@ -0,0 +1,61 @@
+package tokencounter
+
+import (
+	"fmt"
+	"sync"
+)
+
+// TokenCounterPool hands out TokenCounter instances of one encoding so that
+// concurrent goroutines each count with their own encoder
+type TokenCounterPool struct {
+	encoding string
+	// fallback is shared if a new instance cannot be created on demand
+	fallback *TokenCounter
+	pool     sync.Pool
+}
+
+// NewTokenCounterPool creates a pool of counters for an encoding, pre-warmed
+// with size instances. More are created on demand when all are in use.
+// If encoding is empty, defaults to "o200k_base".
+func NewTokenCounterPool(encoding string, size int) (*TokenCounterPool, error) {
+	if encoding == "" {
+		encoding = DefaultEncoding
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	p := &TokenCounterPool{encoding: encoding}
+	counters := make([]*TokenCounter, 0, size)
+	for i := 0; i < size; i++ {
+		tc, err := NewTokenCounter(encoding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to warm token counter pool: %w", err)
+		}
+		counters = append(counters, tc)
+	}
+	p.fallback = counters[0]
+	p.pool.New = func() any {
+		tc, err := NewTokenCounter(p.encoding)
+		if err != nil {
+			return p.fallback
+		}
+		return tc
+	}
+	for _, tc := range counters {
+		p.pool.Put(tc)
+	}
+	return p, nil
+}
+
+// Get takes a counter from the pool; return it with Put when done
+func (p *TokenCounterPool) Get() *TokenCounter {
+	return p.pool.Get().(*TokenCounter)
+}
+
+// Put returns a counter taken with Get to the pool
+func (p *TokenCounterPool) Put(tc *TokenCounter) {
+	if tc != nil {
+		p.pool.Put(tc)
+	}
+}
@@ -2,10 +2,32 @@ package tokencounter
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/localit-io/tiktoken-go"
 )
 
+// DefaultEncoding is the encoding used when none is specified
+const DefaultEncoding = "o200k_base"
+
+// SupportedEncodings lists the tiktoken encodings accepted by NewTokenCounter
+var SupportedEncodings = []string{"o200k_base", "cl100k_base", "p50k_base", "p50k_edit", "r50k_base"}
+
+// ValidateEncoding checks that an encoding name is supported without loading it
+func ValidateEncoding(encoding string) error {
+	if encoding == "" {
+		return nil
+	}
+	for _, supported := range SupportedEncodings {
+		if encoding == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported encoding %q (supported: %s)", encoding, strings.Join(SupportedEncodings, ", "))
+}
+
+// TokenCounter counts tokens with a tiktoken encoding
+// Goroutines counting in parallel each take their own from a TokenCounterPool
 type TokenCounter struct {
 	encoding *tiktoken.Tiktoken
 }
@@ -16,7 +38,7 @@ type TokenCounter struct {
 func NewTokenCounter(encoding string) (*TokenCounter, error) {
 	// Default to o200k_base if not specified
 	if encoding == "" {
-		encoding = "o200k_base"
+		encoding = DefaultEncoding
 	}
 
 	tke, err := tiktoken.GetEncoding(encoding)
@@ -38,3 +60,16 @@ func (tc *TokenCounter) CountTokensWithPath(text string, filePath string) (int,
 
 	return len(tokens), nil
 }
+
+// EstimateTokens approximates the token count of text without encoding it
+// See the package-level EstimateTokens
+func (tc *TokenCounter) EstimateTokens(text string) int {
+	return EstimateTokens(text)
+}
+
+// EstimateTokens approximates the token count of text as one token per four
+// bytes, a common rule of thumb for English text and code. It needs no
+// encoding, so it is much faster than CountTokens on large inputs.
+func EstimateTokens(text string) int {
+	return len(text) / 4
+}
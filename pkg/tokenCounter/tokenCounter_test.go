@@ -1,6 +1,7 @@
 package tokencounter_test
 
 import (
+	"strings"
 	"testing"
 
 	tokencounter "github.com/BHChen24/repo2context/pkg/tokenCounter"
@@ -233,6 +234,31 @@ func TestCountTokensDifferentEncodings(t *testing.T) {
 	}
 }
 
+// TestValidateEncoding tests encoding name validation without loading encodings
+func TestValidateEncoding(t *testing.T) {
+	tests := []struct {
+		encoding    string
+		expectError bool
+	}{
+		{"", false},
+		{"o200k_base", false},
+		{"cl100k_base", false},
+		{"p50k_base", false},
+		{"r50k_base", false},
+		{"invalid_encoding", true},
+		{"O200K_BASE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			err := tokencounter.ValidateEncoding(tt.encoding)
+			if (err != nil) != tt.expectError {
+				t.Errorf("ValidateEncoding(%q) error = %v, expectError %v", tt.encoding, err, tt.expectError)
+			}
+		})
+	}
+}
+
 // TestCountTokens_ConsistentResults tests that the same input produces consistent results
 func TestCountTokensConsistentResults(t *testing.T) {
 	tc, err := tokencounter.NewTokenCounter("o200k_base")
@@ -318,3 +344,57 @@ func BenchmarkCountTokensLongText(b *testing.B) {
 		_, _ = tc.CountTokens(text)
 	}
 }
+
+// TestTokenCounterPool tests that pooled counters count like a single counter
+func TestTokenCounterPool(t *testing.T) {
+	tc, err := tokencounter.NewTokenCounter("cl100k_base")
+	if err != nil {
+		t.Fatalf("NewTokenCounter() error = %v", err)
+	}
+	text := "func main() { fmt.Println(\"hello\") }"
+	want, _ := tc.CountTokens(text)
+
+	pool, err := tokencounter.NewTokenCounterPool("cl100k_base", 4)
+	if err != nil {
+		t.Fatalf("NewTokenCounterPool() error = %v", err)
+	}
+
+	// Take more counters than were pre-warmed
+	var counters []*tokencounter.TokenCounter
+	for i := 0; i < 6; i++ {
+		pooled := pool.Get()
+		if pooled == nil {
+			t.Fatal("Get() returned nil")
+		}
+		got, err := pooled.CountTokens(text)
+		if err != nil || got != want {
+			t.Errorf("CountTokens() = %d, %v, want %d", got, err, want)
+		}
+		counters = append(counters, pooled)
+	}
+	for _, pooled := range counters {
+		pool.Put(pooled)
+	}
+}
+
+// TestNewTokenCounterPoolInvalidEncoding tests that an unknown encoding fails up front
+func TestNewTokenCounterPoolInvalidEncoding(t *testing.T) {
+	if _, err := tokencounter.NewTokenCounterPool("invalid_encoding", 2); err == nil {
+		t.Error("NewTokenCounterPool() expected error for invalid encoding")
+	}
+}
+
+// TestEstimateTokens tests the 4 bytes per token approximation
+func TestEstimateTokens(t *testing.T) {
+	tests := map[string]int{
+		"":                        0,
+		"abc":                     0,
+		"package main\n":          3,
+		strings.Repeat("x", 4000): 1000,
+	}
+	for text, want := range tests {
+		if got := tokencounter.EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%d bytes) = %d, want %d", len(text), got, want)
+		}
+	}
+}